@@ -4,9 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/ory/viper"
 	"github.com/spf13/cobra"
 	"knative.dev/func/pkg/builders"
@@ -27,9 +31,22 @@ NAME
 
 SYNOPSIS
 	{{rootCmdUse}} build [-r|--registry] [--builder] [--builder-image]
-		         [--push] [--username] [--password] [--token]
-	             [--platform] [-p|--path] [-c|--confirm] [-v|--verbose]
-		         [--build-timestamp] [--registry-insecure]
+		         [--push] [--username] [--password] [--token] [--docker-config]
+	             [--platform] [-p|--path] [-c|--confirm] [-v|--verbose] [-q|--quiet]
+		         [--build-timestamp] [--registry-insecure] [--git] [--git-username] [--git-password] [--profile]
+		         [--build-dir] [--cache-info] [--cache-clear] [--clean] [--warm-cache] [--bundle] [--oci-output]
+		         [--digest-algorithm]
+		         [--inspect] [--annotation] [--media-type] [--artifact-type]
+		         [--foreign-layer] [--squash] [--max-layers] [--max-layers-fail]
+		         [--build-concurrency] [--build-tag]
+		         [--pgo] [--build-vcs] [--go-toolchain] [--go-proxy] [--go-private]
+		         [--go-nosumdb] [--go-flags] [--go-netrc] [--go-token] [--secret]
+		         [--middleware-version] [--replace] [--scan] [--scan-severity]
+		         [--checksums] [--sign-key] [--sign-manifests] [--without-source] [--strip-source]
+		         [--print-fingerprint] [--watch] [--registry-mirror] [--keep-tars]
+		         [--push-registry] [--push-dry-run] [--platform-tag-suffix]
+		         [--interactive] [--zero-timestamps] [--verify-reproducible]
+		         [--ca-bundle] [--http-proxy] [--https-proxy] [--no-proxy] [--build-info] [--save-config] [--base-image-pull-policy] [--rebase] [-o|--output]
 
 DESCRIPTION
 
@@ -45,6 +62,27 @@ DESCRIPTION
 	When building a function for the first time, either a registry or explicit
 	image name is required.  Subsequent builds will reuse these option values.
 
+	The builder, registry, image, base image and builder image of a build are
+	saved to func.yaml.  With --save-config the platform (--platform) is also
+	saved, as build.platforms, such that subsequent builds without --platform
+	are of that platform.  All other flags, such as --push, apply only to the
+	build of which they are given.
+
+	If no builder is configured and neither Docker nor Podman is installed,
+	functions of a runtime supported by the host builder (go and python) are
+	built with the host builder, which requires neither.  The builder chosen is
+	recorded in func.yaml.  Otherwise the default builder is used.
+
+	The host builder creates its builds in the function's .func directory.  To
+	keep them out of the function's source tree, enable externalBuilds in the
+	global config (or set FUNC_EXTERNAL_BUILDS=true) to place them in
+	$XDG_CACHE_HOME/func (~/.cache/func by default), or use --build-dir.
+
+	The host builder builds the platforms listed in func.yaml (build.platforms)
+	or, if none are, those of the global config (platforms, or set
+	FUNC_PLATFORMS), for example "linux/amd64,linux/arm64".  Otherwise the
+	function is built for linux/amd64, linux/arm64 and linux/arm/v7.
+
 EXAMPLES
 
 	o Build a function container using the given registry.
@@ -65,11 +103,217 @@ EXAMPLES
 	  builder image.
 	  $ {{rootCmdUse}} build --builder=pack --builder-image=cnbs/sample-builder:bionic
 
+	o Build a function using the registry, base image and labels of the "prod"
+	  profile defined in func.yaml (build.profiles) or the global config.
+	  $ {{rootCmdUse}} build --profile prod
+
+	o Build the function in the "hello" directory of a remote git repository
+	  at the tag "v1.0.0".
+	  $ {{rootCmdUse}} build --git https://github.com/alice/functions@v1.0.0 --path hello
+
+	o Build a function of a private git repository, cloned with a token.
+	  $ FUNC_GIT_PASSWORD=$GITHUB_TOKEN {{rootCmdUse}} build \
+	      --git https://github.com/alice/private-functions --path hello
+
+	o Build a function and export the built OCI layout as a single archive,
+	  for example to hand off to another system.
+	  $ {{rootCmdUse}} build --bundle function.tar
+
+	o Build a function and write the built OCI layout to a directory, for
+	  example for CI to collect without knowing the build's fingerprint.
+	  $ {{rootCmdUse}} build --builder host --oci-output dist/oci
+
+	o Build a function with the host builder, annotating the image with the
+	  owning team and a ticket.
+	  $ {{rootCmdUse}} build --builder host --annotation com.example.team=payments \
+	      --annotation com.example.ticket=PAY-123
+
+	o Build a function with the host builder using Docker schema2 media types,
+	  for a registry which does not accept OCI images.
+	  $ {{rootCmdUse}} build --builder host --media-type docker
+
+	o Build a function with the host builder, exporting its OCI layout with
+	  SHA-512 digests for a policy which requires them.  The build itself,
+	  and the image pushed, remain of SHA-256.
+	  $ {{rootCmdUse}} build --builder host --oci-output ./oci --digest-algorithm sha512
+
+	o Build and push a function with the host builder, also pushing its image
+	  to a second registry for disaster recovery.
+	  $ {{rootCmdUse}} build --builder host --push --registry registry.example.com/alice \
+	      --push-registry dr.example.com/alice
+
+	o Build and push a function for linux/arm64 only with the host builder,
+	  also tagging its image as :latest-arm64.
+	  $ {{rootCmdUse}} build --builder host --push --platform linux/arm64 --platform-tag-suffix
+
+	o Build and push a function with the host builder, signing its image
+	  index and each platform manifest with an unencrypted PEM key, such that
+	  cosign verify --key can verify either.
+	  $ openssl genpkey -algorithm ec -pkeyopt ec_paramgen_curve:P-256 -out signing.pem
+	  $ {{rootCmdUse}} build --builder host --push --sign-key ./signing.pem --sign-manifests
+
+	o Build a function with the host builder and report whether its image is
+	  up-to-date in the registry, or which layers pushing would upload,
+	  without pushing.
+	  $ {{rootCmdUse}} build --builder host --push-dry-run
+
+	o Build a function with the host builder, typing its image index as an
+	  artifact for tools which filter referrers by artifact type (OCI 1.1).
+	  $ {{rootCmdUse}} build --builder host \
+	      --artifact-type application/vnd.example.function.v1
+
+	o Build a function with the host builder, referencing a large base layer
+	  from a CDN rather than pushing it to the registry.
+	  $ {{rootCmdUse}} build --builder host --push \
+	      --foreign-layer sha256:4f4fb7...=https://cdn.example.com/base.tar.gz
+
+	o Build a function with the host builder, pulling base images from Docker
+	  Hub through a registry mirror.
+	  $ {{rootCmdUse}} build --builder host \
+	      --registry-mirror docker.io=mirror.example.com/dockerhub
+
+	o Build a function with the host builder as a single-layer image, squashing
+	  the base image's layers along with the function's.
+	  $ {{rootCmdUse}} build --builder host --squash=all
+
+	o Build a function for several platforms with the host builder, compiling
+	  no more than two at once to limit memory use on a constrained runner.
+	  $ {{rootCmdUse}} build --builder host --build-concurrency 2 \
+	      --platform linux/amd64,linux/arm64,linux/arm/v7
+
+	o Build a Go function with the host builder including files constrained by
+	  the "prod" build tag (//go:build prod), in addition to any build tags
+	  in func.yaml (build.buildTags).
+	  $ {{rootCmdUse}} build --builder host --build-tag prod
+
+	o Build a Go function with the host builder using a CPU profile collected
+	  from production for profile-guided optimization.  A default.pgo in the
+	  function's directory is used without this flag.
+	  $ {{rootCmdUse}} build --builder host --pgo ./profiles/cpu.pprof
+
+	o Build a Go function with the host builder without stamping version
+	  control information into the binary, such as in a CI job which checks
+	  out the repository partially.
+	  $ {{rootCmdUse}} build --builder host --build-vcs=false
+
+	o Build a Go function with the host builder using only the installed go
+	  toolchain, rather than downloading one required by its go.mod.
+	  $ {{rootCmdUse}} build --builder host --go-toolchain local
+
+	o Build a Go function which depends on private modules with the host
+	  builder in CI, fetching them with a token from the environment.
+	  $ FUNC_GO_TOKEN=github.com=$GITHUB_TOKEN {{rootCmdUse}} build --builder host \
+	      --go-private github.com/example
+
+	o Build a Python function with the host builder, installing packages from
+	  a private index configured by a pip.conf of its credentials, which are
+	  not written to the image.
+	  $ {{rootCmdUse}} build --builder host \
+	      --secret id=pip,src=~/.config/pip/pip.conf,target=PIP_CONFIG_FILE
+
+	o Build a Go function with the host builder, pinning the version of the
+	  middleware which serves it, such as to pick up a security fix.
+	  $ {{rootCmdUse}} build --builder host --middleware-version v0.21.4
+
+	o Build a Go function with the host builder against a local fork of the
+	  middleware, without publishing it.
+	  $ {{rootCmdUse}} build --builder host --replace knative.dev/func-go=../func-go
+
+	o Build a function with the host builder and scan the image for
+	  vulnerabilities with grype, failing the build if any of critical
+	  severity are found.
+	  $ {{rootCmdUse}} build --builder host --scan=grype --scan-severity critical
+
+	o Build a function with the host builder, writing the checksums of its
+	  binaries and image index to .func/builds/last/checksums.txt to be signed.
+	  $ {{rootCmdUse}} build --builder host --checksums
+
+	o Build a function with the host builder, keeping the tarball of each layer
+	  in .func/builds/last to inspect its contents.
+	  $ {{rootCmdUse}} build --builder host --keep-tars
+	  $ tar tzf .func/builds/last/datalayer.tar.gz
+
+	o Build a function with the host builder, reporting on failure the phases
+	  started and the layers written before the failure, and waiting for Enter
+	  before cleaning up such that the build directory may be explored.
+	  $ {{rootCmdUse}} build --builder host --interactive
+
+	o Build a function with the host builder with all of its times (creation,
+	  history and of its files) set to the Unix epoch, for conformance testing.
+	  $ {{rootCmdUse}} build --builder host --zero-timestamps
+
+	o Build a function with the host builder twice, failing if the two images
+	  differ, to guarantee in CI that its image is reproducible.
+	  $ {{rootCmdUse}} build --builder host --zero-timestamps --verify-reproducible
+
+	o Build a function with the host builder, adding the root CAs of a
+	  corporate network to the image such that it can reach internal HTTPS
+	  services.
+	  $ {{rootCmdUse}} build --builder host --ca-bundle ./corporate-ca.pem
+
+	o Build a function with the host builder behind a corporate proxy, with
+	  the internal registry of its base image bypassing it.
+	  $ {{rootCmdUse}} build --builder host --https-proxy http://proxy.example.com:3128 \
+	      --no-proxy registry.internal.example.com
+
+	o Build a function with the host builder without contacting the registry
+	  of its base image, such as in air-gapped CI, using the base image last
+	  pulled and failing if it is not cached.
+	  $ {{rootCmdUse}} build --builder host --base-image-pull-policy never
+
+	o Rebase the last build of a function with the host builder onto the
+	  updated image of its base, such as for a security fix of the base,
+	  without building its source, and push it.
+	  $ {{rootCmdUse}} build --builder host --rebase --push
+
+	o Build a Go function with the host builder for amd64 CPUs of the
+	  x86-64-v3 microarchitecture level (GOAMD64=v3), one of v1 to v4.
+	  $ {{rootCmdUse}} build --builder host --platform linux/amd64/v3
+
+	o Build a Go function with the host builder as an image containing only
+	  its binary, omitting its source.
+	  $ {{rootCmdUse}} build --builder host --without-source
+
+	o Build a function with the host builder, excluding its tests, test data
+	  and documentation from the image.
+	  $ {{rootCmdUse}} build --builder host --strip-source
+
+	o Build a function, writing the result (image, digests, layer sizes,
+	  timings and whether pushed) as JSON for scripting.
+	  $ {{rootCmdUse}} build --output json
+
+	o Rebuild a function each time its files change (other than those matching
+	  .funcignore), until interrupted.
+	  $ {{rootCmdUse}} build --watch
+
+	o Show the effective build settings, and from where each was taken
+	  (flag, profile, environment, func.yaml or global config), without building.
+	  $ {{rootCmdUse}} build --registry registry.example.com/alice --inspect
+
+	o Show the location, size and contents of the host builder's blob cache,
+	  without building.
+	  $ {{rootCmdUse}} build --cache-info
+
+	o Remove all builds of the function and the blobs of the host builder's
+	  caches, such as to troubleshoot a build, without building.
+	  $ {{rootCmdUse}} build --clean --cache-clear
+
+	o Download the layers of the base image of a function for each platform
+	  into the host builder's blob cache, without building, such as in a CI
+	  setup step before building in parallel.
+	  $ {{rootCmdUse}} build --builder host --warm-cache
+
+	o Print the fingerprint of the function's source, which identifies its
+	  build, and its build directory, without building.
+	  $ {{rootCmdUse}} build --print-fingerprint
+
 `,
 		SuggestFor: []string{"biuld", "buidl", "built"},
 		PreRunE: bindEnv("image", "path", "builder", "registry", "confirm",
-			"push", "builder-image", "base-image", "platform", "verbose",
-			"build-timestamp", "registry-insecure", "username", "password", "token"),
+			"push", "push-dry-run", "platform-tag-suffix", "builder-image", "base-image", "platform", "verbose",
+			"build-timestamp", "registry-insecure", "username", "password", "token", "docker-config", "git-username", "git-password",
+			"capability", "profile", "quiet", "build-dir", "cache-info", "cache-clear", "clean", "warm-cache", "bundle", "oci-output", "digest-algorithm", "inspect",
+			"media-type", "artifact-type", "squash", "max-layers", "max-layers-fail", "build-concurrency", "pgo", "build-vcs", "go-toolchain", "go-proxy", "go-private", "go-nosumdb", "go-flags", "go-netrc", "go-token", "middleware-version", "scan", "scan-severity", "checksums", "sign-key", "sign-manifests", "without-source", "strip-source", "keep-tars", "interactive", "zero-timestamps", "verify-reproducible", "ca-bundle", "http-proxy", "https-proxy", "no-proxy", "build-info", "save-config", "base-image-pull-policy", "rebase", "print-fingerprint", "watch", "output"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runBuild(cmd, args, newClient)
 		},
@@ -112,7 +356,7 @@ EXAMPLES
 		"Override the base image for your function (host builder only)")
 	// 指定构建镜像名称,可以使用--image 或者 FUNC_IMAGE 指定(只有host模式可以使用)
 	cmd.Flags().StringP("image", "i", f.Image,
-		"Full image name in the form [registry]/[namespace]/[name]:[tag] (optional). This option takes precedence over --registry ($FUNC_IMAGE)")
+		"Full image name in the form [registry]/[namespace]/[name]:[tag] (optional). This option takes precedence over --registry, and if both are given must be of that registry ($FUNC_IMAGE)")
 
 	// 静态配置(不会存放于任何位置)
 
@@ -121,13 +365,235 @@ EXAMPLES
 		"Attempt to push the function image to the configured registry after being successfully built")
 	// 指定平台,可以使用--platform linux/amd64 linux/arm64之类
 	cmd.Flags().StringP("platform", "", "",
-		"Optionally specify a target platform, for example \"linux/amd64\" when using the s2i build strategy")
+		"Optionally specify a target platform, for example \"linux/amd64\" when using the s2i build strategy.  A variant, such as of \"linux/arm/v7\", may be given as \"7\" or in the architecture (\"linux/armv7\"), and that of amd64 is its microarchitecture level, v1 to v4, as of \"linux/amd64/v3\"")
+	// 将平台(--platform)一并保存至func.yaml(build.platforms),后续构建默认使用
+	cmd.Flags().Bool("save-config", false,
+		"Save the platform (--platform) to func.yaml (build.platforms) in addition to the builder, registry, image, base image and builder image which are always saved, such that subsequent builds without --platform are of that platform. ($FUNC_SAVE_CONFIG)")
 	// 用于镜像仓库认证(用户+密码 或者 token)
 	cmd.Flags().StringP("username", "", "", "Username to use when pushing to the registry.")
 	cmd.Flags().StringP("password", "", "", "Password to use when pushing to the registry.")
 	cmd.Flags().StringP("token", "", "", "Token to use when pushing to the registry.")
+	// docker配置目录(config.json),用于拉取基础镜像和推送的认证
+	cmd.Flags().String("docker-config", "",
+		"Directory of the docker configuration (config.json) from which the credentials for pulling base images and pushing are read, such as in CI where the home directory is not that of the user.  Defaults to $DOCKER_CONFIG or ~/.docker. ($FUNC_DOCKER_CONFIG)")
 	// 构建时间
 	cmd.Flags().BoolP("build-timestamp", "", false, "Use the actual time as the created time for the docker image. This is only useful for buildpacks builder.")
+	// 静默模式,仅输出错误(子进程输出仅在失败时输出)
+	cmd.Flags().BoolP("quiet", "q", false,
+		"Suppress all non-error output of the build.  Output of the compiler is shown only if it fails (host builder).  Can not be used with --verbose. ($FUNC_QUIET)")
+	// 构建配置文件(profile),从func.yaml或全局配置中加载
+	cmd.Flags().String("profile", "",
+		"Named set of build settings (registry, builder, builder image, base image and labels) defined in func.yaml or the global config to layer over the function's settings.  Explicitly provided flags take precedence. ($FUNC_PROFILE)")
+	// 从远程git仓库构建,格式为 URL[@ref], --path 为仓库内的相对路径
+	cmd.Flags().String("git", "",
+		"Build the function from a remote git repository in the form URL[@ref], where ref is a branch, tag or commit.  The repository is cloned to a temporary directory which is removed after building.  When provided, --path is the function's path within the repository.")
+	// 克隆git仓库(仅http(s))的凭据,与推送镜像的凭据(--username等)相互独立
+	cmd.Flags().String("git-username", "",
+		"Username with which to clone the repository of --git over http(s).  Repositories of ssh URLs, such as git@github.com:alice/functions.git, are cloned with the ssh agent. ($FUNC_GIT_USERNAME)")
+	cmd.Flags().String("git-password", "",
+		"Password or token with which to clone the repository of --git over http(s).  A token alone is sent with the username oauth2.  Prefer the environment variable to the flag, which is visible to other processes. ($FUNC_GIT_PASSWORD)")
+	// 授予函数二进制文件的Linux文件能力(仅host构建器, go)
+	cmd.Flags().StringSlice("capability", []string{},
+		"Linux file capability to grant the function binary, such as \"cap_net_bind_service\" to bind privileged ports as a non-root user.  Any process executing the binary gains the capability, so grant only what is required.  Can be repeated. (host builder, go only) ($FUNC_CAPABILITY)")
+
+	// 构建工作目录(仅host构建器),默认为函数目录下的.func
+	cmd.Flags().String("build-dir", "",
+		"Directory in which to create the build's working files, such as the scaffolding and image layers, instead of the function's .func directory.  Useful when the function's directory is read-only or on a slow filesystem. (host builder only) ($FUNC_BUILD_DIR)")
+
+	// 查看或清理host构建器的blob缓存(不构建)
+	cmd.Flags().Bool("cache-info", false,
+		"Show the location, number of blobs, size and last use of the host builder's blob caches (shared and the function's) instead of building. ($FUNC_CACHE_INFO)")
+	cmd.Flags().Bool("cache-clear", false,
+		"Remove all blobs from the host builder's blob caches (shared and the function's) instead of building. ($FUNC_CACHE_CLEAR)")
+	// 清除函数的全部构建状态(by-hash, by-pid, by-platform, last),不构建
+	cmd.Flags().Bool("clean", false,
+		"Remove all build state of the function (the builds of its .func/builds directory, or of --build-dir) instead of building, such as to troubleshoot a build from scratch.  Prompts for confirmation in an interactive terminal.  With --cache-clear, the blob caches are also purged. ($FUNC_CLEAN)")
+
+	// 预热blob缓存: 下载基础镜像各平台的层(不构建,仅host构建器)
+	cmd.Flags().Bool("warm-cache", false,
+		"Download the layers of the base image (--base-image or that of the runtime) of each platform to be built (--platform or the defaults) into the host builder's blob cache instead of building, such that builds which follow need not download them. (host builder only) ($FUNC_WARM_CACHE)")
+
+	// 导出构建的OCI布局为单一归档(仅host构建器)
+	cmd.Flags().String("bundle", "",
+		"Export the built OCI layout as a single tar archive at this path, storing each blob once (blobs shared between platforms are not duplicated).  The archive is verified after being written. (host builder only) ($FUNC_BUNDLE)")
+
+	// 将构建的OCI布局写入指定目录(仅host构建器)
+	cmd.Flags().String("oci-output", "",
+		"Write the built OCI layout to this directory after each build, replacing any layout previously written there, such that it may be collected from a predictable path without knowing the build's fingerprint.  Blobs are hard-linked where on the same filesystem, and otherwise copied. (host builder only) ($FUNC_OCI_OUTPUT)")
+
+	// 导出的OCI布局的摘要算法(仅host构建器,仅适用于导出): sha256(默认)或sha512
+	cmd.Flags().String("digest-algorithm", "",
+		"Digest algorithm of the exported OCI layouts, those written with --oci-output and --bundle, only: \"sha256\" (default) or \"sha512\", with which their blobs are named blobs/sha512/{hex} and each descriptor is of its SHA-512 digest.  The build's own layout (.func/builds), the blob cache and the image pushed remain of SHA-256.  Requires --oci-output or --bundle. (host builder only) ($FUNC_DIGEST_ALGORITHM)")
+
+	// 镜像注解(仅host构建器),可重复,默认值来自func.yaml的build.annotations
+	cmd.Flags().StringArray("annotation", []string{},
+		"OCI annotation to add to the image in the form key=value, where the key is in reverse domain notation such as \"com.example.team\".  Added to those defined in func.yaml (build.annotations), overriding any of the same key.  The manifest of each platform also records its base image, as org.opencontainers.image.base.name (\"scratch\" if none) and org.opencontainers.image.base.digest.  Can be repeated. (host builder only)")
+
+	// 镜像媒体类型(仅host构建器): oci(默认)或docker
+	cmd.Flags().String("media-type", "",
+		"Media types of the built image: \"oci\" (default) or \"docker\" (schema2), for registries and tools which only accept Docker images.  With docker, a manifest list is built instead of an image index. (host builder only) ($FUNC_MEDIA_TYPE)")
+
+	// 镜像索引的artifactType(仅host构建器,OCI 1.1)
+	cmd.Flags().String("artifact-type", "",
+		"Media type set as the artifactType of the image index (OCI 1.1), such as \"application/vnd.example.function.v1\", for tools which filter referrers by type.  Requires the oci media types. (host builder only) ($FUNC_ARTIFACT_TYPE)")
+
+	// 外部(foreign)基础镜像层(仅host构建器),可重复
+	cmd.Flags().StringArray("foreign-layer", []string{},
+		"Mark a layer of the base image as a foreign layer in the form digest=url, such that it is fetched from the URL rather than pushed to and pulled from the registry.  The URL must serve the layer to anything which pulls the image.  Can be repeated. (host builder only)")
+
+	// 基础镜像仓库的镜像(mirror)(仅host构建器),可重复
+	cmd.Flags().StringArray("registry-mirror", []string{},
+		"Pull base images of a registry through a mirror in the form registry=mirror, such as docker.io=mirror.example.com/dockerhub.  The mirror is a registry host optionally followed by a path under which the registry's repositories are found.  The base image's tag or digest is preserved.  Can be repeated. (host builder only)")
+
+	// 查询镜像仓库,报告镜像是否为最新或推送将上传的层,而不推送(仅host构建器)
+	cmd.Flags().Bool("push-dry-run", false,
+		"Report whether the function image in the registry is up-to-date with the build, comparing their digests, or otherwise the layers which pushing would upload, without pushing.  Conflicts with --push. (host builder only) ($FUNC_PUSH_DRY_RUN)")
+
+	// 推送单平台镜像时,同时以架构为后缀标记镜像(仅host构建器)
+	cmd.Flags().Bool("platform-tag-suffix", false,
+		"Also tag the image of a single-platform build with its architecture as a suffix on push, such as :latest-amd64 or :latest-armv7, such that it is addressable alongside the image index.  Requires --push and a single platform: --platform, or one of build.platforms. (host builder only) ($FUNC_PLATFORM_TAG_SUFFIX)")
+
+	// 推送后将镜像同时推送至其他镜像仓库(仅host构建器),可重复
+	cmd.Flags().StringArray("push-registry", []string{},
+		"Also push the built image to this registry, such as one for disaster recovery, once pushed to the function's image.  The image is named in it as with --registry ({registry}/{name}:latest).  Blobs are mounted from the function's image where of the same registry rather than uploaded again.  Each registry is reported, and the build fails if the push to any fails.  Requires --push.  Can be repeated. (host builder only)")
+
+	// 压缩镜像层(仅host构建器): function(不指定值时)或all(包括基础镜像层)
+	cmd.Flags().String("squash", "",
+		"Squash the image's layers into a single layer: \"function\" (the default when no value is given) for those of the function, atop the base image's, or \"all\" to include the base image's for a single-layer image. (host builder only) ($FUNC_SQUASH)")
+	cmd.Flags().Lookup("squash").NoOptDefVal = oci.SquashFunction
+
+	// 每个平台镜像的层数上限(仅host构建器),超出时警告或失败,0为不限制
+	cmd.Flags().Int("max-layers", 0,
+		"Maximum number of layers of each platform's image, those of the base image included, beyond which a warning is printed suggesting squashing (--squash).  Defaults to no limit. (host builder only) ($FUNC_MAX_LAYERS)")
+	cmd.Flags().Bool("max-layers-fail", false,
+		"Fail the build, rather than warn, when an image has more layers than --max-layers. (host builder only) ($FUNC_MAX_LAYERS_FAIL)")
+
+	// 并行构建的平台数上限(仅host构建器),0为默认值
+	cmd.Flags().Int("build-concurrency", 0,
+		"Maximum number of platforms built at once, each of which compiles the function, to limit resource usage such as memory.  Defaults to the lesser of the number of platforms and the number of CPUs. (host builder only) ($FUNC_BUILD_CONCURRENCY)")
+
+	// Go构建标签(仅host构建器),可重复,与func.yaml的build.buildTags合并
+	cmd.Flags().StringArray("build-tag", []string{},
+		"Go build tag with which to compile the function, such as \"prod\" to include files constrained by //go:build prod.  Added to those defined in func.yaml (build.buildTags).  The platform's GOOS and GOARCH are implied, and \"cgo\" is never satisfied as functions are built with CGO_ENABLED=0.  Can be repeated. (host builder, go only)")
+
+	// PGO配置文件(仅host构建器),默认使用函数目录中的default.pgo
+	cmd.Flags().String("pgo", "",
+		"CPU profile with which to compile the function for profile-guided optimization, or \"off\" to disable.  Defaults to default.pgo in the function's directory, if present.  The profile must be available at build time, so should be committed with the function or provided. (host builder, go only) ($FUNC_PGO)")
+
+	// 是否将版本控制信息写入二进制文件(仅host构建器): auto(默认),true或false
+	cmd.Flags().String("build-vcs", "",
+		"Stamp version control information into the function binary: \"true\", \"false\" or \"auto\" (default), which stamps it unless the function is in a git repository which can not be read, such as a shallow clone or when git is not installed, where stamping would fail the build. (host builder, go only) ($FUNC_BUILD_VCS)")
+
+	// 固定go工具链(仅host构建器): local或版本号,离线时(GOPROXY=off)默认为local
+	cmd.Flags().String("go-toolchain", "",
+		"Go toolchain with which to build the function (GOTOOLCHAIN): \"local\" for that installed, or a version such as \"go1.22.3\", such that a different toolchain required by the function's go.mod is not silently downloaded.  Defaults to that of the environment, or \"local\" when offline (GOPROXY=off). (host builder, go only) ($FUNC_GO_TOOLCHAIN)")
+
+	// go模块代理、私有模块及其凭据(仅host构建器),默认为环境中的值
+	cmd.Flags().String("go-proxy", "",
+		"Go module proxy with which to build the function (GOPROXY), such as \"https://proxy.example.com,direct\".  Defaults to that of the environment. (host builder, go only) ($FUNC_GO_PROXY)")
+	cmd.Flags().String("go-private", "",
+		"Patterns of private go modules (GOPRIVATE), which are fetched directly and not checked against the checksum database, such as \"github.com/example/*\".  Defaults to that of the environment. (host builder, go only) ($FUNC_GO_PRIVATE)")
+	cmd.Flags().String("go-nosumdb", "",
+		"Patterns of go modules not checked against the checksum database (GONOSUMDB).  Defaults to that of the environment. (host builder, go only) ($FUNC_GO_NOSUMDB)")
+	cmd.Flags().String("go-flags", "",
+		"Flags of the go toolchain (GOFLAGS), such as \"-mod=mod\".  Build tags given with -tags are replaced by those of --build-tag and func.yaml, if any.  Defaults to that of the environment. (host builder, go only) ($FUNC_GO_FLAGS)")
+	cmd.Flags().String("go-netrc", "",
+		"Path of a netrc file with the credentials of hosts of private go modules (NETRC). (host builder, go only) ($FUNC_GO_NETRC)")
+	cmd.Flags().StringSlice("go-token", []string{},
+		"Token of a host of private go modules in the form host=[login:]token, such as \"github.com=$GITHUB_TOKEN\", provided to the go toolchain in a temporary netrc file.  Prefer the environment variable to the flag, which is visible to other processes.  Can be repeated. (host builder, go only) ($FUNC_GO_TOKEN)")
+
+	// 构建密钥(仅host构建器),可重复: 仅提供给构建期间运行的命令,不写入镜像
+	cmd.Flags().StringArray("secret", []string{},
+		"Secret available to the commands of the build (go, pip and build hooks), such as credentials with which private dependencies are fetched, in the form id=ID,src=PATH or id=ID,env=VAR, optionally with target=VAR.  The file of src must be outside of the function's directory.  Provided as a file named by the environment variable target, or FUNC_SECRET_{ID}, such as id=pip,src=~/.config/pip/pip.conf,target=PIP_CONFIG_FILE.  A secret is never written to the image, its config or the build directory, nor does it change the build's fingerprint.  Can be repeated. (host builder only)")
+
+	// 固定go中间件(knative.dev/func-go)版本(仅host构建器),优先于func.yaml
+	cmd.Flags().String("middleware-version", "",
+		"Version of the middleware which serves the function (knative.dev/func-go) to pin, such as \"v0.21.4\", in place of that required by the scaffolding.  Pinned with a replace directive in the scaffolding's go.mod, not the function's.  Takes precedence over func.yaml (build.middlewareVersion). (host builder, go only) ($FUNC_MIDDLEWARE_VERSION)")
+
+	// 脚手架go.mod的replace指令(仅host构建器),可重复
+	cmd.Flags().StringArray("replace", []string{},
+		"Replace a module in the scaffolding's go.mod in the form old=new, where old is a module path, optionally at a version (path@version), and new is a directory containing a go.mod, relative to the current directory, or a module at a version, such as knative.dev/func-go=../func-go to build against a local fork of the middleware.  Added to those of func.yaml (build.replace), overriding any of the same module.  The function's go.mod is not modified.  Can be repeated. (host builder, go only)")
+
+	// 构建后扫描镜像漏洞(仅host构建器): trivy(不指定值时),grype或自定义命令
+	cmd.Flags().String("scan", "",
+		"Scan the built image for vulnerabilities, failing the build if any of at least --scan-severity are found: with \"trivy\" (the default when no value is given), \"grype\", or a command in which {layout} is replaced with the path of the image's OCI layout (appended if absent) and {severity} with the severity, which exits non-zero to fail the build.  The build fails if the scanner is not installed, whereas a scan configured in func.yaml (build.scan) is then skipped. (host builder only) ($FUNC_SCAN)")
+	cmd.Flags().Lookup("scan").NoOptDefVal = oci.ScannerTrivy
+	cmd.Flags().String("scan-severity", "",
+		fmt.Sprintf("Severity of vulnerabilities at or above which the image scan fails the build: %v.  Defaults to that of func.yaml (build.scan.severity), or \"high\". (host builder only) ($FUNC_SCAN_SEVERITY)", strings.Join(fn.ScanSeverities, ", ")))
+
+	// 写入构建产物的sha256校验和(仅host构建器)
+	cmd.Flags().Bool("checksums", false,
+		"Write the SHA-256 checksums of the function's binaries (result/f.*) and of the image index (oci/index.json) to checksums.txt in the build directory (.func/builds/last), in the format of sha256sum, to be signed or archived. (host builder only) ($FUNC_CHECKSUMS)")
+
+	// 以私钥签名镜像索引,可选地签名每个平台清单,推送时一并推送签名(仅host构建器)
+	cmd.Flags().String("sign-key", "",
+		"Sign the image index with the unencrypted PEM private key (ECDSA, Ed25519 or RSA) at this path.  Signatures are of cosign's simple signing format, written to signatures/ in the build directory and pushed alongside the image as {algorithm}-{hex}.sig tags, such that cosign verify --key verifies them. (host builder only) ($FUNC_SIGN_KEY)")
+	cmd.Flags().Bool("sign-manifests", false,
+		"Also sign each platform manifest with --sign-key, such that a verifier which resolves a platform's manifest rather than the index can verify it.  Requires --sign-key. (host builder only) ($FUNC_SIGN_MANIFESTS)")
+
+	// 编译型运行时省略源码数据层(仅host构建器)
+	cmd.Flags().Bool("without-source", false,
+		"Omit the function's source from the image, which then contains only the compiled binary and certificates, such that the source is not shipped.  Files of the function read at runtime should be embedded in the binary instead. (host builder, compiled runtimes such as go only) ($FUNC_WITHOUT_SOURCE)")
+
+	// 从源码数据层中排除测试、测试数据与文档(仅host构建器)
+	cmd.Flags().Bool("strip-source", false,
+		"Exclude tests, test data and documentation (such as *_test.go, testdata/, tests/ and *.md) from the function's source in the image, reducing its size.  They remain available to the build itself. (host builder only) ($FUNC_STRIP_SOURCE)")
+
+	// 保留各镜像层的tar.gz文件以便检查(仅host构建器)
+	cmd.Flags().Bool("keep-tars", false,
+		"Keep the gzipped tarball of each layer (such as datalayer.tar.gz, certslayer.tar.gz and execlayer.*.tar.gz) in the build directory (.func/builds/last), rather than only the blobs named by digest, such that their contents may be inspected with tar tzf.  The blobs are also linked by readable name from blobs-by-name. (host builder only) ($FUNC_KEEP_TARS)")
+
+	// 构建失败时报告部分构建的状态,并在清理前等待以便检查(仅host构建器)
+	cmd.Flags().Bool("interactive", false,
+		"On failure, report the state of the build before its build directory is cleaned up: the phases started, the last of each platform being that which failed, and the blobs written to the partial OCI layout by readable name.  When attached to a terminal, wait for Enter before cleaning up, such that the build directory may be explored. (host builder only) ($FUNC_INTERACTIVE)")
+
+	// 将镜像创建时间、历史与文件时间设置为Unix纪元(仅host构建器)
+	cmd.Flags().Bool("zero-timestamps", false,
+		"Set the creation time of the image, of each entry of its history and the modification times of the files of the layers it builds to the Unix epoch (1970-01-01), for conformance testing and maximally reproducible images.  This may confuse tools which expect realistic dates, such as those listing images by age. (host builder only) ($FUNC_ZERO_TIMESTAMPS)")
+
+	// 构建两次并比较镜像索引摘要,不同时失败并报告差异的blob(仅host构建器)
+	cmd.Flags().Bool("verify-reproducible", false,
+		"Build the image a second time from the same inputs and fail if the digests of the two image indices differ, reporting the config or layers of each platform which differ.  The modification times of files written by the build, such as the compiled binary, usually differ unless --zero-timestamps is given. (host builder only) ($FUNC_VERIFY_REPRODUCIBLE)")
+
+	// 将自定义CA证书(如企业内部根证书)合并至镜像的证书(仅host构建器)
+	cmd.Flags().String("ca-bundle", "",
+		"PEM bundle of CA certificates, such as the root CAs of a corporate network, to add to those of the image such that the function can reach HTTPS services whose certificates they sign.  Merged with the image's bundle, each certificate included once, and must contain only valid certificates. (host builder only) ($FUNC_CA_BUNDLE)")
+
+	// 经由代理拉取基础镜像及依赖(go模块,pip包),覆盖环境变量中的代理(仅host构建器)
+	cmd.Flags().String("http-proxy", "",
+		"Proxy of the build's http requests, such as http://proxy.example.com:3128: of base image pulls and of the build's commands, such as go (modules) and pip (packages).  Overrides HTTP_PROXY, which is otherwise honored. (host builder only) ($FUNC_HTTP_PROXY)")
+	cmd.Flags().String("https-proxy", "",
+		"Proxy of the build's https requests, such as http://proxy.example.com:3128, as with --http-proxy.  Overrides HTTPS_PROXY, which is otherwise honored. (host builder only) ($FUNC_HTTPS_PROXY)")
+	cmd.Flags().String("no-proxy", "",
+		"Hosts which bypass the proxy, such as internal registries and module proxies: a comma-separated list of host names, domains (.example.com, including subdomains), IPs and CIDRs.  Overrides NO_PROXY, which is otherwise honored. (host builder only) ($FUNC_NO_PROXY)")
+
+	// 将构建信息(指纹,func版本,构建时间)写入镜像的/func/.build-info(仅host构建器)
+	cmd.Flags().Bool("build-info", false,
+		"Write the build's fingerprint, the version of func which built it and the time it was built as JSON to /func/.build-info in the image, such that the provenance of a running function can be inspected. (host builder only) ($FUNC_BUILD_INFO)")
+
+	// 基础镜像的拉取策略(仅host构建器): always, if-not-present(默认) 或 never
+	cmd.Flags().String("base-image-pull-policy", "",
+		"When the base image is fetched from its registry: \"always\", resolving it from the registry and fetching its layers again on every build, \"if-not-present\" (default), using the local image or the cached layers where available, or \"never\", using the local image or that last pulled from the blob cache without contacting the registry, failing if it is not cached. (host builder only) ($FUNC_BASE_IMAGE_PULL_POLICY)")
+
+	// 将最后一次构建重新基于基础镜像(的新版本),不重新编译源码(仅host构建器)
+	cmd.Flags().Bool("rebase", false,
+		"Rebase the last build onto the base image (--base-image or that of the runtime), replacing the layers of the base upon which it was built, as recorded in its manifests, with those of the base image as now resolved, without building the source.  Images built from scratch, with squashed base layers or with supplementary groups can not be rebased. (host builder only) ($FUNC_REBASE)")
+
+	// 监听函数文件变化并自动重新构建,直到中断
+	cmd.Flags().Bool("watch", false,
+		"Watch the function's files for changes, ignoring those matching .funcignore, and rebuild on each change until interrupted. ($FUNC_WATCH)")
+
+	// 输出格式: human(默认)或json
+	cmd.Flags().StringP("output", "o", "human",
+		"Output format (human|json).  With json, the result of the build (image, digests of the index and of each platform's image and layers, timings and whether pushed) is written as JSON instead of human-readable text. ($FUNC_OUTPUT)")
+
+	// 打印生效的构建配置及其来源,不进行构建
+	cmd.Flags().Bool("inspect", false,
+		"Print the effective build configuration (builder, registry, image, base image, platforms, push) and the source of each value instead of building. ($FUNC_INSPECT)")
+
+	// 打印函数源码指纹及其构建目录,不进行构建
+	cmd.Flags().Bool("print-fingerprint", false,
+		"Print the fingerprint of the function's source, which identifies its build, and the host builder's build directory for it (.func/builds/by-hash/{fingerprint}) instead of building.  Only the fingerprint is printed with --quiet. ($FUNC_PRINT_FINGERPRINT)")
 
 	// 暂时隐藏基础认证标志
 	_ = cmd.Flags().MarkHidden("username")
@@ -156,8 +622,77 @@ func runBuild(cmd *cobra.Command, _ []string, newClient ClientFactory) (err erro
 		f   fn.Function
 	)
 
+	cfg = newBuildConfig()
+	// NOTE: as with deploy's --env, viper.GetStringSlice does not parse
+	// string arrays (https://github.com/spf13/viper/issues/380)
+	if cfg.Annotations, err = cmd.Flags().GetStringArray("annotation"); err != nil {
+		return
+	}
+	if cfg.ForeignLayers, err = cmd.Flags().GetStringArray("foreign-layer"); err != nil {
+		return
+	}
+	if cfg.RegistryMirrors, err = cmd.Flags().GetStringArray("registry-mirror"); err != nil {
+		return
+	}
+	if cfg.BuildTags, err = cmd.Flags().GetStringArray("build-tag"); err != nil {
+		return
+	}
+	if cfg.Replace, err = cmd.Flags().GetStringArray("replace"); err != nil {
+		return
+	}
+	if cfg.PushRegistries, err = cmd.Flags().GetStringArray("push-registry"); err != nil {
+		return
+	}
+	if cfg.Secrets, err = cmd.Flags().GetStringArray("secret"); err != nil {
+		return
+	}
+
+	// 清除函数的全部构建状态,不进行构建
+	if cfg.Clean {
+		return runBuildClean(cmd, cfg)
+	}
+
+	// 查看或清理构建缓存,不进行构建
+	if cfg.CacheInfo || cfg.CacheClear {
+		return runBuildCache(cmd, cfg)
+	}
+
+	// 从远程git仓库构建: 克隆至临时目录,构建完成后清理
+	// Note the --git flag is not bound to $FUNC_GIT, which is the git binary.
+	if source, _ := cmd.Flags().GetString("git"); source != "" {
+		if cfg.Watch {
+			return errors.New("--watch may not be used when building from a git repository (--git)")
+		}
+		var dir string
+		if dir, err = cfg.useGitSource(cmd, source); err != nil {
+			return
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	// 未配置构建器且无容器运行时: 选择主机构建器
+	if cfg, err = cfg.selectBuilder(cmd); err != nil {
+		return
+	}
+
+	// 应用构建配置文件
+	pre := cfg
+	if cfg, err = cfg.applyProfile(cmd); err != nil {
+		return
+	}
+
+	// 打印生效的构建配置,不进行构建
+	if cfg.Inspect {
+		return cfg.inspect(cmd, pre)
+	}
+
+	// 打印源码指纹,不进行构建
+	if cfg.PrintFingerprint {
+		return runBuildFingerprint(cmd, cfg)
+	}
+
 	// 收集配置
-	if cfg, err = newBuildConfig().Prompt(); err != nil { // gather values into a single instruction set
+	if cfg, err = cfg.Prompt(); err != nil { // gather values into a single instruction set
 		// Layer 2: Catch technical errors and provide CLI-specific user-friendly messages
 
 		// Check if it's a "not initialized" error (no function found)
@@ -179,7 +714,12 @@ func runBuild(cmd *cobra.Command, _ []string, newClient ClientFactory) (err erro
 		if errors.Is(err, fn.ErrConflictingImageAndRegistry) {
 			return fmt.Errorf(`%w
 
-Cannot use both --image and --registry together. Choose one:
+When both are given, --image must be under --registry: its repository, less
+the function's name, is the registry. For example:
+
+  func build --registry example.com/user --image example.com/user/myfunc:v1
+
+Or use only one of them:
 
   Use --image for complete image name:
     func build --image example.com/user/myfunc
@@ -226,7 +766,7 @@ For more options, run 'func build --help'`, err)
 	if err != nil {
 		return
 	}
-	client, done := newClient(ClientConfig{Verbose: cfg.Verbose}, clientOptions...)
+	client, done := newClient(ClientConfig{Verbose: cfg.Verbose, DockerConfig: cfg.DockerConfig}, clientOptions...)
 	defer done()
 
 	// 构建选项
@@ -234,22 +774,81 @@ For more options, run 'func build --help'`, err)
 	if err != nil {
 		return
 	}
-	if f, err = client.Build(cmd.Context(), f, buildOptions...); err != nil {
+
+	// 预热基础镜像层的缓存,不进行构建
+	if cfg.WarmCache {
+		return cfg.warmCache(cmd, client, f, buildOptions)
+	}
+	if _, err = cfg.build(cmd, client, f, buildOptions); err != nil {
 		return
 	}
 
+	// 监听文件变化并重新构建,直到被中断
+	if cfg.Watch {
+		return cfg.watch(cmd, f.Root, func(ctx context.Context) (fn.Function, error) {
+			f, err := fn.NewFunction(cfg.Path)
+			if err != nil {
+				return f, err
+			}
+			return cfg.build(cmd, client, cfg.Configure(f), buildOptions)
+		})
+	}
+	return
+}
+
+// build the function, pushing it if requested, and update its func.yaml.
+func (c buildConfig) build(cmd *cobra.Command, client *fn.Client, f fn.Function, buildOptions []fn.BuildOption) (_ fn.Function, err error) {
+	if c.Rebase {
+		f, err = c.rebase(cmd, client, f) // 重新基于基础镜像,不构建源码
+	} else {
+		f, err = client.Build(cmd.Context(), f, buildOptions...)
+	}
+	if err != nil {
+		return f, wrapHostBuildError(err, "build")
+	}
+
 	// 推送镜像
-	if cfg.Push {
+	var push *oci.PhaseTiming
+	if c.Push {
+		start := time.Now()
 		if f, _, err = client.Push(cmd.Context(), f); err != nil {
-			return
+			return f, err
+		}
+		push = &oci.PhaseTiming{Phase: "push", Duration: time.Since(start)}
+		if !c.Quiet {
+			fmt.Fprintf(cmd.OutOrStdout(), "Push timings: %v\n", *push)
+		}
+	}
+
+	// 推送镜像至其他镜像仓库(可选,如灾备),逐一报告结果
+	var mirrors []mirrorResult
+	if c.Push {
+		mirrors = c.mirror(cmd, client, f)
+	}
+
+	// 预演推送:报告镜像是否为最新,或推送将上传的层
+	var plan *oci.PushPlan
+	if c.PushDryRun {
+		if plan, err = c.planPush(cmd, client, f); err != nil {
+			return f, err
 		}
 	}
 
 	// 更新func.yaml
 	if err = f.Write(); err != nil {
-		return
+		return f, err
+	}
+	if err = f.Stamp(); err != nil {
+		return f, err
+	}
+
+	// 以JSON输出构建结果
+	if Format(c.Output) == JSON {
+		if err = writeBuildOutput(cmd.OutOrStdout(), newBuildOutput(client, f, push, mirrors, plan)); err != nil {
+			return f, err
+		}
 	}
-	return f.Stamp()
+	return f, mirrorsError(mirrors)
 }
 
 // WithValues returns a context populated with values from the build config
@@ -288,6 +887,10 @@ type buildConfig struct {
 	// Push the resulting image to the registry after building.
 	Push bool
 
+	// PushDryRun reports what pushing would upload, without pushing (host
+	// builder only).
+	PushDryRun bool
+
 	// Username when specifying optional basic auth.
 	Username string
 
@@ -299,9 +902,219 @@ type buildConfig struct {
 	// exclusive with Username and Password.
 	Token string
 
+	// DockerConfig is the directory of the docker configuration (config.json)
+	// from which registry credentials are read.  Defaults to $DOCKER_CONFIG or
+	// ~/.docker.
+	DockerConfig string
+
 	// Build with the current timestamp as the created time for docker image.
 	// This is only useful for buildpacks builder.
 	WithTimestamp bool
+
+	// Capabilities are Linux file capabilities granted to the function's
+	// binary (host builder only).
+	Capabilities []string
+
+	// GitUsername and GitPassword (or token) authenticate the clone of the
+	// git build source (--git) over http(s).
+	GitUsername string
+	GitPassword string
+
+	// Profile is the name of the build profile to apply, if any.
+	Profile string
+
+	// Labels from the selected profile to add to the function.
+	Labels []fn.Label
+
+	// Quiet suppresses all non-error output of the build.
+	Quiet bool
+
+	// BuildDir is an alternate directory for build working files
+	// (host builder only).
+	BuildDir string
+
+	// CacheInfo shows the host builder's blob caches instead of building.
+	CacheInfo bool
+
+	// CacheClear purges the host builder's blob caches instead of building.
+	CacheClear bool
+
+	// Clean removes all builds of the function instead of building.
+	Clean bool
+
+	// WarmCache downloads the base image's layers into the host builder's
+	// blob cache instead of building.
+	WarmCache bool
+
+	// Bundle is the path to which to export the built OCI layout
+	// (host builder only).
+	Bundle string
+
+	// OCIOutput is the directory to which to write the built OCI layout
+	// (host builder only).
+	OCIOutput string
+
+	// DigestAlgorithm of the exported OCI layouts only: sha256 or sha512
+	// (host builder only).  The build itself remains of sha256.
+	DigestAlgorithm string
+
+	// Inspect prints the effective configuration instead of building.
+	Inspect bool
+
+	// PrintFingerprint prints the fingerprint of the function's source and
+	// its build directory instead of building.
+	PrintFingerprint bool
+
+	// SaveConfig saves the platform to func.yaml (build.platforms) in
+	// addition to the values of a build which are always saved.
+	SaveConfig bool
+
+	// Annotations to add to the image, in the form key=value
+	// (host builder only).
+	Annotations []string
+
+	// MediaType set of the image: oci or docker (host builder only).
+	MediaType string
+
+	// ArtifactType of the image index, a media type (host builder only).
+	ArtifactType string
+
+	// ForeignLayers of the base image, in the form digest=url
+	// (host builder only).
+	ForeignLayers []string
+
+	// RegistryMirrors through which base images are pulled, in the form
+	// registry=mirror (host builder only).
+	RegistryMirrors []string
+
+	// Squash mode of the image's layers: function or all
+	// (host builder only).
+	Squash string
+
+	// MaxLayers is the maximum number of layers of each platform's image,
+	// zero for no limit (host builder only).
+	MaxLayers int
+
+	// MaxLayersFail fails the build of an image of more than MaxLayers,
+	// rather than warning of it (host builder only).
+	MaxLayersFail bool
+
+	// BuildConcurrency is the maximum number of platforms built at once,
+	// zero for the default (host builder only).
+	BuildConcurrency int
+
+	// BuildTags are Go build tags, in addition to those of the function
+	// (host builder, go only).
+	BuildTags []string
+
+	// PGO is the CPU profile for profile-guided optimization, or "off"
+	// (host builder, go only).
+	PGO string
+
+	// BuildVCS is whether VCS information is stamped into the binary: auto,
+	// true or false (host builder, go only).
+	BuildVCS string
+
+	// GoToolchain pins the go toolchain: local or a version (host builder,
+	// go only).
+	GoToolchain string
+
+	// Middleware is the version to which the go middleware is pinned (host
+	// builder, go only).
+	Middleware string
+
+	// Go module settings: the proxy, private modules and their credentials
+	// as a netrc file or tokens in the form host=[login:]token (host
+	// builder, go only).
+	GoProxy   string
+	GoPrivate string
+	GoNoSumDB string
+	GoFlags   string
+	GoNetRC   string
+	GoTokens  []string
+
+	// PushRegistries are registries to which the image is also pushed once
+	// pushed to that of the function (host builder only).
+	PushRegistries []string
+
+	// PlatformTagSuffix also tags the image of a single-platform build with
+	// its architecture on push (host builder only).
+	PlatformTagSuffix bool
+
+	// Replace directives of the scaffolding's go.mod, in the form old=new
+	// (host builder, go only).
+	Replace []string
+
+	// Secrets available to the commands of the build, in the form
+	// id=ID,src=PATH or id=ID,env=VAR[,target=VAR] (host builder only).
+	Secrets []string
+
+	// Scan the built image with the scanner, failing the build on
+	// vulnerabilities of at least ScanSeverity (host builder only).
+	Scan         string
+	ScanSeverity string
+
+	// Checksums of the build's artifacts are written (host builder only).
+	Checksums bool
+
+	// SignKey is the private key with which the image index is signed, and
+	// with SignManifests each platform manifest (host builder only).
+	SignKey       string
+	SignManifests bool
+
+	// WithoutSource omits the function's source from the image (host
+	// builder, compiled runtimes only).
+	WithoutSource bool
+
+	// StripSource excludes tests and docs from the function's source in the
+	// image (host builder only).
+	StripSource bool
+
+	// KeepTars keeps the tarball of each layer in the build directory
+	// (host builder only).
+	KeepTars bool
+
+	// Interactive reports the state of a failed build before cleaning up
+	// (host builder only).
+	Interactive bool
+
+	// ZeroTimestamps sets the times of the image and its files to the Unix
+	// epoch (host builder only).
+	ZeroTimestamps bool
+
+	// VerifyReproducible builds the image twice, failing if the two differ
+	// (host builder only).
+	VerifyReproducible bool
+
+	// CABundle is a PEM bundle of CA certificates added to those of the
+	// image (host builder only).
+	CABundle string
+
+	// HTTPProxy, HTTPSProxy and NoProxy are the proxy of base image pulls
+	// and of the build's commands, over that of the environment (host
+	// builder only).
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+
+	// BuildInfo writes the provenance of the build to /func/.build-info in
+	// the image (host builder only).
+	BuildInfo bool
+
+	// BaseImagePullPolicy is when the base image is fetched from its
+	// registry: always, if-not-present or never (host builder only).
+	BaseImagePullPolicy string
+
+	// Rebase the last build onto the base image, in place of building
+	// (host builder only).
+	Rebase bool
+
+	// Watch the function's files, rebuilding on change.
+	Watch bool
+
+	// Output format of the build's result: human or json, where json
+	// implies Quiet.
+	Output string
 }
 
 // newBuildConfig gathers options into a single build request.
@@ -313,17 +1126,74 @@ func newBuildConfig() buildConfig {
 			Registry:         registry(), // deferred defaulting
 			Verbose:          viper.GetBool("verbose"),
 			RegistryInsecure: viper.GetBool("registry-insecure"),
+			ExternalBuilds:   externalBuilds(),
+			Platforms:        defaultPlatforms(),
 		},
-		BuilderImage:  viper.GetString("builder-image"),
-		BaseImage:     viper.GetString("base-image"),
-		Image:         viper.GetString("image"),
-		Path:          viper.GetString("path"),
-		Platform:      viper.GetString("platform"),
-		Push:          viper.GetBool("push"),
-		Username:      viper.GetString("username"),
-		Password:      viper.GetString("password"),
-		Token:         viper.GetString("token"),
-		WithTimestamp: viper.GetBool("build-timestamp"),
+		BuilderImage:        viper.GetString("builder-image"),
+		BaseImage:           viper.GetString("base-image"),
+		Image:               viper.GetString("image"),
+		Path:                viper.GetString("path"),
+		Platform:            viper.GetString("platform"),
+		Push:                viper.GetBool("push"),
+		PushDryRun:          viper.GetBool("push-dry-run"),
+		PlatformTagSuffix:   viper.GetBool("platform-tag-suffix"),
+		Username:            viper.GetString("username"),
+		Password:            viper.GetString("password"),
+		Token:               viper.GetString("token"),
+		DockerConfig:        viper.GetString("docker-config"),
+		WithTimestamp:       viper.GetBool("build-timestamp"),
+		Capabilities:        viper.GetStringSlice("capability"),
+		GitUsername:         viper.GetString("git-username"),
+		GitPassword:         viper.GetString("git-password"),
+		Profile:             viper.GetString("profile"),
+		Quiet:               viper.GetBool("quiet") || Format(viper.GetString("output")) == JSON,
+		BuildDir:            viper.GetString("build-dir"),
+		CacheInfo:           viper.GetBool("cache-info"),
+		CacheClear:          viper.GetBool("cache-clear"),
+		Clean:               viper.GetBool("clean"),
+		WarmCache:           viper.GetBool("warm-cache"),
+		SaveConfig:          viper.GetBool("save-config"),
+		Bundle:              viper.GetString("bundle"),
+		OCIOutput:           viper.GetString("oci-output"),
+		DigestAlgorithm:     viper.GetString("digest-algorithm"),
+		Inspect:             viper.GetBool("inspect"),
+		PrintFingerprint:    viper.GetBool("print-fingerprint"),
+		MediaType:           viper.GetString("media-type"),
+		ArtifactType:        viper.GetString("artifact-type"),
+		Squash:              viper.GetString("squash"),
+		MaxLayers:           viper.GetInt("max-layers"),
+		MaxLayersFail:       viper.GetBool("max-layers-fail"),
+		BuildConcurrency:    viper.GetInt("build-concurrency"),
+		PGO:                 viper.GetString("pgo"),
+		BuildVCS:            viper.GetString("build-vcs"),
+		GoToolchain:         viper.GetString("go-toolchain"),
+		Middleware:          viper.GetString("middleware-version"),
+		GoProxy:             viper.GetString("go-proxy"),
+		GoPrivate:           viper.GetString("go-private"),
+		GoNoSumDB:           viper.GetString("go-nosumdb"),
+		GoFlags:             viper.GetString("go-flags"),
+		GoNetRC:             viper.GetString("go-netrc"),
+		GoTokens:            viper.GetStringSlice("go-token"),
+		Scan:                viper.GetString("scan"),
+		ScanSeverity:        viper.GetString("scan-severity"),
+		Checksums:           viper.GetBool("checksums"),
+		SignKey:             viper.GetString("sign-key"),
+		SignManifests:       viper.GetBool("sign-manifests"),
+		WithoutSource:       viper.GetBool("without-source"),
+		StripSource:         viper.GetBool("strip-source"),
+		KeepTars:            viper.GetBool("keep-tars"),
+		Interactive:         viper.GetBool("interactive"),
+		ZeroTimestamps:      viper.GetBool("zero-timestamps"),
+		VerifyReproducible:  viper.GetBool("verify-reproducible"),
+		CABundle:            viper.GetString("ca-bundle"),
+		HTTPProxy:           viper.GetString("http-proxy"),
+		HTTPSProxy:          viper.GetString("https-proxy"),
+		NoProxy:             viper.GetString("no-proxy"),
+		BuildInfo:           viper.GetBool("build-info"),
+		BaseImagePullPolicy: viper.GetString("base-image-pull-policy"),
+		Rebase:              viper.GetBool("rebase"),
+		Watch:               viper.GetBool("watch"),
+		Output:              viper.GetString("output"),
 	}
 }
 
@@ -338,10 +1208,25 @@ func (c buildConfig) Configure(f fn.Function) fn.Function {
 	}
 	f.Image = c.Image
 	f.Build.BaseImage = c.BaseImage
-	// Path, Platform and Push are not part of a function's state.
+	f.Deploy.Labels = mergeLabels(f.Deploy.Labels, c.Labels)
+	// Path and Push are not part of a function's state, nor is Platform
+	// unless saved (--save-config).
+	if c.SaveConfig && c.Platform != "" {
+		if p, err := fn.ParsePlatform(c.Platform); err == nil { // else invalid (see buildOptions)
+			f.Build.Platforms = []string{savedPlatform(p)}
+		}
+	}
 	return f
 }
 
+// savedPlatform is the platform in the form os/arch[/variant] of func.yaml.
+func savedPlatform(p fn.Platform) string {
+	if p.Variant == "" {
+		return p.OS + "/" + p.Architecture
+	}
+	return p.OS + "/" + p.Architecture + "/" + p.Variant
+}
+
 // Prompt the user with value of config members, allowing for interactive changes.
 // Skipped if not in an interactive terminal (non-TTY), or if --confirm false (agree to
 // all prompts) was set (default).
@@ -419,6 +1304,185 @@ func (c buildConfig) Validate(cmd *cobra.Command) (err error) {
 		return
 	}
 
+	// The result is written as JSON in place of all other output
+	switch Format(c.Output) {
+	case "", Human: // commands without --output, such as deploy, are human
+	case JSON:
+		if c.Verbose {
+			return errors.New("--verbose may not be used with --output json")
+		}
+		if c.Watch {
+			return errors.New("--watch may not be used with --output json")
+		}
+	default:
+		return fmt.Errorf("invalid output format %q.  Supported formats are human and json", c.Output)
+	}
+
+	if c.Quiet && c.Verbose {
+		return errors.New("only one of --quiet or --verbose may be specified")
+	}
+
+	// Settings of the host builder only
+	if c.Builder != builders.Host {
+		for _, s := range c.hostOnlySettings() {
+			if s.set {
+				return fmt.Errorf("only host builds support %v (--%v)", s.what, s.flag)
+			}
+		}
+	}
+
+	// The image, if it will be used, must be a valid reference.  This fails
+	// fast, rather than at push after the build.
+	if c.Image != "" || c.Push || c.PushDryRun {
+		if err = c.validateImage(); err != nil {
+			return
+		}
+	}
+
+	// An image given with a registry, by flag or environment variable, must
+	// be of that registry, else which is pushed to would be a surprise.
+	if explicitlySet(cmd, "image") && explicitlySet(cmd, "registry") {
+		if err = c.validateImageRegistry(); err != nil {
+			return
+		}
+	}
+
+	// Values of the host builder's settings, failing fast rather than at
+	// build.  The digest algorithm applies only to exported layouts.
+	if c.DigestAlgorithm != "" {
+		if err = oci.ValidateDigestAlgorithm(c.DigestAlgorithm); err != nil {
+			return
+		}
+		if c.DigestAlgorithm != oci.DigestSHA256 && c.Bundle == "" && c.OCIOutput == "" {
+			return errors.New("the digest algorithm applies only to exported layouts: use --oci-output or --bundle")
+		}
+	}
+
+	if _, err = oci.ParseAnnotations(c.Annotations); err != nil {
+		return
+	}
+	if c.MediaType != "" {
+		if err = oci.ValidateMediaTypes(c.MediaType); err != nil {
+			return
+		}
+	}
+	if c.ArtifactType != "" {
+		if err = oci.ValidateArtifactType(c.ArtifactType); err != nil {
+			return
+		}
+		if c.MediaType == oci.MediaTypesDocker {
+			return errors.New("an artifact type requires the oci media types")
+		}
+	}
+	if _, err = oci.ParseForeignLayers(c.ForeignLayers); err != nil {
+		return
+	}
+	if _, err = oci.ParseRegistryMirrors(c.RegistryMirrors); err != nil {
+		return
+	}
+	if c.Squash != "" {
+		if err = oci.ValidateSquash(c.Squash); err != nil {
+			return
+		}
+		if c.Squash == oci.SquashAll && len(c.ForeignLayers) > 0 {
+			return errors.New("foreign layers may not be used when squashing the base image's layers")
+		}
+	}
+	if c.MaxLayers != 0 || c.MaxLayersFail {
+		if err = oci.ValidateMaxLayers(c.MaxLayers); err != nil {
+			return
+		}
+		if c.MaxLayers == 0 {
+			return errors.New("--max-layers-fail requires --max-layers")
+		}
+	}
+	if errs := fn.ValidateBuildTags(c.BuildTags); len(errs) > 0 {
+		return errors.New(errs[0])
+	}
+	if c.PGO != "" && c.PGO != oci.PGOOff {
+		if _, err = os.Stat(c.PGO); err != nil {
+			return fmt.Errorf("cannot use profile for PGO. %w", err)
+		}
+	}
+	if c.BuildVCS != "" {
+		if err = oci.ValidateBuildVCS(c.BuildVCS); err != nil {
+			return
+		}
+	}
+	if c.GoToolchain != "" {
+		if err = oci.ValidateGoToolchain(c.GoToolchain); err != nil {
+			return
+		}
+	}
+	if _, err = oci.ParseGoTokens(c.GoTokens); err != nil {
+		return
+	}
+	if c.GoNetRC != "" {
+		if _, err = os.Stat(c.GoNetRC); err != nil {
+			return fmt.Errorf("invalid netrc file. %w", err)
+		}
+	}
+	if c.Middleware != "" {
+		if errs := fn.ValidateMiddlewareVersion(c.Middleware); len(errs) > 0 {
+			return errors.New(errs[0])
+		}
+	}
+	if _, err = oci.ParseReplace(c.Replace); err != nil {
+		return
+	}
+	if _, err = oci.ParseSecrets(c.Secrets); err != nil {
+		return
+	}
+	if err = oci.ValidateScanSeverity(c.ScanSeverity); err != nil {
+		return
+	}
+
+	// The image is pushed, and queried, by the host builder's pusher
+	if len(c.PushRegistries) > 0 && !c.Push {
+		return errors.New("pushing to other registries (--push-registry) requires --push")
+	}
+	if c.PlatformTagSuffix {
+		if !c.Push {
+			return errors.New("a platform tag suffix (--platform-tag-suffix) requires --push")
+		}
+		if err = c.validateSinglePlatform(); err != nil {
+			return
+		}
+	}
+	if c.PushDryRun && c.Push {
+		return errors.New("--push-dry-run may not be used with --push")
+	}
+
+	if err = oci.ValidateSigning(c.SignKey, c.SignManifests); err != nil {
+		return
+	}
+	if err = oci.ValidateCABundle(c.CABundle); err != nil {
+		return
+	}
+	if err = oci.ValidateProxy(c.proxy()); err != nil {
+		return
+	}
+	if c.BaseImagePullPolicy != "" {
+		if err = oci.ValidatePullPolicy(c.BaseImagePullPolicy); err != nil {
+			return
+		}
+	}
+
+	// Warming the cache and rebasing are in place of building
+	if c.WarmCache && (c.Rebase || c.Watch || c.Push) {
+		return errors.New("--warm-cache may not be used with --rebase, --watch or --push")
+	}
+	if c.Rebase && c.Watch {
+		return errors.New("--rebase may not be used with --watch")
+	}
+	if c.Rebase && c.VerifyReproducible {
+		return errors.New("--rebase may not be used with --verify-reproducible")
+	}
+
+	if c.BuildConcurrency < 0 {
+		return fmt.Errorf("invalid build concurrency %v: must be at least 1", c.BuildConcurrency)
+	}
+
 	switch c.Builder {
 	case builders.Host:
 	case builders.Pack:
@@ -440,6 +1504,163 @@ func (c buildConfig) Validate(cmd *cobra.Command) (err error) {
 	return
 }
 
+// hostOnlySetting is a setting supported only by the host builder: its flag,
+// whether it is set, and what it is, as in "only host builds support {what}".
+type hostOnlySetting struct {
+	flag string
+	set  bool
+	what string
+}
+
+// hostOnlySettings of the config, those which only the host builder supports.
+func (c buildConfig) hostOnlySettings() []hostOnlySetting {
+	return []hostOnlySetting{
+		{"capability", len(c.Capabilities) > 0, "specifying capabilities"},
+		{"build-dir", c.BuildDir != "", "specifying the build directory"},
+		{"bundle", c.Bundle != "", "exporting a bundle"},
+		{"oci-output", c.OCIOutput != "", "writing the OCI layout to a directory"},
+		{"digest-algorithm", c.DigestAlgorithm != "", "specifying the digest algorithm"},
+		{"annotation", len(c.Annotations) > 0, "annotations"},
+		{"media-type", c.MediaType != "", "specifying the media type"},
+		{"artifact-type", c.ArtifactType != "", "an artifact type"},
+		{"foreign-layer", len(c.ForeignLayers) > 0, "foreign layers"},
+		{"registry-mirror", len(c.RegistryMirrors) > 0, "registry mirrors"},
+		{"squash", c.Squash != "", "squashing layers"},
+		{"max-layers", c.MaxLayers != 0, "limiting the number of layers"},
+		{"max-layers-fail", c.MaxLayersFail, "limiting the number of layers"},
+		{"build-concurrency", c.BuildConcurrency != 0, "limiting build concurrency"},
+		{"build-tag", len(c.BuildTags) > 0, "build tags"},
+		{"pgo", c.PGO != "", "profile-guided optimization"},
+		{"build-vcs", c.BuildVCS != "", "controlling VCS stamping"},
+		{"go-toolchain", c.GoToolchain != "", "pinning the go toolchain"},
+		{"go-proxy", c.GoProxy != "", "configuring go modules"},
+		{"go-private", c.GoPrivate != "", "configuring go modules"},
+		{"go-nosumdb", c.GoNoSumDB != "", "configuring go modules"},
+		{"go-flags", c.GoFlags != "", "configuring go modules"},
+		{"go-netrc", c.GoNetRC != "", "configuring go modules"},
+		{"go-token", len(c.GoTokens) > 0, "configuring go modules"},
+		{"middleware-version", c.Middleware != "", "pinning the middleware version"},
+		{"replace", len(c.Replace) > 0, "replacing go modules"},
+		{"secret", len(c.Secrets) > 0, "build secrets"},
+		{"scan", c.Scan != "", "scanning the image"},
+		{"scan-severity", c.ScanSeverity != "", "scanning the image"},
+		{"push-registry", len(c.PushRegistries) > 0, "pushing to other registries"},
+		{"platform-tag-suffix", c.PlatformTagSuffix, "a platform tag suffix"},
+		{"push-dry-run", c.PushDryRun, "a dry-run push"},
+		{"sign-key", c.SignKey != "", "signing the image"},
+		{"sign-manifests", c.SignManifests, "signing the image"},
+		{"checksums", c.Checksums, "writing checksums"},
+		{"keep-tars", c.KeepTars, "keeping layer tarballs"},
+		{"interactive", c.Interactive, "reporting failed builds"},
+		{"zero-timestamps", c.ZeroTimestamps, "zeroing timestamps"},
+		{"verify-reproducible", c.VerifyReproducible, "verifying reproducibility"},
+		{"ca-bundle", c.CABundle != "", "adding a CA bundle"},
+		{"http-proxy", c.HTTPProxy != "", "specifying a proxy"},
+		{"https-proxy", c.HTTPSProxy != "", "specifying a proxy"},
+		{"no-proxy", c.NoProxy != "", "specifying a proxy"},
+		{"build-info", c.BuildInfo, "writing build information"},
+		{"base-image-pull-policy", c.BaseImagePullPolicy != "", "a base image pull policy"},
+		{"warm-cache", c.WarmCache, "warming the cache"},
+		{"rebase", c.Rebase, "rebasing"},
+		{"without-source", c.WithoutSource, "omitting the source"},
+		{"strip-source", c.StripSource, "stripping the source"},
+	}
+}
+
+// proxy of the host builder, over that of the environment.
+func (c buildConfig) proxy() oci.Proxy {
+	return oci.Proxy{HTTP: c.HTTPProxy, HTTPS: c.HTTPSProxy, NoProxy: c.NoProxy}
+}
+
+// validateSinglePlatform ensures a single platform is built: that given
+// (--platform), else the one of the function (build.platforms), else the one
+// of the global config.  The defaults of the runtime are of several.
+func (c buildConfig) validateSinglePlatform() error {
+	if c.Platform != "" {
+		return nil
+	}
+	f, err := fn.NewFunction(c.Path)
+	if err != nil {
+		return err
+	}
+	n := len(f.Build.Platforms)
+	if n == 0 {
+		pp, err := c.DefaultPlatforms()
+		if err != nil {
+			return err
+		}
+		n = len(pp)
+	}
+	if n != 1 {
+		return errors.New("a platform tag suffix (--platform-tag-suffix) requires a single-platform build: use --platform")
+	}
+	return nil
+}
+
+// validateImage ensures the image, either explicit or as computed from the
+// registry and function name, is a valid image reference.  A missing registry
+// or name is not validated here.
+func (c buildConfig) validateImage() error {
+	if c.Image != "" {
+		if _, err := name.ParseReference(c.Image); err != nil {
+			return fmt.Errorf("%w (%w)", err, fn.ErrInvalidImage)
+		}
+		return nil
+	}
+	f, err := fn.NewFunction(c.Path)
+	if err != nil {
+		return err
+	}
+	if c.Registry != "" {
+		f.Registry = c.Registry
+	}
+	if _, err = f.ImageName(); err != nil && !errors.Is(err, fn.ErrRegistryRequired) && !errors.Is(err, fn.ErrNameRequired) {
+		return fmt.Errorf("%w (%w)", err, fn.ErrInvalidImage)
+	}
+	return nil
+}
+
+// validateImageRegistry returns ErrConflictingImageAndRegistry if the image
+// is not of the registry: its repository, less the function's name, is not
+// the registry (such as example.com/alice of example.com/alice/myfunc).
+func (c buildConfig) validateImageRegistry() error {
+	image, err := name.ParseReference(c.Image)
+	if err != nil {
+		return fmt.Errorf("%w (%w)", err, fn.ErrInvalidImage)
+	}
+	// The registry as the repository of a function of it, such that both are
+	// normalized alike (docker.io as index.docker.io)
+	registry, err := name.NewRepository(c.Registry + "/function")
+	if err != nil {
+		return fmt.Errorf("%w: invalid registry %q. %v", fn.ErrConflictingImageAndRegistry, c.Registry, err)
+	}
+	repo := image.Context()
+	if repo.RegistryStr() != registry.RegistryStr() || path.Dir(repo.RepositoryStr()) != path.Dir(registry.RepositoryStr()) {
+		return fmt.Errorf("%w: the image %v is not of the registry %v, to which it would not be pushed", fn.ErrConflictingImageAndRegistry, c.Image, c.Registry)
+	}
+	return nil
+}
+
+// explicitlySet returns true if the flag was given, or its environment
+// variable ($FUNC_<FLAG>) defined, rather than its value read from the
+// function or the global config.
+func explicitlySet(cmd *cobra.Command, flag string) bool {
+	return cmd.Flags().Changed(flag) || envDefined(flag)
+}
+
+// workDir returns the directory in which the host builder should place its
+// builds: that provided explicitly, the user's cache directory if external
+// builds are enabled, or empty for the function's .func directory.
+func (c buildConfig) workDir() string {
+	if c.BuildDir != "" {
+		return c.BuildDir
+	}
+	if c.ExternalBuilds {
+		return config.CacheDir()
+	}
+	return ""
+}
+
 // clientOptions returns options suitable for instantiating a client based on
 // the current state of the build config object.
 // This will be unnecessary and refactored away when the host-based OCI
@@ -451,10 +1672,6 @@ func (c buildConfig) Validate(cmd *cobra.Command) (err error) {
 // supports multi-arch/platform images), and throw an error if either trying
 // to specify a platform for buildpacks, or trying to specify more than one
 // for S2I.
-//
-// TODO: As a further optimization, it might be ideal to only build the
-// image necessary for the target cluster, since the end product of  a function
-// deployment is not the contiainer, but rather the running service.
 
 // clientOptions 根据构建配置对象的当前状态返回适合实例化客户端的选项。
 func (c buildConfig) clientOptions() ([]fn.Option, error) {
@@ -462,14 +1679,87 @@ func (c buildConfig) clientOptions() ([]fn.Option, error) {
 	switch c.Builder {
 	case builders.Host:
 		// host构建器,使用标准OCI构建器,支持go和py。
+		annotations, err := oci.ParseAnnotations(c.Annotations)
+		if err != nil {
+			return o, err
+		}
+		foreignLayers, err := oci.ParseForeignLayers(c.ForeignLayers)
+		if err != nil {
+			return o, err
+		}
+		mirrors, err := oci.ParseRegistryMirrors(c.RegistryMirrors)
+		if err != nil {
+			return o, err
+		}
+		replace, err := oci.ParseReplace(c.Replace)
+		if err != nil {
+			return o, err
+		}
+		goTokens, err := oci.ParseGoTokens(c.GoTokens)
+		if err != nil {
+			return o, err
+		}
+		secrets, err := oci.ParseSecrets(c.Secrets)
+		if err != nil {
+			return o, err
+		}
+		platforms, err := c.DefaultPlatforms()
+		if err != nil {
+			return o, err
+		}
 		t := newTransport(c.RegistryInsecure) // may provide a custom impl which proxies
-		creds := newCredentialsProvider(config.Dir(), t)
+		creds := newCredentialsProvider(config.Dir(), c.DockerConfig, t)
 		o = append(o,
-			fn.WithBuilder(oci.NewBuilder(builders.Host, c.Verbose)),
+			fn.WithBuilder(oci.NewBuilder(builders.Host, c.Verbose,
+				oci.WithCapabilities(c.Capabilities...),
+				oci.WithQuiet(c.Quiet),
+				oci.WithWorkDir(c.workDir()),
+				oci.WithBundle(c.Bundle),
+				oci.WithOCIOutput(c.OCIOutput),
+				oci.WithDigestAlgorithm(c.DigestAlgorithm),
+				oci.WithAnnotations(annotations),
+				oci.WithMediaTypes(c.MediaType),
+				oci.WithArtifactType(c.ArtifactType),
+				oci.WithForeignLayers(foreignLayers),
+				oci.WithSquash(c.Squash),
+				oci.WithMaxLayers(c.MaxLayers, c.MaxLayersFail),
+				oci.WithConcurrency(c.BuildConcurrency),
+				oci.WithBuildTags(c.BuildTags...),
+				oci.WithPGO(c.PGO),
+				oci.WithBuildVCS(c.BuildVCS),
+				oci.WithGoToolchain(c.GoToolchain),
+				oci.WithGoModules(oci.GoModules{
+					Proxy:   c.GoProxy,
+					Private: c.GoPrivate,
+					NoSumDB: c.GoNoSumDB,
+					Flags:   c.GoFlags,
+					NetRC:   c.GoNetRC,
+					Tokens:  goTokens,
+				}),
+				oci.WithMiddlewareVersion(c.Middleware),
+				oci.WithReplace(replace),
+				oci.WithSecrets(secrets),
+				oci.WithScan(c.Scan, c.ScanSeverity),
+				oci.WithChecksums(c.Checksums),
+				oci.WithSigning(c.SignKey, c.SignManifests),
+				oci.WithoutSource(c.WithoutSource),
+				oci.WithStripSource(c.StripSource),
+				oci.WithKeepTars(c.KeepTars),
+				oci.WithInspectFailure(c.Interactive),
+				oci.WithZeroTimestamps(c.ZeroTimestamps),
+				oci.WithVerifyReproducible(c.VerifyReproducible),
+				oci.WithDefaultPlatforms(platforms),
+				oci.WithCABundle(c.CABundle),
+				oci.WithProxy(c.proxy()),
+				oci.WithBuildInfo(c.BuildInfo),
+				oci.WithBasePullPolicy(c.BaseImagePullPolicy),
+				oci.WithDockerConfig(c.DockerConfig),
+				oci.WithRegistryMirrors(mirrors))),
 			fn.WithPusher(oci.NewPusher(c.RegistryInsecure, false, c.Verbose,
 				oci.WithTransport(newTransport(c.RegistryInsecure)),
 				oci.WithCredentialsProvider(creds),
-				oci.WithVerbose(c.Verbose))),
+				oci.WithVerbose(c.Verbose),
+				oci.WithPlatformTagSuffix(c.PlatformTagSuffix))),
 		)
 	case builders.Pack:
 		// pack构建器,使用Buildpacks构建器,支持nodejs,typescript,go,python,quarkus,rust,springboot,但是需要docker或者podman
@@ -499,13 +1789,15 @@ func (c buildConfig) buildOptions() (oo []fn.BuildOption, err error) {
 	// Pack 构建器：不支持多平台（无）
 	// S2I 构建器：支持单平台（一个）
 	// Host 构建器：支持多平台（多个）
+	// 变体规范化为 arm+v7 等形式,见 fn.NormalizePlatform
 	if c.Platform != "" {
-		parts := strings.Split(c.Platform, "/")
-		if len(parts) != 2 {
-			return oo, fmt.Errorf("the value for --patform must be in the form [OS]/[Architecture].  eg \"linux/amd64\"")
+		p, err := fn.ParsePlatform(c.Platform)
+		if err != nil {
+			return oo, fmt.Errorf("invalid --platform. %w", err)
 		}
-		oo = append(oo, fn.BuildWithPlatforms([]fn.Platform{{OS: parts[0], Architecture: parts[1]}}))
+		oo = append(oo, fn.BuildWithPlatforms([]fn.Platform{p}))
 	}
+	oo = append(oo, fn.BuildWithQuiet(c.Quiet))
 
 	return
 }
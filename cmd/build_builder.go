@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+	"knative.dev/func/pkg/builders"
+	"knative.dev/func/pkg/config"
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci"
+)
+
+// dockerOrPodmanInstalled is DockerOrPodmanInstalled, a variable such that
+// tests may stub the detection of a container runtime.
+var dockerOrPodmanInstalled = DockerOrPodmanInstalled
+
+// DockerOrPodmanInstalled returns true if a container runtime, podman or
+// docker, with which the pack and s2i builders build, is installed.
+// 容器运行时支持 podman或者docker
+func DockerOrPodmanInstalled() bool {
+	_, err := exec.LookPath("podman")
+	if err == nil {
+		return true
+	}
+	_, err = exec.LookPath("docker")
+	return err == nil
+}
+
+// selectBuilder chooses the host builder when no builder is configured, no
+// container runtime is installed and the function's runtime is supported by
+// the host builder, which requires neither Docker nor Podman.  Otherwise the
+// builder is that configured or the default (builders.Default), with which a
+// build requires a container runtime.  A builder is configured by --builder
+// ($FUNC_BUILDER), the function (build.builder), the build profile or the
+// global config.
+func (c buildConfig) selectBuilder(cmd *cobra.Command) (buildConfig, error) {
+	if c.Builder != builders.Default || cmd.Flags().Changed("builder") || os.Getenv("FUNC_BUILDER") != "" {
+		return c, nil
+	}
+	f, err := fn.NewFunction(c.Path)
+	if err != nil {
+		return c, err
+	}
+	if f.Build.Builder != "" || !oci.IsSupported(f.Runtime) {
+		return c, nil
+	}
+	// The profile is applied after (see applyProfile), so its builder is
+	// looked up here.
+	if c.Profile != "" {
+		p, err := c.profile(f)
+		if err != nil {
+			return c, err
+		}
+		if p.Builder != "" {
+			return c, nil
+		}
+	}
+	if global, err := config.Load(config.File()); err == nil && global.Builder != "" {
+		return c, nil
+	}
+	if dockerOrPodmanInstalled() {
+		return c, nil
+	}
+	c.Builder = builders.Host
+	if !c.Quiet {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Neither Docker nor Podman is installed: building the %v function with the host builder, which requires neither.  Use --builder to choose another.\n", f.Runtime)
+	}
+	return c, nil
+}
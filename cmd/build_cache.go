@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/spf13/cobra"
+	"knative.dev/func/pkg/builders"
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci"
+)
+
+// runBuildCache reports on (--cache-info) or purges (--cache-clear) the blob
+// caches of the host builder rather than building.  These are the shared
+// base layer cache and, for an initialized function, the function's own.
+func runBuildCache(cmd *cobra.Command, cfg buildConfig) (err error) {
+	f, err := fn.NewFunction(cfg.Path)
+	if err != nil {
+		return
+	}
+	if !f.Initialized() {
+		f = fn.Function{} // only the shared cache
+	}
+	builder := oci.NewBuilder(builders.Host, cfg.Verbose, oci.WithWorkDir(cfg.workDir()))
+
+	if cfg.CacheClear {
+		if err = builder.ClearCache(f); err != nil {
+			return fmt.Errorf("error clearing the build cache. %w", err)
+		}
+		if !cfg.CacheInfo {
+			return
+		}
+	}
+
+	caches, err := builder.CacheInfo(f)
+	if err != nil {
+		return fmt.Errorf("error reading the build cache. %w", err)
+	}
+	return writeCacheInfo(cmd.OutOrStdout(), caches)
+}
+
+// runBuildClean removes all builds of the function (--clean), and with
+// --cache-clear the blobs of the caches, rather than building.  In an
+// interactive terminal the removal is first confirmed.
+func runBuildClean(cmd *cobra.Command, cfg buildConfig) (err error) {
+	f, err := fn.NewFunction(cfg.Path)
+	if err != nil {
+		return
+	}
+	if !f.Initialized() {
+		return fn.NewErrNotInitialized(f.Root)
+	}
+	if interactiveTerminal() {
+		ok, err := confirmClean(cfg.CacheClear)
+		if err != nil || !ok {
+			return err
+		}
+	}
+	builder := oci.NewBuilder(builders.Host, cfg.Verbose, oci.WithWorkDir(cfg.workDir()))
+	if err = builder.Clean(f); err != nil {
+		return fmt.Errorf("error removing the builds. %w", err)
+	}
+	if cfg.CacheClear {
+		if err = builder.ClearCache(f); err != nil {
+			return fmt.Errorf("error clearing the build cache. %w", err)
+		}
+	}
+	if !cfg.Quiet {
+		fmt.Fprintf(cmd.OutOrStdout(), "Removed the builds of %v\n", f.Root)
+	}
+	return
+}
+
+// confirmClean asks whether to remove the builds, and the caches if cache.
+var confirmClean = func(cache bool) (ok bool, err error) {
+	message := "Remove all builds of the function?"
+	if cache {
+		message = "Remove all builds of the function and the blobs of the build caches?"
+	}
+	err = survey.AskOne(&survey.Confirm{Message: message, Default: true}, &ok)
+	return
+}
+
+// cacheWarmer is a builder which can download the layers of a function's
+// base image into its blob cache without building, such as the host builder.
+type cacheWarmer interface {
+	WarmCache(ctx context.Context, f fn.Function, pp []fn.Platform) error
+}
+
+// warmCache downloads the layers of the function's base image for each
+// platform into the builder's blob cache (--warm-cache), in place of
+// building.
+func (c buildConfig) warmCache(cmd *cobra.Command, client *fn.Client, f fn.Function, buildOptions []fn.BuildOption) error {
+	w, ok := client.Builder().(cacheWarmer)
+	if !ok {
+		return errors.New("the builder does not support warming the cache")
+	}
+	options := fn.BuildOptions{}
+	for _, o := range buildOptions {
+		o(&options)
+	}
+	if err := w.WarmCache(cmd.Context(), f, options.Platforms); err != nil {
+		return fmt.Errorf("error warming the build cache. %w", err)
+	}
+	return nil
+}
+
+// writeCacheInfo writes a summary of each cache followed by its blobs.
+func writeCacheInfo(w io.Writer, caches []oci.CacheInfo) error {
+	if len(caches) == 0 {
+		fmt.Fprintln(w, "No build cache found")
+		return nil
+	}
+	for i, c := range caches {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Cache: %v\nBlobs: %v\nSize:  %v\n", c.Dir, len(c.Blobs), byteSize(c.Size))
+		if len(c.Blobs) == 0 {
+			continue
+		}
+		fmt.Fprintln(w)
+
+		// minwidth, tabwidth, padding, padchar, flags
+		tabWriter := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\n", "DIGEST", "SIZE", "LAST USED")
+		for _, b := range c.Blobs {
+			fmt.Fprintf(tabWriter, "%s\t%s\t%s\n", b.Digest, byteSize(b.Size), b.LastUsed.Format(time.RFC3339))
+		}
+		if err := tabWriter.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// byteSize formats a number of bytes in binary units, eg. "1.5 MiB".
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"knative.dev/func/pkg/builders"
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci"
+)
+
+// runBuildFingerprint prints the fingerprint of the function's source and the
+// host builder's build directory for it (--print-fingerprint) rather than
+// building, such that builds and caches can be correlated.  When quiet, only
+// the fingerprint is printed.
+func runBuildFingerprint(cmd *cobra.Command, cfg buildConfig) (err error) {
+	f, err := fn.NewFunction(cfg.Path)
+	if err != nil {
+		return
+	}
+	if !f.Initialized() {
+		return fn.NewErrNotInitialized(f.Root)
+	}
+	builder := oci.NewBuilder(builders.Host, cfg.Verbose, oci.WithWorkDir(cfg.workDir()))
+	hash, dir, err := builder.Fingerprint(f)
+	if err != nil {
+		return
+	}
+	if cfg.Quiet {
+		fmt.Fprintln(cmd.OutOrStdout(), hash)
+		return
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Fingerprint: %v\nBuild directory: %v\n", hash, dir)
+	return
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	giturls "github.com/chainguard-dev/git-urls"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/spf13/cobra"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// parseGitSource splits a git build source of the form URL[@ref] into its
+// repository URL and optional ref (branch, tag or commit).  The ref separator
+// is the last '@' within the path of the URL such that user information
+// (https://user@host/repo or git@host:repo) is not mistaken for a ref.
+func parseGitSource(source string) (url, ref string, err error) {
+	url = source
+	start := 0 // index at which the URL's path begins
+	if i := strings.Index(source, "://"); i >= 0 {
+		if j := strings.Index(source[i+3:], "/"); j >= 0 {
+			start = i + 3 + j
+		}
+	} else if i := strings.Index(source, ":"); i >= 0 {
+		start = i
+	}
+	if i := strings.LastIndex(source[start:], "@"); i >= 0 {
+		url, ref = source[:start+i], source[start+i+1:]
+		if ref == "" {
+			return url, ref, fmt.Errorf("git source %q has an empty ref", source)
+		}
+		if err = plumbing.NewBranchReferenceName(ref).Validate(); err != nil {
+			return url, ref, fmt.Errorf("git source %q has an invalid ref %q: %w", source, ref, err)
+		}
+	}
+	if _, err = giturls.ParseTransport(url); err != nil {
+		if _, err = giturls.ParseScp(url); err != nil {
+			return url, ref, fmt.Errorf("git source %q is not a valid repository URL", source)
+		}
+	}
+	return
+}
+
+// cloneGitSource clones the repository at url into a new temporary directory
+// and checks out ref, returning the directory.  Branches and tags are cloned
+// shallowly; any other ref is treated as a commit, which requires a full
+// clone.  The caller is responsible for removing the directory.
+func cloneGitSource(ctx context.Context, url, ref string, auth transport.AuthMethod, verbose bool) (dir string, err error) {
+	clone := func(refName plumbing.ReferenceName, shallow bool) (*git.Repository, error) {
+		var err error
+		if dir != "" {
+			_ = os.RemoveAll(dir)
+		}
+		if dir, err = os.MkdirTemp("", "func-build-git"); err != nil {
+			return nil, err
+		}
+		opts := &git.CloneOptions{
+			URL:               url,
+			Auth:              auth,
+			ReferenceName:     refName,
+			RecurseSubmodules: git.NoRecurseSubmodules,
+		}
+		if shallow {
+			opts.Depth = 1
+			opts.SingleBranch = true
+			opts.Tags = git.NoTags
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "git clone %v %v (ref: %q, shallow: %v)\n", url, dir, refName, shallow)
+		}
+		return git.PlainCloneContext(ctx, dir, false, opts)
+	}
+	defer func() {
+		if err != nil && dir != "" {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+
+	// Default branch
+	if ref == "" {
+		if _, err = clone("", true); err != nil {
+			return dir, fmt.Errorf("failed to clone %v: %w", url, err)
+		}
+		return
+	}
+
+	// Branch, then tag
+	for _, refName := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewTagReferenceName(ref),
+	} {
+		_, err = clone(refName, true)
+		if err == nil {
+			return
+		}
+		if !errors.Is(err, git.NoMatchingRefSpecError{}) && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return dir, fmt.Errorf("failed to clone %v: %w", url, err)
+		}
+	}
+
+	// Commit
+	repo, err := clone("", false)
+	if err != nil {
+		return dir, fmt.Errorf("failed to clone %v: %w", url, err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return dir, fmt.Errorf("ref %q not found in %v: %w", ref, url, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return
+	}
+	if err = wt.Checkout(&git.CheckoutOptions{Hash: *hash}); err != nil {
+		return dir, fmt.Errorf("failed to checkout %v: %w", ref, err)
+	}
+	return
+}
+
+// gitSourceAuth returns the authentication to use when cloning the git build
+// source at url: basic auth of the git credentials (--git-username and
+// --git-password), a password alone being a token, for an http(s) URL.  It is
+// nil (anonymous) otherwise, such that an ssh URL is cloned with the ssh
+// agent.  The credentials of the registry (--username, --password and
+// --token) are never sent to the git host.
+func gitSourceAuth(url string, cfg buildConfig) transport.AuthMethod {
+	if cfg.GitUsername == "" && cfg.GitPassword == "" {
+		return nil
+	}
+	u, err := giturls.ParseTransport(url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	if cfg.GitUsername == "" {
+		return &http.BasicAuth{Username: "oauth2", Password: cfg.GitPassword}
+	}
+	return &http.BasicAuth{Username: cfg.GitUsername, Password: cfg.GitPassword}
+}
+
+// gitSourcePath returns the path to the function within a cloned git build
+// source, where path (--path) is relative to the repository root.
+func gitSourcePath(dir, path string) (string, error) {
+	if path == "" {
+		return dir, nil
+	}
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("when building from git, the path %q must be relative to, and within, the repository", path)
+	}
+	return filepath.Join(dir, path), nil
+}
+
+// useGitSource clones the git build source into a temporary directory and
+// updates the config to build the function it contains, returning the
+// directory for removal by the caller.  Values which were not explicitly
+// provided as flags are taken from the cloned function rather than from the
+// function (if any) in the current working directory.
+func (c *buildConfig) useGitSource(cmd *cobra.Command, source string) (dir string, err error) {
+	url, ref, err := parseGitSource(source)
+	if err != nil {
+		return
+	}
+	if dir, err = cloneGitSource(cmd.Context(), url, ref, gitSourceAuth(url, *c), c.Verbose); err != nil {
+		return
+	}
+	if c.Path, err = gitSourcePath(dir, c.Path); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	f, err := fn.NewFunction(c.Path)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	if !cmd.Flags().Changed("image") {
+		c.Image = f.Image
+	}
+	if !cmd.Flags().Changed("base-image") {
+		c.BaseImage = f.Build.BaseImage
+	}
+	if !cmd.Flags().Changed("builder-image") {
+		c.BuilderImage = f.Build.BuilderImages[f.Build.Builder]
+	}
+	return
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// TestBuild_ParseGitSource ensures the git build source is split into its
+// repository URL and ref, and that invalid sources are rejected.
+func TestBuild_ParseGitSource(t *testing.T) {
+	tests := []struct {
+		source string
+		url    string
+		ref    string
+		err    bool
+	}{
+		{source: "https://example.com/alice/repo", url: "https://example.com/alice/repo"},
+		{source: "https://example.com/alice/repo@main", url: "https://example.com/alice/repo", ref: "main"},
+		{source: "https://example.com/alice/repo.git@v1.0.0", url: "https://example.com/alice/repo.git", ref: "v1.0.0"},
+		{source: "https://bob@example.com/alice/repo@feature/x", url: "https://bob@example.com/alice/repo", ref: "feature/x"},
+		{source: "https://bob@example.com/alice/repo", url: "https://bob@example.com/alice/repo"},
+		{source: "git@example.com:alice/repo.git", url: "git@example.com:alice/repo.git"},
+		{source: "git@example.com:alice/repo.git@0a1b2c3", url: "git@example.com:alice/repo.git", ref: "0a1b2c3"},
+		{source: "https://example.com/alice/repo@", err: true},
+		{source: "https://example.com/alice/repo@bad..ref", err: true},
+		{source: "https://example.com/alice/repo@bad ref", err: true},
+		{source: "not a url", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			url, ref, err := parseGitSource(tt.source)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got url %q ref %q", url, ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if url != tt.url || ref != tt.ref {
+				t.Fatalf("expected url %q ref %q, got url %q ref %q", tt.url, tt.ref, url, ref)
+			}
+		})
+	}
+}
+
+// TestBuild_GitSourceAuth ensures the git credentials, and never those of the
+// registry, authenticate clones over http(s), and that ssh sources are cloned
+// without basic auth such that the ssh agent is used.
+func TestBuild_GitSourceAuth(t *testing.T) {
+	registry := buildConfig{Username: "alice", Password: "registry-secret", Token: "registry-token"}
+	git := registry
+	git.GitUsername, git.GitPassword = "bob", "git-secret"
+	token := registry
+	token.GitPassword = "git-token"
+
+	tests := []struct {
+		name string
+		url  string
+		cfg  buildConfig
+		auth transport.AuthMethod
+	}{
+		{"registry credentials", "https://example.com/alice/repo", registry, nil},
+		{"git credentials", "https://example.com/alice/repo", git, &http.BasicAuth{Username: "bob", Password: "git-secret"}},
+		{"git token", "http://example.com/alice/repo", token, &http.BasicAuth{Username: "oauth2", Password: "git-token"}},
+		{"ssh", "ssh://git@example.com/alice/repo.git", git, nil},
+		{"scp", "git@example.com:alice/repo.git", git, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if auth := gitSourceAuth(tt.url, tt.cfg); !reflect.DeepEqual(auth, tt.auth) {
+				t.Fatalf("expected auth %v, got %v", tt.auth, auth)
+			}
+		})
+	}
+}
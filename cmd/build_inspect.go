@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"knative.dev/func/pkg/builders"
+	"knative.dev/func/pkg/config"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Sources of an effective build configuration value, from highest to lowest
+// precedence.
+const (
+	sourceFlag     = "flag"
+	sourceProfile  = "profile"
+	sourceEnv      = "env"
+	sourceFunction = "func.yaml"
+	sourceGlobal   = "global config"
+	sourceComputed = "computed"
+	sourceDefault  = "default"
+)
+
+// inspect writes the effective build configuration, as it would be used to
+// build the function, along with the source of each value, without building.
+// The config before the profile was applied (pre) is used to identify values
+// from the profile.
+func (c buildConfig) inspect(cmd *cobra.Command, pre buildConfig) (err error) {
+	f, err := fn.NewFunction(c.Path)
+	if err != nil {
+		return
+	}
+	if !f.Initialized() {
+		return fn.NewErrNotInitialized(f.Root)
+	}
+	// The global config file exactly as it exists, without static defaults
+	global, _ := config.Load(config.File())
+
+	// source of the value of the given flag, where fromFunction and
+	// fromGlobal indicate the value was defined in func.yaml or the global
+	// config respectively.
+	source := func(flag string, profiled, fromFunction, fromGlobal bool) string {
+		switch {
+		case cmd.Flags().Changed(flag):
+			return sourceFlag
+		case profiled:
+			return sourceProfile
+		case envDefined(flag):
+			return sourceEnv
+		case fromFunction:
+			return sourceFunction
+		case fromGlobal:
+			return sourceGlobal
+		}
+		return sourceDefault
+	}
+
+	var (
+		builderSource = source("builder", pre.Builder != c.Builder,
+			f.Build.Builder != "", global.Builder != "")
+		registrySource = source("registry", pre.Registry != c.Registry,
+			f.Registry != "", global.Registry != "")
+		builderImageSource = source("builder-image", pre.BuilderImage != c.BuilderImage,
+			f.Build.BuilderImages[c.Builder] != "", false)
+		baseImageSource = source("base-image", pre.BaseImage != c.BaseImage,
+			f.Build.BaseImage != "", false)
+		imageSource = source("image", false, f.Image != "", false)
+		pushSource  = source("push", false, false, false)
+	)
+
+	// The image is computed from the registry and name unless explicit
+	f = c.Configure(f)
+	image := f.Image
+	if image == "" {
+		if image, err = f.ImageName(); err != nil {
+			image = fmt.Sprintf("(%v)", err)
+		}
+		imageSource = sourceComputed
+	}
+
+	// The host builder builds those of func.yaml, else of the global config
+	// (or FUNC_PLATFORMS), else its defaults
+	platforms, platformSource := c.Platform, source("platform", false, false, false)
+	if platforms == "" && c.Builder == builders.Host {
+		switch {
+		case len(f.Build.Platforms) > 0:
+			platforms, platformSource = strings.Join(f.Build.Platforms, ","), sourceFunction
+		case c.Platforms != "":
+			platforms, platformSource = c.Platforms, source("platforms", false, false, true)
+		default:
+			pp := []string{}
+			for _, p := range fn.DefaultPlatforms {
+				pp = append(pp, p.OS+"/"+p.Architecture)
+			}
+			platforms = strings.Join(pp, ",")
+		}
+	}
+
+	// minwidth, tabwidth, padding, padchar, flags
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\n", "SETTING", "VALUE", "SOURCE")
+	fmt.Fprintf(w, "%s\t%s\t%s\n", "builder", c.Builder, builderSource)
+	fmt.Fprintf(w, "%s\t%s\t%s\n", "registry", c.Registry, registrySource)
+	fmt.Fprintf(w, "%s\t%s\t%s\n", "image", image, imageSource)
+	fmt.Fprintf(w, "%s\t%s\t%s\n", "builder-image", c.BuilderImage, builderImageSource)
+	fmt.Fprintf(w, "%s\t%s\t%s\n", "base-image", c.BaseImage, baseImageSource)
+	fmt.Fprintf(w, "%s\t%s\t%s\n", "platforms", platforms, platformSource)
+	fmt.Fprintf(w, "%s\t%t\t%s\n", "push", c.Push, pushSource)
+	return w.Flush()
+}
+
+// envDefined returns true if the environment variable of the given flag
+// ($FUNC_<FLAG>) is defined.
+func envDefined(flag string) bool {
+	_, ok := os.LookupEnv("FUNC_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_")))
+	return ok
+}
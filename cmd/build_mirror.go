@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// mirrorPusher is a pusher which can push the function's image, once pushed,
+// to other images, such as the host builder's.
+type mirrorPusher interface {
+	Mirror(ctx context.Context, f fn.Function, image string) (digest string, err error)
+}
+
+// mirrorResult is the result of pushing to a registry of --push-registry.
+type mirrorResult struct {
+	Image  string
+	Digest string
+	Err    error
+}
+
+// mirror the pushed function's image to each registry of --push-registry,
+// reporting each.  All are attempted, such that one failing does not
+// prevent the others.
+func (c buildConfig) mirror(cmd *cobra.Command, client *fn.Client, f fn.Function) (results []mirrorResult) {
+	p, ok := client.Pusher().(mirrorPusher)
+	for _, registry := range c.PushRegistries {
+		var r mirrorResult
+		r.Image, r.Err = fn.Function{Registry: registry, Name: f.Name}.ImageName()
+		if r.Err == nil {
+			if ok {
+				r.Digest, r.Err = p.Mirror(cmd.Context(), f, r.Image)
+			} else {
+				r.Err = fmt.Errorf("the pusher can not push to other registries")
+			}
+		}
+		if r.Err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Failed to push to %v. %v\n", registry, r.Err)
+		} else if !c.Quiet {
+			fmt.Fprintf(cmd.OutOrStdout(), "Pushed to %v\n", r.Image)
+		}
+		results = append(results, r)
+	}
+	return
+}
+
+// mirrorsError returns an error if pushing to any registry failed.
+func mirrorsError(results []mirrorResult) error {
+	var failed int
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to push to %v of %v registries (--push-registry)", failed, len(results))
+	}
+	return nil
+}
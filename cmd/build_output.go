@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci"
+)
+
+// buildOutput is the result of a build as written with --output json.  The
+// digests, layers and timings are those reported by the builder, and are
+// omitted for builders which do not (only the host builder does).
+type buildOutput struct {
+	Image       string              `json:"image"`
+	Pushed      bool                `json:"pushed"`
+	Digest      string              `json:"digest,omitempty"`
+	Fingerprint string              `json:"fingerprint,omitempty"`
+	Platforms   []buildOutputImage  `json:"platforms,omitempty"`
+	Timings     []buildOutputTiming `json:"timings,omitempty"`
+	Seconds     float64             `json:"seconds,omitempty"`
+	Mirrors     []buildOutputMirror `json:"mirrors,omitempty"`
+	DryRun      *buildOutputDryRun  `json:"dryRun,omitempty"`
+}
+
+// buildOutputDryRun is what pushing the image would do (--push-dry-run):
+// nothing if up-to-date, or otherwise upload the blobs.
+type buildOutputDryRun struct {
+	UpToDate bool                  `json:"upToDate"`
+	Remote   string                `json:"remote,omitempty"`
+	Blobs    []buildOutputPushBlob `json:"blobs"`
+}
+
+// buildOutputPushBlob is a blob which pushing would upload.
+type buildOutputPushBlob struct {
+	Platform string `json:"platform"`
+	Digest   string `json:"digest"`
+	Size     int64  `json:"size"`
+	Config   bool   `json:"config,omitempty"`
+}
+
+// buildOutputMirror is the result of pushing the image to another registry
+// (--push-registry): its digest if pushed, or the error.
+type buildOutputMirror struct {
+	Image  string `json:"image"`
+	Pushed bool   `json:"pushed"`
+	Digest string `json:"digest,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// buildOutputImage is the image built for a platform.
+type buildOutputImage struct {
+	Platform string             `json:"platform"`
+	Digest   string             `json:"digest"`
+	Size     int64              `json:"size"`
+	Layers   []buildOutputLayer `json:"layers"`
+}
+
+// buildOutputLayer is a layer of an image, by compressed size in bytes.
+type buildOutputLayer struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// buildOutputTiming is the duration of a phase of the build.
+type buildOutputTiming struct {
+	Phase   string  `json:"phase"`
+	Seconds float64 `json:"seconds"`
+	PeakRSS int64   `json:"peakRSS,omitempty"`
+}
+
+// resultBuilder is a builder which reports the result of its last build,
+// such as the host builder.
+type resultBuilder interface {
+	Result() oci.BuildResult
+}
+
+// newBuildOutput of the built function, which is pushed if the timing of its
+// push is provided, and to the other registries of the mirrors, or of which
+// a push was planned if the plan is provided.
+func newBuildOutput(client *fn.Client, f fn.Function, push *oci.PhaseTiming, mirrors []mirrorResult, plan *oci.PushPlan) (o buildOutput) {
+	o = buildOutput{Image: f.Build.Image, Pushed: push != nil}
+	var timings oci.Timings
+	if b, ok := client.Builder().(resultBuilder); ok {
+		r := b.Result()
+		if r.Index.Hex != "" {
+			o.Digest = r.Index.String()
+		}
+		o.Fingerprint = r.Fingerprint
+		for _, image := range r.Images {
+			oi := buildOutputImage{Platform: image.Platform, Digest: image.Digest.String(), Layers: []buildOutputLayer{}}
+			for _, l := range image.Layers {
+				oi.Layers = append(oi.Layers, buildOutputLayer{Digest: l.Digest.String(), Size: l.Size})
+				oi.Size += l.Size
+			}
+			o.Platforms = append(o.Platforms, oi)
+		}
+		timings = r.Timings
+		o.Seconds = r.Duration.Seconds()
+	}
+	if push != nil {
+		timings = append(timings, *push)
+		o.Seconds += push.Duration.Seconds()
+	}
+	for _, t := range timings {
+		o.Timings = append(o.Timings, buildOutputTiming{Phase: t.Phase, Seconds: t.Duration.Seconds(), PeakRSS: t.PeakRSS})
+	}
+	for _, m := range mirrors {
+		om := buildOutputMirror{Image: m.Image, Pushed: m.Err == nil, Digest: m.Digest}
+		if m.Err != nil {
+			om.Error = m.Err.Error()
+		}
+		o.Mirrors = append(o.Mirrors, om)
+	}
+	if plan != nil {
+		o.DryRun = &buildOutputDryRun{UpToDate: plan.UpToDate(), Blobs: []buildOutputPushBlob{}}
+		if plan.Remote.Hex != "" {
+			o.DryRun.Remote = plan.Remote.String()
+		}
+		for _, b := range plan.Blobs {
+			o.DryRun.Blobs = append(o.DryRun.Blobs, buildOutputPushBlob{Platform: b.Platform, Digest: b.Digest.String(), Size: b.Size, Config: b.Config})
+		}
+	}
+	return
+}
+
+// writeBuildOutput as indented JSON.
+func writeBuildOutput(w io.Writer, o buildOutput) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(o)
+}
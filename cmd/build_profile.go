@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"knative.dev/func/pkg/config"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// applyProfile layers the selected build profile (--profile) over the build
+// config.  The profile is looked up first in the function's func.yaml and then
+// in the global config.  Values of the profile are applied unless the
+// corresponding flag was explicitly provided.
+func (c buildConfig) applyProfile(cmd *cobra.Command) (buildConfig, error) {
+	if c.Profile == "" {
+		return c, nil
+	}
+	f, err := fn.NewFunction(c.Path)
+	if err != nil {
+		return c, err
+	}
+	p, err := c.profile(f)
+	if err != nil {
+		return c, err
+	}
+
+	set := func(flag string, dst *string, val string) {
+		if val != "" && !cmd.Flags().Changed(flag) {
+			*dst = val
+		}
+	}
+	set("registry", &c.Registry, p.Registry)
+	set("builder", &c.Builder, p.Builder)
+	set("builder-image", &c.BuilderImage, p.BuilderImage)
+	set("base-image", &c.BaseImage, p.BaseImage)
+	c.Labels = p.Labels
+	return c, nil
+}
+
+// profile returns the selected build profile of the function, looked up first
+// in its func.yaml and then in the global config.
+func (c buildConfig) profile(f fn.Function) (p fn.BuildProfile, err error) {
+	p, ok := f.Build.Profiles[c.Profile]
+	if !ok {
+		if p, ok, err = config.Profile(config.File(), c.Profile); err != nil {
+			return
+		}
+	}
+	if !ok {
+		err = fmt.Errorf("build profile %q not found in func.yaml or the global config (%v)", c.Profile, config.File())
+	}
+	return
+}
+
+// mergeLabels returns labels with each of overrides added, replacing any
+// label of the same key.
+func mergeLabels(labels, overrides []fn.Label) []fn.Label {
+	for _, o := range overrides {
+		if o.Key == nil {
+			continue
+		}
+		replaced := false
+		for i, l := range labels {
+			if l.Key != nil && *l.Key == *o.Key {
+				labels[i] = o
+				replaced = true
+			}
+		}
+		if !replaced {
+			labels = append(labels, o)
+		}
+	}
+	return labels
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci"
+)
+
+// planPusher is a pusher which can report what pushing would upload without
+// pushing, such as the host builder's.
+type planPusher interface {
+	Plan(ctx context.Context, f fn.Function) (oci.PushPlan, error)
+}
+
+// planPush reports whether the built function's image in the registry is
+// up-to-date, or otherwise the blobs which pushing it would upload
+// (--push-dry-run).
+func (c buildConfig) planPush(cmd *cobra.Command, client *fn.Client, f fn.Function) (*oci.PushPlan, error) {
+	p, ok := client.Pusher().(planPusher)
+	if !ok {
+		return nil, errors.New("the pusher does not support a dry-run push")
+	}
+	plan, err := p.Plan(cmd.Context(), f)
+	if err != nil {
+		return nil, fmt.Errorf("error querying the registry for %v. %w", f.Build.Image, err)
+	}
+	if c.Quiet || Format(c.Output) == JSON {
+		return &plan, nil
+	}
+	w := cmd.OutOrStdout()
+	if plan.UpToDate() {
+		fmt.Fprintf(w, "%v is up-to-date (%v)\n", plan.Image, plan.Digest)
+		return &plan, nil
+	}
+	if plan.Remote.Hex == "" {
+		fmt.Fprintf(w, "%v does not exist: pushing would create it (%v)\n", plan.Image, plan.Digest)
+	} else {
+		fmt.Fprintf(w, "%v is out of date: pushing would replace %v with %v\n", plan.Image, plan.Remote, plan.Digest)
+	}
+	if len(plan.Blobs) == 0 {
+		fmt.Fprintln(w, "No layers would be uploaded: each is in the repository")
+		return &plan, nil
+	}
+	var total int64
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", "PLATFORM", "BLOB", "DIGEST", "SIZE")
+	for _, b := range plan.Blobs {
+		kind := "layer"
+		if b.Config {
+			kind = "config"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", b.Platform, kind, b.Digest, byteSize(b.Size))
+		total += b.Size
+	}
+	if err = tw.Flush(); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(w, "%v blobs (%v) would be uploaded\n", len(plan.Blobs), byteSize(total))
+	return &plan, nil
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// rebaser is a builder which can recompose the function's last build upon
+// its base image without building its source, such as the host builder.
+type rebaser interface {
+	Rebase(ctx context.Context, f fn.Function) error
+}
+
+// rebase the function's last build onto its base image (--rebase), in place
+// of building it, recording the image built as would building.
+func (c buildConfig) rebase(cmd *cobra.Command, client *fn.Client, f fn.Function) (fn.Function, error) {
+	r, ok := client.Builder().(rebaser)
+	if !ok {
+		return f, errors.New("the builder does not support rebasing")
+	}
+	if f.Registry == "" {
+		f.Registry = client.Registry()
+	}
+	var err error
+	if f.Image == "" {
+		if f.Build.Image, err = f.ImageName(); err != nil {
+			return f, err
+		}
+	} else {
+		f.Build.Image = f.Image
+	}
+	if err = r.Rebase(cmd.Context(), f); err != nil {
+		return f, err
+	}
+	return f, f.WriteRuntimeBuiltImage(c.Verbose)
+}
@@ -1,11 +1,29 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"knative.dev/func/pkg/builders"
+	"knative.dev/func/pkg/config"
 	fn "knative.dev/func/pkg/functions"
 	"knative.dev/func/pkg/mock"
+	"knative.dev/func/pkg/oci"
 	. "knative.dev/func/pkg/testing"
 )
 
@@ -30,6 +48,7 @@ func TestBuild_ConfigApplied(t *testing.T) {
 // TestBuild_ConfigPrecedence ensures that the correct precidence for config
 // are applied: static < global < function context < envs < flags
 func TestBuild_ConfigPrecedence(t *testing.T) {
+	withContainerRuntime(t, true) // the static default builder
 	testConfigPrecedence(NewBuildCmd, t)
 }
 
@@ -59,6 +78,66 @@ func TestBuild_ImageAndRegistry(t *testing.T) {
 	testImageAndRegistry(NewBuildCmd, t)
 }
 
+// TestBuild_ImageOfRegistry ensures an image given with a registry is
+// accepted only if of that registry.
+func TestBuild_ImageOfRegistry(t *testing.T) {
+	tests := []struct {
+		registry, image string
+		conflict        bool
+	}{
+		{"example.com/alice", "example.com/alice/myfunc:latest", false},
+		{"docker.io/alice", "alice/myfunc", false},
+		{"example.com/alice", "example.com/bob/myfunc", true},
+		{"example.com/alice", "registry.example.com/alice/myfunc", true},
+		{"example.com/alice", "example.com/alice/team/myfunc", true},
+	}
+	for _, tt := range tests {
+		err := buildConfig{Global: config.Global{Registry: tt.registry}, Image: tt.image}.validateImageRegistry()
+		if tt.conflict != errors.Is(err, fn.ErrConflictingImageAndRegistry) {
+			t.Fatalf("expected conflict %v of %v and %v, got %v", tt.conflict, tt.image, tt.registry, err)
+		}
+	}
+}
+
+// TestBuild_ImageOfRegistryExplicit ensures an image not of the registry is
+// rejected when each is set explicitly, by flag or environment variable, and
+// not when the registry is that of the function.
+func TestBuild_ImageOfRegistryExplicit(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		args     []string
+		conflict bool
+	}{
+		{"flags", nil, []string{"--registry", "example.com/alice", "--image", "example.com/bob/myfunc"}, true},
+		{"environment", map[string]string{"FUNC_REGISTRY": "example.com/alice", "FUNC_IMAGE": "example.com/bob/myfunc"}, nil, true},
+		{"flag and environment", map[string]string{"FUNC_REGISTRY": "example.com/alice"}, []string{"--image", "example.com/bob/myfunc"}, true},
+		{"registry of the function", nil, []string{"--image", "example.com/bob/myfunc"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := FromTempDirectory(t)
+			f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+			if _, err := fn.New().Init(f); err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+			builder := mock.NewBuilder()
+			cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+			cmd.SetArgs(test.args)
+			err := cmd.Execute()
+			if test.conflict != errors.Is(err, fn.ErrConflictingImageAndRegistry) {
+				t.Fatalf("expected conflict %v, got %v", test.conflict, err)
+			}
+			if builder.BuildInvoked == test.conflict {
+				t.Fatalf("expected build invoked %v", !test.conflict)
+			}
+		})
+	}
+}
+
 // TestBuild_InvalidRegistry ensures that providing an invalid registry
 // fails with the expected error.
 func TestBuild_InvalidRegistry(t *testing.T) {
@@ -158,3 +237,866 @@ func TestBuild_Push(t *testing.T) {
 		t.Fatal("push should not be invoked on a failed build")
 	}
 }
+
+// TestBuild_Profile ensures that a build profile defined on the function is
+// layered over the function's settings, that explicit flags take precedence,
+// and that an unknown profile is an error.
+func TestBuild_Profile(t *testing.T) {
+	root := FromTempDirectory(t)
+	t.Setenv("FUNC_CONFIG_FILE", "nonexistent")
+
+	key, value := "env", "prod"
+	f := fn.Function{
+		Root:     root,
+		Name:     "myfunc",
+		Runtime:  "go",
+		Registry: "example.com/alice",
+		Build: fn.BuildSpec{
+			Profiles: map[string]fn.BuildProfile{
+				"prod": {
+					Registry: "example.com/prod",
+					Labels:   []fn.Label{{Key: &key, Value: &value}},
+				},
+			},
+		},
+	}
+	if _, err := fn.New().Init(f); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown profile
+	cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(mock.NewBuilder())))
+	cmd.SetArgs([]string{"--profile", "nope"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected error for an unknown profile")
+	}
+
+	// Explicit flags take precedence over the profile
+	cmd = NewBuildCmd(NewTestClient(fn.WithBuilder(mock.NewBuilder())))
+	cmd.SetArgs([]string{"--profile", "prod", "--registry", "example.com/bob"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ = fn.NewFunction(root); f.Registry != "example.com/bob" {
+		t.Fatalf("expected registry 'example.com/bob', got '%v'", f.Registry)
+	}
+
+	// Profile values are applied
+	cmd = NewBuildCmd(NewTestClient(fn.WithBuilder(mock.NewBuilder())))
+	cmd.SetArgs([]string{"--profile", "prod"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if f, _ = fn.NewFunction(root); f.Registry != "example.com/prod" {
+		t.Fatalf("expected registry 'example.com/prod', got '%v'", f.Registry)
+	}
+	if len(f.Deploy.Labels) != 1 || *f.Deploy.Labels[0].Key != key || *f.Deploy.Labels[0].Value != value {
+		t.Fatalf("expected profile label, got %v", f.Deploy.Labels)
+	}
+}
+
+// TestBuild_Cache ensures the host builder's shared blob cache is reported
+// with --cache-info and purged with --cache-clear, without building.
+func TestBuild_Cache(t *testing.T) {
+	_ = FromTempDirectory(t)
+	cache := oci.SharedCacheDir() // within the test's XDG_CONFIG_HOME
+	if err := os.MkdirAll(cache, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	digest := "0123456789abcdef"
+	if err := os.WriteFile(filepath.Join(cache, digest), []byte("blob"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	builder := mock.NewBuilder()
+	cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+	cmd.SetArgs([]string{"--cache-info"})
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), cache) || !strings.Contains(out.String(), digest) {
+		t.Fatalf("expected cache %v with blob %v, got:\n%v", cache, digest, out.String())
+	}
+
+	cmd = NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+	cmd.SetArgs([]string{"--cache-clear"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cache, digest)); !os.IsNotExist(err) {
+		t.Fatalf("expected cached blob to be removed. %v", err)
+	}
+	if builder.BuildInvoked {
+		t.Fatal("build should not be invoked")
+	}
+}
+
+// TestBuild_Clean ensures --clean removes the builds of the function, and
+// with --cache-clear the blobs of its cache, without building.
+func TestBuild_Clean(t *testing.T) {
+	root := FromTempDirectory(t)
+	f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+	if _, err := fn.New().Init(f); err != nil {
+		t.Fatal(err)
+	}
+	build := filepath.Join(root, fn.RunDataDir, "builds", "by-hash", "a")
+	blob := filepath.Join(oci.SharedCacheDir(), "0123456789abcdef")
+	for _, file := range []string{filepath.Join(build, "index.json"), blob} {
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	builder := mock.NewBuilder()
+	cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+	cmd.SetArgs([]string{"--clean"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, fn.RunDataDir, "builds")); !os.IsNotExist(err) {
+		t.Fatalf("expected the builds to be removed, got %v", err)
+	}
+	if _, err := os.Stat(blob); err != nil {
+		t.Fatalf("expected the cached blob to remain without --cache-clear. %v", err)
+	}
+
+	cmd = NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+	cmd.SetArgs([]string{"--clean", "--cache-clear"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(blob); !os.IsNotExist(err) {
+		t.Fatalf("expected the cached blob to be removed, got %v", err)
+	}
+	if builder.BuildInvoked {
+		t.Fatal("build should not be invoked")
+	}
+}
+
+// TestBuild_PrintFingerprint ensures --print-fingerprint prints the
+// fingerprint of the function's source and its build directory, without
+// building.
+func TestBuild_PrintFingerprint(t *testing.T) {
+	root := FromTempDirectory(t)
+	f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+	if _, err := fn.New().Init(f); err != nil {
+		t.Fatal(err)
+	}
+	hash, _, err := fn.Fingerprint(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder := mock.NewBuilder()
+	cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+	cmd.SetArgs([]string{"--print-fingerprint"})
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if builder.BuildInvoked {
+		t.Fatal("build should not be invoked")
+	}
+	dir := filepath.Join(root, fn.RunDataDir, "builds", "by-hash", hash)
+	if !strings.Contains(out.String(), hash) || !strings.Contains(out.String(), dir) {
+		t.Fatalf("expected fingerprint %v and directory %v, got:\n%v", hash, dir, out.String())
+	}
+
+	cmd = NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+	cmd.SetArgs([]string{"--print-fingerprint", "--quiet"})
+	out.Reset()
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != hash+"\n" {
+		t.Fatalf("expected only the fingerprint when quiet, got %q", out.String())
+	}
+}
+
+// TestBuild_InvalidImage ensures an invalid image, explicit or computed from
+// the registry, fails validation before building.
+func TestBuild_InvalidImage(t *testing.T) {
+	root := FromTempDirectory(t)
+	f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+	if _, err := fn.New().Init(f); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"--image", "my registry/foo"},
+		{"--registry", "my registry", "--push"},
+	} {
+		builder := mock.NewBuilder()
+		cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); !errors.Is(err, fn.ErrInvalidImage) {
+			t.Fatalf("%v: expected ErrInvalidImage, got %v", args, err)
+		}
+		if builder.BuildInvoked {
+			t.Fatalf("%v: build should not be invoked", args)
+		}
+	}
+}
+
+// TestBuild_HostOnly ensures each setting of the host builder is rejected,
+// before building, when building with another builder.
+func TestBuild_HostOnly(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := map[string][]string{
+		"capability":             {"--capability", "cap_net_bind_service=+ep"},
+		"build-dir":              {"--build-dir", "build"},
+		"bundle":                 {"--bundle", "bundle.tar"},
+		"oci-output":             {"--oci-output", "dist/oci"},
+		"digest-algorithm":       {"--digest-algorithm", "sha512"},
+		"annotation":             {"--annotation", "com.example.team=payments"},
+		"media-type":             {"--media-type", "docker"},
+		"artifact-type":          {"--artifact-type", "application/vnd.example.function.v1"},
+		"foreign-layer":          {"--foreign-layer", digest + "=https://cdn.example.com/base.tar.gz"},
+		"registry-mirror":        {"--registry-mirror", "docker.io=mirror.example.com/dockerhub"},
+		"squash":                 {"--squash"},
+		"max-layers":             {"--max-layers", "10"},
+		"max-layers-fail":        {"--max-layers-fail"},
+		"build-concurrency":      {"--build-concurrency", "2"},
+		"build-tag":              {"--build-tag", "prod"},
+		"pgo":                    {"--pgo", "off"},
+		"build-vcs":              {"--build-vcs=false"},
+		"go-toolchain":           {"--go-toolchain", "local"},
+		"go-proxy":               {"--go-proxy", "https://proxy.example.com"},
+		"go-private":             {"--go-private", "example.com/*"},
+		"go-nosumdb":             {"--go-nosumdb", "example.com/*"},
+		"go-flags":               {"--go-flags", "-mod=mod"},
+		"go-netrc":               {"--go-netrc", ".netrc"},
+		"go-token":               {"--go-token", "github.com=token"},
+		"middleware-version":     {"--middleware-version", "v0.21.4"},
+		"replace":                {"--replace", "knative.dev/func-go=../func-go"},
+		"secret":                 {"--secret", "id=token,env=TOKEN"},
+		"scan":                   {"--scan"},
+		"scan-severity":          {"--scan-severity", "critical"},
+		"push-registry":          {"--push", "--push-registry", "dr.example.com/alice"},
+		"platform-tag-suffix":    {"--push", "--platform", "linux/amd64", "--platform-tag-suffix"},
+		"push-dry-run":           {"--push-dry-run"},
+		"sign-key":               {"--sign-key", "signing.pem"},
+		"sign-manifests":         {"--sign-manifests"},
+		"checksums":              {"--checksums"},
+		"keep-tars":              {"--keep-tars"},
+		"interactive":            {"--interactive"},
+		"zero-timestamps":        {"--zero-timestamps"},
+		"verify-reproducible":    {"--verify-reproducible"},
+		"ca-bundle":              {"--ca-bundle", "ca.pem"},
+		"http-proxy":             {"--http-proxy", "http://proxy.example.com:3128"},
+		"https-proxy":            {"--https-proxy", "http://proxy.example.com:3128"},
+		"no-proxy":               {"--no-proxy", ".example.com"},
+		"build-info":             {"--build-info"},
+		"base-image-pull-policy": {"--base-image-pull-policy", "never"},
+		"warm-cache":             {"--warm-cache"},
+		"rebase":                 {"--rebase"},
+		"without-source":         {"--without-source"},
+		"strip-source":           {"--strip-source"},
+	}
+	for _, s := range (buildConfig{}).hostOnlySettings() {
+		if _, ok := tests[s.flag]; !ok {
+			t.Fatalf("no test of the host-only setting --%v", s.flag)
+		}
+	}
+
+	for flag, args := range tests {
+		t.Run(flag, func(t *testing.T) {
+			root := FromTempDirectory(t)
+			f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+			if _, err := fn.New().Init(f); err != nil {
+				t.Fatal(err)
+			}
+			builder := mock.NewBuilder()
+			cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+			cmd.SetArgs(append([]string{"--builder", "pack"}, args...))
+			err := cmd.Execute()
+			if err == nil || !strings.Contains(err.Error(), "only host builds support") || !strings.Contains(err.Error(), "--"+flag) {
+				t.Fatalf("expected --%v to be rejected as host-only, got %v", flag, err)
+			}
+			if builder.BuildInvoked {
+				t.Fatal("build should not be invoked")
+			}
+		})
+	}
+}
+
+// TestBuild_HostValidation ensures invalid values of the host builder's
+// settings, and invalid combinations thereof, are rejected before building.
+func TestBuild_HostValidation(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"annotation without a domain", []string{"--annotation", "team=payments"}},
+		{"annotation without a value", []string{"--annotation", "com.example.team"}},
+		{"unknown media type", []string{"--media-type", "schema1"}},
+		{"artifact type not a media type", []string{"--artifact-type", "function"}},
+		{"artifact type of docker", []string{"--artifact-type", "application/vnd.example.function.v1", "--media-type", "docker"}},
+		{"foreign layer without a URL", []string{"--foreign-layer", digest}},
+		{"foreign layer of a relative URL", []string{"--foreign-layer", digest + "=cdn.example.com/base.tar.gz"}},
+		{"registry mirror without a mirror", []string{"--registry-mirror", "docker.io"}},
+		{"registry mirror not a registry", []string{"--registry-mirror", "docker.io=dockerhub"}},
+		{"unknown squash mode", []string{"--squash=base"}},
+		{"squash all of foreign layers", []string{"--squash=all", "--foreign-layer", digest + "=https://cdn.example.com/base.tar.gz"}},
+		{"negative max layers", []string{"--max-layers", "-1"}},
+		{"max layers fail without max", []string{"--max-layers-fail"}},
+		{"negative concurrency", []string{"--build-concurrency", "-1"}},
+		{"concurrency not a number", []string{"--build-concurrency", "many"}},
+		{"build tags of a list", []string{"--build-tag", "prod,debug"}},
+		{"build tag of flags", []string{"--build-tag", "prod -ldflags=-X"}},
+		{"duplicate build tag", []string{"--build-tag", "prod", "--build-tag", "prod"}},
+		{"missing profile", []string{"--pgo", "missing.pprof"}},
+		{"unknown VCS stamping", []string{"--build-vcs=sometimes"}},
+		{"toolchain of auto", []string{"--go-toolchain", "auto"}},
+		{"toolchain not of a release", []string{"--go-toolchain", "1.22"}},
+		{"go token without a token", []string{"--go-token", "github.com"}},
+		{"missing netrc", []string{"--go-netrc", "missing"}},
+		{"middleware version of latest", []string{"--middleware-version", "latest"}},
+		{"middleware version without v", []string{"--middleware-version", "0.21.4"}},
+		{"replace without a replacement", []string{"--replace", "knative.dev/func-go"}},
+		{"replace of a module path", []string{"--replace", "knative.dev/func-go=func-go"}},
+		{"secret without a source", []string{"--secret", "id=token"}},
+		{"duplicate secret", []string{"--secret", "id=token,env=A", "--secret", "id=token,env=B"}},
+		{"unknown scan severity", []string{"--scan", "--scan-severity", "severe"}},
+		{"unknown digest algorithm", []string{"--digest-algorithm", "md5", "--bundle", "bundle.tar"}},
+		{"digest algorithm not exported", []string{"--digest-algorithm", "sha512"}},
+		{"push registry without push", []string{"--push-registry", "dr.example.com/alice"}},
+		{"platform tag suffix without push", []string{"--platform", "linux/amd64", "--platform-tag-suffix"}},
+		{"platform tag suffix of several platforms", []string{"--push", "--platform-tag-suffix"}}, // the runtime's defaults
+		{"dry-run push with push", []string{"--push", "--push-dry-run"}},
+		{"proxy not a URL", []string{"--https-proxy", "proxy.example.com:3128"}},
+		{"signing manifests without a key", []string{"--sign-manifests"}},
+		{"invalid signing key", []string{"--sign-key", "invalid.pem"}},
+		{"unknown pull policy", []string{"--base-image-pull-policy", "sometimes"}},
+		{"rebase when watching", []string{"--rebase", "--watch"}},
+		{"warm cache when rebasing", []string{"--warm-cache", "--rebase"}},
+		{"warm cache of a builder without a cache", []string{"--warm-cache"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := FromTempDirectory(t)
+			f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+			if _, err := fn.New().Init(f); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(root, "invalid.pem"), []byte("invalid"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			builder := mock.NewBuilder()
+			cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+			cmd.SetArgs(append([]string{"--builder", "host"}, test.args...))
+			if err := cmd.Execute(); err == nil {
+				t.Fatalf("expected error for %v", test.args)
+			}
+			if builder.BuildInvoked {
+				t.Fatal("build should not be invoked")
+			}
+		})
+	}
+}
+
+// TestBuild_HostBuilderOptions ensures the host builder is constructed with
+// the options of its settings, each applied over those of the defaults.
+func TestBuild_HostBuilderOptions(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	key := writeTestSigningKey(t)
+	tests := []struct {
+		name string
+		args []string
+		opts []oci.BuilderOpt
+	}{
+		{"capability", []string{"--capability", "cap_net_bind_service=+ep"},
+			[]oci.BuilderOpt{oci.WithCapabilities("cap_net_bind_service=+ep")}},
+		{"bundle", []string{"--bundle", "bundle.tar", "--digest-algorithm", "sha512"},
+			[]oci.BuilderOpt{oci.WithBundle("bundle.tar"), oci.WithDigestAlgorithm("sha512")}},
+		{"oci-output", []string{"--oci-output", "dist/oci"},
+			[]oci.BuilderOpt{oci.WithOCIOutput("dist/oci")}},
+		{"annotation", []string{"--annotation", "com.example.team=payments"},
+			[]oci.BuilderOpt{oci.WithAnnotations(map[string]string{"com.example.team": "payments"})}},
+		{"media-type", []string{"--media-type", "docker"},
+			[]oci.BuilderOpt{oci.WithMediaTypes("docker")}},
+		{"artifact-type", []string{"--artifact-type", "application/vnd.example.function.v1"},
+			[]oci.BuilderOpt{oci.WithArtifactType("application/vnd.example.function.v1")}},
+		{"foreign-layer", []string{"--foreign-layer", digest + "=https://cdn.example.com/base.tar.gz"},
+			[]oci.BuilderOpt{oci.WithForeignLayers(map[string]string{digest: "https://cdn.example.com/base.tar.gz"})}},
+		{"registry-mirror", []string{"--registry-mirror", "docker.io=mirror.example.com/dockerhub"},
+			[]oci.BuilderOpt{oci.WithRegistryMirrors(map[string]string{"docker.io": "mirror.example.com/dockerhub"})}},
+		{"squash", []string{"--squash"}, // of the function's layers by default
+			[]oci.BuilderOpt{oci.WithSquash(oci.SquashFunction)}},
+		{"max-layers", []string{"--max-layers", "10", "--max-layers-fail"},
+			[]oci.BuilderOpt{oci.WithMaxLayers(10, true)}},
+		{"build-concurrency", []string{"--build-concurrency", "2"},
+			[]oci.BuilderOpt{oci.WithConcurrency(2)}},
+		{"build-tag", []string{"--build-tag", "prod", "--build-tag", "debug"},
+			[]oci.BuilderOpt{oci.WithBuildTags("prod", "debug")}},
+		{"pgo", []string{"--pgo", "off"},
+			[]oci.BuilderOpt{oci.WithPGO("off")}},
+		{"build-vcs", []string{"--build-vcs=false"},
+			[]oci.BuilderOpt{oci.WithBuildVCS("false")}},
+		{"go-toolchain", []string{"--go-toolchain", "local"},
+			[]oci.BuilderOpt{oci.WithGoToolchain("local")}},
+		{"go modules", []string{"--go-proxy", "https://proxy.example.com", "--go-private", "example.com/*", "--go-token", "github.com=token"},
+			[]oci.BuilderOpt{oci.WithGoModules(oci.GoModules{Proxy: "https://proxy.example.com", Private: "example.com/*", Tokens: map[string]string{"github.com": "token"}})}},
+		{"middleware-version", []string{"--middleware-version", "v0.21.4"},
+			[]oci.BuilderOpt{oci.WithMiddlewareVersion("v0.21.4")}},
+		{"replace", []string{"--replace", "knative.dev/func-go=../func-go"},
+			[]oci.BuilderOpt{oci.WithReplace(map[string]string{"knative.dev/func-go": "../func-go"})}},
+		{"secret", []string{"--secret", "id=token,env=TOKEN"},
+			[]oci.BuilderOpt{oci.WithSecrets([]oci.Secret{{ID: "token", Env: "TOKEN"}})}},
+		{"scan", []string{"--scan", "--scan-severity", "critical"}, // with trivy by default
+			[]oci.BuilderOpt{oci.WithScan(oci.ScannerTrivy, "critical")}},
+		{"sign", []string{"--sign-key", key, "--sign-manifests"},
+			[]oci.BuilderOpt{oci.WithSigning(key, true)}},
+		{"checksums", []string{"--checksums"},
+			[]oci.BuilderOpt{oci.WithChecksums(true)}},
+		{"without-source", []string{"--without-source"},
+			[]oci.BuilderOpt{oci.WithoutSource(true)}},
+		{"strip-source", []string{"--strip-source"},
+			[]oci.BuilderOpt{oci.WithStripSource(true)}},
+		{"keep-tars", []string{"--keep-tars"},
+			[]oci.BuilderOpt{oci.WithKeepTars(true)}},
+		{"interactive", []string{"--interactive"},
+			[]oci.BuilderOpt{oci.WithInspectFailure(true)}},
+		{"zero-timestamps", []string{"--zero-timestamps"},
+			[]oci.BuilderOpt{oci.WithZeroTimestamps(true)}},
+		{"verify-reproducible", []string{"--verify-reproducible"},
+			[]oci.BuilderOpt{oci.WithVerifyReproducible(true)}},
+		{"proxy", []string{"--https-proxy", "http://proxy.example.com:3128", "--no-proxy", ".internal.example.com"},
+			[]oci.BuilderOpt{oci.WithProxy(oci.Proxy{HTTPS: "http://proxy.example.com:3128", NoProxy: ".internal.example.com"})}},
+		{"build-info", []string{"--build-info"},
+			[]oci.BuilderOpt{oci.WithBuildInfo(true)}},
+		{"base-image-pull-policy", []string{"--base-image-pull-policy", "never"},
+			[]oci.BuilderOpt{oci.WithBasePullPolicy("never")}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := FromTempDirectory(t)
+			f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+			if _, err := fn.New().Init(f); err != nil {
+				t.Fatal(err)
+			}
+			expected := hostBuilderOf(t)
+			for _, opt := range test.opts {
+				opt(expected)
+			}
+			builder := hostBuilderOf(t, test.args...)
+			if diff := cmp.Diff(expected, builder,
+				cmp.Exporter(func(reflect.Type) bool { return true }),
+				cmp.FilterPath(func(p cmp.Path) bool { return p.Last().Type().Kind() == reflect.Func }, cmp.Ignore()),
+			); diff != "" {
+				t.Fatalf("unexpected host builder of %v (-want, +got):\n%v", test.args, diff)
+			}
+		})
+	}
+}
+
+// hostBuilderOf returns the host builder constructed for a build with the
+// given arguments, building with a mock in its place.
+func hostBuilderOf(t *testing.T, args ...string) *oci.Builder {
+	t.Helper()
+	var builder fn.Builder
+	newClient := func(_ ClientConfig, oo ...fn.Option) (*fn.Client, func()) {
+		builder = fn.New(oo...).Builder()
+		return fn.New(fn.WithBuilder(mock.NewBuilder())), func() {}
+	}
+	cmd := NewBuildCmd(newClient)
+	cmd.SetArgs(append([]string{"--builder", "host"}, args...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	b, ok := builder.(*oci.Builder)
+	if !ok {
+		t.Fatalf("expected the host builder, got %T", builder)
+	}
+	return b
+}
+
+// writeTestSigningKey writes an ECDSA P-256 key, PEM encoded, to a file of
+// the test's temporary directory.
+func writeTestSigningKey(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// reportingBuilder is a mock builder which reports the result of its builds,
+// as does the host builder.
+type reportingBuilder struct {
+	*mock.Builder
+	result oci.BuildResult
+}
+
+func (b reportingBuilder) Result() oci.BuildResult { return b.result }
+
+// TestBuild_OutputJSON ensures --output json writes the result of the build,
+// including that reported by the builder and whether pushed, as JSON in place
+// of human-readable output, and that incompatible flags are rejected.
+func TestBuild_OutputJSON(t *testing.T) {
+	root := FromTempDirectory(t)
+	f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+	if _, err := fn.New().Init(f); err != nil {
+		t.Fatal(err)
+	}
+	index, _ := v1.NewHash("sha256:" + strings.Repeat("a", 64))
+	image, _ := v1.NewHash("sha256:" + strings.Repeat("b", 64))
+	layer, _ := v1.NewHash("sha256:" + strings.Repeat("c", 64))
+	builder := reportingBuilder{Builder: mock.NewBuilder(), result: oci.BuildResult{
+		Duration: 2 * time.Second,
+		Timings:  oci.Timings{{Phase: "scaffold", Duration: time.Second}},
+		Index:    index,
+		Images: []oci.ImageResult{{Platform: "linux/amd64", Digest: image,
+			Layers: []oci.LayerResult{{Digest: layer, Size: 100}, {Digest: layer, Size: 20}}}},
+	}}
+	pusher := mock.NewPusher()
+
+	cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder), fn.WithPusher(pusher)))
+	cmd.SetArgs([]string{"--output", "json", "--push"})
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	var o buildOutput
+	if err := json.Unmarshal(out.Bytes(), &o); err != nil {
+		t.Fatalf("expected only JSON output. %v\n%v", err, out.String())
+	}
+	if !strings.HasPrefix(o.Image, "example.com/alice/myfunc") || !o.Pushed {
+		t.Fatalf("expected the pushed image, got %+v", o)
+	}
+	if o.Digest != index.String() || len(o.Platforms) != 1 || o.Platforms[0].Digest != image.String() {
+		t.Fatalf("expected the digests of the index and image, got %+v", o)
+	}
+	if o.Platforms[0].Size != 120 || len(o.Platforms[0].Layers) != 2 {
+		t.Fatalf("expected the layers of the image, got %+v", o.Platforms[0])
+	}
+	if len(o.Timings) != 2 || o.Timings[0].Phase != "scaffold" || o.Timings[1].Phase != "push" {
+		t.Fatalf("expected the timings of the build and push, got %+v", o.Timings)
+	}
+
+	for _, args := range [][]string{
+		{"--output", "yaml"},
+		{"--output", "json", "--verbose"},
+		{"--output", "json", "--watch"},
+	} {
+		builder.BuildInvoked = false
+		cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+		if builder.BuildInvoked {
+			t.Fatalf("build should not be invoked for %v", args)
+		}
+	}
+}
+
+// TestBuild_PlatformTagSuffix ensures a platform tag suffix is accepted of
+// the single platform of the function (build.platforms).
+func TestBuild_PlatformTagSuffix(t *testing.T) {
+	root := FromTempDirectory(t)
+	f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+	f.Build.Platforms = []string{"linux/arm64"}
+	if _, err := fn.New().Init(f); err != nil {
+		t.Fatal(err)
+	}
+
+	builder := mock.NewBuilder()
+	cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder), fn.WithPusher(mock.NewPusher())))
+	cmd.SetArgs([]string{"--builder", "host", "--push", "--platform-tag-suffix"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !builder.BuildInvoked {
+		t.Fatal("build was not invoked")
+	}
+}
+
+// TestBuild_WarmCache ensures the cache is warmed by the host builder for
+// the platforms requested, in place of building.
+func TestBuild_WarmCache(t *testing.T) {
+	root := FromTempDirectory(t)
+	f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+	if _, err := fn.New().Init(f); err != nil {
+		t.Fatal(err)
+	}
+
+	builder := &warmingBuilder{Builder: mock.NewBuilder()}
+	cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+	cmd.SetArgs([]string{"--builder", "host", "--warm-cache", "--platform", "linux/arm64"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if builder.BuildInvoked {
+		t.Fatal("build should not be invoked")
+	}
+	if want := []fn.Platform{{OS: "linux", Architecture: "arm64"}}; !reflect.DeepEqual(builder.warmed, want) {
+		t.Fatalf("expected the cache warmed for %v, got %v", want, builder.warmed)
+	}
+}
+
+// warmingBuilder is a mock builder which can warm its cache.
+type warmingBuilder struct {
+	*mock.Builder
+	warmed []fn.Platform
+}
+
+func (b *warmingBuilder) WarmCache(_ context.Context, _ fn.Function, pp []fn.Platform) error {
+	b.warmed = pp
+	return nil
+}
+
+// TestBuild_Watch ensures --watch rebuilds the function when its files
+// change, but not when ignored files change, until canceled.
+func TestBuild_Watch(t *testing.T) {
+	root := FromTempDirectory(t)
+	f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+	if _, err := fn.New().Init(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".funcignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(d time.Duration) { watchInterval = d }(watchInterval)
+	watchInterval = 10 * time.Millisecond
+
+	builds := make(chan fn.Function, 10)
+	builder := mock.NewBuilder()
+	builder.BuildFn = func(f fn.Function) error {
+		builds <- f
+		return nil
+	}
+	awaitBuild := func() {
+		t.Helper()
+		select {
+		case <-builds:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for build")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+	cmd.SetArgs([]string{"--watch"})
+	cmd.SetOut(&bytes.Buffer{})
+	done := make(chan error)
+	go func() { done <- cmd.ExecuteContext(ctx) }()
+	awaitBuild() // initial build
+
+	// An ignored file is not built
+	if err := os.WriteFile(filepath.Join(root, "debug.log"), []byte("log"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * watchInterval)
+	if len(builds) != 0 {
+		t.Fatal("expected a change to an ignored file not to be built")
+	}
+
+	// A change to the function is built
+	if err := os.WriteFile(filepath.Join(root, "index.html"), []byte("<html></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	awaitBuild()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for watch to stop")
+	}
+}
+
+// TestBuild_Inspect ensures --inspect prints the effective build settings and
+// the source of each, without building.
+func TestBuild_Inspect(t *testing.T) {
+	root := FromTempDirectory(t)
+	f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+	if _, err := fn.New().Init(f); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FUNC_PUSH", "true")
+	t.Setenv("FUNC_PLATFORMS", "linux/amd64,linux/arm64")
+
+	builder := mock.NewBuilder()
+	cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(builder)))
+	cmd.SetArgs([]string{"--inspect", "--builder", "host", "--registry", "example.com/bob"})
+	out := bytes.Buffer{}
+	cmd.SetOut(&out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if builder.BuildInvoked {
+		t.Fatal("build should not be invoked")
+	}
+
+	expected := map[string][]string{
+		"registry":  {"example.com/bob", sourceFlag},
+		"image":     {"example.com/bob/myfunc:latest", sourceComputed},
+		"push":      {"true", sourceEnv},
+		"platforms": {"linux/amd64,linux/arm64", sourceEnv},
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if e, ok := expected[fields[0]]; ok {
+			if got := strings.Join(fields[1:], " "); got != strings.Join(e, " ") {
+				t.Fatalf("expected %v %v, got %v", fields[0], e, got)
+			}
+			delete(expected, fields[0])
+		}
+	}
+	if len(expected) > 0 {
+		t.Fatalf("settings not printed: %v\n%v", expected, out.String())
+	}
+}
+
+// TestBuild_SelectBuilder ensures the host builder is selected when no
+// builder is configured, no container runtime is installed and the runtime
+// is supported by the host builder, and the default otherwise.
+func TestBuild_SelectBuilder(t *testing.T) {
+	tests := []struct {
+		name      string
+		runtime   string
+		installed bool
+		args      []string
+		expected  string
+	}{
+		{"no container runtime", "go", false, nil, builders.Host},
+		{"container runtime", "go", true, nil, builders.Pack},
+		{"unsupported runtime", "node", false, nil, builders.Pack},
+		{"configured builder", "go", false, []string{"--builder", "s2i"}, builders.S2I},
+		{"profile builder", "go", false, []string{"--profile", "ci"}, builders.S2I},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := FromTempDirectory(t)
+			withContainerRuntime(t, test.installed)
+			f := fn.Function{Root: root, Name: "myfunc", Runtime: test.runtime, Registry: "example.com/alice"}
+			f.Build.Profiles = map[string]fn.BuildProfile{"ci": {Builder: builders.S2I}}
+			if _, err := fn.New().Init(f); err != nil {
+				t.Fatal(err)
+			}
+			stderr := bytes.Buffer{}
+			cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(mock.NewBuilder())))
+			cmd.SetArgs(test.args)
+			cmd.SetErr(&stderr)
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if selected := strings.Contains(stderr.String(), "Neither Docker nor Podman"); selected != (test.expected == builders.Host) {
+				t.Fatalf("unexpected host builder selection message: %q", stderr.String())
+			}
+			f, err := fn.NewFunction(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Build.Builder != test.expected {
+				t.Fatalf("expected builder %q, got %q", test.expected, f.Build.Builder)
+			}
+		})
+	}
+}
+
+// TestBuild_PlatformVariant ensures the variant of --platform is normalized,
+// and rejected if not a variant of its architecture.
+func TestBuild_PlatformVariant(t *testing.T) {
+	for _, platform := range []string{"linux/arm/v7", "linux/arm/7", "linux/armv7"} {
+		oo, err := buildConfig{Platform: platform}.buildOptions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		options := fn.BuildOptions{}
+		for _, o := range oo {
+			o(&options)
+		}
+		want := []fn.Platform{{OS: "linux", Architecture: "arm", Variant: "v7"}}
+		if !reflect.DeepEqual(options.Platforms, want) {
+			t.Fatalf("expected %v to be %v, got %v", platform, want, options.Platforms)
+		}
+	}
+	oo, err := buildConfig{Platform: "linux/amd64/3"}.buildOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := fn.BuildOptions{}
+	for _, o := range oo {
+		o(&options)
+	}
+	if want := []fn.Platform{{OS: "linux", Architecture: "amd64", Variant: "v3"}}; !reflect.DeepEqual(options.Platforms, want) {
+		t.Fatalf("expected %v, got %v", want, options.Platforms)
+	}
+	if _, err := (buildConfig{Platform: "linux/amd64/v7"}).buildOptions(); err == nil {
+		t.Fatal("expected an error for a variant of amd64 which is not a microarchitecture level")
+	}
+}
+
+// TestBuild_SaveConfig ensures the platform is saved to func.yaml only with
+// --save-config, normalized, along with the values always saved.
+func TestBuild_SaveConfig(t *testing.T) {
+	root := FromTempDirectory(t)
+	f := fn.Function{Root: root, Name: "myfunc", Runtime: "go", Registry: "example.com/alice"}
+	if _, err := fn.New().Init(f); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewBuildCmd(NewTestClient(fn.WithBuilder(mock.NewBuilder())))
+	cmd.SetArgs([]string{"--builder", "host", "--platform", "linux/arm64"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := fn.NewFunction(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Build.Platforms) != 0 {
+		t.Fatalf("expected the platform not to be saved without --save-config, got %v", f.Build.Platforms)
+	}
+
+	cmd = NewBuildCmd(NewTestClient(fn.WithBuilder(mock.NewBuilder())))
+	cmd.SetArgs([]string{"--builder", "host", "--platform", "linux/armv7", "--base-image", "example.com/base:1", "--save-config"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if f, err = fn.NewFunction(root); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.Build.Platforms, []string{"linux/arm/v7"}) {
+		t.Fatalf("expected the platform linux/arm/v7 to be saved, got %v", f.Build.Platforms)
+	}
+	if f.Build.Builder != builders.Host || f.Registry != "example.com/alice" || f.Build.BaseImage != "example.com/base:1" {
+		t.Fatalf("expected the builder, registry and base image to be saved, got %v, %v and %v", f.Build.Builder, f.Registry, f.Build.BaseImage)
+	}
+}
+
+// withContainerRuntime stubs the detection of a container runtime for the
+// duration of the test.
+func withContainerRuntime(t *testing.T, installed bool) {
+	t.Helper()
+	detect := dockerOrPodmanInstalled
+	dockerOrPodmanInstalled = func() bool { return installed }
+	t.Cleanup(func() { dockerOrPodmanInstalled = detect })
+}
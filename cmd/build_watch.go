@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	gitignore "github.com/sabhiram/go-gitignore"
+	"github.com/spf13/cobra"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// watchInterval is the interval at which the function's files are checked
+// for changes when watching.  Changes are built once the files have not
+// changed for an interval, such that a burst of changes (for example saving
+// several files) is built once.
+var watchInterval = 500 * time.Millisecond
+
+// fileStamp identifies the version of a watched file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// watch rebuilds the function each time its files change, until the context
+// is canceled (for example by an interrupt).  A failed rebuild is reported,
+// and watching continues.  The function is reloaded for each rebuild, such
+// that changes to func.yaml are also built.
+func (c buildConfig) watch(cmd *cobra.Command, root string, build func(context.Context) (fn.Function, error)) error {
+	ctx := cmd.Context()
+	files, err := watchedFiles(root)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Watching %v for changes (Ctrl+C to stop)\n", root)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	pending := map[string]bool{} // changed files not yet built
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := watchedFiles(root)
+		if err != nil {
+			return err
+		}
+		changed := changedFiles(files, current)
+		files = current
+		for _, path := range changed {
+			pending[path] = true
+		}
+		if len(changed) > 0 || len(pending) == 0 {
+			continue // still changing, or nothing to build
+		}
+
+		start := time.Now()
+		f, err := build(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Rebuild failed (%v): %v\n", changeSummary(pending), err)
+		} else {
+			fmt.Fprintf(cmd.OutOrStdout(), "Rebuilt %v in %.1fs (%v)\n",
+				f.Build.Image, time.Since(start).Seconds(), changeSummary(pending))
+		}
+		pending = map[string]bool{}
+
+		// The build itself writes func.yaml, which is not a change.
+		if files, err = watchedFiles(root); err != nil {
+			return err
+		}
+	}
+}
+
+// watchedFiles returns the files of the function at root which trigger a
+// rebuild when changed, by path relative to root.  The .func and .git
+// directories, and files matching .funcignore, are not watched.
+func watchedFiles(root string) (files map[string]fileStamp, err error) {
+	ignore, err := gitignore.CompileIgnoreFile(filepath.Join(root, ".funcignore"))
+	if os.IsNotExist(err) {
+		ignore, err = gitignore.CompileIgnoreLines(), nil
+	}
+	if err != nil {
+		return
+	}
+	files = map[string]fileStamp{}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() && (d.Name() == fn.RunDataDir || d.Name() == ".git") {
+			return filepath.SkipDir
+		}
+		if ignore.MatchesPath(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[rel] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return
+}
+
+// changedFiles returns the paths of files added, removed or modified.
+func changedFiles(before, after map[string]fileStamp) (changed []string) {
+	for path, stamp := range after {
+		if prior, ok := before[path]; !ok || !prior.modTime.Equal(stamp.modTime) || prior.size != stamp.size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	return
+}
+
+// changeSummary describes the changed files concisely, for example
+// "changed main.go and 2 more".
+func changeSummary(changed map[string]bool) string {
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if len(paths) == 1 {
+		return "changed " + paths[0]
+	}
+	return fmt.Sprintf("changed %v and %v more", paths[0], len(paths)-1)
+}
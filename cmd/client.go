@@ -28,6 +28,11 @@ type ClientConfig struct {
 
 	// Allow insecure server connections when using SSL
 	InsecureSkipVerify bool
+
+	// DockerConfig is the directory of the docker configuration (config.json)
+	// from which registry credentials are read.  Defaults to $DOCKER_CONFIG or
+	// ~/.docker.
+	DockerConfig string
 }
 
 // ClientFactory defines a constructor which assists in the creation of a Client
@@ -56,8 +61,8 @@ func NewTestClient(options ...fn.Option) ClientFactory {
 // 'Verbose' indicates the system should write out a higher amount of logging.
 func NewClient(cfg ClientConfig, options ...fn.Option) (*fn.Client, func()) {
 	var (
-		t  = newTransport(cfg.InsecureSkipVerify)    // may provide a custom impl which proxies
-		c  = newCredentialsProvider(config.Dir(), t) // for accessing registries
+		t  = newTransport(cfg.InsecureSkipVerify)                      // may provide a custom impl which proxies
+		c  = newCredentialsProvider(config.Dir(), cfg.DockerConfig, t) // for accessing registries
 		d  = newKnativeDeployer(cfg.Verbose)
 		pp = newTektonPipelinesProvider(c, cfg.Verbose)
 		o  = []fn.Option{ // standard (shared) options for all commands
@@ -99,7 +104,7 @@ func newTransport(insecureSkipVerify bool) fnhttp.RoundTripCloser {
 // newCredentialsProvider returns a credentials provider which possibly
 // has cluster-flavor specific additional credential loaders to take advantage
 // of features or configuration nuances of cluster variants.
-func newCredentialsProvider(configPath string, t http.RoundTripper) oci.CredentialsProvider {
+func newCredentialsProvider(configPath, dockerConfig string, t http.RoundTripper) oci.CredentialsProvider {
 	additionalLoaders := append(k8s.GetOpenShiftDockerCredentialLoaders(), k8s.GetGoogleCredentialLoader()...)
 	additionalLoaders = append(additionalLoaders, k8s.GetECRCredentialLoader()...)
 	additionalLoaders = append(additionalLoaders, k8s.GetACRCredentialLoader()...)
@@ -108,6 +113,7 @@ func newCredentialsProvider(configPath string, t http.RoundTripper) oci.Credenti
 		creds.WithPromptForCredentialStore(prompt.NewPromptForCredentialStore()),
 		creds.WithTransport(t),
 		creds.WithAdditionalCredentialLoaders(additionalLoaders...),
+		creds.WithDockerConfigDir(dockerConfig),
 	}
 
 	// Other cluster variants can be supported here
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -8,6 +9,7 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -36,7 +38,8 @@ SYNOPSIS
 	             [-b|--build] [--builder] [--builder-image] [-p|--push]
 	             [--domain] [--platform] [--build-timestamp] [--pvc-size]
 	             [--service-account] [-c|--confirm] [-v|--verbose]
-	             [--registry-insecure] [--remote-storage-class]
+	             [--registry-insecure] [--remote-storage-class] [--docker-config]
+	             [--cluster-platform]
 
 DESCRIPTION
 
@@ -62,6 +65,9 @@ DESCRIPTION
 	  By default the function will be built if it has not yet been built, or if
 	  changes are detected in the function's source.  The --build flag can be
 	  used to override this behavior and force building either on or off.
+	  The host builder builds only the platform of the cluster's nodes when
+	  they share one, rather than every default platform.  The
+	  --cluster-platform=false flag builds every default platform regardless.
 
 	Pushing
 	  By default the function's image will be pushed to the configured container
@@ -126,13 +132,18 @@ EXAMPLES
 	  manually deleted from the cluster, it can be quickly redeployed with:
 	  $ {{rootCmdUse}} deploy --build=false --push=false
 
+	o Deploy with the host builder, building the image of every default
+	  platform rather than only that of the cluster's nodes.
+	  $ {{rootCmdUse}} deploy --builder=host --cluster-platform=false
+
 `,
 		SuggestFor: []string{"delpoy", "deplyo"},
 		PreRunE: bindEnv("build", "build-timestamp", "builder", "builder-image",
 			"base-image", "confirm", "domain", "env", "git-branch", "git-dir",
 			"git-url", "image", "namespace", "path", "platform", "push", "pvc-size",
 			"service-account", "registry", "registry-insecure", "remote",
-			"username", "password", "token", "verbose", "remote-storage-class"),
+			"username", "password", "token", "docker-config", "verbose", "remote-storage-class",
+			"cluster-platform"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runDeploy(cmd, newClient)
 		},
@@ -164,7 +175,7 @@ EXAMPLES
 	cmd.Flags().StringP("base-image", "", f.Build.BaseImage,
 		"Override the base image for your function (host builder only)")
 	cmd.Flags().StringP("image", "i", f.Image,
-		"Full image name in the form [registry]/[namespace]/[name]:[tag]@[digest]. This option takes precedence over --registry. Specifying digest is optional, but if it is given, 'build' and 'push' phases are disabled. ($FUNC_IMAGE)")
+		"Full image name in the form [registry]/[namespace]/[name]:[tag]@[digest]. This option takes precedence over --registry, and if both are given must be of that registry. Specifying digest is optional, but if it is given, 'build' and 'push' phases are disabled. ($FUNC_IMAGE)")
 
 	// 环境变量, 使用 NAME=VALUE 设置变量; 使用 NAME- 删除变量
 	cmd.Flags().StringArrayP("env", "e", []string{},
@@ -204,10 +215,16 @@ EXAMPLES
 	// 推送镜像
 	cmd.Flags().BoolP("push", "u", true, "Push the function image to registry before deploying. ($FUNC_PUSH)")
 	cmd.Flags().String("platform", "", "Optionally specify a specific platform to build for (e.g. linux/amd64). ($FUNC_PLATFORM)")
+	// 仅构建集群节点的平台(host构建器),节点平台未知或不一致时构建所有默认平台
+	cmd.Flags().Bool("cluster-platform", true,
+		"Build only the platform of the cluster's nodes, or every default platform if they do not share one (host builder only) ($FUNC_CLUSTER_PLATFORM)")
 	// 镜像仓库认证(用户+密码 或者 token)
 	cmd.Flags().StringP("username", "", "", "Username to use when pushing to the registry.")
 	cmd.Flags().StringP("password", "", "", "Password to use when pushing to the registry.")
 	cmd.Flags().StringP("token", "", "", "Token to use when pushing to the registry.")
+	// docker配置目录(config.json),用于拉取基础镜像和推送的认证
+	cmd.Flags().String("docker-config", "",
+		"Directory of the docker configuration (config.json) from which the credentials for pulling base images and pushing are read, such as in CI where the home directory is not that of the user.  Defaults to $DOCKER_CONFIG or ~/.docker. ($FUNC_DOCKER_CONFIG)")
 	// 时间戳
 	cmd.Flags().BoolP("build-timestamp", "", false, "Use the actual time as the created time for the docker image. This is only useful for buildpacks builder.")
 	// 部署租户
@@ -283,7 +300,12 @@ func runDeploy(cmd *cobra.Command, newClient ClientFactory) (err error) {
 		if errors.Is(err, fn.ErrConflictingImageAndRegistry) {
 			return fmt.Errorf(`%w
 
-Cannot use both --image and --registry together. Choose one:
+When both are given, --image must be under --registry: its repository, less
+the function's name, is the registry. For example:
+
+  func deploy --registry example.com/user --image example.com/user/myfunc:v1
+
+Or use only one of them:
 
   Use --image for complete image name:
     func deploy --image example.com/user/myfunc
@@ -344,7 +366,7 @@ For more options, run 'func deploy --help'`, err)
 	if err != nil {
 		return
 	}
-	client, done := newClient(ClientConfig{Verbose: cfg.Verbose, InsecureSkipVerify: cfg.RegistryInsecure}, clientOptions...)
+	client, done := newClient(ClientConfig{Verbose: cfg.Verbose, InsecureSkipVerify: cfg.RegistryInsecure, DockerConfig: cfg.DockerConfig}, clientOptions...)
 	defer done()
 
 	// Deploy
@@ -362,6 +384,7 @@ For more options, run 'func deploy --help'`, err)
 		if buildOptions, err = cfg.buildOptions(); err != nil {
 			return
 		}
+		buildOptions = append(buildOptions, cfg.clusterPlatformOptions(cmd.Context(), cmd.OutOrStdout())...)
 
 		var (
 			digested   bool
@@ -421,6 +444,42 @@ For more options, run 'func deploy --help'`, err)
 	return f.Stamp()
 }
 
+// clusterPlatform returns the platform of the target cluster's nodes.  A
+// variable such that tests need not connect to a cluster.
+var clusterPlatform = k8s.NodePlatform
+
+// clusterPlatformTimeout bounds the detection of the cluster's platform, which
+// is an optimization the deployment does not wait on indefinitely.
+const clusterPlatformTimeout = 5 * time.Second
+
+// clusterPlatformOptions restricts a host build to the platform of the target
+// cluster's nodes (see ClusterPlatform), returning no options, and thus
+// a build of fn.DefaultPlatforms, if the platform is unknown or not one of
+// the defaults.
+func (c deployConfig) clusterPlatformOptions(ctx context.Context, w io.Writer) []fn.BuildOption {
+	if !c.ClusterPlatform || c.Builder != builders.Host || c.Platform != "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, clusterPlatformTimeout)
+	defer cancel()
+	p, err := clusterPlatform(ctx)
+	if err != nil {
+		if c.Verbose {
+			fmt.Fprintf(w, "Unable to determine the platform of the cluster's nodes, building all platforms. %v\n", err)
+		}
+		return nil
+	}
+	for _, d := range fn.DefaultPlatforms {
+		if d.OS == p.OS && d.Architecture == p.Architecture {
+			if c.Verbose {
+				fmt.Fprintf(w, "Building only the platform of the cluster's nodes: %v/%v\n", p.OS, p.Architecture)
+			}
+			return []fn.BuildOption{fn.BuildWithPlatforms([]fn.Platform{d})}
+		}
+	}
+	return nil
+}
+
 // build when flag == 'auto' and the function is out-of-date, or when the
 // flag value is explicitly truthy such as 'true' or '1'.  Error if flag
 // is neither 'auto' nor parseable as a boolean.  Return CLI-specific error
@@ -435,12 +494,12 @@ func build(cmd *cobra.Command, flag string, f fn.Function, client *fn.Client, bu
 			return f, false, nil
 		} else {
 			if f, err = client.Build(cmd.Context(), f, buildOptions...); err != nil {
-				return f, false, err
+				return f, false, wrapHostBuildError(err, "deploy")
 			}
 		}
 	} else if build, _ := strconv.ParseBool(flag); build {
 		if f, err = client.Build(cmd.Context(), f, buildOptions...); err != nil {
-			return f, false, err
+			return f, false, wrapHostBuildError(err, "deploy")
 		}
 	} else if _, err = strconv.ParseBool(flag); err != nil {
 		return f, false, fmt.Errorf("invalid value for the build flag (%q), valid value is either 'auto' or a boolean", flag)
@@ -579,6 +638,12 @@ type deployConfig struct {
 	// Timestamp the built contaienr with the current date and time.
 	// This is currently only supported by the Pack builder.
 	Timestamp bool
+
+	// ClusterPlatform restricts a host build to the platform of the nodes of
+	// the target cluster, such that only the image deployed is built.  Every
+	// default platform is built if the nodes do not share one or it can not
+	// be determined.
+	ClusterPlatform bool
 }
 
 // newDeployConfig creates a buildConfig populated from command flags and
@@ -598,6 +663,7 @@ func newDeployConfig(cmd *cobra.Command) deployConfig {
 		PVCSize:            viper.GetString("pvc-size"),
 		Timestamp:          viper.GetBool("build-timestamp"),
 		ServiceAccountName: viper.GetString("service-account"),
+		ClusterPlatform:    viper.GetBool("cluster-platform"),
 	}
 	// NOTE: .Env should be viper.GetStringSlice, but this returns unparsed
 	// results and appears to be an open issue since 2017:
@@ -2258,3 +2258,52 @@ func testBaseImage(cmdFn commandConstructor, t *testing.T) {
 		})
 	}
 }
+
+// TestDeploy_ClusterPlatform ensures a host build when deploying is of only
+// the platform of the cluster's nodes, or of every default platform when it
+// is unknown or --cluster-platform=false.
+func TestDeploy_ClusterPlatform(t *testing.T) {
+	arm64 := fn.Platform{OS: "linux", Architecture: "arm64"}
+	tests := []struct {
+		name     string
+		args     []string
+		detected fn.Platform
+		err      error
+		expected []fn.Platform
+	}{
+		{"detected", nil, arm64, nil, []fn.Platform{arm64}},
+		{"variant of the default", nil, fn.Platform{OS: "linux", Architecture: "arm"}, nil, []fn.Platform{{OS: "linux", Architecture: "arm", Variant: "v7"}}},
+		{"mixed or no nodes", nil, fn.Platform{}, nil, nil},
+		{"not a default platform", nil, fn.Platform{OS: "linux", Architecture: "s390x"}, nil, nil},
+		{"no cluster", nil, fn.Platform{}, errors.New("no cluster"), nil},
+		{"disabled", []string{"--cluster-platform=false"}, arm64, nil, nil},
+		{"pack builder", []string{"--builder=pack"}, arm64, nil, nil},
+	}
+	defer func(p func(context.Context) (fn.Platform, error)) { clusterPlatform = p }(clusterPlatform)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := FromTempDirectory(t)
+			if _, err := fn.New().Init(fn.Function{Runtime: "go", Root: root, Registry: TestRegistry}); err != nil {
+				t.Fatal(err)
+			}
+			clusterPlatform = func(context.Context) (fn.Platform, error) { return test.detected, test.err }
+
+			builder := mock.NewBuilder()
+			cmd := NewDeployCmd(NewTestClient(
+				fn.WithBuilder(builder),
+				fn.WithDeployer(mock.NewDeployer()),
+				fn.WithPusher(mock.NewPusher()),
+			))
+			cmd.SetArgs(append([]string{"--builder=host"}, test.args...))
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if !builder.BuildInvoked {
+				t.Fatal("expected the function to be built")
+			}
+			if !reflect.DeepEqual(builder.Platforms, test.expected) {
+				t.Fatalf("expected platforms %v, got %v", test.expected, builder.Platforms)
+			}
+		})
+	}
+}
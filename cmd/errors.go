@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+
+	"knative.dev/func/pkg/oci"
 )
 
 // wrapNotInitializedError wraps an ErrNotInitialized error with CLI-specific guidance
@@ -96,3 +99,123 @@ For more options, run 'func deploy --help'`, err)
 		return err
 	}
 }
+
+// wrapHostBuildError wraps typed host builder errors with CLI-specific
+// guidance.  Other errors are returned unchanged.
+func wrapHostBuildError(err error, command string) error {
+	var (
+		errRuntime   oci.ErrUnsupportedRuntime
+		errPlatform  oci.ErrUnsupportedPlatform
+		errBasePull  oci.ErrBasePull
+		errCompile   oci.ErrCompileFailed
+		errScaffold  oci.ErrScaffold
+		errHook      oci.ErrHookFailed
+		errCerts     oci.ErrCertsNotFound
+		errRepro     oci.ErrNotReproducible
+		errNotCached oci.ErrBaseNotCached
+		errRebase    oci.ErrNotRebasable
+		errLayers    oci.ErrTooManyLayers
+		errNoSpace   oci.ErrNoSpace
+	)
+	switch {
+	case errors.As(err, &errNoSpace): // of any phase, such as a base pull
+		return fmt.Errorf(`%w
+
+Free space on the device and build again, such as by removing the builds
+of the function or purging the blobs of the build caches:
+  func build --clean
+  func build --cache-clear
+Or build in a directory on a device with space:
+  func build --build-dir=<dir>`, err)
+
+	case errors.As(err, &errRuntime):
+		return fmt.Errorf(`%w
+
+Try a builder which supports this language:
+  func %v --builder=pack
+
+For more options, run 'func %v --help'`, err, command, command)
+
+	case errors.As(err, &errPlatform):
+		return fmt.Errorf(`%w
+
+Build for a platform which the %v runtime supports, for example:
+  func %v --platform=linux/amd64
+
+For more options, run 'func %v --help'`, err, errPlatform.Runtime, command, command)
+
+	// Before errBasePull, by which it is wrapped
+	case errors.As(err, &errNotCached):
+		return fmt.Errorf(`%w
+
+The base image is not pulled from its registry with the pull policy never.
+Build once with access to the registry to cache it:
+  func %v --base-image-pull-policy if-not-present`, err, command)
+
+	case errors.As(err, &errBasePull):
+		return fmt.Errorf(`%w
+
+Check that the base image %q exists, and that you are authenticated
+with its registry if it is private (for example with 'docker login').
+
+Or build upon the default base image by removing the override:
+  func %v --base-image=""
+
+For more options, run 'func %v --help'`, err, errBasePull.Ref, command, command)
+
+	case errors.As(err, &errCompile):
+		return fmt.Errorf(`%w
+
+The function's %v source failed to build.  Review the output above,
+fix the reported errors and try again.  For more detail run:
+  func %v --verbose`, err, errCompile.Runtime, command)
+
+	case errors.As(err, &errHook):
+		return fmt.Errorf(`%w
+
+A command of the function's build.%v failed.  Ensure it succeeds when
+run in the function's directory, or remove it from func.yaml.`, err, errHook.Hook)
+
+	case errors.As(err, &errCerts):
+		return fmt.Errorf(`%w
+
+The image's CA certificates are those embedded in func, written to the
+build directory with the scaffolding, or else those of the build host.
+Install the CA certificates of the build host (for example the
+ca-certificates package), or set SSL_CERT_FILE to a PEM bundle:
+  SSL_CERT_FILE=/path/to/ca-bundle.pem func %v`, err, command)
+
+	case errors.As(err, &errRepro):
+		return fmt.Errorf(`%w
+
+The image differed when built again from the same inputs.  Layers written
+by the build hold the modification times of their files, which differ
+between builds unless they are set to the Unix epoch:
+  func %v --zero-timestamps --verify-reproducible
+Otherwise, the layers reported are of inputs which are not deterministic.`, err, command)
+
+	case errors.As(err, &errLayers):
+		return fmt.Errorf(`%w
+
+Squash the function's layers into one, atop those of the base image, or
+all of the image's layers into one:
+  func %v --squash
+  func %v --squash=all
+Otherwise, raise the maximum with --max-layers.`, err, command, command)
+
+	case errors.As(err, &errRebase):
+		return fmt.Errorf(`%w
+
+Build the function from its source instead:
+  func %v`, err, command)
+
+	case errors.As(err, &errScaffold):
+		return fmt.Errorf(`%w
+
+The function could not be wrapped as a service.  Ensure the function
+matches the signature expected by its template (see 'func create --help').`, err)
+
+	default:
+		return err
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"knative.dev/func/pkg/builders"
+	"knative.dev/func/pkg/creds"
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci"
+)
+
+// Exit codes of the CLI by category of failure.  These are stable such that
+// they may be relied upon by scripts and CI.
+const (
+	ExitError     = 1   // Any error not otherwise categorized
+	ExitUsage     = 2   // Invalid usage or configuration
+	ExitCompile   = 3   // The function failed to compile or scaffold
+	ExitRegistry  = 4   // A registry error, such as pulling a base or pushing
+	ExitInterrupt = 130 // Interrupted (SIGINT/SIGTERM)
+	ExitKilled    = 137 // Interrupted a second time while exiting
+)
+
+// ExitCode returns the exit code for the given error returned from executing
+// a command.  A nil error is exit code zero.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var (
+		errNotInit        *fn.ErrNotInitialized
+		errNotRecognized  fn.ErrRuntimeNotRecognized
+		errUnknownBuilder builders.ErrUnknownBuilder
+		errRuntime        oci.ErrUnsupportedRuntime
+		errPlatform       oci.ErrUnsupportedPlatform
+		errCompile        oci.ErrCompileFailed
+		errScaffold       oci.ErrScaffold
+		errBasePull       oci.ErrBasePull
+		errNoSpace        oci.ErrNoSpace
+		errPush           fn.ErrPushFailed
+		errTransport      *transport.Error
+	)
+	switch {
+	case errors.As(err, &errNoSpace): // not of the registry, even when pulling
+		return ExitError
+	case errors.As(err, &errNotInit),
+		errors.As(err, &errNotRecognized),
+		errors.As(err, &errUnknownBuilder),
+		errors.As(err, &errRuntime),
+		errors.As(err, &errPlatform),
+		errors.Is(err, fn.ErrRegistryRequired),
+		errors.Is(err, fn.ErrConflictingImageAndRegistry),
+		errors.Is(err, fn.ErrInvalidImage),
+		errors.Is(err, fn.ErrPlatformNotSupported),
+		errors.Is(err, fn.ErrNameRequired),
+		errors.Is(err, fn.ErrRuntimeRequired):
+		return ExitUsage
+	case errors.As(err, &errCompile),
+		errors.As(err, &errScaffold):
+		return ExitCompile
+	case errors.As(err, &errBasePull),
+		errors.As(err, &errPush),
+		errors.As(err, &errTransport),
+		errors.Is(err, creds.ErrUnauthorized):
+		return ExitRegistry
+	}
+	return ExitError
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"knative.dev/func/pkg/creds"
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci"
+)
+
+// TestExitCode ensures errors are mapped to the exit code of their category,
+// including when wrapped.
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"nil", nil, 0},
+		{"generic", errors.New("generic"), ExitError},
+		{"not initialized", fn.NewErrNotInitialized("/f"), ExitUsage},
+		{"registry required", fn.ErrRegistryRequired, ExitUsage},
+		{"unsupported runtime", oci.ErrUnsupportedRuntime{Runtime: "cobol"}, ExitUsage},
+		{"unsupported platform", oci.ErrUnsupportedPlatform{Runtime: "python", Platform: "linux/riscv64"}, ExitUsage},
+		{"compile", oci.ErrCompileFailed{Runtime: "go", Err: errors.New("x")}, ExitCompile},
+		{"wrapped compile", fmt.Errorf("guidance. %w", oci.ErrCompileFailed{Runtime: "go"}), ExitCompile},
+		{"base pull", oci.ErrBasePull{Ref: "example.com/base"}, ExitRegistry},
+		{"push", fn.ErrPushFailed{Err: errors.New("x")}, ExitRegistry},
+		{"unauthorized", fn.ErrPushFailed{Err: creds.ErrUnauthorized}, ExitRegistry},
+		{"no space pulling", oci.ErrNoSpace{Dir: "/f/.func", Err: oci.ErrBasePull{Ref: "example.com/base"}}, ExitError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := ExitCode(tt.err); code != tt.code {
+				t.Fatalf("expected exit code %v, got %v", tt.code, code)
+			}
+		})
+	}
+}
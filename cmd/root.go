@@ -49,6 +49,14 @@ func NewRootCmd(cfg RootCommandConfig) *cobra.Command {
 	Deploy the function using Docker hub to host the image:
 	{{.Use}} deploy --registry docker.io/alice
 
+Exit status:
+	0    success
+	1    error
+	2    invalid usage or configuration
+	3    the function failed to compile
+	4    registry error, such as pulling a base image or pushing
+	130  interrupted
+
 Learn more about Functions:  https://knative.dev/docs/functions/
 Learn more about Knative at: https://knative.dev`, cfg.Name),
 
@@ -139,6 +147,28 @@ func registry() string {
 	return cfg.RegistryDefault()
 }
 
+// externalBuilds returns whether the host builder should place builds in the
+// user's cache directory rather than the function's .func directory.
+// FUNC_EXTERNAL_BUILDS takes precedence over the global config.
+func externalBuilds() bool {
+	if viper.IsSet("external-builds") {
+		return viper.GetBool("external-builds")
+	}
+	cfg, _ := config.NewDefault()
+	return cfg.ExternalBuilds
+}
+
+// defaultPlatforms returns the platforms the host builder builds when neither
+// the command nor the function give any, as a comma-separated list.
+// FUNC_PLATFORMS takes precedence over the global config.
+func defaultPlatforms() string {
+	if viper.IsSet("platforms") {
+		return viper.GetString("platforms")
+	}
+	cfg, _ := config.NewDefault()
+	return cfg.Platforms
+}
+
 // effectivePath to use is that which was provided by --path or FUNC_PATH.
 // Manually parses flags such that this can be used during (cobra/viper) flag
 // definition (prior to parsing).
@@ -404,6 +404,7 @@ func TestRun_DirectOverride(t *testing.T) {
 	const overrideImage = "registry/myrepo/myimage@sha256:0000000000000000000000000000000000000000000000000000000000000000"
 	root := FromTempDirectory(t)
 	runner := mock.NewRunner()
+	withContainerRuntime(t, true) // a containerized build
 
 	runner.RunFn = func(_ context.Context, f fn.Function, _ string, _ time.Duration) (*fn.Job, error) {
 		if f.Build.Image != overrideImage {
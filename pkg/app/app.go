@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"os/signal"
 	"syscall"
 
@@ -27,7 +26,7 @@ func Main() {
 		<-sigs
 		cancel()
 		<-sigs // second sigint/sigterm is treated as sigkill
-		os.Exit(137)
+		os.Exit(cmd.ExitKilled)
 	}()
 
 	// 主函数
@@ -44,11 +43,11 @@ func Main() {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		}
 		if ctx.Err() != nil || errors.Is(err, terminal.InterruptErr) {
-			os.Exit(130)
+			os.Exit(cmd.ExitInterrupt)
 		}
 
 		if errors.Is(err, docker.ErrNoDocker) {
-			if !dockerOrPodmanInstalled() {
+			if !cmd.DockerOrPodmanInstalled() {
 				fmt.Fprintln(os.Stderr, `Docker/Podman not installed.
 Please consider installing one of these:
   https://podman-desktop.io/
@@ -60,16 +59,7 @@ Please consider installing one of these:
 			}
 		}
 
-		os.Exit(1)
+		// 按错误类别退出,见 cmd.ExitCode
+		os.Exit(cmd.ExitCode(err))
 	}
 }
-
-// 容器运行时支持 podman或者docker
-func dockerOrPodmanInstalled() bool {
-	_, err := exec.LookPath("podman")
-	if err == nil {
-		return true
-	}
-	_, err = exec.LookPath("docker")
-	return err == nil
-}
@@ -42,6 +42,16 @@ type Global struct {
 	// getter/setter accessors to match requests.
 
 	RegistryInsecure bool `yaml:"registryInsecure,omitempty"`
+
+	// ExternalBuilds places the builds and blob cache of the host builder in
+	// the user's cache directory (see CacheDir) rather than the function's
+	// .func directory.
+	ExternalBuilds bool `yaml:"externalBuilds,omitempty"`
+
+	// Platforms are the platforms built by the host builder when neither the
+	// command nor the function (build.platforms) give any, as a comma-separated
+	// list of os/arch[/variant], for example "linux/amd64,linux/arm64".
+	Platforms string `yaml:"platforms,omitempty"`
 }
 
 // New Config struct with all members set to static defaults.  See NewDefaults
@@ -54,6 +64,23 @@ func New() Global {
 	}
 }
 
+// DefaultPlatforms returns the parsed platforms of the config, or nil if
+// none are configured.
+func (c Global) DefaultPlatforms() ([]fn.Platform, error) {
+	if strings.TrimSpace(c.Platforms) == "" {
+		return nil, nil
+	}
+	ss := strings.Split(c.Platforms, ",")
+	for i := range ss {
+		ss[i] = strings.TrimSpace(ss[i])
+	}
+	pp, err := fn.ParsePlatforms(ss)
+	if err != nil {
+		return nil, fmt.Errorf("invalid platforms in global config. %w", err)
+	}
+	return pp, nil
+}
+
 // RegistyDefault is a convenience method for deferred calculation of a
 // default registry taking into account both the global config file and cluster
 // detection.
@@ -103,6 +130,27 @@ func Load(path string) (c Global, err error) {
 	return
 }
 
+// Profile returns the named build profile from the "profiles" section of the
+// config file at path.  Profiles are not members of Global, as they are not
+// individually configurable options.  A missing file defines no profiles.
+func Profile(path, name string) (p fn.BuildProfile, ok bool, err error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	var c struct {
+		Profiles map[string]fn.BuildProfile `yaml:"profiles"`
+	}
+	if err = yaml.Unmarshal(bb, &c); err != nil {
+		return p, false, fmt.Errorf("error reading global config profiles: %v", err)
+	}
+	p, ok = c.Profiles[name]
+	return
+}
+
 // Write the config to the given path
 // To use the currently configured path (used by the constructor) use File()
 //
@@ -181,6 +229,27 @@ func Dir() (path string) {
 	return
 }
 
+// CacheDir is derived in the following order, from lowest to highest
+// precedence.
+//  1. The default path is the zero value, indicating "no cache path available".
+//  2. ~/.cache/func if it can be expanded (user has a home dir)
+//  3. The value of $XDG_CACHE_HOME/func if the environment variable exists.
+//
+// The path is not created.
+func CacheDir() (path string) {
+	// Use home if available
+	if home, err := os.UserHomeDir(); err == nil {
+		path = filepath.Join(home, ".cache", "func")
+	}
+
+	// 'XDG_CACHE_HOME/func' takes precedence if defined
+	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
+		path = filepath.Join(xdg, "func")
+	}
+
+	return
+}
+
 // File returns the full path at which to look for a config file.
 // Use FUNC_CONFIG_FILE to override default.
 func File() string {
@@ -41,6 +41,25 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+// TestProfile ensures that build profiles are read from the global config file,
+// and that both missing profiles and missing files define no profile.
+func TestProfile(t *testing.T) {
+	path := filepath.Join("testdata", "TestProfile", "func", "config.yaml")
+	p, ok, err := config.Profile(path, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || p.Registry != "example.com/prod" || p.BaseImage != "example.com/prod/base:latest" {
+		t.Fatalf("unexpected profile: %v %+v", ok, p)
+	}
+	if _, ok, err = config.Profile(path, "dev"); err != nil || ok {
+		t.Fatalf("expected no dev profile, got %v %v", ok, err)
+	}
+	if _, ok, err = config.Profile("invalid/path", "prod"); err != nil || ok {
+		t.Fatalf("expected no profile from a missing file, got %v %v", ok, err)
+	}
+}
+
 // TestWrite ensures that writing a config persists.
 func TestWrite(t *testing.T) {
 	root, cleanup := Mktemp(t)
@@ -85,6 +104,17 @@ func TestPath(t *testing.T) {
 	}
 }
 
+// TestCacheDir ensures that the cache path is within XDG_CACHE_HOME when
+// defined.
+func TestCacheDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", home)
+
+	if path := filepath.Join(home, "func"); config.CacheDir() != path {
+		t.Fatalf("expected cache path '%v', got '%v'", path, config.CacheDir())
+	}
+}
+
 // TestNewDefault ensures that the default returned from NewDefault includes
 // both the static defaults (see TestNewDefaults), as well as those from the
 // currently effective global config path (~/config/func).
@@ -104,6 +134,26 @@ func TestNewDefault(t *testing.T) {
 	}
 }
 
+// TestDefaultPlatforms ensures the platforms of the config are parsed from
+// a comma-separated list, and that none are returned when unset.
+func TestDefaultPlatforms(t *testing.T) {
+	pp, err := config.Global{}.DefaultPlatforms()
+	if err != nil || pp != nil {
+		t.Fatalf("expected no platforms, got %v (%v)", pp, err)
+	}
+	pp, err = config.Global{Platforms: "linux/amd64, linux/arm/v7"}.DefaultPlatforms()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fn.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm", Variant: "v7"}}
+	if !reflect.DeepEqual(pp, expected) {
+		t.Fatalf("expected %v, got %v", expected, pp)
+	}
+	if _, err = (config.Global{Platforms: "linux"}).DefaultPlatforms(); err == nil {
+		t.Fatal("expected an invalid platform to error")
+	}
+}
+
 // TestCreatePaths ensures that the paths are created when requested.
 func TestCreatePaths(t *testing.T) {
 	home, cleanup := Mktemp(t)
@@ -374,8 +424,10 @@ func TestList(t *testing.T) {
 	expected := []string{
 		"builder",
 		"confirm",
+		"externalBuilds",
 		"language",
 		"namespace",
+		"platforms",
 		"registry",
 		"registryInsecure",
 		"verbose",
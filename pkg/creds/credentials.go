@@ -89,6 +89,7 @@ type credentialsProvider struct {
 	promptForCredentialStore ChooseCredentialHelperCallback
 	credentialLoaders        []CredentialsCallback
 	authFilePath             string
+	dockerConfigDir          string
 	transport                http.RoundTripper
 }
 
@@ -125,6 +126,16 @@ func WithTransport(transport http.RoundTripper) Opt {
 	}
 }
 
+// WithDockerConfigDir sets the directory of the docker configuration
+// (config.json) from which credentials are read, such as in CI where the
+// home directory is not that of the user.  Defaults to $DOCKER_CONFIG or
+// ~/.docker (see oci.DockerConfigDir).
+func WithDockerConfigDir(dir string) Opt {
+	return func(opts *credentialsProvider) {
+		opts.dockerConfigDir = dir
+	}
+}
+
 // WithAdditionalCredentialLoaders adds custom callbacks for credential retrieval.
 // The callbacks shall return ErrCredentialsNotFound if the credentials are not found.
 // The callbacks are supposed to be non-interactive as opposed to WithPromptForCredentials.
@@ -199,14 +210,35 @@ func NewCredentialsProvider(configPath string, opts ...Opt) oci.CredentialsProvi
 			})
 	}
 
-	// add only if home dir is defined -- for .docker/config.json creds
-	home, err := os.UserHomeDir()
-	if err == nil {
-		dockerConfigPath := filepath.Join(home, ".docker", "config.json")
+	// add only if the docker config dir is defined -- for config.json creds,
+	// by way of its credential helper or otherwise.
+	if c.dockerConfigDir == "" {
+		c.dockerConfigDir = oci.DockerConfigDir()
+	}
+	if c.dockerConfigDir != "" {
+		dockerConfigPath := filepath.Join(c.dockerConfigDir, "config.json")
 		defaultCredentialLoaders = append(defaultCredentialLoaders,
 			func(registry string) (oci.Credentials, error) {
 				return getCredentialsByCredentialHelper(dockerConfigPath, registry)
 			})
+		defaultCredentialLoaders = append(defaultCredentialLoaders,
+			func(registry string) (oci.Credentials, error) {
+				if _, err := os.Stat(dockerConfigPath); err != nil {
+					return oci.Credentials{}, ErrCredentialsNotFound
+				}
+				dockerSys := &containersTypes.SystemContext{DockerCompatAuthFilePath: dockerConfigPath}
+				creds, err := dockerConfig.GetCredentials(dockerSys, registry)
+				if err != nil {
+					return oci.Credentials{}, err
+				}
+				if creds.Username == "" || creds.Password == "" {
+					return oci.Credentials{}, ErrCredentialsNotFound
+				}
+				return oci.Credentials{
+					Username: creds.Username,
+					Password: creds.Password,
+				}, nil
+			})
 	}
 	defaultCredentialLoaders = append(defaultCredentialLoaders,
 		func(registry string) (oci.Credentials, error) {
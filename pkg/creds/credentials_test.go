@@ -500,6 +500,38 @@ func TestNewCredentialsProviderEmptyCreds(t *testing.T) {
 	}
 }
 
+// TestNewCredentialsProviderDockerConfigDir ensures credentials are read
+// from the config.json of the docker configuration directory provided,
+// rather than that of the home directory.
+func TestNewCredentialsProviderDockerConfigDir(t *testing.T) {
+	resetHomeDir(t)
+	t.Setenv("DOCKER_CONFIG", "")
+
+	dir := t.TempDir()
+	auth := base64.StdEncoding.EncodeToString([]byte(quayIoUser + ":" + quayIoUserPwd))
+	config := `{"auths": {"registry.example.com": {"auth": "` + auth + `"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	credentialsProvider := creds.NewCredentialsProvider(testConfigPath(t),
+		creds.WithDockerConfigDir(dir),
+		creds.WithPromptForCredentials(pwdCbkThatShallNotBeCalled(t)),
+		creds.WithVerifyCredentials(func(ctx context.Context, image string, credentials Credentials) error {
+			if credentials != (Credentials{Username: quayIoUser, Password: quayIoUserPwd}) {
+				return creds.ErrUnauthorized
+			}
+			return nil
+		}))
+	c, err := credentialsProvider(context.Background(), "registry.example.com/someorg/someimage:sometag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != (Credentials{Username: quayIoUser, Password: quayIoUserPwd}) {
+		t.Fatalf("expected the credentials of the docker config directory, got %+v", c)
+	}
+}
+
 func TestCredentialsProviderSavingFromUserInput(t *testing.T) {
 	resetHomeDir(t)
 
@@ -16,6 +16,7 @@ import (
 	"strings"
 	"time"
 
+	gitignore "github.com/sabhiram/go-gitignore"
 	"gopkg.in/yaml.v2"
 
 	"knative.dev/func/pkg/scaffolding"
@@ -408,6 +409,18 @@ func (c *Client) Instances() *InstanceRefs {
 	return c.instances
 }
 
+// Builder accessor, such that the results of builders which report them
+// may be read after building.
+func (c *Client) Builder() Builder {
+	return c.builder
+}
+
+// Pusher accessor, such that pushers which also push elsewhere (such as to
+// mirrors) may be used after pushing.
+func (c *Client) Pusher() Pusher {
+	return c.pusher
+}
+
 // Repository accessor returns the default registry for use when building
 // Functions which do not specify Registry or Image name explicitly.
 func (c *Client) Registry() string {
@@ -619,6 +632,7 @@ func (c *Client) Init(cfg Function) (Function, error) {
 
 type BuildOptions struct {
 	Platforms []Platform
+	Quiet     bool
 }
 
 type BuildOption func(c *BuildOptions)
@@ -629,26 +643,36 @@ func BuildWithPlatforms(pp []Platform) BuildOption {
 	}
 }
 
+// BuildWithQuiet suppresses the client's progress messages while building.
+// Note the builder's own output is configured on the builder.
+func BuildWithQuiet(quiet bool) BuildOption {
+	return func(c *BuildOptions) {
+		c.Quiet = quiet
+	}
+}
+
 // Build the function at path. Errors if the function is either unloadable or does
 // not contain a populated Image.
 func (c *Client) Build(ctx context.Context, f Function, options ...BuildOption) (Function, error) {
-	fmt.Fprintf(os.Stderr, "Building function image\n")
+	// Options for the build task
+	oo := BuildOptions{}
+	for _, o := range options {
+		o(&oo)
+	}
+
+	if !oo.Quiet {
+		fmt.Fprintf(os.Stderr, "Building function image\n")
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	// If not logging verbosely, the ongoing progress of the build will not
 	// be streaming to stdout, and the lack of activity has been seen to cause
 	// users to prematurely exit due to the sluggishness of pulling large images
-	if !c.verbose {
+	if !c.verbose && !oo.Quiet {
 		c.printBuildActivity(ctx) // print friendly messages until context is canceled
 	}
 
-	// Options for the build task
-	oo := BuildOptions{}
-	for _, o := range options {
-		o(&oo)
-	}
-
 	// Default function registry to the client's global registry
 	if f.Registry == "" {
 		f.Registry = c.registry
@@ -684,7 +708,9 @@ func (c *Client) Build(ctx context.Context, f Function, options ...BuildOption)
 	if runtime.GOOS == "windows" {
 		message = fmt.Sprintf("Function built: %v", f.Build.Image)
 	}
-	fmt.Fprintf(os.Stderr, "%s\n", message)
+	if !oo.Quiet {
+		fmt.Fprintf(os.Stderr, "%s\n", message)
+	}
 
 	return f, err
 }
@@ -1098,7 +1124,7 @@ func (c *Client) Push(ctx context.Context, f Function) (Function, bool, error) {
 
 	imageDigest, err := c.pusher.Push(ctx, f)
 	if err != nil {
-		return f, false, err
+		return f, false, ErrPushFailed{err}
 	}
 
 	// TODO: gauron99 - this is here because of a temporary workaround.
@@ -1213,18 +1239,26 @@ func ensureFuncIgnore(root string) error {
 }
 
 // Fingerprint the files at a given path.  Returns a hash calculated from the
-// filenames and modification timestamps of the files within the given root.
-// Also returns a logfile consiting of the filenames and modification times
-// which contributed to the hash.
+// filenames (relative to root) and modification timestamps of the files within
+// the given root.  Also returns a logfile consiting of the filenames and
+// modification times which contributed to the hash.
 // Intended to determine if there were appreciable changes to a function's
-// source code, certain directories and files are ignored, such as
-// .git and .func.
-// Future updates will include files explicitly marked as ignored by a
-// .funcignore.
+// source code, the .func and .git directories, and files matching the
+// .funcignore, are ignored, such that building (which writes only to .func)
+// does not change the fingerprint.  Directories contribute only by way of the
+// files within, as their modification times change with ignored files.
 func Fingerprint(root string) (hash, log string, err error) {
 	h := sha256.New()   // Hash builder
 	l := bytes.Buffer{} // Log buffer
 
+	ignore, err := gitignore.CompileIgnoreFile(filepath.Join(root, ".funcignore"))
+	if os.IsNotExist(err) {
+		ignore, err = gitignore.CompileIgnoreLines(), nil
+	}
+	if err != nil {
+		return
+	}
+
 	err = filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -1232,12 +1266,29 @@ func Fingerprint(root string) (hash, log string, err error) {
 		if path == root {
 			return nil
 		}
-		// Always ignore .func, .git (TODO: .funcignore)
-		if info.IsDir() && (info.Name() == RunDataDir || info.Name() == ".git") {
-			return filepath.SkipDir
+		// Always ignore .func and .git
+		if info.Name() == RunDataDir || info.Name() == ".git" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if ignore.MatchesPath(rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
 		}
-		fmt.Fprintf(h, "%v:%v:", path, info.ModTime().UnixNano())   // Write to the Hasher
-		fmt.Fprintf(&l, "%v:%v\n", path, info.ModTime().UnixNano()) // Write to the Log
+		fmt.Fprintf(h, "%v:%v:", rel, info.ModTime().UnixNano())   // Write to the Hasher
+		fmt.Fprintf(&l, "%v:%v\n", rel, info.ModTime().UnixNano()) // Write to the Log
 		return nil
 	})
 	return fmt.Sprintf("%x", h.Sum(nil)), l.String(), err
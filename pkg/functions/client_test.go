@@ -2124,6 +2124,65 @@ func TestClient_BuildCleanFingerprint(t *testing.T) {
 	}
 }
 
+// TestFingerprint_Ignored ensures the fingerprint of a function reflects only
+// its source: it is unchanged by writes to .func (such as builds), .git or
+// files matching .funcignore, and is independent of the function's location,
+// but changes with the source.
+func TestFingerprint_Ignored(t *testing.T) {
+	root, cleanup := Mktemp(t)
+	defer cleanup()
+	write := func(path string) {
+		t.Helper()
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fingerprint := func(root string) string {
+		t.Helper()
+		hash, _, err := fn.Fingerprint(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	write("handle.go")
+	write("node_modules/a/index.js")
+	write(".funcignore")
+	if err := os.WriteFile(filepath.Join(root, ".funcignore"), []byte("node_modules\n*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash := fingerprint(root)
+
+	write(".func/builds/by-hash/abc/result/f.linux.amd64")
+	write(".git/HEAD")
+	write("node_modules/b/index.js")
+	write("debug.log")
+	if h := fingerprint(root); h != hash {
+		t.Fatal("expected the fingerprint to be unchanged by ignored files")
+	}
+
+	// The same source elsewhere has the same fingerprint
+	moved := filepath.Join(t.TempDir(), "moved")
+	if err := os.Rename(root, moved); err != nil {
+		t.Fatal(err)
+	}
+	if h := fingerprint(moved); h != hash {
+		t.Fatal("expected the fingerprint to be independent of the function's location")
+	}
+	if err := os.Rename(moved, root); err != nil {
+		t.Fatal(err)
+	}
+
+	write("pkg/util.go")
+	if h := fingerprint(root); h == hash {
+		t.Fatal("expected the fingerprint to change with the source")
+	}
+}
+
 // TestClient_DeployRemoves ensures that the Remover is invoked when a
 // function is moved to a new namespace.
 // specifically: deploy to 'nsone' -> simulate change of namespace with change to
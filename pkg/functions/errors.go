@@ -34,6 +34,10 @@ var (
 
 	// ErrConflictingImageAndRegistry is returned when both --image and --registry flags are explicitly provided
 	ErrConflictingImageAndRegistry = errors.New("both --image and --registry flags provided")
+
+	// ErrInvalidImage is returned when the function's image, explicit or
+	// computed from the registry and name, is not a valid image reference.
+	ErrInvalidImage = errors.New("invalid image")
 )
 
 // ErrNotInitialized indicates that a function is uninitialized
@@ -87,3 +91,17 @@ type ErrEnvNotExist struct {
 func (e ErrEnvNotExist) Error() string {
 	return fmt.Sprintf("environment variable %q does not exist", e.Name)
 }
+
+// ErrPushFailed indicates the function's image could not be pushed to its
+// registry.
+type ErrPushFailed struct {
+	Err error
+}
+
+func (e ErrPushFailed) Error() string {
+	return fmt.Sprintf("failed to push function image. %v", e.Err)
+}
+
+func (e ErrPushFailed) Unwrap() error {
+	return e.Err
+}
@@ -157,6 +157,77 @@ type BuildSpec struct {
 
 	// Mounts used in build phase. This is useful in particular for paketo bindings.
 	Mounts []MountSpec `yaml:"volumes,omitempty"`
+
+	// Profiles are named sets of build settings which can be selected when
+	// building (--profile), such as a registry and base image per environment.
+	Profiles map[string]BuildProfile `yaml:"profiles,omitempty"`
+
+	// Annotations are OCI annotations added to the function's image index and
+	// manifests (host builder only).  Keys are in reverse domain notation,
+	// for example "com.example.team".
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+
+	// NoScaffold builds the function's source as-is, without wrapping it in
+	// the scaffolding which instantiates it as a service (host builder, go
+	// only).  The function must then be a main package which itself serves
+	// on $LISTEN_ADDRESS.
+	NoScaffold bool `yaml:"noScaffold,omitempty"`
+
+	// BuildTags are Go build tags with which the function is compiled, for
+	// example "prod" to include files constrained by //go:build prod (host
+	// builder, go only).  The platform's GOOS and GOARCH are implied, and
+	// "cgo" is never satisfied as functions are built with CGO_ENABLED=0.
+	BuildTags []string `yaml:"buildTags,omitempty"`
+
+	// Platforms are those for which the function's image is built when none
+	// are given, in the form os/arch[/variant], for example "linux/amd64" (host
+	// builder only).  When empty, those of the global config (platforms), or
+	// else of the builder, are built.
+	Platforms []string `yaml:"platforms,omitempty"`
+
+	// MiddlewareVersion pins the version of the middleware with which the
+	// scaffolding serves the function (knative.dev/func-go), in place of that
+	// which the scaffolding requires, for example to pick up a security fix
+	// (host builder, go only).  Must be a semantic version such as "v0.21.4".
+	MiddlewareVersion string `yaml:"middlewareVersion,omitempty"`
+
+	// Replace are replace directives added to the go.mod of the scaffolding,
+	// replacing a module (path or path@version) by a directory relative to
+	// the function, or by a module at a version, for example to develop
+	// against a local fork of the middleware (host builder, go only).  The
+	// function's own go.mod is not modified.
+	Replace map[string]string `yaml:"replace,omitempty"`
+
+	// Files are files or directories of the function placed at paths of its
+	// image outside of /func, in a layer of their own, such as configuration
+	// files or static assets expected at a specific path (host builder only).
+	Files []FileSpec `yaml:"files,omitempty"`
+
+	// Groups are IDs of supplementary groups of the function's user, for
+	// example to access volumes mounted with the permissions of a group
+	// (host builder only).  The function's user is added to each in the
+	// image's /etc/group, from which container runtimes set the supplementary
+	// groups of its process.
+	Groups []int `yaml:"groups,omitempty"`
+
+	// PreBuild are commands run in the function's root, in order, before it is
+	// built, such as code generators whose output is then built with the
+	// function (host builder only).  Each is run by the shell with the build
+	// environment (buildEnvs), and the build fails if any fails.
+	PreBuild []string `yaml:"preBuild,omitempty"`
+
+	// PostBuild are commands run in the function's root, in order, once its
+	// image is built and before it is pushed, such as signing, scanning or
+	// notification (host builder only).  Each is run by the shell with the
+	// build environment (buildEnvs) and the built image (FUNC_OCI_LAYOUT,
+	// FUNC_IMAGE_DIGEST and FUNC_FINGERPRINT), and the build fails if any
+	// fails.
+	PostBuild []string `yaml:"postBuild,omitempty"`
+
+	// Scan configures a scan of the built image for vulnerabilities, which
+	// fails the build if any of at least the configured severity are found
+	// (host builder only).
+	Scan Scan `yaml:"scan,omitempty"`
 }
 
 type MountSpec struct {
@@ -166,6 +237,25 @@ type MountSpec struct {
 	Destination string `yaml:"path"`
 }
 
+// BuildProfile is a named set of build settings which, when selected, are
+// layered over the function's own settings.  Empty values are not applied.
+type BuildProfile struct {
+	// Registry to use in place of the function's registry.
+	Registry string `yaml:"registry,omitempty"`
+
+	// Builder to use in place of the function's builder.
+	Builder string `yaml:"builder,omitempty" jsonschema:"enum=pack,enum=s2i,enum=host"`
+
+	// BuilderImage to use for the profile's (or function's) builder.
+	BuilderImage string `yaml:"builderImage,omitempty"`
+
+	// BaseImage to build upon (host builder only).
+	BaseImage string `yaml:"baseImage,omitempty"`
+
+	// Labels added to the function, replacing those with the same key.
+	Labels []Label `yaml:"labels,omitempty"`
+}
+
 // RunSpec
 type RunSpec struct {
 	// List of volumes to be mounted to the function
@@ -179,6 +269,14 @@ type RunSpec struct {
 	// with containerized docker runner and deployed Knative service integration
 	// in development.
 	StartTimeout time.Duration `yaml:"startTimeout,omitempty"`
+
+	// Ports on which the function serves in addition to the primary HTTP
+	// port, such as gRPC or metrics.
+	Ports []Port `yaml:"ports,omitempty"`
+
+	// Metrics exposes a Prometheus metrics port (9090/tcp) in the image, and
+	// instructs the function to serve metrics there via $METRICS_ADDRESS.
+	Metrics bool `yaml:"metrics,omitempty"`
 }
 
 // DeploySpec
@@ -329,11 +427,22 @@ func (f Function) Validate() error {
 	var ctr int
 	errs := [][]string{
 		validateVolumes(f.Run.Volumes),
+		validatePorts(f.Run.Ports),
+		validateMetrics(f.Run),
 		ValidateBuildEnvs(f.Build.BuildEnvs),
 		ValidateEnvs(f.Run.Envs),
 		validateOptions(f.Deploy.Options),
 		ValidateLabels(f.Deploy.Labels),
 		validateGit(f.Build.Git),
+		ValidateBuildTags(f.Build.BuildTags),
+		ValidatePlatforms(f.Build.Platforms),
+		ValidateMiddlewareVersion(f.Build.MiddlewareVersion),
+		ValidateReplace(f.Build.Replace),
+		ValidateFiles(f.Build.Files),
+		ValidateGroups(f.Build.Groups),
+		ValidateHook("preBuild", f.Build.PreBuild),
+		ValidateHook("postBuild", f.Build.PostBuild),
+		validateScan(f.Build.Scan),
 	}
 
 	var b strings.Builder
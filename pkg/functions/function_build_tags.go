@@ -0,0 +1,27 @@
+package functions
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// buildTag is the format of a Go build tag: letters, digits, underscores and
+// dots, as accepted by the go toolchain's -tags flag.  This also ensures a
+// tag can not inject further arguments or separate into several tags.
+var buildTag = regexp.MustCompile(`^[a-zA-Z0-9_.]+$`)
+
+// ValidateBuildTags checks that the Go build tags (build.buildTags) are each
+// a single valid tag, and not repeated.
+// Returns array of error messages, empty if no errors are found
+func ValidateBuildTags(tags []string) (errors []string) {
+	seen := map[string]bool{}
+	for i, tag := range tags {
+		if !buildTag.MatchString(tag) {
+			errors = append(errors, fmt.Sprintf("build tag entry #%d %q is not valid: must contain only letters, digits, underscores and dots", i, tag))
+		} else if seen[tag] {
+			errors = append(errors, fmt.Sprintf("build tag entry #%d %q is a duplicate", i, tag))
+		}
+		seen[tag] = true
+	}
+	return
+}
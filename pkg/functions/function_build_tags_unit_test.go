@@ -0,0 +1,31 @@
+package functions
+
+import (
+	"testing"
+)
+
+func Test_ValidateBuildTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		errs int
+	}{
+		{"correct entry - single tag", []string{"prod"}, 0},
+		{"correct entry - multiple tags", []string{"prod", "netgo", "go1.22", "with_metrics"}, 0},
+		{"incorrect entry - empty", []string{""}, 1},
+		{"incorrect entry - comma separated", []string{"prod,debug"}, 1},
+		{"incorrect entry - space separated", []string{"prod debug"}, 1},
+		{"incorrect entry - flag injection", []string{"-ldflags=-X"}, 1},
+		{"incorrect entry - shell metacharacters", []string{"prod;rm"}, 1},
+		{"incorrect entry - negation", []string{"!prod"}, 1},
+		{"incorrect entry - duplicate", []string{"prod", "prod"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateBuildTags(tt.tags); len(got) != tt.errs {
+				t.Errorf("ValidateBuildTags() = %v\n got %d errors but want %d", got, len(got), tt.errs)
+			}
+		})
+	}
+}
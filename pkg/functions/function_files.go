@@ -0,0 +1,105 @@
+package functions
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FileSpec is a file or directory of the function placed at a path of its
+// image outside of /func, where its source is, such as a configuration file
+// expected at /etc/myapp/config.yaml (host builder only).  For example:
+// files:
+//   - source: config/prod.yaml
+//     path: /etc/myapp/config.yaml
+//     mode: "0640"
+type FileSpec struct {
+	// Source is the file or directory, relative to the function's root.
+	Source string `yaml:"source"`
+
+	// Path is the absolute path at which it is placed in the image.
+	Path string `yaml:"path"`
+
+	// Mode of the files (octal), such as "0644".  Defaults to that of the
+	// source.  Directories are always 0755.
+	Mode string `yaml:"mode,omitempty"`
+
+	// Owner of the files and directories as uid[:gid], such as "0:0".
+	// Defaults to the function's user (1000:1000).
+	Owner string `yaml:"owner,omitempty"`
+}
+
+// ProtectedPaths of the image at, within or over which files of the function
+// may not be placed (see FileSpec), being the function's source, the CA
+// certificates of the image, system binaries and libraries, and the system's
+// users.
+var ProtectedPaths = []string{
+	"/func",
+	"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32",
+	"/usr/bin", "/usr/sbin", "/usr/lib", "/usr/lib32", "/usr/lib64", "/usr/libexec",
+	"/etc/passwd", "/etc/group", "/etc/shadow", "/etc/gshadow", "/etc/sudoers",
+	"/etc/ssl/certs", "/etc/pki/tls/certs",
+	"/dev", "/proc", "/sys",
+}
+
+// ValidateFiles checks that the files placed in the image (build.files) are
+// each of a source within the function, at a distinct absolute path which is
+// not a protected path (see ProtectedPaths), with a valid mode and owner.
+// Whether a source exists is checked when building.
+// Returns array of error messages, empty if no errors are found
+func ValidateFiles(files []FileSpec) (errors []string) {
+	paths := map[string]bool{}
+	for i, f := range files {
+		if f.Source == "" || !filepath.IsLocal(filepath.FromSlash(f.Source)) {
+			errors = append(errors, fmt.Sprintf("file %d: source %q is not valid: must be a path within the function", i, f.Source))
+		}
+		if !path.IsAbs(f.Path) || path.Clean(f.Path) != f.Path || f.Path == "/" {
+			errors = append(errors, fmt.Sprintf("file %d: path %q is not valid: must be a clean absolute path, such as /etc/myapp/config.yaml", i, f.Path))
+		} else if p := protectedPath(f.Path); p != "" {
+			errors = append(errors, fmt.Sprintf("file %d: path %q is not valid: may not be at, within or over the protected path %v", i, f.Path, p))
+		} else if paths[f.Path] {
+			errors = append(errors, fmt.Sprintf("file %d: path %q is not valid: placed more than once", i, f.Path))
+		}
+		paths[f.Path] = true
+		if f.Mode != "" {
+			if mode, err := strconv.ParseUint(f.Mode, 8, 32); err != nil || mode > 0777 {
+				errors = append(errors, fmt.Sprintf("file %d: mode %q is not valid: must be octal permissions, such as \"0644\"", i, f.Mode))
+			}
+		}
+		if f.Owner != "" {
+			if _, _, err := ParseOwner(f.Owner); err != nil {
+				errors = append(errors, fmt.Sprintf("file %d: owner %q is not valid: %v", i, f.Owner, err))
+			}
+		}
+	}
+	return
+}
+
+// ParseOwner parses an owner in the form uid[:gid], where the gid defaults to
+// the uid.
+func ParseOwner(owner string) (uid, gid int, err error) {
+	u, g, ok := strings.Cut(owner, ":")
+	if !ok {
+		g = u
+	}
+	if uid, err = strconv.Atoi(u); err != nil || uid < 0 {
+		return 0, 0, fmt.Errorf("must be a numeric uid[:gid], such as \"0:0\"")
+	}
+	if gid, err = strconv.Atoi(g); err != nil || gid < 0 {
+		return 0, 0, fmt.Errorf("must be a numeric uid[:gid], such as \"0:0\"")
+	}
+	return uid, gid, nil
+}
+
+// protectedPath returns the protected path which the given path is at,
+// within or over (an ancestor of), or empty if none.
+func protectedPath(p string) string {
+	for _, protected := range ProtectedPaths {
+		if p == protected || strings.HasPrefix(p, protected+"/") || strings.HasPrefix(protected, p+"/") {
+			return protected
+		}
+	}
+	return ""
+}
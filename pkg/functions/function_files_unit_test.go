@@ -0,0 +1,40 @@
+package functions
+
+import (
+	"testing"
+)
+
+func Test_ValidateFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []FileSpec
+		errs  int
+	}{
+		{"correct entry - none", nil, 0},
+		{"correct entry - file", []FileSpec{{Source: "config.yaml", Path: "/etc/myapp/config.yaml"}}, 0},
+		{"correct entry - directory", []FileSpec{{Source: "static", Path: "/srv/static", Mode: "0644", Owner: "0:0"}}, 0},
+		{"correct entry - owner uid", []FileSpec{{Source: "config.yaml", Path: "/opt/config.yaml", Owner: "65532"}}, 0},
+		{"incorrect entry - missing source", []FileSpec{{Path: "/etc/myapp/config.yaml"}}, 1},
+		{"incorrect entry - source escapes", []FileSpec{{Source: "../secret", Path: "/etc/myapp/secret"}}, 1},
+		{"incorrect entry - absolute source", []FileSpec{{Source: "/etc/hosts", Path: "/etc/myapp/hosts"}}, 1},
+		{"incorrect entry - relative path", []FileSpec{{Source: "config.yaml", Path: "etc/config.yaml"}}, 1},
+		{"incorrect entry - unclean path", []FileSpec{{Source: "config.yaml", Path: "/etc/myapp/../passwd"}}, 1},
+		{"incorrect entry - root", []FileSpec{{Source: "static", Path: "/"}}, 1},
+		{"incorrect entry - function source", []FileSpec{{Source: "config.yaml", Path: "/func/config.yaml"}}, 1},
+		{"incorrect entry - system binaries", []FileSpec{{Source: "sh", Path: "/bin/sh"}}, 1},
+		{"incorrect entry - over system libraries", []FileSpec{{Source: "static", Path: "/usr"}}, 1},
+		{"incorrect entry - users", []FileSpec{{Source: "passwd", Path: "/etc/passwd"}}, 1},
+		{"incorrect entry - duplicate path", []FileSpec{{Source: "a", Path: "/opt/a"}, {Source: "b", Path: "/opt/a"}}, 1},
+		{"incorrect entry - setuid mode", []FileSpec{{Source: "a", Path: "/opt/a", Mode: "4755"}}, 1},
+		{"incorrect entry - mode", []FileSpec{{Source: "a", Path: "/opt/a", Mode: "rw-r--r--"}}, 1},
+		{"incorrect entry - owner", []FileSpec{{Source: "a", Path: "/opt/a", Owner: "root"}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateFiles(tt.files); len(got) != tt.errs {
+				t.Errorf("ValidateFiles() = %v\n got %d errors but want %d", got, len(got), tt.errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package functions
+
+import (
+	"fmt"
+)
+
+// MaxGid is the greatest group ID which may be a supplementary group of the
+// function (see BuildSpec.Groups).
+const MaxGid = 1<<31 - 1
+
+// ValidateGroups checks that the supplementary groups of the function's user
+// (build.groups) are distinct group IDs.
+// Returns array of error messages, empty if no errors are found
+func ValidateGroups(groups []int) (errors []string) {
+	seen := map[int]bool{}
+	for _, gid := range groups {
+		if gid < 0 || gid > MaxGid {
+			errors = append(errors, fmt.Sprintf("group %d is not valid: must be a group ID from 0 to %d", gid, MaxGid))
+		} else if seen[gid] {
+			errors = append(errors, fmt.Sprintf("group %d is not valid: listed more than once", gid))
+		}
+		seen[gid] = true
+	}
+	return
+}
@@ -0,0 +1,28 @@
+package functions
+
+import (
+	"testing"
+)
+
+func Test_ValidateGroups(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []int
+		errs   int
+	}{
+		{"correct entry - none", nil, 0},
+		{"correct entry - groups", []int{2000, 65534}, 0},
+		{"correct entry - root group", []int{0}, 0},
+		{"incorrect entry - negative", []int{-1}, 1},
+		{"incorrect entry - too large", []int{MaxGid + 1}, 1},
+		{"incorrect entry - duplicate", []int{2000, 2000}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateGroups(tt.groups); len(got) != tt.errs {
+				t.Errorf("ValidateGroups() = %v\n got %d errors but want %d", got, len(got), tt.errs)
+			}
+		})
+	}
+}
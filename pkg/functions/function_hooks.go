@@ -0,0 +1,18 @@
+package functions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateHook checks that the commands of a build hook (build.preBuild or
+// build.postBuild) are not empty.
+// Returns array of error messages, empty if no errors are found
+func ValidateHook(hook string, commands []string) (errors []string) {
+	for i, c := range commands {
+		if strings.TrimSpace(c) == "" {
+			errors = append(errors, fmt.Sprintf("%v command %d is not valid: must not be empty", hook, i+1))
+		}
+	}
+	return
+}
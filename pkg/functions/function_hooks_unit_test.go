@@ -0,0 +1,26 @@
+package functions
+
+import (
+	"testing"
+)
+
+func Test_ValidateHook(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		errs     int
+	}{
+		{"correct entry - none", nil, 0},
+		{"correct entry - commands", []string{"go generate ./...", "templ generate"}, 0},
+		{"incorrect entry - empty", []string{""}, 1},
+		{"incorrect entry - blank", []string{"go generate ./...", "  "}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateHook("preBuild", tt.commands); len(got) != tt.errs {
+				t.Errorf("ValidateHook() = %v\n got %d errors but want %d", got, len(got), tt.errs)
+			}
+		})
+	}
+}
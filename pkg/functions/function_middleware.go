@@ -0,0 +1,19 @@
+package functions
+
+import (
+	"fmt"
+
+	"golang.org/x/mod/semver"
+)
+
+// ValidateMiddlewareVersion checks that the version of the middleware
+// (build.middlewareVersion) is a semantic version of a module, such as
+// v0.21.4 or a pseudo-version, prefixed with "v".  Empty is the version of
+// the scaffolding.
+// Returns array of error messages, empty if no errors are found
+func ValidateMiddlewareVersion(version string) (errors []string) {
+	if version != "" && !semver.IsValid(version) {
+		errors = append(errors, fmt.Sprintf("middleware version %q is not valid: must be a semantic version such as \"v0.21.4\"", version))
+	}
+	return
+}
@@ -0,0 +1,30 @@
+package functions
+
+import (
+	"testing"
+)
+
+func Test_ValidateMiddlewareVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		errs    int
+	}{
+		{"correct entry - empty", "", 0},
+		{"correct entry - release", "v0.21.4", 0},
+		{"correct entry - prerelease", "v0.22.0-rc.1", 0},
+		{"correct entry - pseudo-version", "v0.21.4-0.20240501120000-0123456789ab", 0},
+		{"incorrect entry - missing v", "0.21.4", 1},
+		{"incorrect entry - branch", "main", 1},
+		{"incorrect entry - latest", "latest", 1},
+		{"incorrect entry - path", "../func-go", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateMiddlewareVersion(tt.version); len(got) != tt.errs {
+				t.Errorf("ValidateMiddlewareVersion() = %v\n got %d errors but want %d", got, len(got), tt.errs)
+			}
+		})
+	}
+}
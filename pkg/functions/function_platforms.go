@@ -0,0 +1,149 @@
+package functions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// platformPart is the format of each of a platform's os, architecture and
+// variant, such as "linux", "amd64" and "v7".
+var platformPart = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// platformVariant is the format of a variant, with or without its "v"
+// prefix, and armVariant that of an arm architecture naming its variant,
+// such as "armv7".
+var (
+	platformVariant = regexp.MustCompile(`^v?([0-9]+(\.[0-9]+)?)$`)
+	armVariant      = regexp.MustCompile(`^arm(v[0-9]+)$`)
+)
+
+// platformVariants are the variants of each architecture which has them, in
+// canonical form: the arm versions (GOARM), the arm64 versions and the amd64
+// microarchitecture levels (GOAMD64).  Other architectures have none.
+var platformVariants = map[string]*regexp.Regexp{
+	"arm":   regexp.MustCompile(`^v[5-7]$`),
+	"arm64": regexp.MustCompile(`^v(8|9)(\.[0-9])?$`),
+	"amd64": regexp.MustCompile(`^v[1-4]$`),
+}
+
+// ParsePlatform parses a platform in the form os/arch[/variant], for example
+// "linux/amd64" or "linux/arm/v7", which is normalized (see
+// NormalizePlatform).
+func ParsePlatform(s string) (p Platform, err error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return p, fmt.Errorf("platform %q is not valid: must be in the form os/arch[/variant], for example \"linux/amd64\"", s)
+	}
+	for _, part := range parts {
+		if !platformPart.MatchString(part) {
+			return p, fmt.Errorf("platform %q is not valid: must be in the form os/arch[/variant], for example \"linux/amd64\"", s)
+		}
+	}
+	p = Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return NormalizePlatform(p)
+}
+
+// NormalizePlatform returns the platform with its variant in canonical form,
+// as expected by the builders: "v" followed by its version, such that "7",
+// "v7" and an architecture of "armv7" are each arm with the variant v7.  A
+// variant must be one of its architecture, such as an amd64
+// microarchitecture level (v1 to v4); architectures other than arm, arm64
+// and amd64 have none.
+func NormalizePlatform(p Platform) (Platform, error) {
+	invalid := func(reason string) error {
+		return fmt.Errorf("platform %q is not valid: %v", platformString(p), reason)
+	}
+	n := p
+	if m := armVariant.FindStringSubmatch(p.Architecture); m != nil {
+		n.Architecture = "arm"
+		if p.Variant != "" && strings.TrimPrefix(p.Variant, "v") != strings.TrimPrefix(m[1], "v") {
+			return p, invalid(fmt.Sprintf("the architecture %v and variant %v differ", p.Architecture, p.Variant))
+		}
+		n.Variant = m[1]
+	}
+	if n.Variant == "" {
+		return n, nil
+	}
+	m := platformVariant.FindStringSubmatch(n.Variant)
+	if m == nil {
+		return p, invalid(fmt.Sprintf("the variant %v is not a version, for example \"v7\"", n.Variant))
+	}
+	n.Variant = "v" + m[1]
+	variants, ok := platformVariants[n.Architecture]
+	switch {
+	case !ok:
+		return p, invalid(fmt.Sprintf("the architecture %v has no variants", n.Architecture))
+	case n.Architecture == "amd64" && !variants.MatchString(n.Variant):
+		return p, invalid(fmt.Sprintf("the variant of amd64 must be a microarchitecture level v1 to v4, not %v", n.Variant))
+	case !variants.MatchString(n.Variant):
+		return p, invalid(fmt.Sprintf("%v is not a variant of %v", n.Variant, n.Architecture))
+	}
+	return n, nil
+}
+
+// NormalizePlatforms normalizes each of the platforms (see
+// NormalizePlatform), which must then be distinct.
+func NormalizePlatforms(pp []Platform) ([]Platform, error) {
+	normalized := make([]Platform, 0, len(pp))
+	seen := map[Platform]bool{}
+	for _, p := range pp {
+		n, err := NormalizePlatform(p)
+		if err != nil {
+			return nil, err
+		}
+		if seen[n] {
+			return nil, fmt.Errorf("platform %q is listed more than once", platformString(n))
+		}
+		seen[n] = true
+		normalized = append(normalized, n)
+	}
+	return normalized, nil
+}
+
+// ParsePlatforms parses each of the platforms (see ParsePlatform), which
+// must be distinct.
+func ParsePlatforms(ss []string) (pp []Platform, err error) {
+	seen := map[Platform]bool{}
+	for _, s := range ss {
+		p, err := ParsePlatform(s)
+		if err != nil {
+			return nil, err
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("platform %q is listed more than once", s)
+		}
+		seen[p] = true
+		pp = append(pp, p)
+	}
+	return
+}
+
+// platformString returns the platform in the form os/arch[/variant].
+func platformString(p Platform) string {
+	if p.Variant == "" {
+		return p.OS + "/" + p.Architecture
+	}
+	return p.OS + "/" + p.Architecture + "/" + p.Variant
+}
+
+// ValidatePlatforms checks that the function's default platforms
+// (build.platforms) are each of the form os/arch[/variant], of a variant of
+// the architecture, and not repeated.
+// Returns array of error messages, empty if no errors are found
+func ValidatePlatforms(platforms []string) (errors []string) {
+	seen := map[Platform]bool{}
+	for i, s := range platforms {
+		p, err := ParsePlatform(s)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("platform entry #%d: %v", i, err))
+		} else if seen[p] {
+			errors = append(errors, fmt.Sprintf("platform entry #%d %q is a duplicate", i, s))
+		}
+		seen[p] = true
+	}
+	return
+}
@@ -0,0 +1,88 @@
+package functions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ValidatePlatforms(t *testing.T) {
+	tests := []struct {
+		name      string
+		platforms []string
+		errs      int
+	}{
+		{"correct entry - single platform", []string{"linux/amd64"}, 0},
+		{"correct entry - with variant", []string{"linux/amd64", "linux/arm/v7"}, 0},
+		{"incorrect entry - empty", []string{""}, 1},
+		{"incorrect entry - os only", []string{"linux"}, 1},
+		{"incorrect entry - empty architecture", []string{"linux/"}, 1},
+		{"incorrect entry - too many parts", []string{"linux/arm/v7/x"}, 1},
+		{"incorrect entry - comma separated", []string{"linux/amd64, linux/arm64"}, 1},
+		{"incorrect entry - duplicate", []string{"linux/arm64", "linux/arm64"}, 1},
+		{"incorrect entry - duplicate when normalized", []string{"linux/arm/v7", "linux/armv7"}, 1},
+		{"incorrect entry - variant of amd64", []string{"linux/amd64/v7"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidatePlatforms(tt.platforms); len(got) != tt.errs {
+				t.Errorf("ValidatePlatforms() = %v\n got %d errors but want %d", got, len(got), tt.errs)
+			}
+		})
+	}
+}
+
+func Test_ParsePlatforms(t *testing.T) {
+	pp, err := ParsePlatforms([]string{"linux/amd64", "linux/arm/v7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm", Variant: "v7"}}
+	if !reflect.DeepEqual(pp, want) {
+		t.Fatalf("expected %v, got %v", want, pp)
+	}
+	if _, err = ParsePlatforms([]string{"linux/amd64", "linux/amd64"}); err == nil {
+		t.Fatal("expected an error for a duplicate platform")
+	}
+}
+
+func Test_NormalizePlatform(t *testing.T) {
+	tests := []struct {
+		platform string
+		want     Platform
+		err      bool
+	}{
+		{"linux/amd64", Platform{OS: "linux", Architecture: "amd64"}, false},
+		{"linux/arm/v7", Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, false},
+		{"linux/arm/7", Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, false},
+		{"linux/armv7", Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, false},
+		{"linux/armv6/6", Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, false},
+		{"linux/arm64/8", Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, false},
+		{"linux/arm64/v8.2", Platform{OS: "linux", Architecture: "arm64", Variant: "v8.2"}, false},
+		{"linux/amd64/v3", Platform{OS: "linux", Architecture: "amd64", Variant: "v3"}, false},
+		{"linux/amd64/2", Platform{OS: "linux", Architecture: "amd64", Variant: "v2"}, false},
+		{"linux/amd64/v5", Platform{}, true},
+		{"linux/amd64/v7", Platform{}, true},
+		{"linux/arm/v8", Platform{}, true},
+		{"linux/arm/x7", Platform{}, true},
+		{"linux/armv7/v6", Platform{}, true},
+		{"linux/s390x/v1", Platform{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			p, err := ParsePlatform(tt.platform)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, p)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package functions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Port is an additional port on which a function serves, such as gRPC or
+// metrics, alongside the primary HTTP port (8080) on $LISTEN_ADDRESS.
+type Port struct {
+	// Name of the port, for example "grpc".  Communicated to the function
+	// as the environment variable FUNC_PORT_<NAME> (eg. FUNC_PORT_GRPC).
+	Name string `yaml:"name"`
+	// Port number
+	Port int `yaml:"port"`
+	// Protocol of the port: tcp (default) or udp
+	Protocol string `yaml:"protocol,omitempty" jsonschema:"enum=tcp,enum=udp"`
+}
+
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+
+	// primaryPort is that of the function's HTTP (or CloudEvents) handler
+	primaryPort = 8080
+
+	// MetricsPort is that on which a function with metrics enabled
+	// (run.metrics) serves Prometheus metrics, communicated to the function
+	// as $METRICS_ADDRESS.
+	MetricsPort = 9090
+)
+
+// portName is the format of port names: lowercase alphanumerics and dashes,
+// starting with a letter, as with Kubernetes container port names.
+var portName = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+// Proto returns the protocol of the port, tcp if not specified.
+func (p Port) Proto() string {
+	if p.Protocol == "" {
+		return ProtocolTCP
+	}
+	return p.Protocol
+}
+
+// Env returns the environment variable communicating the port to the
+// function, for example FUNC_PORT_GRPC=9090.
+func (p Port) Env() string {
+	name := strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_"))
+	return fmt.Sprintf("FUNC_PORT_%v=%v", name, p.Port)
+}
+
+func (p Port) String() string {
+	return fmt.Sprintf("%v %v/%v", p.Name, p.Port, p.Proto())
+}
+
+// validatePorts checks that the additional ports are correct.
+// Returns array of error messages, empty if no errors are found
+//
+// Allowed settings:
+//   - name: grpc        # lowercase alphanumerics and dashes, at most 15
+//     port: 9090        # 1-65535, other than the primary port 8080
+//     protocol: tcp     # tcp (default) or udp
+func validatePorts(ports []Port) (errors []string) {
+	var (
+		names   = map[string]bool{}
+		numbers = map[string]bool{}
+	)
+	for i, p := range ports {
+		if !portName.MatchString(p.Name) || len(p.Name) > 15 {
+			errors = append(errors, fmt.Sprintf("port entry #%d (%s) has invalid name: must be at most 15 lowercase alphanumerics or '-', starting with a letter", i, p))
+		} else if names[p.Name] {
+			errors = append(errors, fmt.Sprintf("port entry #%d (%s) has duplicate name", i, p))
+		}
+		names[p.Name] = true
+
+		if p.Port < 1 || p.Port > 65535 {
+			errors = append(errors, fmt.Sprintf("port entry #%d (%s) has invalid port: must be between 1 and 65535", i, p))
+		} else if p.Port == primaryPort {
+			errors = append(errors, fmt.Sprintf("port entry #%d (%s) may not use the primary port %d", i, p, primaryPort))
+		}
+
+		if p.Proto() != ProtocolTCP && p.Proto() != ProtocolUDP {
+			errors = append(errors, fmt.Sprintf("port entry #%d (%s) has invalid protocol: must be tcp or udp", i, p))
+		} else if key := fmt.Sprintf("%d/%s", p.Port, p.Proto()); numbers[key] {
+			errors = append(errors, fmt.Sprintf("port entry #%d (%s) has duplicate port", i, p))
+		} else {
+			numbers[key] = true
+		}
+	}
+	return
+}
+
+// validateMetrics checks that, when metrics are enabled, the metrics port is
+// not also declared as an additional port.
+func validateMetrics(run RunSpec) (errors []string) {
+	if !run.Metrics {
+		return
+	}
+	for i, p := range run.Ports {
+		if p.Port == MetricsPort && p.Proto() == ProtocolTCP {
+			errors = append(errors, fmt.Sprintf("port entry #%d (%s) may not use the metrics port %d when metrics are enabled", i, p, MetricsPort))
+		}
+	}
+	return
+}
@@ -0,0 +1,63 @@
+package functions
+
+import (
+	"testing"
+)
+
+func Test_validatePorts(t *testing.T) {
+	tests := []struct {
+		name  string
+		ports []Port
+		errs  int
+	}{
+		{"correct entry - single port", []Port{{Name: "grpc", Port: 9090}}, 0},
+		{"correct entry - tcp and udp", []Port{
+			{Name: "grpc", Port: 9090, Protocol: "tcp"},
+			{Name: "stats", Port: 9090, Protocol: "udp"},
+		}, 0},
+		{"incorrect entry - missing name", []Port{{Port: 9090}}, 1},
+		{"incorrect entry - invalid name", []Port{{Name: "gRPC_port", Port: 9090}}, 1},
+		{"incorrect entry - name too long", []Port{{Name: "a-very-long-port-name", Port: 9090}}, 1},
+		{"incorrect entry - port out of range", []Port{{Name: "grpc", Port: 70000}}, 1},
+		{"incorrect entry - missing port", []Port{{Name: "grpc"}}, 1},
+		{"incorrect entry - primary port", []Port{{Name: "http", Port: 8080}}, 1},
+		{"incorrect entry - invalid protocol", []Port{{Name: "grpc", Port: 9090, Protocol: "sctp"}}, 1},
+		{"incorrect entry - duplicate name", []Port{
+			{Name: "grpc", Port: 9090},
+			{Name: "grpc", Port: 9091},
+		}, 1},
+		{"incorrect entry - duplicate port", []Port{
+			{Name: "grpc", Port: 9090},
+			{Name: "metrics", Port: 9090},
+		}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validatePorts(tt.ports); len(got) != tt.errs {
+				t.Errorf("validatePorts() = %v\n got %d errors but want %d", got, len(got), tt.errs)
+			}
+		})
+	}
+}
+
+func TestPort_Env(t *testing.T) {
+	p := Port{Name: "grpc-web", Port: 9091}
+	if got, want := p.Env(), "FUNC_PORT_GRPC_WEB=9091"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func Test_validateMetrics(t *testing.T) {
+	ports := []Port{{Name: "grpc", Port: MetricsPort}}
+	if errs := validateMetrics(RunSpec{Ports: ports}); len(errs) != 0 {
+		t.Fatalf("expected no errors with metrics disabled, got %v", errs)
+	}
+	if errs := validateMetrics(RunSpec{Ports: ports, Metrics: true}); len(errs) != 1 {
+		t.Fatalf("expected the metrics port to conflict, got %v", errs)
+	}
+	ports = []Port{{Name: "stats", Port: MetricsPort, Protocol: ProtocolUDP}}
+	if errs := validateMetrics(RunSpec{Ports: ports, Metrics: true}); len(errs) != 0 {
+		t.Fatalf("expected no conflict with a udp port, got %v", errs)
+	}
+}
@@ -0,0 +1,44 @@
+package functions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// ValidateReplace checks that the Go module replacements (build.replace) are
+// each of a module path, optionally at a version (path@version), by either a
+// directory (a path beginning with ./, ../ or /) or a module at a version.
+// Whether a directory exists is checked when building.
+// Returns array of error messages, empty if no errors are found
+func ValidateReplace(replace map[string]string) (errors []string) {
+	olds := make([]string, 0, len(replace))
+	for old := range replace {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds) // errors in a stable order
+	for _, old := range olds {
+		path, version, versioned := strings.Cut(old, "@")
+		if err := module.CheckImportPath(path); err != nil {
+			errors = append(errors, fmt.Sprintf("replaced module %q is not valid: %v", old, err))
+		} else if versioned && !semver.IsValid(version) {
+			errors = append(errors, fmt.Sprintf("replaced module %q is not valid: version must be a semantic version such as \"v1.2.3\"", old))
+		}
+
+		new := replace[old]
+		if modfile.IsDirectoryPath(new) {
+			continue
+		}
+		path, version, versioned = strings.Cut(new, "@")
+		if err := module.CheckImportPath(path); err != nil {
+			errors = append(errors, fmt.Sprintf("replacement %q of %q is not valid: must be a directory beginning with ./, ../ or /, or a module at a version", new, old))
+		} else if !versioned || !semver.IsValid(version) {
+			errors = append(errors, fmt.Sprintf("replacement %q of %q is not valid: a module must be at a semantic version, such as %v@v1.2.3", new, old, path))
+		}
+	}
+	return
+}
@@ -0,0 +1,34 @@
+package functions
+
+import (
+	"testing"
+)
+
+func Test_ValidateReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace map[string]string
+		errs    int
+	}{
+		{"correct entry - none", nil, 0},
+		{"correct entry - relative directory", map[string]string{"knative.dev/func-go": "../func-go"}, 0},
+		{"correct entry - current directory", map[string]string{"knative.dev/func-go": "./func-go"}, 0},
+		{"correct entry - absolute directory", map[string]string{"knative.dev/func-go": "/src/func-go"}, 0},
+		{"correct entry - module", map[string]string{"knative.dev/func-go": "github.com/alice/func-go@v0.21.4"}, 0},
+		{"correct entry - versioned", map[string]string{"knative.dev/func-go@v0.21.3": "../func-go"}, 0},
+		{"incorrect entry - replaced path", map[string]string{"knative.dev/func go": "../func-go"}, 1},
+		{"incorrect entry - replaced version", map[string]string{"knative.dev/func-go@latest": "../func-go"}, 1},
+		{"incorrect entry - module without version", map[string]string{"knative.dev/func-go": "github.com/alice/func-go"}, 1},
+		{"incorrect entry - module with invalid version", map[string]string{"knative.dev/func-go": "github.com/alice/func-go@main"}, 1},
+		{"incorrect entry - bare directory", map[string]string{"knative.dev/func-go": "func go"}, 1},
+		{"incorrect entry - several", map[string]string{"knative.dev/func-go": "", "example.com/x@1": "../x"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateReplace(tt.replace); len(got) != tt.errs {
+				t.Errorf("ValidateReplace() = %v\n got %d errors but want %d", got, len(got), tt.errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package functions
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Severities of vulnerabilities, in increasing order, at or above which a
+// scan of the built image fails the build.
+const (
+	ScanSeverityLow      = "low"
+	ScanSeverityMedium   = "medium"
+	ScanSeverityHigh     = "high" // default
+	ScanSeverityCritical = "critical"
+)
+
+// ScanSeverities are the known severities, in increasing order.
+var ScanSeverities = []string{ScanSeverityLow, ScanSeverityMedium, ScanSeverityHigh, ScanSeverityCritical}
+
+// Scan configures a scan of the built image for vulnerabilities after each
+// build (host builder only).  The build fails if any vulnerability of at
+// least the severity is found.  The scan is skipped if the scanner is not
+// installed.
+type Scan struct {
+	// Scanner is "trivy", "grype", or a command which scans the OCI layout at
+	// {layout} (appended if absent), exiting non-zero to fail the build.
+	Scanner string `yaml:"scanner,omitempty"`
+
+	// Severity at or above which vulnerabilities fail the build.
+	Severity string `yaml:"severity,omitempty" jsonschema:"enum=low,enum=medium,enum=high,enum=critical"`
+}
+
+// validateScan checks that the severity of the scan, if any, is known.
+// Returns array of error messages, empty if no errors are found
+func validateScan(scan Scan) (errors []string) {
+	if scan.Severity != "" && !slices.Contains(ScanSeverities, scan.Severity) {
+		errors = append(errors, fmt.Sprintf("scan severity %q is not valid: must be one of %v", scan.Severity, ScanSeverities))
+	}
+	return
+}
@@ -0,0 +1,27 @@
+package functions
+
+import (
+	"testing"
+)
+
+func Test_validateScan(t *testing.T) {
+	tests := []struct {
+		name string
+		scan Scan
+		errs int
+	}{
+		{"correct entry - none", Scan{}, 0},
+		{"correct entry - scanner only", Scan{Scanner: "trivy"}, 0},
+		{"correct entry - scanner and severity", Scan{Scanner: "grype", Severity: "critical"}, 0},
+		{"incorrect entry - unknown severity", Scan{Scanner: "trivy", Severity: "severe"}, 1},
+		{"incorrect entry - uppercase severity", Scan{Scanner: "trivy", Severity: "HIGH"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateScan(tt.scan); len(got) != tt.errs {
+				t.Errorf("validateScan() = %v\n got %d errors but want %d", got, len(got), tt.errs)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package k8s
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// NodePlatform returns the platform (OS and architecture) of the nodes of the
+// current cluster.  The zero Platform is returned if the nodes are of more
+// than one platform, or there are none, in which case images for every
+// platform a function may be scheduled on are required.
+func NodePlatform(ctx context.Context) (fn.Platform, error) {
+	client, err := NewKubernetesClientset()
+	if err != nil {
+		return fn.Platform{}, err
+	}
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fn.Platform{}, err
+	}
+	return nodesPlatform(nodes.Items), nil
+}
+
+// nodesPlatform is the platform shared by all of the nodes, or the zero
+// Platform if they do not share one.
+func nodesPlatform(nodes []corev1.Node) (p fn.Platform) {
+	for i, n := range nodes {
+		np := fn.Platform{OS: n.Status.NodeInfo.OperatingSystem, Architecture: n.Status.NodeInfo.Architecture}
+		if np.OS == "" || np.Architecture == "" {
+			return fn.Platform{}
+		}
+		if i > 0 && np != p {
+			return fn.Platform{}
+		}
+		p = np
+	}
+	return
+}
@@ -0,0 +1,33 @@
+package k8s
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+func Test_nodesPlatform(t *testing.T) {
+	node := func(os, arch string) corev1.Node {
+		return corev1.Node{Status: corev1.NodeStatus{NodeInfo: corev1.NodeSystemInfo{OperatingSystem: os, Architecture: arch}}}
+	}
+	tests := []struct {
+		name     string
+		nodes    []corev1.Node
+		expected fn.Platform
+	}{
+		{"no nodes", nil, fn.Platform{}},
+		{"single node", []corev1.Node{node("linux", "arm64")}, fn.Platform{OS: "linux", Architecture: "arm64"}},
+		{"same platform", []corev1.Node{node("linux", "amd64"), node("linux", "amd64")}, fn.Platform{OS: "linux", Architecture: "amd64"}},
+		{"mixed platforms", []corev1.Node{node("linux", "amd64"), node("linux", "arm64")}, fn.Platform{}},
+		{"unreported", []corev1.Node{node("linux", "")}, fn.Platform{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if p := nodesPlatform(test.nodes); p != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, p)
+			}
+		})
+	}
+}
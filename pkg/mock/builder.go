@@ -9,6 +9,7 @@ import (
 type Builder struct {
 	BuildInvoked bool
 	BuildFn      func(fn.Function) error
+	Platforms    []fn.Platform // of the last build
 }
 
 func NewBuilder() *Builder {
@@ -17,7 +18,8 @@ func NewBuilder() *Builder {
 	}
 }
 
-func (i *Builder) Build(ctx context.Context, f fn.Function, _ []fn.Platform) error {
+func (i *Builder) Build(ctx context.Context, f fn.Function, pp []fn.Platform) error {
 	i.BuildInvoked = true
+	i.Platforms = pp
 	return i.BuildFn(f)
 }
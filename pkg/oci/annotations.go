@@ -0,0 +1,103 @@
+package oci
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Annotations of each platform's manifest recording the base image upon
+// which it was built, as pre-defined by the OCI image spec, such that tools
+// can determine whether an image is to be rebased.
+const (
+	// AnnotationBaseName is the reference of the base image, as given, or
+	// BaseScratch.
+	AnnotationBaseName = "org.opencontainers.image.base.name"
+	// AnnotationBaseDigest is the digest of the base image of the platform.
+	// Absent when built from scratch.
+	AnnotationBaseDigest = "org.opencontainers.image.base.digest"
+)
+
+// BaseScratch is the base name (AnnotationBaseName) of an image built from
+// scratch, without a base image.
+const BaseScratch = "scratch"
+
+// annotationKey is the format of annotation keys: reverse domain notation,
+// for example "com.example.team", as recommended by the OCI image spec.
+var annotationKey = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9_-]*[a-zA-Z0-9])?)+$`)
+
+// WithAnnotations adds the given OCI annotations to the built image's index
+// and per-platform manifests, in addition to (and overriding) those defined
+// by the function (build.annotations).
+func WithAnnotations(annotations map[string]string) BuilderOpt {
+	return func(b *Builder) {
+		b.annotations = annotations
+	}
+}
+
+// ValidateAnnotations returns an error if any of the annotation keys is not
+// in reverse domain notation (eg. "com.example.team").
+func ValidateAnnotations(annotations map[string]string) error {
+	for k := range annotations {
+		if !annotationKey.MatchString(k) {
+			return fmt.Errorf("invalid annotation key %q: must be in reverse domain notation, for example \"com.example.team\"", k)
+		}
+	}
+	return nil
+}
+
+// ParseAnnotations parses annotations in the form key=value, as provided
+// on the command line.
+func ParseAnnotations(aa []string) (map[string]string, error) {
+	annotations := map[string]string{}
+	for _, a := range aa {
+		k, v, ok := strings.Cut(a, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid annotation %q: must be in the form key=value", a)
+		}
+		annotations[k] = v
+	}
+	return annotations, ValidateAnnotations(annotations)
+}
+
+// annotations of the image: those of the function overridden by those of
+// the builder.  Nil if there are none.
+func (j buildJob) annotations() map[string]string {
+	if len(j.function.Build.Annotations) == 0 && len(j.options.annotations) == 0 {
+		return nil
+	}
+	aa := map[string]string{}
+	for k, v := range j.function.Build.Annotations {
+		aa[k] = v
+	}
+	for k, v := range j.options.annotations {
+		aa[k] = v
+	}
+	return aa
+}
+
+// manifestAnnotations of a platform's image: those of the image (see
+// annotations) and the base image upon which it was built, if any, or
+// BaseScratch.  The base is recorded even when its layers are squashed, as
+// the image is nonetheless of its content, and takes precedence over
+// annotations of the same keys.
+func (j buildJob) manifestAnnotations(base v1.Image) (map[string]string, error) {
+	aa := j.annotations()
+	if aa == nil {
+		aa = map[string]string{}
+	}
+	if base == nil {
+		aa[AnnotationBaseName] = BaseScratch
+		delete(aa, AnnotationBaseDigest)
+		return aa, nil
+	}
+	digest, err := base.Digest()
+	if err != nil {
+		return nil, err
+	}
+	aa[AnnotationBaseName] = j.languageBuilder.Base(j.function.Build.BaseImage)
+	aa[AnnotationBaseDigest] = digest.String()
+	return aa, nil
+}
@@ -0,0 +1,126 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// TestParseAnnotations ensures annotations are parsed from key=value and
+// that keys must be in reverse domain notation.
+func TestParseAnnotations(t *testing.T) {
+	tests := []struct {
+		annotation string
+		valid      bool
+	}{
+		{"com.example.team=payments", true},
+		{"com.example.ticket=PAY-123=x", true},
+		{"org.opencontainers.image.authors=alice", true},
+		{"com.example.empty=", true},
+		{"team=payments", false},
+		{".com.example=x", false},
+		{"com.example.=x", false},
+		{"com example.team=x", false},
+		{"com.example.team", false},
+		{"=x", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.annotation, func(t *testing.T) {
+			_, err := ParseAnnotations([]string{tt.annotation})
+			if tt.valid && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+// TestBuilder_Annotations ensures the annotations of the function and the
+// builder, which take precedence, are written to the index.
+func TestBuilder_Annotations(t *testing.T) {
+	job := buildJob{
+		ctx:  context.Background(),
+		hash: "hash",
+		function: fn.Function{
+			Root: t.TempDir(),
+			Build: fn.BuildSpec{Annotations: map[string]string{
+				"com.example.team":   "payments",
+				"com.example.ticket": "PAY-1",
+			}},
+		},
+		options: options{annotations: map[string]string{"com.example.ticket": "PAY-2"}},
+	}
+	if err := os.MkdirAll(job.ociDir(), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeIndex(job, []v1.Descriptor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(filepath.Join(job.ociDir(), "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	index, err := v1.ParseIndexManifest(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"com.example.team": "payments", "com.example.ticket": "PAY-2"}
+	if diff := cmp.Diff(expected, index.Annotations); diff != "" {
+		t.Fatalf("unexpected annotations (-want, +got): %v", diff)
+	}
+}
+
+// TestBuildJob_ManifestAnnotations ensures each platform's manifest records
+// its base image, by reference and digest, or that it was built from scratch,
+// in addition to the image's annotations.
+func TestBuildJob_ManifestAnnotations(t *testing.T) {
+	job := buildJob{
+		function:        fn.Function{Build: fn.BuildSpec{Annotations: map[string]string{"com.example.team": "payments"}}},
+		languageBuilder: goBuilder{},
+	}
+
+	// From scratch
+	aa, err := job.manifestAnnotations(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"com.example.team": "payments", AnnotationBaseName: BaseScratch}
+	if diff := cmp.Diff(expected, aa); diff != "" {
+		t.Fatalf("unexpected annotations (-want, +got): %v", diff)
+	}
+
+	// With a base
+	base, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := base.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.function.Build.BaseImage = "example.com/base:1"
+	if aa, err = job.manifestAnnotations(base); err != nil {
+		t.Fatal(err)
+	}
+	expected = map[string]string{
+		"com.example.team":   "payments",
+		AnnotationBaseName:   "example.com/base:1",
+		AnnotationBaseDigest: digest.String(),
+	}
+	if diff := cmp.Diff(expected, aa); diff != "" {
+		t.Fatalf("unexpected annotations (-want, +got): %v", diff)
+	}
+	if job.function.Build.Annotations[AnnotationBaseName] != "" {
+		t.Fatal("the function's annotations were modified")
+	}
+}
@@ -0,0 +1,201 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Media types of an artifact built by BuildArtifact.
+const (
+	// MediaTypeEmpty is that of the OCI empty descriptor ({}), the config of
+	// an artifact which has none.
+	MediaTypeEmpty = "application/vnd.oci.empty.v1+json"
+	// MediaTypeArtifactLayer is the default media type of an artifact's file.
+	MediaTypeArtifactLayer = "application/octet-stream"
+)
+
+// annotationTitle is the annotation of a layer naming its file, by which
+// artifact clients such as oras name the file when pulled.
+const annotationTitle = "org.opencontainers.image.title"
+
+// artifactManifest is an image manifest with the artifactType of OCI 1.1,
+// which v1.Manifest lacks.
+type artifactManifest struct {
+	v1.Manifest
+	ArtifactType string `json:"artifactType,omitempty"`
+}
+
+// BuildArtifact builds the file at path (relative to the function's root) as
+// an OCI artifact rather than an image, for functions distributed as a
+// non-executable bundle such as a wasm module: a manifest of the builder's
+// artifactType (see WithArtifactType), which is required, whose config is the
+// OCI empty descriptor and whose single layer is the file as it is, of the
+// given media type (MediaTypeArtifactLayer if empty).  The manifest, without
+// a platform, is the only one of the index, which is the last build
+// (.func/builds/last) such that it is pushed as the function's image.  The
+// function's source is not otherwise built: there is no scaffolding,
+// compilation nor base image, and post-build commands (build.postBuild) are
+// not run.
+func (b *Builder) BuildArtifact(ctx context.Context, f fn.Function, path, mediaType string) (err error) {
+	var job buildJob
+	defer func(start time.Time) { b.record(f, nil, start, job.cacheStats, err) }(time.Now())
+
+	if mediaType == "" {
+		mediaType = MediaTypeArtifactLayer
+	}
+	if err = b.options.validate(); err != nil {
+		return
+	}
+	if b.artifactType == "" {
+		return errors.New("an artifact requires an artifact type (see WithArtifactType)")
+	}
+	if !mediaTypeFormat.MatchString(mediaType) {
+		return fmt.Errorf("invalid artifact media type %q: must be in the form type/subtype", mediaType)
+	}
+	if err = ValidateAnnotations(f.Build.Annotations); err != nil {
+		return
+	}
+
+	// The runtime is irrelevant to the artifact
+	job, err = newBuildJob(ctx, f, nil, b.verbose)
+	var errRuntime ErrUnsupportedRuntime
+	if err != nil && !errors.As(err, &errRuntime) {
+		return
+	}
+	job.options = b.options
+	job.quiet = b.quiet && !b.verbose
+	// Built apart from an image of the same source
+	job.hash += ".artifact"
+	var index v1.Hash
+	defer func() {
+		b.resultMu.Lock()
+		b.result = job.result()
+		b.result.Index = index
+		b.resultMu.Unlock()
+	}()
+
+	source := path
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(f.Root, path)
+	}
+	if fi, err := os.Stat(source); err != nil {
+		return fmt.Errorf("invalid artifact %v. %w", path, err)
+	} else if !fi.Mode().IsRegular() {
+		return fmt.Errorf("invalid artifact %v: not a regular file", path)
+	}
+
+	if err = setup(job); err != nil {
+		return
+	}
+	defer cleanup(job)
+	defer func() {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "rm %v\n", job.pidLink())
+		}
+		_ = os.Remove(job.pidLink())
+	}()
+	if err = os.WriteFile(filepath.Join(job.ociDir(), "oci-layout"),
+		[]byte(`{ "imageLayoutVersion": "1.0.0" }`), os.ModePerm); err != nil {
+		return
+	}
+
+	config, err := writeEmptyBlob(job)
+	if err != nil {
+		return
+	}
+	layer, err := writeFileBlob(job, source)
+	if err != nil {
+		return
+	}
+	layer.MediaType = types.MediaType(mediaType)
+	layer.Annotations = map[string]string{annotationTitle: filepath.Base(source)}
+
+	manifest, err := writeAsJSONBlob(job, "manifest.artifact.json", artifactManifest{
+		Manifest: v1.Manifest{
+			SchemaVersion: 2,
+			MediaType:     types.OCIManifestSchema1,
+			Config:        config,
+			Layers:        []v1.Descriptor{layer},
+			Annotations:   job.annotations(),
+		},
+		ArtifactType: job.artifactType,
+	})
+	if err != nil {
+		return
+	}
+	manifest.MediaType = types.OCIManifestSchema1
+	manifest.ArtifactType = job.artifactType
+	if err = writeIndex(job, []v1.Descriptor{manifest}); err != nil {
+		return
+	}
+	if index, _, err = readImages(job.ociDir()); err != nil {
+		return
+	}
+	if err = updateLastLink(job); err != nil {
+		return
+	}
+	if !job.quiet {
+		fmt.Printf("Built artifact %v (%v)\n", filepath.Base(source), index)
+	}
+	return
+}
+
+// writeEmptyBlob writes the OCI empty descriptor's blob ({}), returning its
+// descriptor.
+func writeEmptyBlob(job buildJob) (desc v1.Descriptor, err error) {
+	empty := []byte("{}")
+	sum := sha256.Sum256(empty)
+	desc = v1.Descriptor{
+		MediaType: MediaTypeEmpty,
+		Digest:    v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum[:])},
+		Size:      int64(len(empty)),
+	}
+	err = os.WriteFile(filepath.Join(job.blobsDir(), desc.Digest.Hex), empty, 0644)
+	return
+}
+
+// writeFileBlob copies the file at source, as it is, into the job's blobs
+// directory named by its digest, returning its descriptor (without a media
+// type).
+func writeFileBlob(job buildJob, source string) (desc v1.Descriptor, err error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	tmp := filepath.Join(job.buildDir(), "artifact."+filepath.Base(source))
+	out, err := os.Create(tmp)
+	if err != nil {
+		return
+	}
+	h := sha256.New()
+	if desc.Size, err = io.Copy(io.MultiWriter(out, h), in); err != nil {
+		out.Close()
+		return
+	}
+	if err = out.Close(); err != nil {
+		return
+	}
+	desc.Digest = v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(h.Sum(nil))}
+	blob := filepath.Join(job.blobsDir(), desc.Digest.Hex)
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "mv %v %v\n", rel(job.buildDir(), tmp), rel(job.buildDir(), blob))
+	}
+	if err = os.Rename(tmp, blob); err != nil {
+		return
+	}
+	linkBlob(job, tmp, blob)
+	return
+}
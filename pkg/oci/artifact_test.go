@@ -0,0 +1,119 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestBuilder_BuildArtifact ensures a file is built as an artifact of the
+// artifactType, with the empty config and the file as its single layer, and
+// that it is pushed as the function's image.
+func TestBuilder_BuildArtifact(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	module := []byte("\x00asm\x01\x00\x00\x00")
+	if err = os.WriteFile(filepath.Join(root, "f.wasm"), module, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The artifact type is required
+	if err = NewBuilder("", false, WithQuiet(true)).BuildArtifact(context.Background(), f, "f.wasm", "application/wasm"); err == nil {
+		t.Fatal("expected error building an artifact without an artifact type")
+	}
+
+	const artifactType = "application/vnd.example.wasm.v1"
+	b := NewBuilder("", false, WithQuiet(true), WithArtifactType(artifactType))
+	if err = b.BuildArtifact(context.Background(), f, "f.wasm", "application/wasm"); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(root, fn.RunDataDir, "builds", "last", "oci")
+	readBlob := func(digest string) []byte {
+		algorithm, encoded, _ := strings.Cut(digest, ":")
+		bb, err := os.ReadFile(filepath.Join(dir, "blobs", algorithm, encoded))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bb
+	}
+	type descriptor struct {
+		MediaType    string            `json:"mediaType"`
+		Digest       string            `json:"digest"`
+		Size         int64             `json:"size"`
+		ArtifactType string            `json:"artifactType"`
+		Annotations  map[string]string `json:"annotations"`
+	}
+	var index struct {
+		Manifests []descriptor `json:"manifests"`
+	}
+	bb, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(bb, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].ArtifactType != artifactType {
+		t.Fatalf("expected the artifact's manifest, got %+v", index.Manifests)
+	}
+	var manifest struct {
+		ArtifactType string       `json:"artifactType"`
+		Config       descriptor   `json:"config"`
+		Layers       []descriptor `json:"layers"`
+	}
+	if err = json.Unmarshal(readBlob(index.Manifests[0].Digest), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ArtifactType != artifactType {
+		t.Fatalf("expected artifactType %v, got %v", artifactType, manifest.ArtifactType)
+	}
+	if manifest.Config.MediaType != MediaTypeEmpty || manifest.Config.Size != 2 ||
+		manifest.Config.Digest != "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a" {
+		t.Fatalf("expected the empty config, got %+v", manifest.Config)
+	}
+	if string(readBlob(manifest.Config.Digest)) != "{}" {
+		t.Fatal("expected the empty config's blob")
+	}
+	if len(manifest.Layers) != 1 || manifest.Layers[0].MediaType != "application/wasm" || manifest.Layers[0].Annotations[annotationTitle] != "f.wasm" {
+		t.Fatalf("expected the file as the single layer, got %+v", manifest.Layers)
+	}
+	if string(readBlob(manifest.Layers[0].Digest)) != string(module) {
+		t.Fatal("expected the layer to be the file as it is")
+	}
+	if b.Result().Index.String() == "" {
+		t.Fatal("expected the result of the build")
+	}
+
+	// Pushed as the function's image
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer s.Close()
+	f.Build.Image = strings.TrimPrefix(s.URL, "http://") + "/funcs/f:latest"
+	digest, err := NewPusher(true, true, false).Push(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(f.Build.Image, name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := remote.Head(ref); err != nil || d.Digest.String() != digest {
+		t.Fatalf("expected the artifact to be pushed. %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// mediaTypeFormat is the format of a media type (RFC 6838) as required of an
+// artifactType by the OCI image spec: type/subtype, each a restricted name.
+var mediaTypeFormat = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]{0,126}/[A-Za-z0-9][A-Za-z0-9!#$&^_.+-]{0,126}$`)
+
+// WithArtifactType sets the artifactType of the image index (OCI 1.1), such
+// that the image is a typed artifact for tools which filter referrers by
+// type, for example "application/vnd.example.function.v1".
+func WithArtifactType(t string) BuilderOpt {
+	return func(b *Builder) {
+		b.artifactType = t
+	}
+}
+
+// WithSubject sets the subject of the image index (OCI 1.1): the descriptor
+// of the manifest to which the image refers, such that it is listed among
+// that manifest's referrers.
+func WithSubject(subject *v1.Descriptor) BuilderOpt {
+	return func(b *Builder) {
+		b.subject = subject
+	}
+}
+
+// ValidateArtifactType returns an error if the artifact type is not a media
+// type in the form type/subtype.  Empty is no artifact type.
+func ValidateArtifactType(t string) error {
+	if t == "" || mediaTypeFormat.MatchString(t) {
+		return nil
+	}
+	return fmt.Errorf("invalid artifact type %q: must be a media type in the form type/subtype", t)
+}
+
+// ValidateSubject returns an error if the subject is not the descriptor of a
+// manifest: with a media type, a valid digest and a size.  Nil is no subject.
+func ValidateSubject(subject *v1.Descriptor) error {
+	if subject == nil {
+		return nil
+	}
+	if !mediaTypeFormat.MatchString(string(subject.MediaType)) {
+		return fmt.Errorf("invalid subject media type %q: must be in the form type/subtype", subject.MediaType)
+	}
+	if _, err := v1.NewHash(subject.Digest.String()); err != nil {
+		return fmt.Errorf("invalid subject digest %q. %w", subject.Digest, err)
+	}
+	if subject.Size <= 0 {
+		return fmt.Errorf("invalid subject size %v: must be positive", subject.Size)
+	}
+	return nil
+}
+
+// validateArtifact validates the artifact type and subject, which are
+// properties of an OCI image index that a Docker manifest list lacks.
+func validateArtifact(o options) error {
+	if err := ValidateArtifactType(o.artifactType); err != nil {
+		return err
+	}
+	if err := ValidateSubject(o.subject); err != nil {
+		return err
+	}
+	if (o.artifactType != "" || o.subject != nil) && o.mediaType == MediaTypesDocker {
+		return errors.New("an artifact type or subject requires the OCI media types")
+	}
+	return nil
+}
+
+// artifactIndex is an image index with the artifactType of OCI 1.1, which
+// v1.IndexManifest lacks.
+type artifactIndex struct {
+	v1.IndexManifest
+	ArtifactType string `json:"artifactType,omitempty"`
+}
@@ -0,0 +1,97 @@
+package oci
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+func TestValidateArtifactType(t *testing.T) {
+	for _, valid := range []string{"", "application/vnd.example.function.v1", "application/vnd.oci.image.index.v1+json"} {
+		if err := ValidateArtifactType(valid); err != nil {
+			t.Fatalf("expected %q to be valid. %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"application", "application/", "/json", "application/vnd example", "a/b/c", "-app/json"} {
+		if err := ValidateArtifactType(invalid); err == nil {
+			t.Fatalf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestValidateSubject(t *testing.T) {
+	digest, _, _ := v1.SHA256(strings.NewReader("subject"))
+	valid := v1.Descriptor{MediaType: types.OCIManifestSchema1, Digest: digest, Size: 7}
+	if err := ValidateSubject(&valid); err != nil {
+		t.Fatalf("expected a valid subject. %v", err)
+	}
+	if err := ValidateSubject(nil); err != nil {
+		t.Fatalf("expected no subject to be valid. %v", err)
+	}
+	for name, invalid := range map[string]v1.Descriptor{
+		"no media type": {Digest: digest, Size: 7},
+		"no digest":     {MediaType: types.OCIManifestSchema1, Size: 7},
+		"no size":       {MediaType: types.OCIManifestSchema1, Digest: digest},
+	} {
+		if err := ValidateSubject(&invalid); err == nil {
+			t.Fatalf("%v: expected an error", name)
+		}
+	}
+
+	// Docker manifest lists have neither
+	o := options{mediaType: MediaTypesDocker, artifactType: "application/vnd.example.function.v1"}
+	if err := o.validate(); err == nil {
+		t.Fatal("expected an error with an artifact type and Docker media types")
+	}
+}
+
+// TestWriteIndex_Artifact ensures the artifact type and subject are written
+// to the index.
+func TestWriteIndex_Artifact(t *testing.T) {
+	digest, _, _ := v1.SHA256(strings.NewReader("subject"))
+	subject := v1.Descriptor{MediaType: types.OCIManifestSchema1, Digest: digest, Size: 7}
+
+	job := buildJob{function: fn.Function{Root: t.TempDir()}, hash: "abc"}
+	job.artifactType = "application/vnd.example.function.v1"
+	job.subject = &subject
+	if err := os.MkdirAll(job.ociDir(), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeIndex(job, []v1.Descriptor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bb, err := os.ReadFile(filepath.Join(job.ociDir(), "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index artifactIndex
+	if err = json.Unmarshal(bb, &index); err != nil {
+		t.Fatal(err)
+	}
+	if index.ArtifactType != job.artifactType {
+		t.Fatalf("expected artifact type %q, got %q", job.artifactType, index.ArtifactType)
+	}
+	if index.Subject == nil || index.Subject.Digest != digest {
+		t.Fatalf("expected subject %v, got %v", digest, index.Subject)
+	}
+
+	// Without either, neither is written
+	job.artifactType, job.subject = "", nil
+	if err = writeIndex(job, []v1.Descriptor{}); err != nil {
+		t.Fatal(err)
+	}
+	if bb, err = os.ReadFile(filepath.Join(job.ociDir(), "index.json")); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bb), "artifactType") || strings.Contains(string(bb), "subject") {
+		t.Fatalf("expected neither an artifact type nor subject, got %s", bb)
+	}
+}
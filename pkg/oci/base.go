@@ -0,0 +1,177 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/daemon"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// baseImages caches the base images resolved for the duration of a build,
+// such that a multi-platform build resolves the base image (its index) once
+// rather than once per platform, and reuses the image of each platform.
+type baseImages struct {
+	mu      sync.Mutex
+	local   map[string]v1.Image            // by ref, nil if not found locally
+	remotes map[string]*remote.Descriptor  // by ref
+	images  map[string]map[string]v1.Image // by ref and platform
+	fetched map[string]bool                // cached layers fetched again (PullAlways)
+}
+
+func newBaseImages() *baseImages {
+	return &baseImages{
+		local:   map[string]v1.Image{},
+		remotes: map[string]*remote.Descriptor{},
+		images:  map[string]map[string]v1.Image{},
+		fetched: map[string]bool{},
+	}
+}
+
+// resolveBase returns the base image for the given platform: the image in
+// the local daemon if it exists, otherwise that of the platform in the
+// registry, subject to the pull policy (see WithBasePullPolicy).  Results are
+// cached by the job, and a job without a cache (nil) resolves each time.
+func resolveBase(job buildJob, ref name.Reference, p v1.Platform) (image v1.Image, err error) {
+	b := job.bases
+	if b == nil {
+		b = newBaseImages()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := ref.String()
+	if image, ok := b.images[key][p.String()]; ok {
+		return image, nil
+	}
+
+	// The local image is used for all platforms, unless always pulled
+	image, ok := b.local[key]
+	if !ok && job.pullPolicy() != PullAlways {
+		if image, err = daemon.Image(ref); err != nil {
+			image = nil
+		}
+		b.local[key] = image
+	}
+	if image != nil {
+		return image, nil
+	}
+
+	// Never pulled: that last pulled, from the blob cache
+	if job.pullPolicy() == PullNever {
+		if image, err = cachedBase(job, ref, p); err != nil {
+			return
+		}
+		if b.images[key] == nil {
+			b.images[key] = map[string]v1.Image{}
+		}
+		b.images[key][p.String()] = image
+		return
+	}
+
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "Base image %v not found locally, pulling %v/%v\n", ref, p.OS, p.Architecture)
+	}
+	desc, ok := b.remotes[key]
+	if !ok {
+		// 经由镜像仓库的镜像(mirror)拉取, 保留原有的标签或摘要
+		var pull name.Reference
+		if pull, err = mirrorReference(job, ref); err != nil {
+			return
+		}
+		if job.verbose && pull != ref {
+			fmt.Fprintf(os.Stderr, "Pulling base image %v through mirror %v\n", ref, pull)
+		}
+		if desc, err = remote.Get(pull, remote.WithContext(job.ctx), remote.WithPlatform(p),
+			remote.WithAuthFromKeychain(job.keychain()), remote.WithTransport(job.transport())); err != nil {
+			return
+		}
+		b.remotes[key] = desc
+	}
+	if image, err = platformImage(desc, p); err != nil {
+		return
+	}
+	if err = cacheBase(job, image); err != nil {
+		return
+	}
+
+	// A change of the image to which a tag refers is not silent (that of a
+	// digest can not change, but is recorded to be found by PullNever)
+	var digest v1.Hash
+	if digest, err = image.Digest(); err != nil {
+		return
+	}
+	previous, err := recordBase(job, ref, p, digest)
+	if err != nil {
+		return nil, err
+	}
+	if previous != "" {
+		fmt.Fprintf(os.Stderr, "Warning: base image %v (%v) has changed since last resolved, from %v to %v\n",
+			ref, platformName(p), previous, digest)
+	}
+	if b.images[key] == nil {
+		b.images[key] = map[string]v1.Image{}
+	}
+	b.images[key][p.String()] = image
+	return
+}
+
+// platformImage returns the image of the given platform from the descriptor,
+// which is either an index (multi-platform) or an image.
+func platformImage(desc *remote.Descriptor, p v1.Platform) (v1.Image, error) {
+	if !desc.MediaType.IsIndex() {
+		return desc.Image()
+	}
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range manifest.Manifests {
+		if m.Platform != nil && m.Platform.Satisfies(p) {
+			return index.Image(m.Digest)
+		}
+	}
+	return nil, fmt.Errorf("no image found for platform %v", p.String())
+}
+
+// baseDigests are the digests of the base images last resolved from their
+// registries, by reference and platform.
+type baseDigests map[string]map[string]string
+
+// recordBase records the digest of the base image of the platform resolved
+// for the given reference, returning the digest previously recorded if it
+// differs, such that a changed base image may be reported.  The digests are
+// recorded in the function's builds directory (.func/builds/bases.json).
+func recordBase(job buildJob, ref name.Reference, p v1.Platform, digest v1.Hash) (previous string, err error) {
+	path := filepath.Join(job.dataDir(), "builds", "bases.json")
+	digests, err := readBaseDigests(job) // an unreadable record is replaced
+	if err != nil {
+		return
+	}
+
+	key, platform := ref.String(), platformName(p)
+	if previous = digests[key][platform]; previous == digest.String() {
+		return "", nil
+	}
+	if digests[key] == nil {
+		digests[key] = map[string]string{}
+	}
+	digests[key][platform] = digest.String()
+	bb, err := json.MarshalIndent(digests, "", "  ")
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return
+	}
+	return previous, os.WriteFile(path, bb, 0644)
+}
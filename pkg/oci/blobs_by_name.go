@@ -0,0 +1,46 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blobNames rewrites the names of the files from which blobs are written to
+// the readable names of their links (see linkBlob), where the two differ.
+var blobNames = strings.NewReplacer("certslayer", "certs", "fileslayer", "files", "groupslayer", "groups", "execlayer.f.", "exe.")
+
+// blobName returns the readable name of the blob written from the file at
+// path, such as datalayer, certs, exe.linux.arm64 or config.linux.amd64.
+func blobName(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".tar.gz"), ".json")
+	return blobNames.Replace(name)
+}
+
+// linkBlob links the given blob from the blobs-by-name directory of the build
+// under the readable name of the file at path from which it was written, such
+// that the blobs (named by digest) are navigable when debugging.  The links
+// are a sibling of the OCI layout, which is unaffected, and are only created
+// when verbose, keeping the layer tarballs (see WithKeepTars) or inspecting
+// failed builds (see WithInspectFailure).  This is
+// best-effort, as symbolic links may not be permitted (Windows).
+func linkBlob(job buildJob, path, blob string) {
+	if !job.verbose && !job.keepTars && !job.inspect {
+		return
+	}
+	dir := job.blobsByNameDir()
+	link := filepath.Join(dir, blobName(path))
+	target, err := filepath.Rel(dir, blob)
+	if err == nil {
+		err = os.MkdirAll(dir, os.ModePerm)
+	}
+	if err == nil {
+		_ = os.Remove(link) // of a previous build to this directory
+		err = os.Symlink(target, link)
+	}
+	if err != nil && job.verbose {
+		fmt.Fprintf(os.Stderr, "Warning: unable to link blob %v as %v. %v\n", filepath.Base(blob), link, err)
+	}
+}
@@ -0,0 +1,25 @@
+package oci
+
+import "testing"
+
+func Test_blobName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/build/datalayer.tar.gz", "datalayer"},
+		{"/build/certslayer.tar.gz", "certs"},
+		{"/build/fileslayer.tar.gz", "files"},
+		{"/build/groupslayer.linux.amd64.tar.gz", "groups.linux.amd64"},
+		{"/build/execlayer.f.linux.arm64.tar.gz", "exe.linux.arm64"},
+		{"/build/execlayer.f.linux.arm.v7.tar.gz", "exe.linux.arm.v7"},
+		{"/build/config.linux.amd64.json", "config.linux.amd64"},
+		{"/build/manifest.linux.amd64.json", "manifest.linux.amd64"},
+		{"/build/lib.tar.gz", "lib"},
+	}
+	for _, tt := range tests {
+		if got := blobName(tt.path); got != tt.want {
+			t.Errorf("blobName(%q): expected %q, got %q", tt.path, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Build hooks: the function's commands run before and after it is built.
+const (
+	HookPreBuild  = "preBuild"  // before the build (build.preBuild)
+	HookPostBuild = "postBuild" // after the image is built (build.postBuild)
+)
+
+// preBuild runs the function's pre-build commands (build.preBuild) in its
+// root, in order, with the build environment (buildEnvs).  As the commands
+// may generate source, the job's fingerprint is then recalculated such that
+// the build is of the source as generated.
+func preBuild(job *buildJob) (err error) {
+	if len(job.function.Build.PreBuild) == 0 {
+		return nil
+	}
+	if err = runHook(*job, HookPreBuild, job.function.Build.PreBuild); err != nil {
+		return
+	}
+	if job.hash, _, err = fn.Fingerprint(job.function.Root); err != nil {
+		return fmt.Errorf("error calculating fingerprint for build. %w", err)
+	}
+	return nil
+}
+
+// postBuild runs the function's post-build commands (build.postBuild) in its
+// root, in order, once the image is built and before it is pushed, with the
+// build environment (buildEnvs) and the built image:
+//
+//	FUNC_OCI_LAYOUT   directory of the image's OCI layout
+//	FUNC_IMAGE_DIGEST digest of the image's index
+//	FUNC_FINGERPRINT  fingerprint of the function's source
+func postBuild(job buildJob, index v1.Hash) error {
+	return runHook(job, HookPostBuild, job.function.Build.PostBuild,
+		"FUNC_OCI_LAYOUT="+job.ociDir(),
+		"FUNC_IMAGE_DIGEST="+index.String(),
+		"FUNC_FINGERPRINT="+job.hash)
+}
+
+// runHook runs the commands of the hook by the shell, failing on the first
+// which fails.
+func runHook(job buildJob, hook string, commands []string, envs ...string) error {
+	if len(commands) == 0 {
+		return nil
+	}
+	buildEnvs, err := hookEnvs(job.function)
+	if err != nil {
+		return err
+	}
+	envs = append(buildEnvs, envs...)
+
+	end := job.phase(hook)
+	defer end()
+	for _, c := range commands {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "%v\n", c)
+		} else if !job.quiet {
+			fmt.Printf("   %v\n", c)
+		}
+		cmd := shellCommand(job, c)
+		cmd.Env = envs
+		cmd.Dir = job.function.Root
+		if out, err := runCmd(job, cmd); err != nil {
+			return ErrHookFailed{Hook: hook, Command: c, Output: out, Err: err}
+		}
+	}
+	return nil
+}
+
+// hookEnvs are the environment of the hooks' commands: that of the process
+// with the function's build envs, interpolated, taking precedence.
+func hookEnvs(f fn.Function) ([]string, error) {
+	buildEnvs, err := fn.Interpolate(f.Build.BuildEnvs)
+	if err != nil {
+		return nil, err
+	}
+	envs := os.Environ()
+	for k, v := range buildEnvs {
+		envs = append(envs, k+"="+v) // the last of a name is used
+	}
+	return envs, nil
+}
+
+// shellCommand runs the command line c by the shell of the system.
+func shellCommand(job buildJob, c string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(job.ctx, "cmd", "/C", c)
+	}
+	return exec.CommandContext(job.ctx, "sh", "-c", c)
+}
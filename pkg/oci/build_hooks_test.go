@@ -0,0 +1,109 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestPreBuild ensures the pre-build commands are run in the function's root
+// with its build envs, the build's fingerprint including their output, and
+// that a failing command fails the build.
+func TestPreBuild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands of the test are of sh")
+	}
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.BuildEnvs.Add("GREETING", "hello")
+	f.Build.PreBuild = []string{"echo generated > gen.txt", "echo $GREETING > env.txt"}
+
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.quiet = true
+	hash := job.hash
+	if err = preBuild(&job); err != nil {
+		t.Fatal(err)
+	}
+	for file, expected := range map[string]string{"gen.txt": "generated", "env.txt": "hello"} {
+		bb, err := os.ReadFile(filepath.Join(root, file))
+		if err != nil {
+			t.Fatalf("expected %v to be written by a pre-build command. %v", file, err)
+		}
+		if strings.TrimSpace(string(bb)) != expected {
+			t.Fatalf("expected %v to contain %q, got %q", file, expected, bb)
+		}
+	}
+	if job.hash == hash {
+		t.Fatal("expected the fingerprint to include the generated files")
+	}
+
+	// A failing command fails the build, and those after it are not run
+	job.function.Build.PreBuild = []string{"echo oops && exit 3", "echo run > after.txt"}
+	err = preBuild(&job)
+	var e ErrHookFailed
+	if !errors.As(err, &e) {
+		t.Fatalf("expected ErrHookFailed, got %v", err)
+	}
+	if e.Hook != HookPreBuild || e.Command != "echo oops && exit 3" || !strings.Contains(e.Output, "oops") {
+		t.Fatalf("expected the failed command and its output, got %+v", e)
+	}
+	if _, err = os.Stat(filepath.Join(root, "after.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected the command after the failure not to be run, got %v", err)
+	}
+}
+
+// TestPostBuild ensures the post-build commands are run with the built image
+// in their environment, and that a failing command fails the build.
+func TestPostBuild(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands of the test are of sh")
+	}
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.PostBuild = []string{"echo $FUNC_OCI_LAYOUT $FUNC_IMAGE_DIGEST $FUNC_FINGERPRINT > built.txt"}
+
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.quiet = true
+	index, _, _ := v1.SHA256(strings.NewReader("index"))
+	if err = postBuild(job, index); err != nil {
+		t.Fatal(err)
+	}
+	bb, err := os.ReadFile(filepath.Join(root, "built.txt"))
+	if err != nil {
+		t.Fatalf("expected built.txt to be written by the post-build command. %v", err)
+	}
+	if expected := job.ociDir() + " " + index.String() + " " + job.hash; strings.TrimSpace(string(bb)) != expected {
+		t.Fatalf("expected the built image %q, got %q", expected, bb)
+	}
+
+	job.function.Build.PostBuild = []string{"exit 1"}
+	var e ErrHookFailed
+	if err = postBuild(job, index); !errors.As(err, &e) || e.Hook != HookPostBuild {
+		t.Fatalf("expected a failed post-build hook, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BuildInfoPath is the path in the image of the build information file
+// written with WithBuildInfo.
+const BuildInfoPath = "/func/.build-info"
+
+// BuildInfo is the provenance of the image, written as JSON to
+// BuildInfoPath, such that the function can read it at runtime from the
+// filesystem.  Its version and creation time are those of the FUNC_VERSION
+// and FUNC_CREATED environment variables of the image.
+type BuildInfo struct {
+	Fingerprint string `json:"fingerprint"` // of the function's source
+	Version     string `json:"version"`     // git describe --tags, if known
+	Created     string `json:"created"`     // RFC3339
+}
+
+// WithBuildInfo writes the build's provenance (see BuildInfo) as a file of
+// the image, in a layer of its own such that the function's other layers are
+// unchanged from build to build.
+func WithBuildInfo(write bool) BuilderOpt {
+	return func(b *Builder) {
+		b.buildInfo = write
+	}
+}
+
+// writeBuildInfoLayer writes the layer of the build information file, if
+// enabled.
+func writeBuildInfoLayer(job buildJob) ([]ImageLayer, error) {
+	if !job.buildInfo {
+		return []ImageLayer{}, nil
+	}
+	data, err := json.MarshalIndent(BuildInfo{
+		Fingerprint: job.hash,
+		Version:     funcVersion(job),
+		Created:     job.created().Format(time.RFC3339),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "Writing build information to %v\n", BuildInfoPath)
+	}
+	target := filepath.Join(job.buildDir(), "buildinfolayer.tar.gz")
+	if err = newBuildInfoTarball(target, append(data, '\n'), job.created()); err != nil {
+		return nil, err
+	}
+	layer, err := writeLayer(job, target)
+	if err != nil {
+		return nil, err
+	}
+	return []ImageLayer{layer}, nil
+}
+
+// newBuildInfoTarball writes the build information file to a gzipped
+// tarball at target, owned by the function's user as are its other files.
+func newBuildInfoTarball(target string, data []byte, modTime time.Time) error {
+	targetFile, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+
+	gw := gzip.NewWriter(targetFile)
+	defer gw.Close()
+
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     BuildInfoPath,
+		Mode:     0444,
+		Size:     int64(len(data)),
+		Uid:      DefaultUid,
+		Gid:      DefaultGid,
+		ModTime:  modTime,
+	}
+	if err = tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
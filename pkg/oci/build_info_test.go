@@ -0,0 +1,71 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// Test_writeBuildInfoLayer ensures the build information file is written
+// only if enabled, of the build's fingerprint and creation time.
+func Test_writeBuildInfoLayer(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("FUNC_GIT", "git-not-installed") // no version
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+
+	// Disabled by default
+	layers, err := writeBuildInfoLayer(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 0 {
+		t.Fatalf("expected no layer by default, got %v", len(layers))
+	}
+
+	job.buildInfo = true
+	if layers, err = writeBuildInfoLayer(job); err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) != 1 {
+		t.Fatalf("expected one layer, got %v", len(layers))
+	}
+	layer, err := tarball.LayerFromFile(filepath.Join(job.blobsDir(), layers[0].Descriptor.Digest.Hex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := readFiles([]v1.Layer{layer}, BuildInfoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info BuildInfo
+	if err = json.Unmarshal(files[BuildInfoPath], &info); err != nil {
+		t.Fatalf("invalid build information %q. %v", files[BuildInfoPath], err)
+	}
+	expected := BuildInfo{Fingerprint: job.hash, Created: job.created().Format(time.RFC3339)}
+	if info != expected {
+		t.Fatalf("expected %+v, got %+v", expected, info)
+	}
+}
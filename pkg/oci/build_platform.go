@@ -0,0 +1,158 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// PlatformImage is the image of a single platform built by BuildPlatform.
+type PlatformImage struct {
+	ImageResult
+	Descriptor v1.Descriptor // of the image's manifest
+	Path       string        // directory of the image's blobs (blobs/sha256)
+}
+
+// BuildPlatform builds the image of a single platform: its layers, config and
+// manifest, but no index, such that callers which orchestrate platforms
+// themselves, or which need only the platform of the cluster to which they
+// deploy, need not build every platform.  The image's blobs are written to
+// .func/builds/by-platform/{os.arch[.variant]} (or within the work
+// directory), replacing the image of that platform built previously, and
+// the last build (.func/builds/last) is left as is.  Post-build commands
+// (build.postBuild), which are of a built index, are not run.
+func (b *Builder) BuildPlatform(ctx context.Context, f fn.Function, p fn.Platform) (image PlatformImage, err error) {
+	var job buildJob
+	defer func(start time.Time) { b.record(f, []fn.Platform{p}, start, job.cacheStats, err) }(time.Now())
+
+	if err = b.options.validate(); err != nil {
+		return
+	}
+	if err = ValidateAnnotations(f.Build.Annotations); err != nil {
+		return
+	}
+	if job, err = newBuildJob(ctx, f, []fn.Platform{p}, b.verbose); err != nil {
+		return
+	}
+	defer func() { err = noSpace(job.dataDir(), err) }()
+	job.options = b.options
+	job.quiet = b.quiet && !b.verbose
+	job.sharedCache = availableCache(job.sharedCache, job.verbose)
+	if b.impl != nil {
+		job.languageBuilder = b.impl
+	}
+	platform := job.platforms[0]
+	if err = checkPlatforms(job); err != nil {
+		return
+	}
+	if err = checkTools(job); err != nil {
+		return
+	}
+	endSecrets, err := prepareSecrets(&job)
+	if err != nil {
+		return
+	}
+	defer endSecrets()
+	if err = preBuild(&job); err != nil {
+		return
+	}
+	// Built apart from a build of every platform of the same source
+	job.hash += "." + platformDirName(platform)
+
+	if err = setup(job); err != nil {
+		return
+	}
+	defer cleanup(job)
+	defer func() {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "rm %v\n", job.pidLink())
+		}
+		_ = os.Remove(job.pidLink())
+	}()
+	defer func() {
+		if err != nil && job.inspect {
+			inspectFailure(job, os.Stderr, os.Stdin)
+		}
+	}()
+
+	endScaffold := job.phase("scaffold")
+	err = scaffold(job)
+	endScaffold()
+	if err != nil {
+		return
+	}
+
+	sharedLayers, err := writeSharedLayers(job)
+	if err != nil {
+		return
+	}
+	manifest, bases, err := buildPlatform(job, platform, sharedLayers)
+	if err != nil {
+		return
+	}
+	baseLayers := map[v1.Hash]bool{}
+	for _, h := range bases {
+		baseLayers[h] = true
+	}
+	if err = checkForeignLayers(job, baseLayers); err != nil {
+		return
+	}
+
+	// Move the image out of the build directory, which is removed
+	image.Path = job.platformDir(platform)
+	if err = os.RemoveAll(image.Path); err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(image.Path), os.ModePerm); err != nil {
+		return
+	}
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "mv %v %v\n", job.ociDir(), image.Path)
+	}
+	if err = os.Rename(job.ociDir(), image.Path); err != nil {
+		return
+	}
+	image.Descriptor = manifest
+	image.ImageResult, err = readPlatformImage(image.Path, manifest)
+	return
+}
+
+// readPlatformImage reads the result of the image of the given manifest
+// from the blobs of the directory.
+func readPlatformImage(dir string, desc v1.Descriptor) (image ImageResult, err error) {
+	bb, err := os.ReadFile(filepath.Join(dir, "blobs", desc.Digest.Algorithm, desc.Digest.Hex))
+	if err != nil {
+		return
+	}
+	var m v1.Manifest
+	if err = json.Unmarshal(bb, &m); err != nil {
+		return
+	}
+	image.Digest = desc.Digest
+	if desc.Platform != nil {
+		image.Platform = platformName(*desc.Platform)
+	}
+	for _, l := range m.Layers {
+		image.Layers = append(image.Layers, LayerResult{Digest: l.Digest, Size: l.Size})
+	}
+	return
+}
+
+// platformDirName is the platform in the form os.arch[.variant], as used in
+// file names.
+func platformDirName(p v1.Platform) string {
+	return strings.ReplaceAll(platformName(p), "/", ".")
+}
+
+// platformDir is the directory of the image of the platform built alone.
+func (j buildJob) platformDir(p v1.Platform) string {
+	return filepath.Join(j.dataDir(), "builds", "by-platform", platformDirName(p))
+}
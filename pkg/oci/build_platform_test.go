@@ -0,0 +1,62 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestBuilder_BuildPlatform ensures the image of a single platform is built
+// without an index, apart from the last build.
+func TestBuilder_BuildPlatform(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBuilder("", false, WithQuiet(true))
+	b.impl = NewTestLanguageBuilder()
+
+	image, err := b.BuildPlatform(context.Background(), f, fn.Platform{OS: "linux", Architecture: "arm64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Platform != "linux/arm64" {
+		t.Fatalf("expected an image for linux/arm64, got %q", image.Platform)
+	}
+	if image.Path != filepath.Join(root, fn.RunDataDir, "builds", "by-platform", "linux.arm64") {
+		t.Fatalf("unexpected image path %v", image.Path)
+	}
+	if len(image.Layers) == 0 {
+		t.Fatal("expected the image's layers")
+	}
+
+	// The manifest, config and layers are blobs of the image, without an index
+	if _, err = os.Stat(filepath.Join(image.Path, "blobs", "sha256", image.Digest.Hex)); err != nil {
+		t.Fatalf("expected the manifest blob. %v", err)
+	}
+	for _, l := range image.Layers {
+		if _, err = os.Stat(filepath.Join(image.Path, "blobs", "sha256", l.Digest.Hex)); err != nil {
+			t.Fatalf("expected the blob of layer %v. %v", l.Digest, err)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(image.Path, "index.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected no index, got %v", err)
+	}
+
+	// The last build is unaffected, and the build directory removed
+	if _, err = os.Lstat(filepath.Join(root, fn.RunDataDir, "builds", "last")); !os.IsNotExist(err) {
+		t.Fatalf("expected no last build link, got %v", err)
+	}
+	dd, _ := os.ReadDir(filepath.Join(root, fn.RunDataDir, "builds", "by-hash"))
+	if len(dd) != 0 {
+		t.Fatalf("expected the build directory to be removed, got %v", dd)
+	}
+}
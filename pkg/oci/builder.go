@@ -2,6 +2,7 @@ package oci
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
@@ -15,8 +16,10 @@ import (
 	slashpath "path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -27,6 +30,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 
 	fn "knative.dev/func/pkg/functions"
 	"knative.dev/func/pkg/scaffolding"
@@ -44,24 +48,125 @@ var defaultIgnored = []string{
 	".gitignore",
 }
 
-// OCI 构建器支持的语言(根据key选择)
-var builders = map[string]languageBuilder{
-	"go":     goBuilder{},
-	"python": pythonBuilder{},
+// strippedSource are the patterns additionally excluded from the data layer
+// when stripping source: tests, test fixtures and documentation which the
+// function does not need at runtime.
+var strippedSource = []string{
+	"*_test.go",
+	"testdata",
+	"test_*.py",
+	"*_test.py",
+	"conftest.py",
+	"tests",
+	"__pycache__",
+	"*.md",
 }
 
+// OCI 构建器支持的语言(根据key选择)
+var (
+	builders = map[string]LanguageBuilder{
+		"go":        goBuilder{},
+		"python":    pythonBuilder{},
+		wasmRuntime: wasmBuilder{},
+	}
+	builtins   = map[string]bool{"go": true, "python": true, wasmRuntime: true}
+	buildersMu sync.RWMutex
+)
+
 // IsSupported is for UX.
 func IsSupported(runtime string) bool {
-	_, ok := builders[runtime]
+	_, ok := builderFor(runtime)
 	return ok
 }
 
-type imageLayer struct {
+// RegisterBuilder registers the language builder used to build functions of
+// the given runtime, such that languages may be supported without modifying
+// this package.  Intended to be called from an init function.  Errors if a
+// builder is already registered for the runtime, including the built-in
+// builders; see ReplaceBuilder to explicitly replace one.
+func RegisterBuilder(runtime string, b LanguageBuilder) error {
+	buildersMu.Lock()
+	defer buildersMu.Unlock()
+	if runtime == "" || b == nil {
+		return errors.New("a runtime and builder are required to register a language builder")
+	}
+	if _, ok := builders[runtime]; ok {
+		return ErrBuilderRegistered{Runtime: runtime, Builtin: builtins[runtime]}
+	}
+	builders[runtime] = b
+	return nil
+}
+
+// ReplaceBuilder registers the language builder for the given runtime,
+// explicitly replacing any already registered including the built-ins.
+func ReplaceBuilder(runtime string, b LanguageBuilder) {
+	buildersMu.Lock()
+	defer buildersMu.Unlock()
+	builders[runtime] = b
+}
+
+// builderFor returns the language builder registered for the runtime.
+func builderFor(runtime string) (LanguageBuilder, bool) {
+	buildersMu.RLock()
+	defer buildersMu.RUnlock()
+	b, ok := builders[runtime]
+	return b, ok
+}
+
+// ImageLayer is a layer of the image being built, and its descriptor.
+type ImageLayer struct {
 	Descriptor v1.Descriptor
 	Layer      v1.Layer
 }
 
-type languageBuilder interface {
+// BuildContext is the view of a build provided to language builders.
+type BuildContext struct {
+	buildJob
+}
+
+// Context of the build, canceled if the build is canceled.
+func (c BuildContext) Context() context.Context { return c.ctx }
+
+// Function being built.
+func (c BuildContext) Function() fn.Function { return c.function }
+
+// Platforms being built.
+func (c BuildContext) Platforms() []v1.Platform { return c.platforms }
+
+// BuildDir is the directory of the build, containing the function's
+// scaffolding.  Language builders may write intermediate files here.
+func (c BuildContext) BuildDir() string { return c.buildDir() }
+
+// BlobsDir is the directory into which layers are written as blobs.
+func (c BuildContext) BlobsDir() string { return c.blobsDir() }
+
+// Verbose is true when the build should log verbosely.
+func (c BuildContext) Verbose() bool { return c.verbose }
+
+// Quiet is true when the build should output only errors.
+func (c BuildContext) Quiet() bool { return c.quiet }
+
+// WriteLayer moves the gzipped layer tarball at path into the blobs directory
+// and returns the resultant layer.
+func (c BuildContext) WriteLayer(path string) (ImageLayer, error) {
+	return writeLayer(c.buildJob, path)
+}
+
+// LanguageBuilder builds the language-specific parts of a function's image.
+// The host builder calls, for each build:
+//
+//  1. Base once per platform, to determine the base image upon which to
+//     build.  An empty string builds from scratch.
+//  2. WriteShared once, after the function's source and scaffolding are in
+//     the build directory, to write layers common to all platforms.
+//  3. WritePlatform once per platform, to write layers for that platform.
+//  4. Configure once per platform, to set for example the entrypoint.
+//
+// Layers returned must have been written as blobs to the build's blobs
+// directory, named by the hex of their digest (see BuildContext.WriteLayer).
+// Implementations must be safe to use concurrently by separate builds, and
+// should honor the build's context for cancellation.
+type LanguageBuilder interface {
 	// Base returns the base image (if any) to use.  Ideally this is a
 	// multi-arch base image with a corresponding platform image for
 	// each requested to be built.
@@ -69,47 +174,409 @@ type languageBuilder interface {
 
 	// WriteShared layers (not platform-specific) which need to be genearted
 	// on demand per language, such as shared dependencies.
-	WriteShared(buildJob) ([]imageLayer, error)
+	WriteShared(BuildContext) ([]ImageLayer, error)
 
-	// WritePlatform layers which are specific to the
-	WritePlatform(buildJob, v1.Platform) ([]imageLayer, error)
+	// WritePlatform layers which are specific to the platform.
+	// Platforms are built concurrently, so this may be called concurrently
+	// for different platforms (see WithConcurrency).
+	WritePlatform(BuildContext, v1.Platform) ([]ImageLayer, error)
 
 	// Configure a config with, for example, the entrypoint.
-	// Called once per platform.
-	Configure(buildJob, v1.Platform, v1.ConfigFile) (v1.ConfigFile, error)
+	// Called once per platform, possibly concurrently.
+	Configure(BuildContext, v1.Platform, v1.ConfigFile) (v1.ConfigFile, error)
+
+	// RequiredTools returns the commands run by the build, such as go, which
+	// must be found on the PATH of the build host.  They are checked before
+	// building (see checkTools), such that a missing tool fails with a hint
+	// rather than mid-build.
+	RequiredTools() []string
+}
+
+// CompiledBuilder is optionally implemented by the language builder of a
+// compiled runtime, whose image runs a self-contained binary, such that the
+// function's source (the data layer) may be omitted from the image (see
+// WithoutSource).
+type CompiledBuilder interface {
+	// Compiled returns true if the function's source is not required by
+	// the image at runtime.
+	Compiled() bool
+}
+
+// compiled returns true if the language builder is of a compiled runtime.
+func compiled(lb LanguageBuilder) bool {
+	c, ok := lb.(CompiledBuilder)
+	return ok && c.Compiled()
+}
+
+// PlatformBuilder is optionally implemented by language builders which can
+// build for only certain platforms, such that an unsupported platform fails
+// before building rather than when pulling the base image or compiling.
+// Language builders which do not implement it are assumed to support all.
+type PlatformBuilder interface {
+	// SupportsPlatform returns true if the platform can be built.
+	SupportsPlatform(v1.Platform) bool
+}
+
+// checkPlatforms returns an ErrUnsupportedPlatform for the first platform of
+// the job which its language builder does not support.
+func checkPlatforms(job buildJob) error {
+	pb, ok := job.languageBuilder.(PlatformBuilder)
+	if !ok {
+		return nil
+	}
+	for _, p := range job.platforms {
+		if !pb.SupportsPlatform(p) {
+			return ErrUnsupportedPlatform{Runtime: job.function.Runtime, Platform: platformName(p)}
+		}
+	}
+	return nil
+}
+
+// DefaultPlatformsBuilder is optionally implemented by language builders
+// whose images are not of the platforms of fn.DefaultPlatforms, such as
+// wasm, such that a build without platforms builds theirs.
+type DefaultPlatformsBuilder interface {
+	// DefaultPlatforms returns the platforms built when none are given.
+	DefaultPlatforms() []fn.Platform
+}
+
+// defaultPlatforms returns the platforms built for functions of the runtime
+// when none are given: those of its language builder, if it has defaults,
+// or else fn.DefaultPlatforms.
+func defaultPlatforms(runtime string) []fn.Platform {
+	if lb, ok := builderFor(runtime); ok {
+		if d, ok := lb.(DefaultPlatformsBuilder); ok {
+			return d.DefaultPlatforms()
+		}
+	}
+	return fn.DefaultPlatforms
+}
+
+// WithDefaultPlatforms sets the platforms built when none are given and the
+// function defines none (build.platforms), such as a team's fixed set of
+// targets, in place of those of the language builder or fn.DefaultPlatforms.
+func WithDefaultPlatforms(pp []fn.Platform) BuilderOpt {
+	return func(b *Builder) {
+		b.platforms = pp
+	}
+}
+
+// buildPlatforms returns the platforms to build when none are given: those
+// of the function (build.platforms), else those of the builder (see
+// WithDefaultPlatforms), else the defaults of the function's runtime.
+func (b *Builder) buildPlatforms(f fn.Function) ([]fn.Platform, error) {
+	if len(f.Build.Platforms) > 0 {
+		pp, err := fn.ParsePlatforms(f.Build.Platforms)
+		if err != nil {
+			return nil, fmt.Errorf("invalid build.platforms. %w", err)
+		}
+		return pp, nil
+	}
+	if len(b.platforms) > 0 {
+		return b.platforms, nil
+	}
+	return defaultPlatforms(f.Runtime), nil
 }
 
 type Builder struct {
 	name    string // TODO: why is this used again?
 	verbose bool   // log verbosely
 
+	options // 构建选项,传递至每个构建任务
+
 	onDone func()          // 用于测试，完成通知
-	impl   languageBuilder // 用于测试，构建实现的覆盖
+	impl   LanguageBuilder // 用于测试，构建实现的覆盖
+
+	result   BuildResult // of the last build
+	resultMu sync.Mutex
+}
+
+// options of the builder which are applied to each build job.
+type options struct {
+	capabilities  []string          // file capabilities of the function binary
+	quiet         bool              // suppress all non-error output
+	workDir       string            // relocates builds and the blob cache
+	sharedCache   string            // blob cache shared by all functions, if any
+	bundle        string            // path to which to export the built OCI layout
+	ociOutput     string            // directory to which to write the built OCI layout
+	annotations   map[string]string // added to the image's index and manifests
+	mediaType     string            // media type set of the image (oci or docker)
+	artifactType  string            // artifactType of the image index, if any
+	subject       *v1.Descriptor    // subject of the image index, if any
+	foreignLayers map[string]string // URLs of base layers to mark foreign, by digest
+	squash        string            // squash mode of the image's layers
+	concurrency   int               // platforms built at once, 0 for the default
+	buildTags     []string          // go build tags, added to those of the function
+	pgo           string            // go profile for PGO, "off" to disable default.pgo
+	buildVCS      string            // go VCS stamping mode: auto, true or false
+	goToolchain   string            // GOTOOLCHAIN of go builds: local or a version
+	goModules     GoModules         // module proxy, private modules and credentials
+	middleware    string            // version to which the go middleware is pinned
+	replace       map[string]string // go.mod replace directives of the scaffolding
+	scanner       string            // scanner of the built image, required if set
+	scanSeverity  string            // severity at or above which the scan fails
+	checksums     bool              // write checksums.txt of the artifacts
+	withoutSource bool              // omit the data layer (compiled runtimes only)
+	stripSource   bool              // exclude tests and docs from the data layer
+	keepTars      bool              // keep layer tarballs in the build directory
+	inspect       bool              // report the partial layout of a failed build
+	zeroTimes     bool              // set the times of the image and its files to the epoch
+	dockerConfig  string            // docker config directory of base pull credentials
+	caBundle      string            // PEM bundle of CAs added to those of the image
+	buildInfo     bool              // write the build's provenance to /func/.build-info
+	secrets       []Secret          // available to the build's commands, never to the image
+	proxy         Proxy             // of base pulls and the build's commands, over the environment's
+	signingKey    string            // private key with which the index is signed, if any
+	signManifests bool              // also sign each platform manifest
+
+	registryMirrors map[string]string // mirrors of base image registries, by registry
+	digestAlgorithm string            // digest algorithm of exported layouts
+
+	verifyReproducible bool // build twice, failing if the images differ
+
+	platforms  []fn.Platform // built when none are given nor defined by the function
+	pullPolicy string        // when the base image is pulled, "" for if-not-present
+	telemetry  Sink          // receives an event of each build, if any
+
+	maxLayers     int  // of each platform's image, 0 for no limit
+	maxLayersFail bool // fail rather than warn of more layers than maxLayers
+}
+
+// validate the options prior to building.
+func (o options) validate() error {
+	if _, err := capabilityData(o.capabilities); err != nil {
+		return err
+	}
+	if err := ValidateAnnotations(o.annotations); err != nil {
+		return err
+	}
+	if err := ValidateMediaTypes(o.mediaType); err != nil {
+		return err
+	}
+	if err := validateArtifact(o); err != nil {
+		return err
+	}
+	if err := ValidateForeignLayers(o.foreignLayers); err != nil {
+		return err
+	}
+	if err := ValidateRegistryMirrors(o.registryMirrors); err != nil {
+		return err
+	}
+	if err := ValidateSquash(o.squash); err != nil {
+		return err
+	}
+	if err := ValidateScanSeverity(o.scanSeverity); err != nil {
+		return err
+	}
+	if err := ValidateCABundle(o.caBundle); err != nil {
+		return err
+	}
+	if err := ValidateSecrets(o.secrets); err != nil {
+		return err
+	}
+	if err := ValidateProxy(o.proxy); err != nil {
+		return err
+	}
+	if err := ValidateSigning(o.signingKey, o.signManifests); err != nil {
+		return err
+	}
+	if err := ValidateDigestAlgorithm(o.digestAlgorithm); err != nil {
+		return err
+	}
+	if err := ValidateGoToolchain(o.goToolchain); err != nil {
+		return err
+	}
+	if err := ValidateBuildVCS(o.buildVCS); err != nil {
+		return err
+	}
+	if err := ValidatePullPolicy(o.pullPolicy); err != nil {
+		return err
+	}
+	if err := ValidateMaxLayers(o.maxLayers); err != nil {
+		return err
+	}
+	if errs := fn.ValidateBuildTags(o.buildTags); len(errs) > 0 {
+		return errors.New(errs[0])
+	}
+	if o.concurrency < 0 {
+		return fmt.Errorf("invalid build concurrency %v: must not be negative", o.concurrency)
+	}
+	if o.squash == SquashAll && len(o.foreignLayers) > 0 {
+		return errors.New("foreign layers are not supported when squashing the base image's layers")
+	}
+	return nil
+}
+
+// BuilderOpt is an optional setting of a Builder.
+type BuilderOpt func(*Builder)
+
+// WithCapabilities sets the given Linux file capabilities (for example
+// "cap_net_bind_service") as permitted and effective on the function's binary
+// (Go functions only).  This allows a function which runs as a non-root user
+// to, for example, bind to privileged ports.
+//
+// Security: any process executing the binary gains these capabilities, so
+// grant only those which are strictly necessary.  Note also that the runtime
+// must permit the capabilities (be in the container's bounding set), as
+// executing a binary with file capabilities which are not allowed fails.
+// Off by default.
+func WithCapabilities(capabilities ...string) BuilderOpt {
+	return func(b *Builder) {
+		b.capabilities = capabilities
+	}
+}
+
+// WithQuiet suppresses all non-error output of the build, including progress
+// messages and the output of child processes such as the compiler.  The
+// output of a failed child process is instead returned with its error.
+// Ignored when building verbosely.
+func WithQuiet(quiet bool) BuilderOpt {
+	return func(b *Builder) {
+		b.quiet = quiet
+	}
+}
+
+// WithWorkDir relocates the builds and blob cache from the function's .func
+// directory to a subdirectory of the given directory, for example a fast local
+// temporary directory when the function's source is on a slow or read-only
+// mount.  The link to the last build (.func/builds/last) and the built
+// image.tar remain in the function's .func directory.
+func WithWorkDir(dir string) BuilderOpt {
+	return func(b *Builder) {
+		b.workDir = dir
+	}
+}
+
+// WithConcurrency limits the number of platforms built at once, each of
+// which compiles the function, to reduce the resources used, for example to
+// avoid running out of memory on a constrained runner.  The default (0) is
+// the lesser of the number of platforms and GOMAXPROCS.
+func WithConcurrency(n int) BuilderOpt {
+	return func(b *Builder) {
+		b.concurrency = n
+	}
+}
+
+// WithoutSource omits the function's source (the data layer) from the image,
+// for compiled runtimes (see CompiledBuilder) whose binary is self-contained,
+// such that proprietary source is not shipped.  The image then contains only
+// the binary and certificates (and the base image).  A function which reads
+// files of its source at runtime, such as templates or static assets, should
+// instead embed them (go:embed).  Off by default, as the source aids
+// debugging, and an error for runtimes which are not compiled.
+func WithoutSource(without bool) BuilderOpt {
+	return func(b *Builder) {
+		b.withoutSource = without
+	}
+}
+
+// WithStripSource excludes tests, test fixtures and documentation (such as
+// *_test.go, testdata/ and *.md) from the data layer, in addition to the
+// always-ignored files, reducing the image's size.  The excluded files remain
+// available to the build itself.  Off by default.
+func WithStripSource(strip bool) BuilderOpt {
+	return func(b *Builder) {
+		b.stripSource = strip
+	}
+}
+
+// WithKeepTars keeps the gzipped tarball of each layer written by the build
+// (such as datalayer.tar.gz, certslayer.tar.gz and execlayer.*.tar.gz) in the
+// build directory (.func/builds/last) under its descriptive name, in addition
+// to the blob named by its digest, such that its contents may be inspected
+// with, for example, tar tzf.  The blobs are then also linked by readable
+// name from blobs-by-name in the build directory, as when verbose.  Off by
+// default, as the tarballs are otherwise moved.
+func WithKeepTars(keep bool) BuilderOpt {
+	return func(b *Builder) {
+		b.keepTars = keep
+	}
 }
 
 // NewBuilder creates a builder instance.
-func NewBuilder(name string, verbose bool) *Builder {
-	return &Builder{name: name, verbose: verbose, onDone: func() {}}
+func NewBuilder(name string, verbose bool, opts ...BuilderOpt) *Builder {
+	b := &Builder{name: name, verbose: verbose, onDone: func() {}}
+	b.sharedCache = SharedCacheDir()
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
 }
 
 // Build 构建一个OCI镜像的函数(类似docker打包)，包装在服务中，暴露接口作为网络服务。
-// 平台是可选的，默认为fn.DefaultPlatforms
-// "linux/amd64", "linux/arm64", "linux/arm/v7"
+// 平台是可选的，默认为函数的build.platforms,其次为构建器的默认平台(见WithDefaultPlatforms),
+// 否则为fn.DefaultPlatforms "linux/amd64", "linux/arm64", "linux/arm/v7"
+// 或语言构建器的默认平台(见DefaultPlatformsBuilder),如wasm的wasi/wasm
 func (b *Builder) Build(ctx context.Context, f fn.Function, pp []fn.Platform) (err error) {
+	// 遥测(可选),包括创建构建任务之前的失败
+	var job buildJob
+	defer func(start time.Time) { b.record(f, pp, start, job.cacheStats, err) }(time.Now())
+
+	// 校验函数根目录存在且包含已初始化的函数(非CLI调用者未必已校验)
+	if err = checkRoot(f); err != nil {
+		return
+	}
 	// cmd中限制了只能使用默认的platform
 	if len(pp) == 0 {
-		pp = fn.DefaultPlatforms
+		if pp, err = b.buildPlatforms(f); err != nil {
+			return
+		}
+	}
+	// 规范化平台变体(如 arm/7 -> arm/v7),见 fn.NormalizePlatform
+	if pp, err = fn.NormalizePlatforms(pp); err != nil {
+		return
 	}
 
 	// 1) 创建构建任务(根据语言选择构建器)
-	job, err := newBuildJob(ctx, f, pp, b.verbose)
-	if err != nil {
+	if err = b.options.validate(); err != nil {
 		return
 	}
+	if err = ValidateAnnotations(f.Build.Annotations); err != nil {
+		return
+	}
+	if job, err = newBuildJob(ctx, f, pp, b.verbose); err != nil {
+		return
+	}
+	job.options = b.options
+	job.quiet = b.quiet && !b.verbose
+	job.sharedCache = availableCache(job.sharedCache, job.verbose)
+	var (
+		index  v1.Hash       // digest of the built image index
+		images []ImageResult // built image of each platform
+	)
+	defer func() {
+		err = noSpace(job.dataDir(), err) // 磁盘空间不足
+		b.resultMu.Lock()
+		b.result = job.result()
+		b.result.Index, b.result.Images = index, images
+		b.resultMu.Unlock()
+		if err == nil && !job.quiet {
+			fmt.Printf("Build fingerprint: %v (%v)\n", b.result.Fingerprint, b.result.Dir)
+			fmt.Printf("Build timings: %v (total %.1fs)\n", b.result.Timings, b.result.Duration.Seconds())
+		}
+	}()
 	if b.impl != nil {
 		// 自定义构建器,用于测试
 		job.languageBuilder = b.impl
 	}
+	if err = checkPlatforms(job); err != nil {
+		return
+	}
+	if err = checkTools(job); err != nil {
+		return
+	}
+
+	// 构建密钥(可选),仅提供给构建期间运行的命令,不写入镜像
+	endSecrets, err := prepareSecrets(&job)
+	if err != nil {
+		return
+	}
+	defer endSecrets()
+
+	// 构建前命令(可选,如代码生成),在计算指纹和生成脚手架之前运行
+	if err = preBuild(&job); err != nil {
+		return
+	}
 
 	// 2) 设置构建环境(创建目录)
 	if err = setup(job); err != nil {
@@ -123,9 +590,18 @@ func (b *Builder) Build(ctx context.Context, f fn.Function, pp []fn.Platform) (e
 		}
 		_ = os.Remove(job.pidLink())
 	}()
+	defer func() {
+		// 构建失败时报告部分构建的状态(在清理之前)
+		if err != nil && job.inspect {
+			inspectFailure(job, os.Stderr, os.Stdin)
+		}
+	}()
 
 	// 3) 生成脚手架代码
-	if err = scaffold(job); err != nil {
+	endScaffold := job.phase("scaffold")
+	err = scaffold(job)
+	endScaffold()
+	if err != nil {
 		return
 	}
 
@@ -133,14 +609,55 @@ func (b *Builder) Build(ctx context.Context, f fn.Function, pp []fn.Platform) (e
 	if err = containerize(job); err != nil {
 		return
 	}
+	if index, images, err = readImages(job.ociDir()); err != nil {
+		return
+	}
+
+	// 再次构建以验证可重现性(可选),两次构建的镜像不同时失败
+	if err = verifyReproducible(job, index); err != nil {
+		return
+	}
+
+	// 写入构建产物的校验和(可选)
+	if err = writeChecksums(job); err != nil {
+		return
+	}
+
+	// 构建后命令(可选,如签名、扫描、通知),在推送之前运行
+	if err = postBuild(job, index); err != nil {
+		return
+	}
 
 	// 5) 更新最后一次构建的链接 .func/builds/last
 	if err = updateLastLink(job); err != nil {
 		return
 	}
 
+	// 输出OCI布局到指定目录(可选)
+	if job.ociOutput != "" {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "Writing OCI layout to %v\n", job.ociOutput)
+		}
+		if err = exportLayout(job.ociDir(), job.ociOutput, job.digestAlgorithm()); err != nil {
+			return
+		}
+	}
+
+	// 导出OCI布局归档(可选)
+	if job.bundle != "" {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "Exporting bundle %v\n", job.bundle)
+		}
+		if err = exportBundle(job.ociDir(), job.bundle, job.digestAlgorithm()); err != nil {
+			return
+		}
+	}
+
 	// 6) 构建镜像(使用DOCKER_HOST对应的镜像仓库,可自行修改)
-	if err = buildImage(f, job); err != nil {
+	endSave := job.phase("save")
+	err = buildImage(f, job)
+	endSave()
+	if err != nil {
 		return
 	}
 
@@ -149,6 +666,69 @@ func (b *Builder) Build(ctx context.Context, f fn.Function, pp []fn.Platform) (e
 	return
 }
 
+// Fingerprint of the function's source in its current state, which
+// identifies its build, and the directory to which the builder would build it
+// (.func/builds/by-hash/{fingerprint}, or within the work directory).
+func (b *Builder) Fingerprint(f fn.Function) (hash, dir string, err error) {
+	job := buildJob{function: f, options: b.options}
+	if job.hash, _, err = fn.Fingerprint(f.Root); err != nil {
+		return "", "", fmt.Errorf("error calculating fingerprint for build. %w", err)
+	}
+	return job.hash, job.buildDir(), nil
+}
+
+// SourceLayer is a function's source (data) layer as built alone by
+// BuildSourceLayer.
+type SourceLayer struct {
+	ImageLayer
+	Path string // path of the layer's blob
+}
+
+// BuildSourceLayer builds only the function's source (data) layer, skipping
+// compilation, base image pull and image assembly, such that the source may
+// be overlaid onto a prebuilt runtime base at deploy time.  The layer's blob
+// is written to the function's blob cache, where it remains after the build.
+func (b *Builder) BuildSourceLayer(ctx context.Context, f fn.Function) (layer SourceLayer, err error) {
+	// The runtime is irrelevant to the source layer
+	job, err := newBuildJob(ctx, f, nil, b.verbose)
+	var errRuntime ErrUnsupportedRuntime
+	if err != nil && !errors.As(err, &errRuntime) {
+		return
+	}
+	job.options = b.options
+	job.quiet = b.quiet && !b.verbose
+	job.sharedCache = "" // the source layer is specific to the function
+
+	if err = setup(job); err != nil {
+		return
+	}
+	defer cleanup(job)
+	defer func() {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "rm %v\n", job.pidLink())
+		}
+		_ = os.Remove(job.pidLink())
+	}()
+
+	data, err := writeDataLayer(job)
+	if err != nil {
+		return
+	}
+
+	// Move the blob to the cache, as the build directory is removed
+	blob := filepath.Join(job.blobsDir(), data.Descriptor.Digest.Hex)
+	layer.Path = filepath.Join(job.cacheDir(), data.Descriptor.Digest.Hex)
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "mv %v %v\n", rel(job.buildDir(), blob), layer.Path)
+	}
+	if err = os.Rename(blob, layer.Path); err != nil {
+		return
+	}
+	layer.Descriptor = data.Descriptor
+	layer.Layer, err = tarball.LayerFromFile(layer.Path)
+	return
+}
+
 // setup 设置构建环境
 func setup(job buildJob) (err error) {
 	// 如果另一个构建正在进行，则失败
@@ -197,8 +777,8 @@ func setup(job buildJob) (err error) {
 	}
 
 	// 用于构建之间共享基础层的 Blob 缓存目录。
-	// 注意：可能会将其转换为系统全局缓存（如果可用），位于
-	// XDG_CONFIG_HOME/func/image-cache，当前实现作为后备方案：
+	// 可用时为系统全局缓存 XDG_CONFIG_HOME/func/image-cache (see
+	// WithSharedCache)，否则使用函数的 .func/blob-cache 作为后备方案。
 	// TODO：虽然不太可能，但在活跃开发过程中，经过多轮基础层更改后，
 	// 这个目录可能会变得难以管理。我们应该有某种方式来截断或
 	// 缓解这种潜在的磁盘内存泄漏问题。
@@ -245,123 +825,311 @@ func cleanup(job buildJob) {
 
 // scaffold 写出进程包装代码，当包含在最终容器中时，将实例化函数并将其作为服务暴露。
 func scaffold(job buildJob) (err error) {
+	// 无脚手架模式: 直接构建函数自身的main包
+	if job.function.Build.NoScaffold {
+		if job.function.Runtime != "go" {
+			return ErrScaffold{fmt.Errorf("building without scaffolding is only supported for go functions, not %q", job.function.Runtime)}
+		}
+		if err = goEntrypoint(job.function.Root); err != nil {
+			return ErrScaffold{err}
+		}
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "Skipping scaffolding of %v\n", job.function.Root)
+		}
+		return os.MkdirAll(job.buildDir(), os.ModePerm)
+	}
+
+	// wasm函数没有脚手架: 使用预构建模块或函数自身的main包
+	if job.function.Runtime == wasmRuntime {
+		return wasmScaffold(job)
+	}
+
 	// 提取嵌入的文件系统，其中包含给定运行时的 scaffolding
 	repo, err := fn.NewRepository("", "")
 	if err != nil {
-		return
+		return ErrScaffold{err}
 	}
 
 	if job.verbose {
 		fmt.Fprintf(os.Stderr, "Scaffolding to %v\n", job.buildDir())
 	}
 
-	return scaffolding.Write(
+	if err = scaffolding.Write(
 		job.buildDir(),       // desintation for scaffolding
 		job.function.Root,    // source to be scaffolded
 		job.function.Runtime, // scaffolding language to write
-		job.function.Invoke, repo.FS())
+		job.function.Invoke, repo.FS()); err != nil {
+		return ErrScaffold{err}
+	}
+
+	// 替换go模块(replace), 包括固定中间件(knative.dev/func-go)版本
+	if err = writeGoReplaces(job); err != nil {
+		return ErrScaffold{err}
+	}
+	return
 }
 
 // containerize 容器化整个服务，包括scaffolded函数、函数实现、基础镜像、数据层等。
 func containerize(job buildJob) error {
-	sharedLayers := []imageLayer{}
+	// 1) 创建共享层
+	sharedLayers, err := writeSharedLayers(job)
+	if err != nil {
+		return err
+	}
+
+	// 2) 为每个平台创建镜像,并行构建(并发数见 WithConcurrency)
+	// 任一平台失败时取消其余平台的构建
+	var (
+		manifests   = make([]v1.Descriptor, len(job.platforms))
+		bases       = make([][]v1.Hash, len(job.platforms)) // base layers of each
+		g, ctx      = errgroup.WithContext(job.ctx)
+		platformJob = job
+	)
+	platformJob.ctx = ctx
+	g.SetLimit(job.concurrency())
+	for i, p := range job.platforms {
+		g.Go(func() (err error) {
+			manifests[i], bases[i], err = buildPlatform(platformJob, p, sharedLayers)
+			return
+		})
+	}
+	if err = g.Wait(); err != nil {
+		return err
+	}
+	baseLayers := map[v1.Hash]bool{} // of all platforms
+	for _, hh := range bases {
+		for _, h := range hh {
+			baseLayers[h] = true
+		}
+	}
+	if err = checkForeignLayers(job, baseLayers); err != nil {
+		return err
+	}
+
+	// 3) 创建镜像索引
+
+	/*
+		.func/builds/by-hash/{hash}/
+		├── oci/
+		│   ├── oci-layout          # OCI布局文件
+		│   ├── index.json          # 镜像索引
+		│   └── blobs/sha256/       # 所有层和配置的blob
+		│       ├── {digest1}       # 数据层
+		│       ├── {digest2}       # 证书层
+		│       ├── {digest3}       # 语言层
+		│       └── {digest4}       # 配置文件
+		├── result/                 # 编译结果
+		│   └── f.linux.amd64      # Go二进制文件
+		└── service/               # 脚手架代码
+		    └── main.py            # Python服务包装器
+	*/
+
+	if err = writeIndex(job, manifests); err != nil {
+		return err
+	}
+	if err = verifyIndex(job); err != nil {
+		return err
+	}
+
+	// 4) 扫描镜像漏洞(可选),超过阈值时构建失败
+	return scanImage(job)
+}
+
+// writeSharedLayers writes the oci-layout file and the layers shared by the
+// images of all platforms: the data, certificates and files layers and those
+// written by the language builder's WriteShared.  Each is written to the
+// blobs directory once, named by its digest, and the same descriptor is
+// referenced by the manifest of each platform, such that a shared layer is
+// neither duplicated on disk nor pushed more than once.
+func writeSharedLayers(job buildJob) ([]ImageLayer, error) {
+	sharedLayers := []ImageLayer{}
 
 	if err := os.WriteFile(filepath.Join(job.ociDir(), "oci-layout"),
 		[]byte(`{ "imageLayoutVersion": "1.0.0" }`), os.ModePerm); err != nil {
-		return err
+		return nil, err
+	}
+
+	// - 数据层（源码）,编译型运行时可选择省略
+	if job.withoutSource && !compiled(job.languageBuilder) {
+		return nil, fmt.Errorf("the source of %v functions is required at runtime, so can not be omitted", job.function.Runtime)
+	}
+	endLayers := job.phase("layers")
+	if !job.withoutSource {
+		data, err := writeDataLayer(job)
+		if err != nil {
+			return nil, err
+		}
+		sharedLayers = append(sharedLayers, data)
+	} else if job.verbose {
+		fmt.Fprintf(os.Stderr, "Omitting the function's source (data layer)\n")
+	}
+
+	// - 证书层
+	certs, err := writeCertsLayer(job) // shared
+	if err != nil {
+		endLayers()
+		return nil, err
+	}
+	sharedLayers = append(sharedLayers, certs)
+
+	// - 文件层(可选,放置于镜像中/func以外的指定路径,见build.files)
+	files, err := writeFilesLayer(job)
+	endLayers()
+	if err != nil {
+		return nil, err
+	}
+	sharedLayers = append(sharedLayers, files...)
+
+	// - 构建信息层(可选,/func/.build-info)
+	info, err := writeBuildInfoLayer(job)
+	if err != nil {
+		return nil, err
+	}
+	sharedLayers = append(sharedLayers, info...)
+
+	// - 语言特定共享层（如Python依赖）
+	endShared := job.phase("shared")
+	shared, err := job.languageBuilder.WriteShared(BuildContext{job})
+	endShared()
+	if err != nil {
+		return nil, err
+	}
+	if err = validateLayers(job, "WriteShared", shared); err != nil {
+		return nil, err
+	}
+	return append(sharedLayers, shared...), nil
+}
+
+// buildPlatform builds the image of a single platform atop the shared
+// layers, returning its manifest's descriptor and the digests of the layers
+// of its base image.  Called concurrently for each platform.
+func buildPlatform(job buildJob, p v1.Platform, sharedLayers []ImageLayer) (manifest v1.Descriptor, baseLayers []v1.Hash, err error) {
+	// 创建平台特定层(根据语言来决定平台特定层的内容)
+	endCompile := job.phase("compile " + platformName(p))
+	platformSpecificLayers, err := job.languageBuilder.WritePlatform(BuildContext{job}, p)
+	endCompile()
+	if err != nil {
+		return
+	}
+	if err = validateLayers(job, "WritePlatform", platformSpecificLayers); err != nil {
+		return
+	}
+	// The shared layers are copied, as they are shared between platforms
+	layers := append(append([]ImageLayer{}, sharedLayers...), platformSpecificLayers...)
+
+	// 拉取基础镜像(使用go-containerregistry)
+	endPull := job.phase("base-pull " + platformName(p))
+	base, err := pullBase(job, p)
+	endPull()
+	if err != nil {
+		return
+	}
+	if base != nil {
+		baseManifest, err := base.Manifest()
+		if err != nil {
+			return manifest, nil, err
+		}
+		for _, l := range baseManifest.Layers {
+			baseLayers = append(baseLayers, l.Digest)
+		}
+	}
+
+	// 补充组(可选): 写入/etc/passwd与/etc/group层,容器运行时据此设置函数用户的补充组
+	groups, err := writeGroupsLayer(job, p, base)
+	if err != nil {
+		return
 	}
+	layers = append(layers, groups...)
 
-	// 1) 创建共享层
-	// - 数据层（源码）
-	data, err := writeDataLayer(job)
+	// 压缩层(可选),包括基础镜像层时清单不再引用基础镜像层
+	manifestBase := base
+	if job.squash != SquashNone {
+		endSquash := job.phase("squash " + platformName(p))
+		layers, err = squash(job, p, base, layers)
+		endSquash()
+		if err != nil {
+			return
+		}
+		if job.squash == SquashAll {
+			manifestBase = nil
+		}
+	}
+
+	// 创建配置文件
+	configFile, err := newConfigFile(job, p, base, layers)
 	if err != nil {
-		return err
+		return
+	}
+	if job.squash == SquashAll && base != nil {
+		configFile = squashedConfig(job, configFile)
+	}
+	configFile, err = job.languageBuilder.Configure(BuildContext{job}, p, configFile)
+	if err != nil {
+		return
 	}
-	sharedLayers = append(sharedLayers, data)
 
-	// - 证书层
-	certs, err := writeCertsLayer(job) // shared
+	// 写入配置
+	config, err := writeConfig(job, p, configFile)
 	if err != nil {
-		return err
+		return
 	}
-	sharedLayers = append(sharedLayers, certs)
 
-	// - 语言特定共享层（如Python依赖）
-	shared, err := job.languageBuilder.WriteShared(job)
+	// 创建manifests清单,注解记录基础镜像(或scratch)
+	annotations, err := job.manifestAnnotations(base)
 	if err != nil {
-		return err
+		return
 	}
-	sharedLayers = append(sharedLayers, shared...)
+	manifest, err = writeManifest(job, p, manifestBase, config, layers, annotations)
+	return
+}
 
-	// 2) 为每个平台创建镜像(这里转换为镜像需要只能是一个平台的)
-	manifests := []v1.Descriptor{}
-	for _, p := range job.platforms {
-		// 创建平台特定层(根据语言来决定平台特定层的内容)
-		platformSpecificLayers, err := job.languageBuilder.WritePlatform(job, p)
-		if err != nil {
-			return err
+// validateLayers ensures that each of the layers returned by a language
+// builder's method was written as a blob to the blobs directory, and that
+// the blob's digest and size match its descriptor.
+func validateLayers(job buildJob, method string, layers []ImageLayer) error {
+	for i, l := range layers {
+		invalid := func(format string, args ...any) error {
+			return ErrInvalidLayer{
+				Runtime: job.function.Runtime,
+				Method:  method,
+				Index:   i,
+				Digest:  l.Descriptor.Digest.String(),
+				Reason:  fmt.Sprintf(format, args...),
+			}
 		}
-		layers := append(sharedLayers, platformSpecificLayers...)
-
-		// 拉取基础镜像(使用go-containerregistry)
-		base, err := pullBase(job, p)
-		if err != nil {
-			return err
+		if l.Layer == nil {
+			return invalid("no layer")
 		}
-
-		// 创建配置文件
-		configFile, err := newConfigFile(job, p, base, layers)
+		digest, err := l.Layer.Digest()
 		if err != nil {
-			return err
+			return invalid("unable to calculate digest. %v", err)
 		}
-		configFile, err = job.languageBuilder.Configure(job, p, configFile)
-		if err != nil {
-			return err
+		if digest != l.Descriptor.Digest {
+			return invalid("layer digest is %v", digest)
 		}
-
-		// 写入配置
-		config, err := writeConfig(job, configFile)
+		info, err := os.Stat(filepath.Join(job.blobsDir(), l.Descriptor.Digest.Hex))
 		if err != nil {
-			return err
+			return invalid("blob not found. %v", err)
 		}
-
-		// 创建manifests清单
-		manifest, err := writeManifest(job, p, base, config, layers)
-		if err != nil {
-			return err
+		if info.Size() != l.Descriptor.Size {
+			return invalid("blob size is %v but the descriptor size is %v", info.Size(), l.Descriptor.Size)
 		}
-		manifests = append(manifests, manifest)
 	}
-
-	// 3) 创建镜像索引
-
-	/*
-		.func/builds/by-hash/{hash}/
-		├── oci/
-		│   ├── oci-layout          # OCI布局文件
-		│   ├── index.json          # 镜像索引
-		│   └── blobs/sha256/       # 所有层和配置的blob
-		│       ├── {digest1}       # 数据层
-		│       ├── {digest2}       # 证书层
-		│       ├── {digest3}       # 语言层
-		│       └── {digest4}       # 配置文件
-		├── result/                 # 编译结果
-		│   └── f.linux.amd64      # Go二进制文件
-		└── service/               # 脚手架代码
-		    └── main.py            # Python服务包装器
-	*/
-
-	return writeIndex(job, manifests)
+	return nil
 }
 
 // writeDataLayer 将源码打包成tar.gz(数据层)
-func writeDataLayer(job buildJob) (layer imageLayer, err error) {
+func writeDataLayer(job buildJob) (layer ImageLayer, err error) {
 	// 创建根目录
 	source := job.function.Root
 	target := filepath.Join(job.buildDir(), "datalayer.tar.gz")
 
 	// 创建源码压缩包，排除 .git, .func 等文件
-	if err = newDataTarball(source, target, defaultIgnored, job.verbose); err != nil {
+	ignored := defaultIgnored
+	if job.stripSource {
+		ignored = append(append([]string{}, defaultIgnored...), strippedSource...)
+	}
+	if err = newDataTarball(source, target, ignored, job.zeroTimes, job.verbose); err != nil {
 		return
 	}
 
@@ -376,15 +1144,11 @@ func writeDataLayer(job buildJob) (layer imageLayer, err error) {
 	}
 
 	// 移动到blobs目录
-	blob := filepath.Join(job.blobsDir(), layer.Descriptor.Digest.Hex)
-	if job.verbose {
-		fmt.Fprintf(os.Stderr, "mv %v %v\n", rel(job.buildDir(), target), rel(job.buildDir(), blob))
-	}
-	err = os.Rename(target, blob)
+	err = moveLayer(job, target, filepath.Join(job.blobsDir(), layer.Descriptor.Digest.Hex))
 	return
 }
 
-func newDataTarball(root, target string, ignored []string, verbose bool) error {
+func newDataTarball(root, target string, ignored []string, zeroTimes, verbose bool) error {
 	targetFile, err := os.Create(target)
 	if err != nil {
 		return err
@@ -397,14 +1161,20 @@ func newDataTarball(root, target string, ignored []string, verbose bool) error {
 	tw := tar.NewWriter(gw)
 	defer tw.Close()
 
+	// The archive path of the first occurrence of each hard-linked file
+	links := map[fileKey]string{}
+
 	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip files explicitly ignored
+		// Skip files explicitly ignored, matching names as glob patterns
 		for _, v := range ignored {
-			if info.Name() == v {
+			if path == root {
+				break
+			}
+			if ok, _ := filepath.Match(v, info.Name()); ok {
 				if info.IsDir() {
 					return filepath.SkipDir
 				}
@@ -431,6 +1201,29 @@ func newDataTarball(root, target string, ignored []string, verbose bool) error {
 		header.Name = slashpath.Join("/func", filepath.ToSlash(relPath))
 		header.Uid = DefaultUid
 		header.Gid = DefaultGid
+		zeroHeaderTimes(header, zeroTimes)
+		longNames(header)
+
+		// Directories, including empty directories, are written explicitly
+		// such that they exist in the container.  Being owned by the
+		// function's user, it is ensured that user can also create files
+		// within them at runtime (for example a cache directory).
+		if info.IsDir() {
+			header.Mode |= 0700
+		}
+
+		// Subsequent hard links to an already written file are written as
+		// links to its first occurrence rather than as a copy.
+		// Where this can not be determined (Windows), the file is copied.
+		key, isHardlink := hardlinkKey(info)
+		if first, ok := links[key]; isHardlink && ok {
+			header.Typeflag = tar.TypeLink
+			header.Linkname = first
+			header.Size = 0
+		} else if isHardlink {
+			links[key] = header.Name
+		}
+		largeFiles(header)
 
 		if err := tw.WriteHeader(header); err != nil {
 			return err
@@ -438,7 +1231,7 @@ func newDataTarball(root, target string, ignored []string, verbose bool) error {
 		if verbose {
 			fmt.Fprintf(os.Stderr, "→ %v \n", header.Name)
 		}
-		if !info.Mode().IsRegular() { //nothing more to do for non-regular
+		if header.Typeflag != tar.TypeReg { //nothing more to do for non-regular
 			return nil
 		}
 
@@ -465,36 +1258,112 @@ func validatedLinkTarget(root, path string) (tgt string, err error) {
 
 	// Absolute links will not be correct when copied into the runtime
 	// container, because they are placed into path into '/func',
-	if filepath.IsAbs(tgt) {
+	if isAbsLink(tgt) {
 		return tgt, errors.New("project may not contain absolute links")
 	}
 
-	// Calculate the actual target of the link
-	// (relative to the parent of the symlink)
-	lnkTgt := filepath.Join(filepath.Dir(path), tgt)
-
-	// Calculate the relative path from the function's root to
-	// this actual target location
-	relLnkTgt, err := filepath.Rel(root, lnkTgt)
-	if err != nil {
+	// Work with absolute paths such that walking up from a relative root
+	// (for example ".") is detected.
+	if root, err = filepath.Abs(root); err != nil {
 		return
 	}
-
-	// Fail if this path is outside the function's root.
-	if strings.HasPrefix(relLnkTgt, ".."+string(filepath.Separator)) || relLnkTgt == ".." {
-		return tgt, errors.New("links must stay within project root")
+	if path, err = filepath.Abs(path); err != nil {
+		return
 	}
+
+	// Resolve the actual target of the link (relative to the parent of the
+	// symlink), failing if at any point it leaves the function's root.
+	_, err = resolveLinkTarget(root, filepath.Dir(path), tgt, 0)
 	return
 }
 
+// maxLinkDepth is the number of nested links followed when resolving a link
+// target before giving up (a link cycle).
+const maxLinkDepth = 40
+
+// resolveLinkTarget walks the link target tgt, relative to the directory dir,
+// one element at a time, returning the resolved path.
+//
+// Targets can not simply be cleaned lexically: if an element is itself a link
+// to a directory, a subsequent ".." is relative to that link's target and not
+// the link's parent.  For example a target of "b/linkToRoot/.." where
+// "b/linkToRoot" is a link to ".." resolves to the root's parent.  Therefore
+// any links encountered along the way are followed, and an error is returned
+// if at any point the path leaves root.  Elements which do not exist are
+// resolved lexically.
+func resolveLinkTarget(root, dir, tgt string, depth int) (string, error) {
+	if depth > maxLinkDepth {
+		// A link cycle can not be followed in the container either, and every
+		// element up until this point was within the root.
+		return dir, nil
+	}
+	cur := dir
+	elems := strings.FieldsFunc(tgt, func(r rune) bool { return r < 256 && os.IsPathSeparator(uint8(r)) })
+	for _, elem := range elems {
+		switch elem {
+		case ".":
+			continue
+		case "..":
+			cur = filepath.Dir(cur)
+		default:
+			cur = filepath.Join(cur, elem)
+			info, err := os.Lstat(cur)
+			if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+				break // not (yet) extant or not a link: lexical
+			}
+			lnk, err := os.Readlink(cur)
+			if err != nil {
+				return cur, fmt.Errorf("cannot read link: %w", err)
+			}
+			if isAbsLink(lnk) {
+				return cur, errors.New("project may not contain absolute links")
+			}
+			if cur, err = resolveLinkTarget(root, filepath.Dir(cur), lnk, depth+1); err != nil {
+				return cur, err
+			}
+		}
+		if !isWithin(root, cur) {
+			return cur, errors.New("links must stay within project root")
+		}
+	}
+	return cur, nil
+}
+
+// isAbsLink returns true if the link target is absolute either on the host
+// or, when placed into the container, in the runtime (rooted paths such as
+// "/etc" on Windows are not considered absolute by filepath.IsAbs).
+func isAbsLink(tgt string) bool {
+	return filepath.IsAbs(tgt) || strings.HasPrefix(tgt, "/") ||
+		(len(tgt) > 0 && os.IsPathSeparator(tgt[0]))
+}
+
+// isWithin returns true if path is root or a path beneath root.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // writeCertsLayer 创建证书层
-func writeCertsLayer(job buildJob) (layer imageLayer, err error) {
+func writeCertsLayer(job buildJob) (layer ImageLayer, err error) {
 	// 创建证书压缩包
 	source := filepath.Join(job.buildDir(), "ca-certificates.crt")
 	target := filepath.Join(job.buildDir(), "certslayer.tar.gz")
 
+	// 脚手架未提供证书时使用构建主机的系统证书
+	if err = ensureCerts(job, source); err != nil {
+		return
+	}
+
+	// 合并自定义CA证书(可选,如企业内部根证书)
+	if err = writeCABundle(job, source); err != nil {
+		return
+	}
+
 	// 创建根目录
-	if err = newCertsTarball(source, target, job.verbose); err != nil {
+	if err = newCertsTarball(source, target, job.zeroTimes, job.verbose); err != nil {
 		return
 	}
 
@@ -509,15 +1378,11 @@ func writeCertsLayer(job buildJob) (layer imageLayer, err error) {
 	}
 
 	// 移动到blobs目录
-	blob := filepath.Join(job.blobsDir(), layer.Descriptor.Digest.Hex)
-	if job.verbose {
-		fmt.Fprintf(os.Stderr, "mv %v %v\n", rel(job.buildDir(), target), rel(job.buildDir(), blob))
-	}
-	err = os.Rename(target, blob)
+	err = moveLayer(job, target, filepath.Join(job.blobsDir(), layer.Descriptor.Digest.Hex))
 	return
 }
 
-func newCertsTarball(source, target string, verbose bool) error {
+func newCertsTarball(source, target string, zeroTimes, verbose bool) error {
 	targetFile, err := os.Create(target)
 	if err != nil {
 		return err
@@ -551,6 +1416,7 @@ func newCertsTarball(source, target string, verbose bool) error {
 		header.Name = path
 		header.Uid = DefaultUid
 		header.Gid = DefaultGid
+		zeroHeaderTimes(header, zeroTimes)
 
 		if err := tw.WriteHeader(header); err != nil {
 			return err
@@ -574,8 +1440,8 @@ func newCertsTarball(source, target string, verbose bool) error {
 
 // pullBase 拉取运行基础镜像(最好设置)
 func pullBase(job buildJob, p v1.Platform) (image v1.Image, err error) {
-	baseImage := job.function.Build.BaseImage
-	if job.languageBuilder.Base(baseImage) == "" {
+	baseImage := job.languageBuilder.Base(job.function.Build.BaseImage)
+	if baseImage == "" {
 		return // 从头开始构建
 	}
 
@@ -583,19 +1449,19 @@ func pullBase(job buildJob, p v1.Platform) (image v1.Image, err error) {
 	// 1) 解析镜像引用
 	ref, err := name.ParseReference(baseImage)
 	if err != nil {
-		return
+		return nil, ErrBasePull{baseImage, err}
 	}
 
-	// 2) 读取本地镜像
-	image, err = daemon.Image(ref)
-	if err != nil {
-		return
+	// 2) 读取本地镜像, 本地不存在时从镜像仓库拉取对应平台的镜像
+	// (每次构建仅解析一次, 见 resolveBase)
+	if image, err = resolveBase(job, ref, basePlatform(p)); err != nil {
+		return nil, ErrBasePull{baseImage, err}
 	}
 
 	// 3) 环境基础镜像层
 	layers, err := image.Layers()
 	if err != nil {
-		return
+		return nil, ErrBasePull{baseImage, err}
 	}
 	for _, layer := range layers {
 		if err = writeBaseLayer(job, layer); err != nil {
@@ -605,6 +1471,16 @@ func pullBase(job buildJob, p v1.Platform) (image v1.Image, err error) {
 	return
 }
 
+// basePlatform returns the platform of the base image of an image of the
+// platform p.  That of an amd64 microarchitecture level (GOAMD64), such as
+// linux/amd64/v3, is amd64, as base images are not published by level.
+func basePlatform(p v1.Platform) v1.Platform {
+	if p.Architecture == "amd64" {
+		p.Variant = ""
+	}
+	return p
+}
+
 func writeBaseLayer(job buildJob, layer v1.Layer) (err error) {
 	if err = ensureCached(job, layer); err != nil {
 		return
@@ -618,19 +1494,25 @@ func writeBaseLayer(job buildJob, layer v1.Layer) (err error) {
 	sourcePath := filepath.Join(job.cacheDir(), digest.Hex)
 	destPath := filepath.Join(job.blobsDir(), digest.Hex)
 
+	// Platforms are built concurrently and may share base layers: lock the
+	// entry (as does ensureCached) such that it is added by only one.
+	unlock, err := lockCached(job, digest.Hex)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
 	// Check if already added
 	if _, err := os.Stat(destPath); !os.IsNotExist(err) {
 		return nil // layer already in blobs.
 	}
 
-	// Add it to the image via hard link
-	if err := os.Link(sourcePath, destPath); err != nil {
+	// Add it to the image via hard link (or copy)
+	if err := linkOrCopy(sourcePath, destPath); err != nil && !os.IsExist(err) {
 		return fmt.Errorf("creating hard link for layer %s: %w", digest, err)
 	}
 
 	return
-
-	// TODO: fallback to copying eg if windows without perms?
 }
 
 func ensureCached(job buildJob, layer v1.Layer) (err error) {
@@ -640,25 +1522,54 @@ func ensureCached(job buildJob, layer v1.Layer) (err error) {
 	}
 
 	cachePath := filepath.Join(job.cacheDir(), digest.Hex)
-	if _, err = os.Stat(cachePath); !os.IsNotExist(err) {
+	fetch := refetch(job, digest.Hex) // even if cached (see PullAlways)
+	if _, err = os.Stat(cachePath); !os.IsNotExist(err) && !fetch {
 		if job.verbose {
 			fmt.Fprintf(os.Stderr, "Using cached base layer: %v\n", digest.Hex)
 		}
+		job.cacheHit()
+		// Record the use (see CacheInfo), as access times are unreliable
+		_ = os.Chtimes(cachePath, time.Now(), time.Now())
 		return
 	}
 
+	// The cache may be shared by concurrent builds: lock the entry and check
+	// again, as it may have been written while waiting.
+	unlock, err := lockCached(job, digest.Hex)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	if _, err = os.Stat(cachePath); !os.IsNotExist(err) && !fetch {
+		job.cacheHit()
+		return
+	}
+	job.cacheMiss()
+
 	reader, err := layer.Compressed()
 	if err != nil {
 		return
 	}
 	defer reader.Close()
 
-	file, err := os.Create(cachePath)
+	// Write to a temporary file which is renamed into place when complete
+	// such that a partially written entry is never used.
+	tmpPath := cachePath + ".tmp"
+	file, err := os.Create(tmpPath)
 	if err != nil {
 		return
 	}
-
 	if _, err = io.Copy(file, reader); err != nil {
+		file.Close()
+		_ = os.Remove(tmpPath)
+		return
+	}
+	if err = file.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return
+	}
+	if err = os.Rename(tmpPath, cachePath); err != nil {
+		_ = os.Remove(tmpPath)
 		return
 	}
 	if job.verbose {
@@ -667,10 +1578,10 @@ func ensureCached(job buildJob, layer v1.Layer) (err error) {
 	return
 }
 
-func newConfigFile(job buildJob, p v1.Platform, base v1.Image, imageLayers []imageLayer) (cfg v1.ConfigFile, err error) {
+func newConfigFile(job buildJob, p v1.Platform, base v1.Image, imageLayers []ImageLayer) (cfg v1.ConfigFile, err error) {
 	// 配置文件
 	cfg = v1.ConfigFile{
-		Created:      v1.Time{Time: job.start},
+		Created:      v1.Time{Time: job.created()},
 		Architecture: p.Architecture,
 		OS:           p.OS,
 		OSVersion:    p.OSVersion,
@@ -679,7 +1590,7 @@ func newConfigFile(job buildJob, p v1.Platform, base v1.Image, imageLayers []ima
 		Config: v1.Config{
 			Env:          newConfigEnvs(job),
 			Volumes:      newConfigVolumes(job),
-			ExposedPorts: map[string]struct{}{"8080/tcp": {}},
+			ExposedPorts: newConfigPorts(job),
 			WorkingDir:   "/func/",
 			StopSignal:   "SIGKILL",
 			User:         fmt.Sprintf("%v:%v", DefaultUid, DefaultGid),
@@ -690,7 +1601,7 @@ func newConfigFile(job buildJob, p v1.Platform, base v1.Image, imageLayers []ima
 		History: []v1.History{
 			{
 				Author:     "func",
-				Created:    v1.Time{Time: job.start},
+				Created:    v1.Time{Time: job.created()},
 				Comment:    "func host builder",
 				EmptyLayer: true,
 			},
@@ -732,6 +1643,7 @@ func newConfigFile(job buildJob, p v1.Platform, base v1.Image, imageLayers []ima
 		// Prepend diffIDs
 		cfg.RootFS.DiffIDs = append(baseCfg.RootFS.DiffIDs, cfg.RootFS.DiffIDs...)
 	}
+	cfg.History = zeroHistoryTimes(cfg.History, job.zeroTimes)
 
 	return cfg, nil
 }
@@ -740,28 +1652,54 @@ func newConfigFile(job buildJob, p v1.Platform, base v1.Image, imageLayers []ima
 // the container.  This consists of func-provided build metadata envs as well
 // as any environment variables provided on the function itself.
 func newConfigEnvs(job buildJob) []string {
-	// TODO:  long-term, the correct architecture is to not read env vars
-	// from deep within a package, but rather to expose the setting as a
-	// variable and leave interacting with the environment to main.
-	// This is a shortcut used by many packages, however, so it will work for
-	// now.
-	gitbin := os.Getenv("FUNC_GIT") // Use if provided
-	if gitbin == "" {
-		gitbin = "git" // default to looking on PATH
-	}
-
 	envs := []string{}
 
 	// FUNC_CREATED
 	// Formats container timestamp as RFC3339; a stricter version of the ISO 8601
 	// format used by the container image manifest's 'Created' attribute.
-	envs = append(envs, "FUNC_CREATED="+job.start.Format(time.RFC3339))
+	envs = append(envs, "FUNC_CREATED="+job.created().Format(time.RFC3339))
 
 	// FUNC_VERSION
 	// TODO 需要改进
 	// If source controlled, and if being built from a system with git, the
 	// environment FUNC_VERSION will be populated.  Otherwise it will exist
 	// (to indicate this logic was executed) but have an empty value.
+	envs = append(envs, "FUNC_VERSION="+funcVersion(job))
+
+	// TODO: OTHERS?
+	// Other metadata that may be useful. Perhaps:
+	//   - func client version (func cli) used when building this file?
+	//   - user/environment which triggered this build?
+	//   - A reflection of the function itself?  Image, registry, etc. etc?
+
+	// FUNC_PORT_<NAME>
+	// Additional ports on which the function serves, such as gRPC.
+	for _, p := range job.function.Run.Ports {
+		envs = append(envs, p.Env())
+	}
+
+	// METRICS_ADDRESS
+	// The address on which to serve Prometheus metrics, if enabled.
+	if job.function.Run.Metrics {
+		envs = append(envs, fmt.Sprintf("METRICS_ADDRESS=[::]:%v", fn.MetricsPort))
+	}
+
+	// ENVs defined on the Function
+	return append(envs, job.function.Run.Envs.Slice()...)
+}
+
+// funcVersion returns the version of the function (git describe --tags) if
+// source controlled and built from a system with git, otherwise empty.
+func funcVersion(job buildJob) string {
+	// TODO:  long-term, the correct architecture is to not read env vars
+	// from deep within a package, but rather to expose the setting as a
+	// variable and leave interacting with the environment to main.
+	// This is a shortcut used by many packages, however, so it will work for
+	// now.
+	gitbin := os.Getenv("FUNC_GIT") // Use if provided
+	if gitbin == "" {
+		gitbin = "git" // default to looking on PATH
+	}
 	if job.verbose {
 		fmt.Fprintf(os.Stderr, "cd %v && export FUNC_VERSION=$(%v describe --tags)\n", job.function.Root, gitbin)
 	}
@@ -772,19 +1710,23 @@ func newConfigEnvs(job buildJob) []string {
 		if job.verbose {
 			fmt.Fprintf(os.Stderr, "WARN: unable to determine function version. %v\n", err)
 		}
-		envs = append(envs, "FUNC_VERSION=")
-	} else {
-		envs = append(envs, "FUNC_VERSION="+strings.TrimSpace(string(output)))
+		return ""
 	}
+	return strings.TrimSpace(string(output))
+}
 
-	// TODO: OTHERS?
-	// Other metadata that may be useful. Perhaps:
-	//   - func client version (func cli) used when building this file?
-	//   - user/environment which triggered this build?
-	//   - A reflection of the function itself?  Image, registry, etc. etc?
-
-	// ENVs defined on the Function
-	return append(envs, job.function.Run.Envs.Slice()...)
+// newConfigPorts returns the ports exposed by the container: the primary
+// HTTP port, the metrics port if enabled and any additional ports defined on
+// the function.
+func newConfigPorts(job buildJob) map[string]struct{} {
+	ports := map[string]struct{}{"8080/tcp": {}}
+	if job.function.Run.Metrics {
+		ports[fmt.Sprintf("%v/tcp", fn.MetricsPort)] = struct{}{}
+	}
+	for _, p := range job.function.Run.Ports {
+		ports[fmt.Sprintf("%v/%v", p.Port, p.Proto())] = struct{}{}
+	}
+	return ports
 }
 
 func newConfigVolumes(job buildJob) map[string]struct{} {
@@ -798,15 +1740,17 @@ func newConfigVolumes(job buildJob) map[string]struct{} {
 	return volumes
 }
 
-func writeConfig(job buildJob, configFile v1.ConfigFile) (configDesc v1.Descriptor, err error) {
-	configDesc, err = writeAsJSONBlob(job, "config.json", configFile)
-	configDesc.MediaType = types.OCIConfigJSON
+func writeConfig(job buildJob, p v1.Platform, configFile v1.ConfigFile) (configDesc v1.Descriptor, err error) {
+	configDesc, err = writeAsJSONBlob(job,
+		fmt.Sprintf("config.%v.json", strings.ReplaceAll(platformName(p), "/", ".")), configFile)
+	configDesc.MediaType = job.mediaTypes().config()
 	return
 }
 
 // writeManifest creates an image manifest for the given platform.
 // The image consists of the shared data layer which is provided
-func writeManifest(job buildJob, p v1.Platform, base v1.Image, configDesc v1.Descriptor, layers []imageLayer) (v1.Descriptor, error) {
+// The manifest bears the given annotations (see manifestAnnotations).
+func writeManifest(job buildJob, p v1.Platform, base v1.Image, configDesc v1.Descriptor, layers []ImageLayer, annotations map[string]string) (v1.Descriptor, error) {
 
 	// the layers for the final manifest.
 	layerDescs := []v1.Descriptor{}
@@ -817,54 +1761,134 @@ func writeManifest(job buildJob, p v1.Platform, base v1.Image, configDesc v1.Des
 		if err != nil {
 			return v1.Descriptor{}, err
 		}
-		layerDescs = baseManifest.Layers
+		layerDescs = foreignLayers(job, baseManifest.Layers)
 	}
 
 	// Append our layers
 	for _, layer := range layers {
 		layerDescs = append(layerDescs, layer.Descriptor)
 	}
+	if err := checkLayers(job, p, len(layerDescs)); err != nil {
+		return v1.Descriptor{}, err
+	}
 
 	// The final manifest for this platform's image
 	manifest := v1.Manifest{
 		SchemaVersion: 2,
-		MediaType:     types.OCIManifestSchema1,
+		MediaType:     job.mediaTypes().manifest(),
 		Config:        configDesc,
-		Layers:        layerDescs,
+		Layers:        job.mediaTypes().layers(layerDescs),
+		Annotations:   annotations,
 	}
 
 	// Write it to blobs
 	manifestDesc, err := writeAsJSONBlob(
 		job,
-		fmt.Sprintf("manifest.%v.%v.json", p.OS, p.Architecture),
+		fmt.Sprintf("manifest.%v.json", strings.ReplaceAll(platformName(p), "/", ".")),
 		manifest)
-	manifestDesc.MediaType = types.OCIManifestSchema1
+	if err != nil {
+		return manifestDesc, err
+	}
+	manifestDesc.MediaType = job.mediaTypes().manifest()
 	manifestDesc.Platform = &p
 
+	// 签名平台清单(可选),使解析平台清单而非索引的验证者亦可验证
+	if job.signManifests {
+		if err = signDigest(job, manifestDesc.Digest); err != nil {
+			return manifestDesc, err
+		}
+	}
+
 	// returning the blob's descriptor for inclusion in the index
-	return manifestDesc, err
+	return manifestDesc, nil
 }
 
 func writeIndex(job buildJob, manifests []v1.Descriptor) (err error) {
-	index := v1.IndexManifest{
-		SchemaVersion: 2,
-		MediaType:     types.OCIImageIndex,
-		Manifests:     manifests,
+	index := artifactIndex{
+		IndexManifest: v1.IndexManifest{
+			SchemaVersion: 2,
+			MediaType:     job.mediaTypes().index(),
+			Manifests:     manifests,
+			Annotations:   job.annotations(),
+			Subject:       job.subject,
+		},
+		ArtifactType: job.artifactType,
 	}
 
-	filePath := filepath.Join(job.ociDir(), "index.json")
-	file, err := os.Create(filePath)
-	if err != nil {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(index); err != nil {
+		return
+	}
+	if err = os.WriteFile(filepath.Join(job.ociDir(), "index.json"), b.Bytes(), 0644); err != nil {
 		return
 	}
-	defer file.Close()
 
-	enc := json.NewEncoder(file)
-	enc.SetIndent("", "  ")
-	err = enc.Encode(index)
+	// 签名索引(可选),其摘要即推送的镜像索引的摘要
+	if job.signingKey != "" {
+		sum := sha256.Sum256(b.Bytes())
+		err = signDigest(job, v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sum[:])})
+	}
 	return
 }
 
+// verifyIndex reads back the index written by writeIndex and ensures it
+// holds exactly one manifest per platform of the job, each with a platform
+// descriptor matching it.
+func verifyIndex(job buildJob) error {
+	file, err := os.Open(filepath.Join(job.ociDir(), "index.json"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var index v1.IndexManifest
+	if err = json.NewDecoder(file).Decode(&index); err != nil {
+		return fmt.Errorf("cannot read image index. %w", err)
+	}
+
+	counts := map[string]int{}
+	for _, m := range index.Manifests {
+		if m.Platform == nil {
+			counts["unknown"]++
+			continue
+		}
+		counts[indexPlatformKey(*m.Platform)]++
+	}
+
+	var e ErrIncompleteIndex
+	requested := map[string]bool{}
+	for _, p := range job.platforms {
+		key := indexPlatformKey(p)
+		requested[key] = true
+		switch n := counts[key]; {
+		case n == 0:
+			e.Missing = append(e.Missing, key)
+		case n > 1:
+			e.Duplicated = append(e.Duplicated, key)
+		}
+	}
+	for key := range counts {
+		if !requested[key] {
+			e.Unexpected = append(e.Unexpected, key)
+		}
+	}
+	if len(e.Missing) > 0 || len(e.Duplicated) > 0 || len(e.Unexpected) > 0 {
+		sort.Strings(e.Unexpected)
+		return e
+	}
+	return nil
+}
+
+// indexPlatformKey identifies a platform of the index: its name including
+// the OS version, if any.
+func indexPlatformKey(p v1.Platform) string {
+	if p.OSVersion != "" {
+		return platformName(p) + ":" + p.OSVersion
+	}
+	return platformName(p)
+}
+
 // -----------------------
 // Build Job
 // -----------------------
@@ -879,19 +1903,47 @@ type buildJob struct {
 	hash            string          // a fingerprint of the fs at start
 	function        fn.Function     // Function being built
 	platforms       []v1.Platform   // Platforms to build
-	languageBuilder languageBuilder // build implementation
+	languageBuilder LanguageBuilder // build implementation
 	verbose         bool
+	bases           *baseImages // base images resolved by this build
+	timer           *phaseTimer // timings of the build's phases
+	cacheStats      *cacheStats // base layers found in and added to the cache
+	secretEnvs      []string    // environment of the build's secrets (see WithSecrets)
+
+	options // options of the builder
+}
+
+// checkRoot returns ErrNoFunction if the root of the function does not
+// exist, is not a directory or does not contain an initialized function.
+func checkRoot(f fn.Function) error {
+	if f.Root == "" {
+		return ErrNoFunction{Root: f.Root, Err: errors.New("the function has no root")}
+	}
+	info, err := os.Stat(f.Root)
+	if err != nil {
+		return ErrNoFunction{Root: f.Root, Err: err}
+	}
+	if !info.IsDir() {
+		return ErrNoFunction{Root: f.Root, Err: errors.New("the root is not a directory")}
+	}
+	if _, err = os.Stat(filepath.Join(f.Root, fn.FunctionFile)); err != nil || !f.Initialized() {
+		return ErrNoFunction{Root: f.Root, Err: fn.NewErrNotInitialized(f.Root)}
+	}
+	return nil
 }
 
 // newBuildJob creates a struct which contains information about the current
 // build job and convenience accessors to eg pertinent directories.
 func newBuildJob(ctx context.Context, f fn.Function, pp []fn.Platform, verbose bool) (buildJob, error) {
 	job := buildJob{
-		ctx:       ctx,
-		start:     time.Now(),
-		function:  f,
-		platforms: toPlatforms(pp),
-		verbose:   verbose,
+		ctx:        ctx,
+		start:      time.Now(),
+		function:   f,
+		platforms:  toPlatforms(pp),
+		verbose:    verbose,
+		bases:      newBaseImages(),
+		timer:      &phaseTimer{},
+		cacheStats: &cacheStats{},
 	}
 
 	// Calculate a hash of the Function filesystem at time of start.
@@ -902,37 +1954,70 @@ func newBuildJob(ctx context.Context, f fn.Function, pp []fn.Platform, verbose b
 
 	// 根据语言选择构建器
 	var ok bool
-	if job.languageBuilder, ok = builders[f.Runtime]; !ok {
-		return job, fmt.Errorf("%v functions are not yet supported by the host builder", f.Runtime)
+	if job.languageBuilder, ok = builderFor(f.Runtime); !ok {
+		return job, ErrUnsupportedRuntime{f.Runtime}
 	}
 	return job, nil
 }
 
 // some convenience accessors
 
+// concurrency is the number of platforms to build at once.
+func (j buildJob) concurrency() int {
+	if j.options.concurrency > 0 {
+		return j.options.concurrency
+	}
+	return max(1, min(len(j.platforms), runtime.GOMAXPROCS(0)))
+}
+
 func (j buildJob) lastLink() string {
 	return filepath.Join(j.function.Root, fn.RunDataDir, "builds", "last")
 }
 func (j buildJob) pidsDir() string {
-	return filepath.Join(j.function.Root, fn.RunDataDir, "builds", "by-pid")
+	return filepath.Join(j.dataDir(), "builds", "by-pid")
 }
 func (j buildJob) pidLink() string {
-	return filepath.Join(j.function.Root, fn.RunDataDir, "builds", "by-pid", strconv.Itoa(os.Getpid()))
+	return filepath.Join(j.dataDir(), "builds", "by-pid", strconv.Itoa(os.Getpid()))
 }
 func (j buildJob) buildsDir() string {
-	return filepath.Join(j.function.Root, fn.RunDataDir, "builds", "by-hash")
+	return filepath.Join(j.dataDir(), "builds", "by-hash")
 }
 func (j buildJob) buildDir() string {
-	return filepath.Join(j.function.Root, fn.RunDataDir, "builds", "by-hash", j.hash)
+	return filepath.Join(j.dataDir(), "builds", "by-hash", j.hash)
 }
 func (j buildJob) ociDir() string {
-	return filepath.Join(j.function.Root, fn.RunDataDir, "builds", "by-hash", j.hash, "oci")
+	return filepath.Join(j.dataDir(), "builds", "by-hash", j.hash, "oci")
 }
 func (j buildJob) blobsDir() string {
-	return filepath.Join(j.function.Root, fn.RunDataDir, "builds", "by-hash", j.hash, "oci", "blobs", "sha256")
+	return filepath.Join(j.dataDir(), "builds", "by-hash", j.hash, "oci", "blobs", "sha256")
+}
+func (j buildJob) blobsByNameDir() string {
+	return filepath.Join(j.dataDir(), "builds", "by-hash", j.hash, "blobs-by-name")
 }
 func (j buildJob) cacheDir() string {
-	return filepath.Join(j.function.Root, fn.RunDataDir, "blob-cache")
+	if j.sharedCache != "" {
+		return j.sharedCache
+	}
+	return filepath.Join(j.dataDir(), "blob-cache")
+}
+
+// dataDir is the directory of the builds and blob cache: the function's
+// .func directory unless relocated to a work directory (see WithWorkDir), in
+// which case a subdirectory per function is used.
+func (j buildJob) dataDir() string {
+	if j.workDir == "" {
+		return filepath.Join(j.function.Root, fn.RunDataDir)
+	}
+	root, _ := filepath.Abs(j.function.Root)
+	sum := sha256.Sum256([]byte(root))
+	dir, _ := filepath.Abs(j.workDir)
+	return filepath.Join(dir, hex.EncodeToString(sum[:8]))
+}
+
+// imageBuildDir is the path of the build directory within the image, which
+// is the same regardless of the build directory's location on disk.
+func (j buildJob) imageBuildDir() string {
+	return slashpath.Join("/func", fn.RunDataDir, "builds", "by-hash", j.hash)
 }
 
 func (j *buildJob) localImagePath() string {
@@ -970,6 +2055,68 @@ func rel(base, path string) string {
 	return path
 }
 
+// writeLayer moves the gzipped layer tarball at path into the job's blobs
+// directory, named by its digest, and returns the resultant layer.
+func writeLayer(job buildJob, path string) (ImageLayer, error) {
+	layer, err := tarball.LayerFromFile(path)
+	if err != nil {
+		return ImageLayer{}, err
+	}
+	desc, err := newDescriptor(layer)
+	if err != nil {
+		return ImageLayer{}, err
+	}
+	if err = moveLayer(job, path, filepath.Join(job.blobsDir(), desc.Digest.Hex)); err != nil {
+		return ImageLayer{}, fmt.Errorf("cannot rename blob: %w", err)
+	}
+	return ImageLayer{Descriptor: desc, Layer: layer}, nil
+}
+
+// moveLayer moves the layer tarball at path to the given blob, or links (or
+// copies) it when the tarballs are kept (see WithKeepTars), such that it
+// remains in the build directory under its descriptive name.
+func moveLayer(job buildJob, path, blob string) (err error) {
+	if job.keepTars {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "ln %v %v\n", rel(job.buildDir(), path), rel(job.buildDir(), blob))
+		}
+		err = linkOrCopy(path, blob)
+	} else {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "mv %v %v\n", rel(job.buildDir(), path), rel(job.buildDir(), blob))
+		}
+		err = os.Rename(path, blob)
+	}
+	if err == nil {
+		linkBlob(job, path, blob)
+	}
+	return
+}
+
+// runCmd runs a child process of the build such as the compiler.  Its
+// combined output is captured and returned such that it can be reported
+// should the process fail, and is additionally streamed when verbose.  It is
+// run with the environment of the build's proxy and secrets, if any (see
+// WithProxy and WithSecrets), which override those of its own.
+func runCmd(job buildJob, cmd *exec.Cmd) (output string, err error) {
+	var buf bytes.Buffer
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	// 代理及构建密钥,仅存在于命令的环境中
+	if envs := append(job.proxyEnvs(), job.secretEnvs...); len(envs) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(append([]string{}, cmd.Env...), envs...)
+	}
+	if job.verbose {
+		cmd.Stdout = io.MultiWriter(os.Stdout, &buf)
+		cmd.Stderr = io.MultiWriter(os.Stderr, &buf)
+	}
+	err = cmd.Run()
+	return buf.String(), err
+}
+
 // processExists returns true if the process with the given PID
 // exists.
 func processExists(pid string) bool {
@@ -1013,9 +2160,15 @@ func updateLastLink(job buildJob) error {
 		fmt.Fprintf(os.Stderr, "ln -s %v %v\n", job.buildDir(), job.lastLink())
 	}
 	_ = os.RemoveAll(job.lastLink())
+	if err := os.MkdirAll(filepath.Dir(job.lastLink()), os.ModePerm); err != nil {
+		return err
+	}
 	rp, err := filepath.Rel(filepath.Dir(job.lastLink()), job.buildDir())
 	if err != nil {
-		return err
+		// eg. a work directory on another volume
+		if rp, err = filepath.Abs(job.buildDir()); err != nil {
+			return err
+		}
 	}
 	return os.Symlink(rp, job.lastLink())
 }
@@ -1030,10 +2183,15 @@ func updateLastLink(job buildJob) error {
 // {OS: "linux", Architecture: "arm", Variant: "v7"},
 // {OS: "darwin", Architecture: "amd64"},
 // {OS: "darwin", Architecture: "arm64"},
+// {OS: "wasip1", Architecture: "wasm"} (as {OS: "wasi", Architecture: "wasm"}),
+// the OS of wasm targets as named by Go being wasi as named by images.
 func toPlatforms(pp []fn.Platform) []v1.Platform {
 	platforms := make([]v1.Platform, len(pp))
 	for i, p := range pp {
 		platforms[i] = v1.Platform{OS: p.OS, Architecture: p.Architecture, Variant: p.Variant}
+		if p.OS == "wasip1" && p.Architecture == "wasm" {
+			platforms[i].OS = wasmPlatform.OS
+		}
 	}
 	return platforms
 }
@@ -1093,6 +2251,7 @@ func writeAsJSONBlob(job buildJob, tempName string, data any) (desc v1.Descripto
 	if err = os.Rename(filePath, blobPath); err != nil {
 		return
 	}
+	linkBlob(job, filePath, blobPath)
 
 	return v1.Descriptor{
 		Digest: hash,
@@ -1147,6 +2306,8 @@ func buildImage(f fn.Function, job buildJob) error {
 	if err := tarball.WriteToFile(job.localImagePath(), ref, image); err != nil {
 		return err
 	}
-	fmt.Printf("Save built image: '%s' at local path: '%s'\n", f.Build.Image, job.localImagePath())
+	if !job.quiet {
+		fmt.Printf("Save built image: '%s' at local path: '%s'\n", f.Build.Image, job.localImagePath())
+	}
 	return nil
 }
@@ -9,18 +9,28 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci/mock"
 	. "knative.dev/func/pkg/testing"
 )
 
@@ -31,6 +41,7 @@ var TestPlatforms = []fn.Platform{{OS: "linux", Architecture: runtime.GOARCH}}
 func TestBuilder_BuildGo(t *testing.T) {
 	root, done := Mktemp(t)
 	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
 
 	client := fn.New(fn.WithVerbose(true))
 
@@ -61,6 +72,7 @@ func TestBuilder_BuildPython(t *testing.T) {
 	}
 	root, done := Mktemp(t)
 	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
 
 	client := fn.New(fn.WithVerbose(true))
 
@@ -85,6 +97,7 @@ func TestBuilder_BuildPython(t *testing.T) {
 func TestBuilder_Files(t *testing.T) {
 	root, done := Mktemp(t)
 	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
 
 	// Create a function with the default template
 	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
@@ -146,6 +159,7 @@ func TestBuilder_Files(t *testing.T) {
 func TestBuilder_Concurrency(t *testing.T) {
 	root, done := Mktemp(t)
 	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
 
 	client := fn.New()
 
@@ -181,12 +195,12 @@ func TestBuilder_Concurrency(t *testing.T) {
 	// Build A
 	builder1 := NewBuilder("builder1", true)
 	testImplA := NewTestLanguageBuilder()
-	testImplA.WritePlatformFn = func(job buildJob, p v1.Platform) ([]imageLayer, error) {
+	testImplA.WritePlatformFn = func(job BuildContext, p v1.Platform) ([]ImageLayer, error) {
 		if isFirstBuild(job, p) {
 			pausedCh <- true // Notify of being paused
 			<-continueCh     // Block until released
 		}
-		return []imageLayer{}, nil
+		return []ImageLayer{}, nil
 	}
 	builder1.impl = testImplA
 
@@ -204,8 +218,8 @@ func TestBuilder_Concurrency(t *testing.T) {
 	// Build B
 	builder2 := NewBuilder("builder2", true)
 	testImplB := NewTestLanguageBuilder()
-	testImplB.WritePlatformFn = func(job buildJob, p v1.Platform) ([]imageLayer, error) {
-		return []imageLayer{}, fmt.Errorf("the buildFn should not have been invoked")
+	testImplB.WritePlatformFn = func(job BuildContext, p v1.Platform) ([]ImageLayer, error) {
+		return []ImageLayer{}, fmt.Errorf("the buildFn should not have been invoked")
 	}
 	wg.Add(1)
 	go func() {
@@ -221,8 +235,8 @@ func TestBuilder_Concurrency(t *testing.T) {
 	wg.Wait()
 }
 
-func isFirstBuild(cfg buildJob, current v1.Platform) bool {
-	first := cfg.platforms[0]
+func isFirstBuild(cfg BuildContext, current v1.Platform) bool {
+	first := cfg.Platforms()[0]
 	return current.OS == first.OS &&
 		current.Architecture == first.Architecture &&
 		current.Variant == first.Variant
@@ -371,6 +385,7 @@ type fileInfo struct {
 func TestBuilder_StaticEnvs(t *testing.T) {
 	root, done := Mktemp(t)
 	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
 
 	staticEnvs := []string{
 		"FUNC_CREATED",
@@ -460,50 +475,67 @@ func TestBuilder_StaticEnvs(t *testing.T) {
 // TestLanguageBuilder is the language-specific builder implementation used by the
 // OCI builder for each language, and can be overridden for testing
 type TestLanguageBuilder struct {
+	mu sync.Mutex // platforms are built concurrently
+
 	BaseInvoked bool
 	BaseFn      func(customImage string) string
 
 	WriteSharedInvoked bool
-	WriteSharedFn      func(buildJob) ([]imageLayer, error)
+	WriteSharedFn      func(BuildContext) ([]ImageLayer, error)
 
 	WritePlatformInvoked bool
-	WritePlatformFn      func(buildJob, v1.Platform) ([]imageLayer, error)
+	WritePlatformFn      func(BuildContext, v1.Platform) ([]ImageLayer, error)
 
 	ConfigureInvoked bool
-	ConfigureFn      func(buildJob, v1.Platform, v1.ConfigFile) (v1.ConfigFile, error)
+	ConfigureFn      func(BuildContext, v1.Platform, v1.ConfigFile) (v1.ConfigFile, error)
+
+	RequiredToolsFn func() []string
 }
 
 func NewTestLanguageBuilder() *TestLanguageBuilder {
 	return &TestLanguageBuilder{
 		BaseFn:          func(customImage string) string { return "" },
-		WriteSharedFn:   func(buildJob) ([]imageLayer, error) { return []imageLayer{}, nil },
-		WritePlatformFn: func(buildJob, v1.Platform) ([]imageLayer, error) { return []imageLayer{}, nil },
-		ConfigureFn: func(buildJob, v1.Platform, v1.ConfigFile) (v1.ConfigFile, error) {
+		WriteSharedFn:   func(BuildContext) ([]ImageLayer, error) { return []ImageLayer{}, nil },
+		WritePlatformFn: func(BuildContext, v1.Platform) ([]ImageLayer, error) { return []ImageLayer{}, nil },
+		ConfigureFn: func(BuildContext, v1.Platform, v1.ConfigFile) (v1.ConfigFile, error) {
 			return v1.ConfigFile{}, nil
 		},
+		RequiredToolsFn: func() []string { return nil },
 	}
 }
 
 func (l *TestLanguageBuilder) Base(customImage string) string {
+	l.mu.Lock()
 	l.BaseInvoked = true
+	l.mu.Unlock()
 	return l.BaseFn(customImage)
 }
 
-func (l *TestLanguageBuilder) WriteShared(job buildJob) ([]imageLayer, error) {
+func (l *TestLanguageBuilder) WriteShared(job BuildContext) ([]ImageLayer, error) {
+	l.mu.Lock()
 	l.WriteSharedInvoked = true
+	l.mu.Unlock()
 	return l.WriteSharedFn(job)
 }
 
-func (l *TestLanguageBuilder) WritePlatform(job buildJob, p v1.Platform) ([]imageLayer, error) {
+func (l *TestLanguageBuilder) WritePlatform(job BuildContext, p v1.Platform) ([]ImageLayer, error) {
+	l.mu.Lock()
 	l.WritePlatformInvoked = true
+	l.mu.Unlock()
 	return l.WritePlatformFn(job, p)
 }
 
-func (l *TestLanguageBuilder) Configure(job buildJob, p v1.Platform, c v1.ConfigFile) (v1.ConfigFile, error) {
+func (l *TestLanguageBuilder) Configure(job BuildContext, p v1.Platform, c v1.ConfigFile) (v1.ConfigFile, error) {
+	l.mu.Lock()
 	l.ConfigureInvoked = true
+	l.mu.Unlock()
 	return l.ConfigureFn(job, p, c)
 }
 
+func (l *TestLanguageBuilder) RequiredTools() []string {
+	return l.RequiredToolsFn()
+}
+
 // Test_validatedLinkTaarget ensures that the function disallows
 // links which are absolute or refer to targets outside the given root, in
 // addition to the basic job of returning the value of reading the link.
@@ -560,3 +592,1444 @@ func Test_validatedLinkTarget(t *testing.T) {
 	}
 
 }
+
+// TestBuilder_PullBase ensures that a multi-arch base image is resolved to
+// the image of the requested platform, and that its layers are cached and
+// added to the build's blobs.
+func TestBuilder_PullBase(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	registry := mock.NewRegistry()
+	defer registry.Close()
+
+	platforms := []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+	base, _, err := registry.SeedBase("base", "latest", 1, 2, platforms...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.BaseImage = base
+
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+
+	for _, p := range platforms {
+		image, err := pullBase(job, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := image.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Architecture != p.Architecture {
+			t.Fatalf("expected base for %v, got %v", p.Architecture, cfg.Architecture)
+		}
+		layers, err := image.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, layer := range layers {
+			digest, err := layer.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(job.cacheDir(), digest.Hex)); err != nil {
+				t.Fatalf("base layer %v not cached. %v", digest, err)
+			}
+			if _, err := os.Stat(filepath.Join(job.blobsDir(), digest.Hex)); err != nil {
+				t.Fatalf("base layer %v not in blobs. %v", digest, err)
+			}
+		}
+	}
+}
+
+// TestBuilder_PullBaseChanged ensures the digest of each platform's base
+// image resolved for a tag is recorded, such that a change of the image to
+// which the tag refers is reported by the next build which resolves it.
+func TestBuilder_PullBaseChanged(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	registry := mock.NewRegistry()
+	defer registry.Close()
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	base, _, err := registry.SeedBase("base", "latest", 1, 1, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.BaseImage = base
+	ref, err := name.ParseReference(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pull the base with a new job, returning the digest recorded for it.
+	pull := func() (recorded v1.Hash) {
+		job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = setup(job); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup(job)
+		defer os.Remove(job.pidLink())
+		image, err := pullBase(job, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		digest, err := image.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if previous, err := recordBase(job, ref, p, digest); err != nil || previous != "" {
+			t.Fatalf("expected digest %v to be recorded, got previous %q. %v", digest, previous, err)
+		}
+		return digest
+	}
+
+	first := pull()
+	if second := pull(); second != first {
+		t.Fatalf("expected an unchanged base, got %v and %v", first, second)
+	}
+
+	// The tag now refers to a different image, which is recorded when pulled
+	if _, _, err = registry.SeedBase("base", "latest", 2, 1, p); err != nil {
+		t.Fatal(err)
+	}
+	third := pull()
+	if third == first {
+		t.Fatal("expected the changed base to be resolved")
+	}
+
+	// A change is reported as the digest previously recorded
+	previous, err := recordBase(buildJob{function: f}, ref, p, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != third.String() {
+		t.Fatalf("expected the previously recorded digest %v, got %q", third, previous)
+	}
+}
+
+// TestBuilder_PullBaseResolvedOnce ensures the base image index is resolved
+// once per build rather than once per platform.
+func TestBuilder_PullBaseResolvedOnce(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	registry := mock.NewRegistry()
+	defer registry.Close()
+	var resolved int32
+	registry.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/base/manifests/latest" {
+			atomic.AddInt32(&resolved, 1)
+		}
+		registry.RegistryImpl.ServeHTTP(w, r)
+	}
+
+	platforms := []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "linux", Architecture: "arm", Variant: "v7"},
+	}
+	base, _, err := registry.SeedBase("base", "latest", 1, 1, platforms...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.BaseImage = base
+
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+
+	atomic.StoreInt32(&resolved, 0) // exclude seeding
+	for _, p := range platforms {
+		image, err := pullBase(job, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := image.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Architecture != p.Architecture || cfg.Variant != p.Variant {
+			t.Fatalf("expected base for %v, got %v/%v", p, cfg.Architecture, cfg.Variant)
+		}
+	}
+	if n := atomic.LoadInt32(&resolved); n != 1 {
+		t.Fatalf("expected the base index to be resolved once, got %v", n)
+	}
+}
+
+// TestBuilder_TypedErrors ensures that build failures are reported as
+// typed errors which callers can switch on.
+func TestBuilder_TypedErrors(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unsupported runtime
+	f.Runtime = "cobol"
+	_, err = newBuildJob(context.Background(), f, TestPlatforms, false)
+	var errRuntime ErrUnsupportedRuntime
+	if !errors.As(err, &errRuntime) || errRuntime.Runtime != "cobol" {
+		t.Fatalf("expected ErrUnsupportedRuntime, got %v", err)
+	}
+
+	// Invalid base image reference
+	f.Runtime = "go"
+	f.Build.BaseImage = "INVALID::ref"
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pullBase(job, v1.Platform{OS: "linux", Architecture: "amd64"})
+	var errBasePull ErrBasePull
+	if !errors.As(err, &errBasePull) || errBasePull.Ref != "INVALID::ref" {
+		t.Fatalf("expected ErrBasePull, got %v", err)
+	}
+}
+
+// TestBuilder_NoFunction ensures building a root which does not exist, or
+// which does not contain an initialized function, fails with ErrNoFunction.
+func TestBuilder_NoFunction(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	b := NewBuilder("", false, WithQuiet(true))
+	tests := []struct {
+		name string
+		f    fn.Function
+	}{
+		{"no root", fn.Function{Runtime: "go"}},
+		{"missing root", fn.Function{Root: filepath.Join(root, "missing"), Runtime: "go"}},
+		{"not initialized", fn.Function{Root: root, Runtime: "go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e ErrNoFunction
+			if err := b.Build(context.Background(), tt.f, TestPlatforms); !errors.As(err, &e) {
+				t.Fatalf("expected ErrNoFunction, got %v", err)
+			}
+		})
+	}
+
+	// Not written: a function created in memory without its func.yaml
+	var e *fn.ErrNotInitialized
+	err := b.Build(context.Background(), fn.Function{Root: root, Runtime: "go", Created: time.Now()}, TestPlatforms)
+	if !errors.As(err, &e) {
+		t.Fatalf("expected ErrNotInitialized of a function without %v, got %v", fn.FunctionFile, err)
+	}
+}
+
+// TestBuilder_UnsupportedPlatform ensures language builders report the
+// platforms they support, and that building for one which is not fails with
+// ErrUnsupportedPlatform before building.
+func TestBuilder_UnsupportedPlatform(t *testing.T) {
+	tests := []struct {
+		lb       PlatformBuilder
+		platform v1.Platform
+		expected bool
+	}{
+		{goBuilder{}, v1.Platform{OS: "linux", Architecture: "amd64"}, true},
+		{goBuilder{}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, true},
+		{goBuilder{}, v1.Platform{OS: "linux", Architecture: "riscv64"}, true},
+		{goBuilder{}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v8"}, false},
+		{goBuilder{}, v1.Platform{OS: "linux", Architecture: "sparc"}, false},
+		{goBuilder{}, v1.Platform{OS: "windows", Architecture: "amd64"}, false},
+		{pythonBuilder{}, v1.Platform{OS: "linux", Architecture: "arm64"}, true},
+		{pythonBuilder{}, v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, true},
+		{pythonBuilder{}, v1.Platform{OS: "linux", Architecture: "riscv64"}, false},
+		{pythonBuilder{}, v1.Platform{OS: "darwin", Architecture: "arm64"}, false},
+	}
+	for _, tt := range tests {
+		if supported := tt.lb.SupportsPlatform(tt.platform); supported != tt.expected {
+			t.Errorf("%T: expected support of %v to be %v", tt.lb, platformName(tt.platform), tt.expected)
+		}
+	}
+
+	root, done := Mktemp(t)
+	defer done()
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = NewBuilder("", false).Build(context.Background(), f, []fn.Platform{{OS: "windows", Architecture: "amd64"}})
+	var errPlatform ErrUnsupportedPlatform
+	if !errors.As(err, &errPlatform) || errPlatform.Runtime != "go" || errPlatform.Platform != "windows/amd64" {
+		t.Fatalf("expected ErrUnsupportedPlatform, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, fn.RunDataDir, "builds", "by-hash")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to be built. %v", err)
+	}
+}
+
+// TestRegisterBuilder ensures that language builders may be registered for
+// new runtimes, that built-ins and existing registrations are not replaced
+// unless explicitly, and that jobs use the registered builder.
+func TestRegisterBuilder(t *testing.T) {
+	t.Cleanup(func() {
+		buildersMu.Lock()
+		delete(builders, "test-runtime")
+		builders["go"] = goBuilder{}
+		buildersMu.Unlock()
+	})
+
+	impl := NewTestLanguageBuilder()
+	if err := RegisterBuilder("test-runtime", impl); err != nil {
+		t.Fatal(err)
+	}
+	if !IsSupported("test-runtime") {
+		t.Fatal("registered runtime not supported")
+	}
+
+	var errRegistered ErrBuilderRegistered
+	if err := RegisterBuilder("test-runtime", impl); !errors.As(err, &errRegistered) || errRegistered.Builtin {
+		t.Fatalf("expected ErrBuilderRegistered for an existing registration, got %v", err)
+	}
+	if err := RegisterBuilder("go", impl); !errors.As(err, &errRegistered) || !errRegistered.Builtin {
+		t.Fatalf("expected ErrBuilderRegistered for a built-in, got %v", err)
+	}
+
+	ReplaceBuilder("go", impl)
+	root, done := Mktemp(t)
+	defer done()
+	job, err := newBuildJob(context.Background(), fn.Function{Root: root, Runtime: "go"}, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.languageBuilder != impl {
+		t.Fatal("expected the replacement builder to be used")
+	}
+}
+
+// TestBuildContext_WriteLayer ensures that a layer written by a language
+// builder is moved into the blobs directory, named by its digest, and that
+// layers are validated against their blobs.
+func TestBuildContext_WriteLayer(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+
+	ctx := BuildContext{job}
+	source := filepath.Join(ctx.BuildDir(), "exe")
+	target := filepath.Join(ctx.BuildDir(), "layer.tar.gz")
+	if err = os.WriteFile(source, []byte("exe"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = goExeTarball(source, target, nil, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	layer, err := ctx.WriteLayer(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(ctx.BlobsDir(), layer.Descriptor.Digest.Hex)); err != nil {
+		t.Fatalf("layer not written to blobs. %v", err)
+	}
+	if _, err = os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("expected layer tarball to be moved, got %v", err)
+	}
+
+	// The written layer is valid
+	if err = validateLayers(job, "WritePlatform", []ImageLayer{layer}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A descriptor which does not match its blob is not
+	var errInvalid ErrInvalidLayer
+	wrongSize := layer
+	wrongSize.Descriptor.Size++
+	if err = validateLayers(job, "WritePlatform", []ImageLayer{layer, wrongSize}); !errors.As(err, &errInvalid) || errInvalid.Index != 1 {
+		t.Fatalf("expected ErrInvalidLayer for layer 1, got %v", err)
+	}
+	if err = os.Remove(filepath.Join(ctx.BlobsDir(), layer.Descriptor.Digest.Hex)); err != nil {
+		t.Fatal(err)
+	}
+	if err = validateLayers(job, "WritePlatform", []ImageLayer{layer}); !errors.As(err, &errInvalid) {
+		t.Fatalf("expected ErrInvalidLayer for a missing blob, got %v", err)
+	}
+}
+
+// TestBuilder_BuildSourceLayer ensures that the source layer alone can be
+// built, including for runtimes without a language builder, and that its
+// blob remains after the build.
+func TestBuilder_BuildSourceLayer(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Runtime = "node"
+
+	layer, err := NewBuilder("", false).BuildSourceLayer(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(layer.Path)
+	if err != nil {
+		t.Fatalf("source layer blob not found. %v", err)
+	}
+	if info.Size() != layer.Descriptor.Size {
+		t.Fatalf("expected blob size %v, got %v", layer.Descriptor.Size, info.Size())
+	}
+	digest, err := layer.Layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != layer.Descriptor.Digest {
+		t.Fatalf("expected layer digest %v, got %v", layer.Descriptor.Digest, digest)
+	}
+}
+
+// TestBuilder_WorkDir ensures that build files are written to the work
+// directory rather than the function's .func directory when provided.
+func TestBuilder_WorkDir(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Runtime = "node"
+	workDir := t.TempDir()
+
+	layer, err := NewBuilder("", false, WithWorkDir(workDir)).BuildSourceLayer(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isWithin(workDir, layer.Path) {
+		t.Fatalf("expected source layer within work dir %v, got %v", workDir, layer.Path)
+	}
+	if _, err := os.Stat(filepath.Join(root, fn.RunDataDir, "builds", "by-hash")); !os.IsNotExist(err) {
+		t.Fatalf("expected no builds in the function's %v directory. %v", fn.RunDataDir, err)
+	}
+}
+
+// TestBuilder_Fingerprint ensures the fingerprint of a function's source is
+// reported with the build directory it identifies, without building, and that
+// it changes with the source.
+func TestBuilder_Fingerprint(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, dir, err := NewBuilder("", false).Fingerprint(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _, err := fn.Fingerprint(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != expected {
+		t.Fatalf("expected fingerprint %v, got %v", expected, hash)
+	}
+	if dir != filepath.Join(root, fn.RunDataDir, "builds", "by-hash", hash) {
+		t.Fatalf("unexpected build directory %v", dir)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing to be built. %v", err)
+	}
+
+	workDir := t.TempDir()
+	if _, dir, err = NewBuilder("", false, WithWorkDir(workDir)).Fingerprint(f); err != nil {
+		t.Fatal(err)
+	}
+	if !isWithin(workDir, dir) {
+		t.Fatalf("expected build directory within work dir %v, got %v", workDir, dir)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, _, err := NewBuilder("", false).Fingerprint(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == hash {
+		t.Fatal("expected the fingerprint to change with the source")
+	}
+}
+
+// TestBuilder_FingerprintStable ensures rebuilding unchanged source yields
+// the same fingerprint, and thus build directory, despite the first build's
+// writes to .func.
+func TestBuilder_FingerprintStable(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := NewTestLanguageBuilder()
+	impl.ConfigureFn = func(_ BuildContext, _ v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
+		return cf, nil
+	}
+
+	// build the function, returning its job.
+	build := func() buildJob {
+		job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.languageBuilder = impl
+		job.quiet = true
+		if err = setup(job); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup(job)
+		defer os.Remove(job.pidLink())
+		if err = scaffold(job); err != nil {
+			t.Fatal(err)
+		}
+		if err = containerize(job); err != nil {
+			t.Fatal(err)
+		}
+		if err = updateLastLink(job); err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+
+	first, second := build(), build()
+	if first.hash != second.hash {
+		t.Fatalf("expected the same fingerprint for unchanged source, got %v and %v", first.hash, second.hash)
+	}
+	if first.buildDir() != second.buildDir() {
+		t.Fatalf("expected the same build directory, got %v and %v", first.buildDir(), second.buildDir())
+	}
+}
+
+// TestBuilder_SharedCache ensures that base layers are cached in the shared
+// cache when provided, and that concurrent writers of the same entry are
+// serialized by its lock.
+func TestBuilder_SharedCache(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	cache := t.TempDir()
+	layer, err := random.Layer(1024, types.DockerLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// each a different function, sharing the cache
+			job := buildJob{
+				ctx:      context.Background(),
+				function: fn.Function{Root: filepath.Join(root, strconv.Itoa(i))},
+				options:  options{sharedCache: cache},
+			}
+			errs <- ensureCached(job, layer)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != digest.Hex {
+		t.Fatalf("expected only the cached layer %v in the shared cache, got %v", digest.Hex, entries)
+	}
+	if _, err := os.Stat(filepath.Join(root, "0", fn.RunDataDir, "blob-cache")); !os.IsNotExist(err) {
+		t.Fatalf("expected no function blob cache when shared. %v", err)
+	}
+}
+
+// Test_lockCachedStale ensures that the lock of a cache entry held by a
+// process which no longer exists does not block.
+func Test_lockCachedStale(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job := buildJob{ctx: ctx, options: options{sharedCache: t.TempDir()}}
+
+	// An int beyond the max PID of the platforms
+	stale := filepath.Join(job.cacheDir(), "digest.lock")
+	if err := os.WriteFile(stale, []byte("2147483647"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockCached(job, "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected lock to be removed on unlock. %v", err)
+	}
+}
+
+// Test_validatedLinkTargetMatrix ensures links are validated by where they
+// actually resolve, including links whose targets traverse other links, dot
+// prefixes, platform separators and targets which clean to "..".
+func Test_validatedLinkTargetMatrix(t *testing.T) {
+	root := newLinkFixture(t)
+
+	// A backslash is a separator on Windows but a valid filename character
+	// elsewhere, in which case the link can not escape.
+	backslashEscapes := runtime.GOOS == "windows"
+
+	tests := []struct {
+		dir    string // directory within root in which to create the link
+		target string // link target
+		valid  bool   // If it should be considered valid
+		name   string // descriptive name of the test
+	}{
+		{".", "./a.txt", true, "dot-slash prefix"},
+		{".", ".", true, "link to the root itself"},
+		{".", "./", true, "link to the root itself with trailing separator"},
+		{".", "...", true, "dot-prefixed name"},
+		{".", "..a", true, "double-dot-prefixed name"},
+		{".", "..", false, "parent of root"},
+		{".", "./..", false, "dot-slash to parent of root"},
+		{".", "b/..", true, "cleans to root"},
+		{".", "b/../..", false, "cleans to parent of root"},
+		{".", "b/c/../../../a.txt", false, "up and out via subdirectories"},
+		{"b/c", "../../a.txt", true, "nested link up, but within project"},
+		{"b/c", "../../..", false, "nested link to parent of root"},
+		{"b/c", "../../b/c/../../..", false, "nested link up, down and out"},
+		{".", "b/linkToRoot/a.txt", true, "through link to root"},
+		{".", "b/linkToRoot/..", false, "parent of link target rather than link parent"},
+		{".", "b/linkToRoot/../..", false, "through link to root then out"},
+		{"b", "linkToRoot/../b", false, "out through a link and back in"},
+		{".", "b/linkToRootsParent/a.txt", false, "through a link which escapes"},
+		{".", "/etc/passwd", false, "absolute"},
+		{".", `..\..\etc`, !backslashEscapes, "windows separators"},
+		{".", `b\..\..`, !backslashEscapes, "windows separators cleaning to parent"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(root, tt.dir, fmt.Sprintf("lnk%v", i))
+			if err := os.Symlink(tt.target, path); err != nil {
+				t.Skipf("unable to create link: %v", err)
+			}
+			_, err := validatedLinkTarget(root, path)
+			if err == nil != tt.valid {
+				t.Fatalf("expected validity '%v' for %q, got '%v'", tt.valid, tt.target, err)
+			}
+		})
+	}
+}
+
+// Fuzz_validatedLinkTarget ensures that no link target which is accepted
+// resolves to a location outside the project root.
+func Fuzz_validatedLinkTarget(f *testing.F) {
+	for _, seed := range []string{".", "..", "./a.txt", "b/..", "b/linkToRoot/..",
+		"b/c/../../..", "...", `..\..`, "/etc", "b/linkToRootsParent"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, target string) {
+		root := newLinkFixture(t)
+		path := filepath.Join(root, "b", "c", "lnk")
+		if err := os.Symlink(target, path); err != nil {
+			t.Skip()
+		}
+		if _, err := validatedLinkTarget(root, path); err != nil {
+			return // rejected
+		}
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return // does not resolve to anything on disk
+		}
+		if !isWithin(root, resolved) {
+			t.Fatalf("link target %q accepted but resolves to %q outside %q", target, resolved, root)
+		}
+	})
+}
+
+// newLinkFixture creates a project root containing subdirectories and
+// links for validating link targets:
+//
+//	a.txt
+//	b/c/
+//	b/linkToRoot -> ..
+//	b/linkToRootsParent -> ../..
+func newLinkFixture(t *testing.T) string {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root = filepath.Join(root, "root")
+	if err := os.MkdirAll(filepath.Join(root, "b", "c"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{
+		"linkToRoot":        "..",
+		"linkToRootsParent": filepath.Join("..", ".."),
+	} {
+		if err := os.Symlink(target, filepath.Join(root, "b", name)); err != nil {
+			t.Skipf("unable to create link: %v", err)
+		}
+	}
+	return root
+}
+
+// Test_newDataTarballHardlinks ensures that files hard-linked within the
+// function are written to the data layer once, with subsequent occurrences
+// written as links to the first.
+func Test_newDataTarballHardlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are copied on windows")
+	}
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("file a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(root, "a.txt"), filepath.Join(root, "b.txt")); err != nil {
+		t.Skipf("unable to create hard link: %v", err)
+	}
+
+	target := filepath.Join(t.TempDir(), "datalayer.tar.gz")
+	if err := newDataTarball(root, target, defaultIgnored, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	headers := readTarball(t, target)
+	a, b := headers["/func/a.txt"], headers["/func/b.txt"]
+	if a == nil || b == nil {
+		t.Fatalf("expected both a.txt and b.txt in the layer, got %v", headers)
+	}
+	if a.Typeflag != tar.TypeReg || a.Size != int64(len("file a")) {
+		t.Fatalf("expected first occurrence to be a regular file with content, got %v", a)
+	}
+	if b.Typeflag != tar.TypeLink || b.Linkname != "/func/a.txt" || b.Size != 0 {
+		t.Fatalf("expected hard link to /func/a.txt, got type %q to %q (size %v)", b.Typeflag, b.Linkname, b.Size)
+	}
+}
+
+// readTarball returns the headers of all entries of the given .tar.gz
+// keyed by name.
+func readTarball(t *testing.T, path string) map[string]*tar.Header {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gr.Close()
+
+	headers := map[string]*tar.Header{}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		headers[hdr.Name] = hdr
+	}
+	return headers
+}
+
+// Test_newDataTarballEmptyDirs ensures that empty directories are preserved
+// in the data layer, owned by and writable by the function's user.
+func Test_newDataTarballEmptyDirs(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "cache"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "readonly"), 0555); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(t.TempDir(), "datalayer.tar.gz")
+	if err := newDataTarball(root, target, defaultIgnored, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	headers := readTarball(t, target)
+	for _, name := range []string{"/func", "/func/cache", "/func/a", "/func/a/b", "/func/readonly"} {
+		hdr, ok := headers[name]
+		if !ok {
+			t.Fatalf("directory %v not found in layer", name)
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			t.Fatalf("expected %v to be a directory, got type %q", name, hdr.Typeflag)
+		}
+		if hdr.Uid != DefaultUid || hdr.Gid != DefaultGid {
+			t.Fatalf("expected %v to be owned by %v:%v, got %v:%v", name, DefaultUid, DefaultGid, hdr.Uid, hdr.Gid)
+		}
+		if runtime.GOOS != "windows" && hdr.Mode&0700 != 0700 {
+			t.Fatalf("expected %v to be accessible by its owner, got mode %o", name, hdr.Mode)
+		}
+	}
+}
+
+// Test_newDataTarballStripped ensures tests, test fixtures and documentation
+// are excluded from the data layer when stripping source, and retained
+// otherwise.
+func Test_newDataTarballStripped(t *testing.T) {
+	root := t.TempDir()
+	files := []string{"handle.go", "handle_test.go", "README.md", "testdata/in.json",
+		"function/func.py", "tests/test_func.py", "static/index.html"}
+	for _, f := range files {
+		path := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := filepath.Join(t.TempDir(), "datalayer.tar.gz")
+	if err := newDataTarball(root, target, defaultIgnored, false, false); err != nil {
+		t.Fatal(err)
+	}
+	headers := readTarball(t, target)
+	for _, f := range files {
+		if _, ok := headers["/func/"+f]; !ok {
+			t.Fatalf("expected %v in the layer when not stripping", f)
+		}
+	}
+
+	ignored := append(append([]string{}, defaultIgnored...), strippedSource...)
+	if err := newDataTarball(root, target, ignored, false, false); err != nil {
+		t.Fatal(err)
+	}
+	headers = readTarball(t, target)
+	for _, f := range []string{"handle.go", "function/func.py", "static/index.html"} {
+		if _, ok := headers["/func/"+f]; !ok {
+			t.Fatalf("expected %v in the stripped layer", f)
+		}
+	}
+	for _, f := range []string{"handle_test.go", "README.md", "testdata", "testdata/in.json", "tests"} {
+		if _, ok := headers["/func/"+f]; ok {
+			t.Fatalf("expected %v to be stripped from the layer", f)
+		}
+	}
+}
+
+// Test_newConfigFilePorts ensures additional ports of the function are
+// exposed alongside the primary port, and communicated to the function via
+// its environment.
+func Test_newConfigFilePorts(t *testing.T) {
+	f := fn.Function{Root: t.TempDir(), Run: fn.RunSpec{Ports: []fn.Port{
+		{Name: "grpc", Port: 9090},
+		{Name: "stats", Port: 9125, Protocol: "udp"},
+	}}}
+	job := buildJob{ctx: context.Background(), start: time.Now(), function: f}
+
+	cfg, err := newConfigFile(job, v1.Platform{OS: "linux", Architecture: "amd64"}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, port := range []string{"8080/tcp", "9090/tcp", "9125/udp"} {
+		if _, ok := cfg.Config.ExposedPorts[port]; !ok {
+			t.Errorf("expected port %v to be exposed, got %v", port, cfg.Config.ExposedPorts)
+		}
+	}
+	for _, env := range []string{"FUNC_PORT_GRPC=9090", "FUNC_PORT_STATS=9125"} {
+		if !slices.Contains(cfg.Config.Env, env) {
+			t.Errorf("expected env %v, got %v", env, cfg.Config.Env)
+		}
+	}
+}
+
+// Test_newConfigFileMetrics ensures the metrics port is exposed and its
+// address communicated to the function only when metrics are enabled.
+func Test_newConfigFileMetrics(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		f := fn.Function{Root: t.TempDir(), Run: fn.RunSpec{Metrics: enabled}}
+		job := buildJob{ctx: context.Background(), start: time.Now(), function: f}
+
+		cfg, err := newConfigFile(job, v1.Platform{OS: "linux", Architecture: "amd64"}, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cfg.Config.ExposedPorts["9090/tcp"]; ok != enabled {
+			t.Errorf("metrics %v: unexpected exposed ports %v", enabled, cfg.Config.ExposedPorts)
+		}
+		if ok := slices.Contains(cfg.Config.Env, "METRICS_ADDRESS=[::]:9090"); ok != enabled {
+			t.Errorf("metrics %v: unexpected envs %v", enabled, cfg.Config.Env)
+		}
+	}
+}
+
+// TestBuilder_ConcurrencyLimit ensures that platforms are built concurrently,
+// no more at once than the configured concurrency, and that the index lists
+// the platforms' manifests in the order of the platforms regardless.
+func TestBuilder_ConcurrencyLimit(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	platforms := []fn.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "linux", Architecture: "arm", Variant: "v7"},
+		{OS: "linux", Architecture: "s390x"},
+	}
+
+	var (
+		mu              sync.Mutex
+		running, maxRun int
+	)
+	impl := NewTestLanguageBuilder()
+	impl.WritePlatformFn = func(BuildContext, v1.Platform) ([]ImageLayer, error) {
+		mu.Lock()
+		running++
+		maxRun = max(maxRun, running)
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return []ImageLayer{}, nil
+	}
+	impl.ConfigureFn = func(_ BuildContext, _ v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
+		return cf, nil
+	}
+
+	job, err := newBuildJob(context.Background(), f, platforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.languageBuilder = impl
+	job.options.concurrency = 2
+	job.quiet = true
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	if err = scaffold(job); err != nil {
+		t.Fatal(err)
+	}
+	if err = containerize(job); err != nil {
+		t.Fatal(err)
+	}
+	if maxRun != 2 {
+		t.Fatalf("expected 2 platforms built at once, got %v", maxRun)
+	}
+
+	index, err := os.ReadFile(filepath.Join(job.ociDir(), "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest v1.IndexManifest
+	if err = json.Unmarshal(index, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Manifests) != len(platforms) {
+		t.Fatalf("expected %v manifests, got %v", len(platforms), len(manifest.Manifests))
+	}
+	for i, m := range manifest.Manifests {
+		if m.Platform.Architecture != platforms[i].Architecture || m.Platform.Variant != platforms[i].Variant {
+			t.Fatalf("expected manifest %v to be of %v, got %v", i, platforms[i], m.Platform)
+		}
+	}
+}
+
+// TestBuildJob_Concurrency ensures that the default concurrency is the
+// lesser of the number of platforms and GOMAXPROCS, and that an explicit
+// concurrency is used as is.
+func TestBuildJob_Concurrency(t *testing.T) {
+	job := buildJob{platforms: make([]v1.Platform, 3)}
+	if n := job.concurrency(); n != min(3, runtime.GOMAXPROCS(0)) {
+		t.Fatalf("unexpected default concurrency %v", n)
+	}
+	job.platforms = make([]v1.Platform, 1000)
+	if n := job.concurrency(); n != runtime.GOMAXPROCS(0) {
+		t.Fatalf("expected default concurrency limited to GOMAXPROCS, got %v", n)
+	}
+	job.options.concurrency = 1
+	if n := job.concurrency(); n != 1 {
+		t.Fatalf("expected concurrency 1, got %v", n)
+	}
+	if err := (options{concurrency: -1}).validate(); err == nil {
+		t.Fatal("expected an error for negative concurrency")
+	}
+}
+
+// compiledTestLanguageBuilder is a test language builder of a compiled
+// runtime (see CompiledBuilder).
+type compiledTestLanguageBuilder struct {
+	*TestLanguageBuilder
+}
+
+func (compiledTestLanguageBuilder) Compiled() bool { return true }
+
+// TestBuilder_WithoutSource ensures the function's source (the data layer) is
+// omitted from the image of a compiled runtime when requested, and that it
+// can not be omitted for other runtimes.
+func TestBuilder_WithoutSource(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := NewTestLanguageBuilder()
+	impl.ConfigureFn = func(_ BuildContext, _ v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
+		return cf, nil
+	}
+
+	// layers returns the number of layers of the image built.
+	layers := func(lb LanguageBuilder, withoutSource bool) (int, error) {
+		job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.languageBuilder = lb
+		job.withoutSource = withoutSource
+		job.quiet = true
+		if err = setup(job); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup(job)
+		defer os.Remove(job.pidLink())
+		if err = scaffold(job); err != nil {
+			t.Fatal(err)
+		}
+		if err = containerize(job); err != nil {
+			return 0, err
+		}
+		ii, err := layout.ImageIndexFromPath(job.ociDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		index, err := ii.IndexManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := ii.Image(index.Manifests[0].Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ll, err := img.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(ll), nil
+	}
+
+	// Default: the data and certificates layers
+	n, err := layers(compiledTestLanguageBuilder{impl}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected the data and certificates layers, got %v layers", n)
+	}
+
+	// Without source: only the certificates layer
+	if n, err = layers(compiledTestLanguageBuilder{impl}, true); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected only the certificates layer, got %v layers", n)
+	}
+
+	// The source of a runtime which is not compiled is required
+	if _, err = layers(impl, true); err == nil {
+		t.Fatal("expected an error omitting the source of a runtime which is not compiled")
+	}
+}
+
+// TestBuilder_KeepTars ensures the tarballs of the layers remain in the build
+// directory only when kept, along with their blobs, which are then linked by
+// name.
+func TestBuilder_KeepTars(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := NewTestLanguageBuilder()
+	impl.ConfigureFn = func(_ BuildContext, _ v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
+		return cf, nil
+	}
+
+	// build with the tarballs kept or not, checking the build directory.
+	build := func(keep bool) {
+		job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.languageBuilder = impl
+		job.keepTars = keep
+		job.quiet = true
+		if err = setup(job); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup(job)
+		defer os.Remove(job.pidLink())
+		if err = scaffold(job); err != nil {
+			t.Fatal(err)
+		}
+		if err = containerize(job); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tar := range []string{"datalayer.tar.gz", "certslayer.tar.gz"} {
+			path := filepath.Join(job.buildDir(), tar)
+			if _, err = os.Stat(path); keep && err != nil {
+				t.Fatalf("expected %v to be kept. %v", tar, err)
+			} else if !keep && !os.IsNotExist(err) {
+				t.Fatalf("expected %v not to be kept, got %v", tar, err)
+			}
+			if !keep {
+				continue
+			}
+			layer, err := tarball.LayerFromFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			digest, err := layer.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			blob := filepath.Join(job.blobsDir(), digest.Hex)
+			if _, err = os.Stat(blob); err != nil {
+				t.Fatalf("expected the blob of %v. %v", tar, err)
+			}
+			// The blob is linked by name
+			target, err := filepath.EvalSymlinks(filepath.Join(job.blobsByNameDir(), blobName(tar)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected, _ := filepath.EvalSymlinks(blob); target != expected {
+				t.Fatalf("expected %v to be linked to %v, got %v", tar, expected, target)
+			}
+		}
+		if _, err = os.Stat(job.blobsByNameDir()); !keep && !os.IsNotExist(err) {
+			t.Fatalf("expected blobs not to be linked by name, got %v", err)
+		}
+	}
+
+	build(false)
+	build(true)
+}
+
+// TestBuilder_IndexPlatforms ensures a build for several platforms yields an
+// index with a manifest for each.
+func TestBuilder_IndexPlatforms(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	platforms := []fn.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+		{OS: "linux", Architecture: "arm", Variant: "v7"},
+	}
+	job, err := newBuildJob(context.Background(), f, platforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.languageBuilder = NewTestLanguageBuilder()
+	job.quiet = true
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+	if err = scaffold(job); err != nil {
+		t.Fatal(err)
+	}
+	if err = containerize(job); err != nil {
+		t.Fatal(err)
+	}
+
+	ii, err := layout.ImageIndexFromPath(job.ociDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 3 {
+		t.Fatalf("expected 3 manifests, got %v", len(index.Manifests))
+	}
+	for i, m := range index.Manifests {
+		if m.Platform == nil || platformName(*m.Platform) != platformName(job.platforms[i]) {
+			t.Fatalf("expected manifest %v for %v, got %v", i, platformName(job.platforms[i]), m.Platform)
+		}
+	}
+}
+
+// Test_verifyIndex ensures an index missing a requested platform, or holding
+// one twice, is an error.
+func Test_verifyIndex(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	platforms := []fn.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+	job, err := newBuildJob(context.Background(), f, platforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(job.ociDir(), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(job.buildDir())
+
+	manifest := func(arch string) v1.Descriptor {
+		digest, _, _ := v1.SHA256(strings.NewReader(arch))
+		return v1.Descriptor{Digest: digest, Platform: &v1.Platform{OS: "linux", Architecture: arch}}
+	}
+	tests := []struct {
+		name       string
+		manifests  []v1.Descriptor
+		missing    []string
+		duplicated []string
+		unexpected []string
+	}{
+		{"complete", []v1.Descriptor{manifest("amd64"), manifest("arm64")}, nil, nil, nil},
+		{"missing", []v1.Descriptor{manifest("amd64")}, []string{"linux/arm64"}, nil, nil},
+		{"duplicated", []v1.Descriptor{manifest("amd64"), manifest("arm64"), manifest("arm64")}, nil, []string{"linux/arm64"}, nil},
+		{"unexpected", []v1.Descriptor{manifest("amd64"), manifest("arm64"), manifest("s390x")}, nil, nil, []string{"linux/s390x"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := writeIndex(job, test.manifests); err != nil {
+				t.Fatal(err)
+			}
+			err := verifyIndex(job)
+			if test.missing == nil && test.duplicated == nil && test.unexpected == nil {
+				if err != nil {
+					t.Fatalf("unexpected error. %v", err)
+				}
+				return
+			}
+			var e ErrIncompleteIndex
+			if !errors.As(err, &e) {
+				t.Fatalf("expected ErrIncompleteIndex, got %v", err)
+			}
+			if !cmp.Equal(e.Missing, test.missing) ||
+				!cmp.Equal(e.Duplicated, test.duplicated) ||
+				!cmp.Equal(e.Unexpected, test.unexpected) {
+				t.Fatalf("expected missing %v, duplicated %v, unexpected %v, got %+v",
+					test.missing, test.duplicated, test.unexpected, e)
+			}
+		})
+	}
+}
+
+// TestBuilder_BuildPlatforms ensures that, when no platforms are given, those
+// of the function are built, else those of the builder, else the defaults of
+// the function's runtime.
+func TestBuilder_BuildPlatforms(t *testing.T) {
+	team := []fn.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}}
+	tests := []struct {
+		name     string
+		function fn.Function
+		builder  []fn.Platform
+		expected []fn.Platform
+	}{
+		{"runtime default", fn.Function{Runtime: "go"}, nil, fn.DefaultPlatforms},
+		{"runtime default (wasm)", fn.Function{Runtime: "wasm"}, nil, []fn.Platform{{OS: "wasi", Architecture: "wasm"}}},
+		{"builder default", fn.Function{Runtime: "go"}, team, team},
+		{"function", fn.Function{Runtime: "go", Build: fn.BuildSpec{Platforms: []string{"linux/arm/v7"}}}, team,
+			[]fn.Platform{{OS: "linux", Architecture: "arm", Variant: "v7"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := NewBuilder("", false, WithDefaultPlatforms(test.builder))
+			pp, err := b.buildPlatforms(test.function)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(pp, test.expected) {
+				t.Fatalf("expected platforms %v, got %v", test.expected, pp)
+			}
+		})
+	}
+
+	b := NewBuilder("", false)
+	if _, err := b.buildPlatforms(fn.Function{Build: fn.BuildSpec{Platforms: []string{"linux"}}}); err == nil {
+		t.Fatal("expected invalid platforms of the function to error")
+	}
+}
+
+// Test_containerizeSharedLayers ensures the layers shared by the images of
+// all platforms (data and certificates) are written to the blobs directory
+// once, referenced by the manifest of each platform, and that each blob is
+// of the image.
+func Test_containerizeSharedLayers(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.languageBuilder = NewTestLanguageBuilder()
+	job.quiet = true
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+	if err = scaffold(job); err != nil {
+		t.Fatal(err)
+	}
+	if err = containerize(job); err != nil {
+		t.Fatal(err)
+	}
+
+	ii, err := layout.ImageIndexFromPath(job.ociDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, err := ii.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(im.Manifests) != len(TestPlatforms) {
+		t.Fatalf("expected %v manifests, got %v", len(TestPlatforms), len(im.Manifests))
+	}
+	blobs := map[string]bool{}      // of the image
+	references := map[v1.Hash]int{} // by manifests, of each layer
+	for _, desc := range im.Manifests {
+		blobs[desc.Digest.Hex] = true
+		img, err := ii.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		blobs[m.Config.Digest.Hex] = true
+		for _, l := range m.Layers {
+			blobs[l.Digest.Hex] = true
+			references[l.Digest]++
+		}
+	}
+	shared := 0
+	for digest, n := range references {
+		if n == len(im.Manifests) {
+			shared++
+		} else if n != 1 {
+			t.Fatalf("expected layer %v to be referenced once per manifest, got %v", digest, n)
+		}
+	}
+	if shared < 2 {
+		t.Fatalf("expected the data and certificates layers to be shared, got %v shared", shared)
+	}
+
+	// Each blob is written once, and each is of the image
+	ee, err := os.ReadDir(job.blobsDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ee) != len(blobs) {
+		names := []string{}
+		for _, e := range ee {
+			names = append(names, e.Name())
+		}
+		t.Fatalf("expected the %v blobs of the image, got %v: %v", len(blobs), len(ee), names)
+	}
+	for _, e := range ee {
+		if !blobs[e.Name()] {
+			t.Fatalf("blob %v is not of the image", e.Name())
+		}
+	}
+}
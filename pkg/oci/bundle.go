@@ -0,0 +1,279 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// WithBundle exports the built OCI layout to a bundle at the given path after
+// each successful build (see ExportBundle).
+func WithBundle(path string) BuilderOpt {
+	return func(b *Builder) {
+		b.bundle = path
+	}
+}
+
+// ExportBundle writes the OCI layout at dir to a single tar archive at dest
+// for archival or handoff.  The bundle is itself an OCI layout: the
+// oci-layout file, the index and each blob reachable from the index stored
+// once by digest, such that blobs shared between platforms (for example base
+// layers) are not duplicated and blobs not referenced are omitted.  The blobs
+// are already compressed, so the archive is not.  The bundle is verified to
+// resolve (every referenced blob present with its digest and size) after
+// being written, and is removed if it does not.
+func ExportBundle(dir, dest string) error {
+	return exportBundle(dir, dest, DigestSHA256)
+}
+
+// exportBundle exports the layout at dir to the bundle dest (see
+// ExportBundle) with digests of the given algorithm, converting its blobs
+// unless of SHA-256.
+func exportBundle(dir, dest, algorithm string) (err error) {
+	blobs, err := layoutBlobs(dir)
+	if err != nil {
+		return fmt.Errorf("error reading OCI layout %v. %w", dir, err)
+	}
+	if algorithm != DigestSHA256 {
+		var tmp string
+		if tmp, err = os.MkdirTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*"); err != nil {
+			return
+		}
+		defer os.RemoveAll(tmp)
+		if blobs, err = convertLayout(dir, tmp, algorithm); err != nil {
+			return fmt.Errorf("error converting OCI layout %v. %w", dir, err)
+		}
+		dir = tmp
+	}
+	if err = writeBundle(dir, dest, blobs); err != nil {
+		_ = os.Remove(dest)
+		return fmt.Errorf("error writing bundle %v. %w", dest, err)
+	}
+	if err = verifyBundle(dest, blobs); err != nil {
+		_ = os.Remove(dest)
+		return fmt.Errorf("error verifying bundle %v. %w", dest, err)
+	}
+	return
+}
+
+// layoutBlobs returns the size of each blob reachable from the index of the
+// OCI layout at dir by digest, ensuring each exists and is of that size.
+func layoutBlobs(dir string) (blobs map[v1.Hash]int64, err error) {
+	bb, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return
+	}
+	index, err := v1.ParseIndexManifest(bytes.NewReader(bb))
+	if err != nil {
+		return
+	}
+	blobs = map[v1.Hash]int64{}
+	err = addIndexBlobs(dir, index, blobs)
+	return
+}
+
+// addIndexBlobs adds the blobs referenced by the index, recursively.
+func addIndexBlobs(dir string, index *v1.IndexManifest, blobs map[v1.Hash]int64) error {
+	for _, d := range index.Manifests {
+		if err := addBlob(dir, d, blobs); err != nil {
+			return err
+		}
+		path := blobPath(dir, d.Digest)
+		switch {
+		case d.MediaType.IsIndex():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			child, err := v1.ParseIndexManifest(file)
+			file.Close()
+			if err != nil {
+				return err
+			}
+			if err = addIndexBlobs(dir, child, blobs); err != nil {
+				return err
+			}
+		case d.MediaType.IsImage():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			manifest, err := v1.ParseManifest(file)
+			file.Close()
+			if err != nil {
+				return err
+			}
+			if err = addBlob(dir, manifest.Config, blobs); err != nil {
+				return err
+			}
+			for _, l := range manifest.Layers {
+				if err = addBlob(dir, l, blobs); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// addBlob adds the blob of the descriptor, ensuring it exists with the
+// described size.
+func addBlob(dir string, d v1.Descriptor, blobs map[v1.Hash]int64) error {
+	if d.Digest.Algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest algorithm %q of blob %v", d.Digest.Algorithm, d.Digest)
+	}
+	info, err := os.Stat(blobPath(dir, d.Digest))
+	if err != nil {
+		return fmt.Errorf("blob %v not found. %w", d.Digest, err)
+	}
+	if info.Size() != d.Size {
+		return fmt.Errorf("blob %v is %v bytes but described as %v", d.Digest, info.Size(), d.Size)
+	}
+	blobs[d.Digest] = d.Size
+	return nil
+}
+
+// writeBundle writes the layout file, index and given blobs of the layout at
+// dir to a tar archive at dest, in digest order.
+func writeBundle(dir, dest string, blobs map[v1.Hash]int64) (err error) {
+	file, err := os.Create(dest)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	tw := tar.NewWriter(file)
+
+	for _, name := range []string{"oci-layout", "index.json"} {
+		if err = addBundleFile(tw, filepath.Join(dir, name), name); err != nil {
+			return
+		}
+	}
+	for _, d := range sortedDigests(blobs) {
+		name := "blobs/" + d.Algorithm + "/" + d.Hex
+		if err = addBundleFile(tw, blobPath(dir, d), name); err != nil {
+			return
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return
+	}
+	return file.Close()
+}
+
+// addBundleFile adds the regular file at path to the archive with the given
+// name.
+func addBundleFile(tw *tar.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Uid:     DefaultUid,
+		Gid:     DefaultGid,
+	}
+	if err = tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+// verifyBundle ensures the bundle at path contains the layout file, the
+// index and exactly the given blobs, each once and of the expected digest
+// and size.
+func verifyBundle(path string, blobs map[v1.Hash]int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var (
+		tr   = tar.NewReader(file)
+		seen = map[string]bool{}
+	)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if seen[header.Name] {
+			return fmt.Errorf("duplicate entry %v", header.Name)
+		}
+		seen[header.Name] = true
+		if header.Name == "oci-layout" || header.Name == "index.json" {
+			continue
+		}
+
+		// Blobs are named by their digest: blobs/<algorithm>/<hex>
+		name, ok := strings.CutPrefix(header.Name, "blobs/")
+		if !ok {
+			return fmt.Errorf("unexpected entry %v", header.Name)
+		}
+		algorithm, encoded, ok := strings.Cut(name, "/")
+		if !ok {
+			return fmt.Errorf("unexpected entry %v", header.Name)
+		}
+		d := v1.Hash{Algorithm: algorithm, Hex: encoded}
+		size, ok := blobs[d]
+		if !ok {
+			return fmt.Errorf("unreferenced blob %v", d)
+		}
+		h, err := newHasher(d.Algorithm)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(h, tr)
+		if err != nil {
+			return err
+		}
+		if n != size {
+			return fmt.Errorf("blob %v is %v bytes, expected %v", d, n, size)
+		}
+		if hex.EncodeToString(h.Sum(nil)) != d.Hex {
+			return fmt.Errorf("blob %v does not match its digest", d)
+		}
+	}
+
+	if !seen["oci-layout"] || !seen["index.json"] {
+		return errors.New("missing oci-layout or index.json")
+	}
+	for d := range blobs {
+		if !seen["blobs/"+d.Algorithm+"/"+d.Hex] {
+			return fmt.Errorf("missing blob %v", d)
+		}
+	}
+	return nil
+}
+
+func blobPath(dir string, d v1.Hash) string {
+	return filepath.Join(dir, "blobs", d.Algorithm, d.Hex)
+}
+
+func sortedDigests(blobs map[v1.Hash]int64) (dd []v1.Hash) {
+	for d := range blobs {
+		dd = append(dd, d)
+	}
+	sort.Slice(dd, func(i, j int) bool { return dd[i].String() < dd[j].String() })
+	return
+}
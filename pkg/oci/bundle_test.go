@@ -0,0 +1,97 @@
+package oci
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// TestExportBundle ensures the exported bundle stores blobs shared between
+// platforms once, and that a layout which does not resolve is not exported.
+func TestExportBundle(t *testing.T) {
+	base, err := random.Layer(1024, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two platform images sharing a base layer, each with its own layer.
+	index := v1.ImageIndex(empty.Index)
+	for _, arch := range []string{"amd64", "arm64"} {
+		own, err := random.Layer(512, types.OCILayer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := mutate.AppendLayers(empty.Image, base, own)
+		if err != nil {
+			t.Fatal(err)
+		}
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: arch}},
+		})
+	}
+	dir := t.TempDir()
+	if _, err = layout.Write(dir, index); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stray blob, which is not referenced, is omitted
+	if err = os.WriteFile(filepath.Join(dir, "blobs", "sha256", "stray"), []byte("stray"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "bundle.tar")
+	if err = ExportBundle(dir, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 manifests, 2 configs, 1 shared and 2 own layers
+	if blobs := bundleBlobs(t, dest); blobs != 7 {
+		t.Fatalf("expected 7 blobs in the bundle, got %v", blobs)
+	}
+
+	// A layout missing a referenced blob does not resolve
+	digest, err := base.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(filepath.Join(dir, "blobs", "sha256", digest.Hex)); err != nil {
+		t.Fatal(err)
+	}
+	if err = ExportBundle(dir, dest); err == nil {
+		t.Fatal("expected error exporting a layout with a missing blob")
+	}
+}
+
+// bundleBlobs returns the number of blobs in the bundle at path.
+func bundleBlobs(t *testing.T, path string) (n int) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(header.Name, "blobs/sha256/") {
+			n++
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// WithCABundle adds the certificates of the PEM bundle at path, such as the
+// root CAs of a corporate network, to those of the image (its certs layer),
+// such that the function can reach HTTPS services whose certificates they
+// sign.  The bundle is merged with that of the scaffolding, each certificate
+// included once, and must contain only valid certificates.
+func WithCABundle(path string) BuilderOpt {
+	return func(b *Builder) {
+		b.caBundle = path
+	}
+}
+
+// ValidateCABundle returns an error if the file at path is not a PEM bundle
+// of at least one certificate, each valid.  Empty is no bundle.
+func ValidateCABundle(path string) error {
+	if path == "" {
+		return nil
+	}
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("invalid CA bundle %v. %w", path, err)
+	}
+	if _, err = parseCABundle(bb, true); err != nil {
+		return fmt.Errorf("invalid CA bundle %v. %w", path, err)
+	}
+	return nil
+}
+
+// writeCABundle merges the job's CA bundle, if any, into the certificates
+// bundle at path (that of the scaffolding), which is rewritten with the
+// certificates of both, those of the scaffolding first.
+func writeCABundle(job buildJob, path string) error {
+	if job.caBundle == "" {
+		return nil
+	}
+	base, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	extra, err := os.ReadFile(job.caBundle)
+	if err != nil {
+		return fmt.Errorf("invalid CA bundle %v. %w", job.caBundle, err)
+	}
+	merged, err := mergeCABundles(base, extra)
+	if err != nil {
+		return fmt.Errorf("invalid CA bundle %v. %w", job.caBundle, err)
+	}
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "cat %v >> %v\n", job.caBundle, path)
+	}
+	return os.WriteFile(path, merged, 0644)
+}
+
+// mergeCABundles returns the certificates of the base bundle followed by
+// those of the extra bundle not already included, as PEM.  Certificates of
+// the extra bundle must be valid, while those of the base are included as
+// they are.
+func mergeCABundles(base, extra []byte) ([]byte, error) {
+	baseCerts, err := parseCABundle(base, false)
+	if err != nil {
+		return nil, err
+	}
+	extraCerts, err := parseCABundle(extra, true)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		buf  bytes.Buffer
+		seen = map[string]bool{}
+	)
+	for _, c := range append(baseCerts, extraCerts...) {
+		if seen[string(c.Bytes)] {
+			continue
+		}
+		seen[string(c.Bytes)] = true
+		if err = pem.Encode(&buf, c); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// parseCABundle returns the certificate blocks of the PEM bundle, ignoring
+// any text between them (such as the comments of a system bundle).  When
+// strict, each must be a valid certificate, and there must be at least one.
+func parseCABundle(bb []byte, strict bool) (certs []*pem.Block, err error) {
+	for n := 1; ; n++ {
+		var block *pem.Block
+		if block, bb = pem.Decode(bb); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			if strict {
+				return nil, fmt.Errorf("block %d is a %v, expected a CERTIFICATE", n, block.Type)
+			}
+			continue
+		}
+		if strict {
+			if _, err = x509.ParseCertificate(block.Bytes); err != nil {
+				return nil, fmt.Errorf("certificate %d is not valid. %w", n, err)
+			}
+		}
+		certs = append(certs, &pem.Block{Type: block.Type, Bytes: block.Bytes})
+	}
+	if strict && len(certs) == 0 {
+		return nil, errors.New("no PEM encoded certificates found")
+	}
+	return
+}
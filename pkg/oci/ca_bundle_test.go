@@ -0,0 +1,101 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMergeCABundles ensures the certificates of a CA bundle are added to
+// those of the base, each once, and that an invalid bundle is rejected.
+func TestMergeCABundles(t *testing.T) {
+	var (
+		a = newTestCA(t, "a")
+		b = newTestCA(t, "b")
+		c = newTestCA(t, "c")
+	)
+	base := []byte("# System bundle\n\n" + string(a) + "\nTitle\n=====\n" + string(b))
+
+	merged, err := mergeCABundles(base, append(append([]byte{}, b...), c...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := string(a) + string(b) + string(c); string(merged) != expected {
+		t.Fatalf("expected the certificates of both, each once\nexpected:\n%v\ngot:\n%v", expected, merged)
+	}
+
+	invalid := map[string][]byte{
+		"empty":      {},
+		"not PEM":    []byte("not a certificate"),
+		"key":        pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("key")}),
+		"corrupt":    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("corrupt")}),
+		"valid, key": append(append([]byte{}, c...), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("key")})...),
+	}
+	for name, extra := range invalid {
+		if _, err = mergeCABundles(base, extra); err == nil {
+			t.Errorf("expected bundle %q to be rejected", name)
+		}
+	}
+}
+
+// TestValidateCABundle ensures a bundle must exist and be valid.
+func TestValidateCABundle(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.pem")
+	if err := os.WriteFile(valid, newTestCA(t, "a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalid, []byte("invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ValidateCABundle(""); err != nil {
+		t.Fatalf("expected no bundle to be valid, got %v", err)
+	}
+	if err := ValidateCABundle(valid); err != nil {
+		t.Fatalf("expected %v to be valid, got %v", valid, err)
+	}
+	if err := ValidateCABundle(invalid); err == nil {
+		t.Fatalf("expected %v to be invalid", invalid)
+	}
+	if err := ValidateCABundle(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Fatal("expected a missing bundle to be invalid")
+	}
+}
+
+// newTestCA returns a self-signed CA certificate of the given name as PEM.
+func newTestCA(t *testing.T, name string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,296 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// cacheLockInterval is the interval at which a locked cache entry is polled.
+var cacheLockInterval = 100 * time.Millisecond
+
+// WithSharedCache sets the directory of the blob cache shared by builds of
+// all functions, such that base layers are pulled once per system rather than
+// once per function.  Defaults to XDG_CONFIG_HOME/func/image-cache (see
+// SharedCacheDir).  An empty value disables the shared cache, caching base
+// layers in each function's .func/blob-cache.
+func WithSharedCache(dir string) BuilderOpt {
+	return func(b *Builder) {
+		b.sharedCache = dir
+	}
+}
+
+// SharedCacheDir returns the default location of the shared blob cache:
+// $XDG_CONFIG_HOME/func/image-cache if defined, otherwise
+// ~/.config/func/image-cache.  Empty if neither is available.
+func SharedCacheDir() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "func", "image-cache")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".config", "func", "image-cache")
+	}
+	return ""
+}
+
+// availableCache returns the shared cache directory if it can be created,
+// or empty such that the per-function cache is used as a fallback.
+func availableCache(dir string, verbose bool) string {
+	if dir == "" {
+		return ""
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Shared blob cache unavailable, using function cache. %v\n", err)
+		}
+		return ""
+	}
+	return dir
+}
+
+// lockCached acquires the lock on the cache entry of the given digest such
+// that concurrent builds, including those of other processes, do not write
+// the same entry at once.  The lock is a file containing the holder's PID,
+// and is considered stale (removed) if that process no longer exists.
+// The returned function releases the lock.
+func lockCached(job buildJob, digest string) (unlock func(), err error) {
+	path := filepath.Join(job.cacheDir(), digest+".lock")
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = file.WriteString(strconv.Itoa(os.Getpid()))
+			file.Close()
+			if err != nil {
+				_ = os.Remove(path)
+				return nil, err
+			}
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("error locking cached layer %v. %w", digest, err)
+		}
+
+		// Remove the lock of a holder which exited without releasing it.
+		// An empty lock is one being created, and is not stale.
+		if pid, err := os.ReadFile(path); err == nil && len(pid) > 0 && !processExists(string(pid)) {
+			_ = os.Remove(path)
+			continue
+		}
+
+		select {
+		case <-job.ctx.Done():
+			return nil, job.ctx.Err()
+		case <-time.After(cacheLockInterval):
+		}
+	}
+}
+
+// linkOrCopy hard links source to dest, falling back to a copy when links
+// are not possible, such as when the shared cache is on another filesystem.
+func linkOrCopy(source, dest string) (err error) {
+	if err = os.Link(source, dest); err == nil {
+		return
+	}
+	src, err := os.Open(source)
+	if err != nil {
+		return
+	}
+	defer src.Close()
+
+	// Copied to a temporary file first, such that a partial copy is never
+	// visible at dest, as concurrent builds may link or copy the same file.
+	dst, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*")
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		_ = os.Remove(dst.Name())
+		return
+	}
+	if err = dst.Close(); err != nil {
+		_ = os.Remove(dst.Name())
+		return
+	}
+	if err = os.Rename(dst.Name(), dest); err != nil {
+		_ = os.Remove(dst.Name())
+	}
+	return
+}
+
+// WarmCache resolves the function's base image (f.Build.BaseImage, or that
+// of its runtime) for each of the platforms, or those built by default (see
+// WithDefaultPlatforms), and downloads its layers into the blob cache without
+// building, such that builds which follow, including those run in parallel,
+// need not download them.  The pull policy and registry mirrors of the
+// builder apply as when building.  Functions built from scratch have no base
+// layers to cache.
+func (b *Builder) WarmCache(ctx context.Context, f fn.Function, pp []fn.Platform) (err error) {
+	if len(pp) == 0 {
+		if pp, err = b.buildPlatforms(f); err != nil {
+			return
+		}
+	}
+	if pp, err = fn.NormalizePlatforms(pp); err != nil {
+		return
+	}
+	if err = b.options.validate(); err != nil {
+		return
+	}
+	job, err := newBuildJob(ctx, f, pp, b.verbose)
+	if err != nil {
+		return
+	}
+	job.options = b.options
+	job.quiet = b.quiet && !b.verbose
+	job.sharedCache = availableCache(job.sharedCache, job.verbose)
+	if err = checkPlatforms(job); err != nil {
+		return
+	}
+
+	baseImage := job.languageBuilder.Base(f.Build.BaseImage)
+	if baseImage == "" {
+		if !job.quiet {
+			fmt.Println("Built from scratch: no base image layers to cache")
+		}
+		return
+	}
+	ref, err := name.ParseReference(baseImage)
+	if err != nil {
+		return ErrBasePull{baseImage, err}
+	}
+	if err = os.MkdirAll(job.cacheDir(), os.ModePerm); err != nil {
+		return
+	}
+	for _, p := range job.platforms {
+		image, err := resolveBase(job, ref, basePlatform(p))
+		if err != nil {
+			return ErrBasePull{baseImage, err}
+		}
+		layers, err := image.Layers()
+		if err != nil {
+			return ErrBasePull{baseImage, err}
+		}
+		for _, layer := range layers {
+			if err = ensureCached(job, layer); err != nil {
+				return noSpace(job.cacheDir(), err)
+			}
+		}
+		if !job.quiet {
+			fmt.Printf("Cached %v (%v): %v layers\n", baseImage, platformName(p), len(layers))
+		}
+	}
+	return
+}
+
+// CacheInfo describes the contents of a blob cache.
+type CacheInfo struct {
+	Dir   string       // location of the cache
+	Blobs []CachedBlob // cached blobs, most recently used first
+	Size  int64        // total size of the blobs in bytes
+}
+
+// CachedBlob is a blob in a cache.
+type CachedBlob struct {
+	Digest   string    // hex of the blob's sha256 digest
+	Size     int64     // size in bytes
+	LastUsed time.Time // last written or used by a build
+}
+
+// CacheInfo returns information about the blob caches used when building the
+// given function: the shared cache (if enabled) followed by the function's
+// own.  Caches which do not exist are omitted.
+func (b *Builder) CacheInfo(f fn.Function) (caches []CacheInfo, err error) {
+	for _, dir := range b.cacheDirs(f) {
+		info, err := readCache(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return caches, err
+		}
+		caches = append(caches, info)
+	}
+	return
+}
+
+// ClearCache removes all blobs from the caches used when building the given
+// function, and the function's cached exe layers.  Blobs locked by an active
+// build are left in place.
+func (b *Builder) ClearCache(f fn.Function) error {
+	if f.Root != "" {
+		job := buildJob{function: f, options: b.options}
+		if b.verbose {
+			fmt.Fprintf(os.Stderr, "rm -rf %v\n", job.exeCacheDir())
+		}
+		if err := os.RemoveAll(job.exeCacheDir()); err != nil {
+			return err
+		}
+	}
+	for _, dir := range b.cacheDirs(f) {
+		info, err := readCache(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, blob := range info.Blobs {
+			if _, err = os.Stat(filepath.Join(dir, blob.Digest+".lock")); err == nil {
+				continue
+			}
+			if b.verbose {
+				fmt.Fprintf(os.Stderr, "rm %v\n", filepath.Join(dir, blob.Digest))
+			}
+			if err = os.Remove(filepath.Join(dir, blob.Digest)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cacheDirs returns the shared and function blob cache directories.
+func (b *Builder) cacheDirs(f fn.Function) (dirs []string) {
+	job := buildJob{function: f, options: b.options}
+	if job.sharedCache != "" {
+		dirs = append(dirs, job.cacheDir())
+		job.sharedCache = ""
+	}
+	if f.Root != "" {
+		dirs = append(dirs, job.cacheDir())
+	}
+	return
+}
+
+// readCache reads the blobs of the cache at dir.  Lock and partially written
+// entries are not blobs.
+func readCache(dir string) (info CacheInfo, err error) {
+	info.Dir = dir
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != "" {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return info, err
+		}
+		info.Blobs = append(info.Blobs, CachedBlob{Digest: e.Name(), Size: fi.Size(), LastUsed: fi.ModTime()})
+		info.Size += fi.Size()
+	}
+	sort.Slice(info.Blobs, func(i, j int) bool {
+		return info.Blobs[i].LastUsed.After(info.Blobs[j].LastUsed)
+	})
+	return
+}
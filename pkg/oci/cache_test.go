@@ -0,0 +1,156 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"golang.org/x/sync/errgroup"
+
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci/mock"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestBuilder_WarmCache ensures the layers of the base image of each
+// platform are cached without building, such that the base can then be
+// built upon without its registry.
+func TestBuilder_WarmCache(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	registry := mock.NewRegistry()
+	pp := []v1.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}}
+	ref, index, err := registry.SeedBase("library/base", "latest", 1, 2, pp...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.BaseImage = ref
+
+	platforms := []fn.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}}
+	if err = NewBuilder("", false, WithQuiet(true)).WarmCache(context.Background(), f, platforms); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(root, fn.RunDataDir, "builds", "last")); !os.IsNotExist(err) {
+		t.Fatal("expected no build")
+	}
+
+	// Each layer of each platform's image is cached
+	im, err := index.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, desc := range im.Manifests {
+		img, err := index.Image(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range layers {
+			digest, err := l.Digest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = os.Stat(filepath.Join(SharedCacheDir(), digest.Hex)); err != nil {
+				t.Fatalf("expected layer %v of %v to be cached. %v", digest, desc.Platform, err)
+			}
+		}
+	}
+
+	// And so can be built upon without the registry
+	registry.Close()
+	b := NewBuilder("", false, WithQuiet(true), WithBasePullPolicy(PullNever))
+	if err = b.WarmCache(context.Background(), f, platforms); err != nil {
+		t.Fatalf("expected the cached base image. %v", err)
+	}
+}
+
+// Test_writeBaseLayerConcurrent ensures a base layer shared by platforms
+// built concurrently is added to the blobs once, without error.
+func Test_writeBaseLayerConcurrent(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.sharedCache = t.TempDir()
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	layer, err := random.Layer(1024, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var g errgroup.Group
+	for i := 0; i < 8; i++ {
+		g.Go(func() error { return writeBaseLayer(job, layer) })
+	}
+	if err = g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(job.blobsDir(), digest.Hex)); err != nil {
+		t.Fatalf("expected the layer in the blobs. %v", err)
+	}
+}
+
+// Test_writeCachedBlobConcurrent ensures a blob written to the blob cache by
+// concurrent builds, such as the manifest of a shared base, is written once
+// in full, without error.
+func Test_writeCachedBlobConcurrent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	job := buildJob{ctx: ctx, options: options{sharedCache: t.TempDir()}}
+	bb := bytes.Repeat([]byte("manifest"), 512*1024)
+	digest, _, err := v1.SHA256(bytes.NewReader(bb))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var g errgroup.Group
+	for i := 0; i < 32; i++ {
+		g.Go(func() error { return writeCachedBlob(job, digest, bb) })
+	}
+	if err = g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := os.ReadFile(filepath.Join(job.cacheDir(), digest.Hex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cached, bb) {
+		t.Fatal("expected the blob cached in full")
+	}
+	entries, err := os.ReadDir(job.cacheDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the cached blob, got %v", entries)
+	}
+}
@@ -0,0 +1,90 @@
+package oci
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// capabilityXattr is the extended attribute in which Linux stores file
+// capabilities, as a PAX record understood by container runtimes when
+// extracting layers.
+const capabilityXattr = "SCHILY.xattr.security.capability"
+
+// Linux capability numbers (see capability.h)
+var capabilities = map[string]uint{
+	"chown":              0,
+	"dac_override":       1,
+	"dac_read_search":    2,
+	"fowner":             3,
+	"fsetid":             4,
+	"kill":               5,
+	"setgid":             6,
+	"setuid":             7,
+	"setpcap":            8,
+	"linux_immutable":    9,
+	"net_bind_service":   10,
+	"net_broadcast":      11,
+	"net_admin":          12,
+	"net_raw":            13,
+	"ipc_lock":           14,
+	"ipc_owner":          15,
+	"sys_module":         16,
+	"sys_rawio":          17,
+	"sys_chroot":         18,
+	"sys_ptrace":         19,
+	"sys_pacct":          20,
+	"sys_admin":          21,
+	"sys_boot":           22,
+	"sys_nice":           23,
+	"sys_resource":       24,
+	"sys_time":           25,
+	"sys_tty_config":     26,
+	"mknod":              27,
+	"lease":              28,
+	"audit_write":        29,
+	"audit_control":      30,
+	"setfcap":            31,
+	"mac_override":       32,
+	"mac_admin":          33,
+	"syslog":             34,
+	"wake_alarm":         35,
+	"block_suspend":      36,
+	"audit_read":         37,
+	"perfmon":            38,
+	"bpf":                39,
+	"checkpoint_restore": 40,
+}
+
+const (
+	vfsCapRevision2      = 0x02000000
+	vfsCapFlagsEffective = 0x000001
+)
+
+// capabilityData returns the value of the security.capability extended
+// attribute which grants the named capabilities as permitted and effective
+// (the equivalent of "setcap cap_x,cap_y+ep").  Names are case-insensitive
+// and may optionally be prefixed with "cap_", for example "NET_BIND_SERVICE"
+// or "cap_net_bind_service".  Returned is nil if no capabilities are named.
+func capabilityData(names []string) ([]byte, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var permitted [2]uint32
+	for _, name := range names {
+		key := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "cap_")
+		c, ok := capabilities[key]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized capability %q", name)
+		}
+		permitted[c/32] |= 1 << (c % 32)
+	}
+
+	// struct vfs_cap_data (revision 2): magic, then permitted and inheritable
+	// for each of the two 32-bit capability sets.
+	data := make([]byte, 20)
+	binary.LittleEndian.PutUint32(data[0:], vfsCapRevision2|vfsCapFlagsEffective)
+	binary.LittleEndian.PutUint32(data[4:], permitted[0])
+	binary.LittleEndian.PutUint32(data[12:], permitted[1])
+	return data, nil
+}
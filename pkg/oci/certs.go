@@ -0,0 +1,59 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The certificates of the image (its certs layer) are those of the
+// scaffolding: the bundle of root CAs embedded in func
+// (templates/certs/ca-certificates.crt, updated with 'make certs'), which
+// scaffolding copies to the build directory.  Should the scaffolding not
+// provide it, the build host's system bundle is used instead.
+
+// systemCertFiles are the locations of the system CA bundle of common
+// systems, in order of preference (as searched by crypto/x509).
+var systemCertFiles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",                // Debian/Ubuntu/Gentoo etc.
+	"/etc/pki/tls/certs/ca-bundle.crt",                  // Fedora/RHEL 6
+	"/etc/ssl/ca-bundle.pem",                            // OpenSUSE
+	"/etc/pki/tls/cacert.pem",                           // OpenELEC
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem", // CentOS/RHEL 7
+	"/etc/ssl/cert.pem",                                 // Alpine Linux, macOS
+}
+
+// ensureCerts ensures the certificates bundle exists at path (that of the
+// scaffolding), writing that of the build host's system if it does not.
+// The system bundle is that of SSL_CERT_FILE, if set, or the first of
+// systemCertFiles found.
+func ensureCerts(job buildJob, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	searched := systemCertFiles
+	if file := os.Getenv("SSL_CERT_FILE"); file != "" {
+		searched = []string{file}
+	}
+	for _, file := range searched {
+		bb, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return ErrCertsNotFound{Path: path, Searched: searched, Err: err}
+		}
+		if _, err = parseCABundle(bb, true); err != nil {
+			return ErrCertsNotFound{Path: path, Searched: searched, Err: fmt.Errorf("invalid bundle %v. %w", file, err)}
+		}
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "cp %v %v\n", file, rel(job.buildDir(), path))
+		}
+		if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return err
+		}
+		return os.WriteFile(path, bb, 0644)
+	}
+	return ErrCertsNotFound{Path: path, Searched: searched}
+}
@@ -0,0 +1,57 @@
+package oci
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestEnsureCerts ensures the certificates of the scaffolding are used when
+// present, that those of the system are written when absent, and that an
+// ErrCertsNotFound is returned when there are neither.
+func TestEnsureCerts(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		path   = filepath.Join(dir, "build", "ca-certificates.crt")
+		system = filepath.Join(dir, "system.pem")
+		ca     = newTestCA(t, "system")
+	)
+	if err := os.WriteFile(system, ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+	job := buildJob{}
+
+	// Absent: those of the system are written
+	t.Setenv("SSL_CERT_FILE", system)
+	if err := ensureCerts(job, path); err != nil {
+		t.Fatal(err)
+	}
+	if bb, err := os.ReadFile(path); err != nil || string(bb) != string(ca) {
+		t.Fatalf("expected the system certificates to be written, got %q (%v)", bb, err)
+	}
+
+	// Present: those of the scaffolding are used as they are
+	if err := os.WriteFile(path, []byte("scaffolding"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ensureCerts(job, path); err != nil {
+		t.Fatal(err)
+	}
+	if bb, _ := os.ReadFile(path); string(bb) != "scaffolding" {
+		t.Fatalf("expected the scaffolding's certificates to be unchanged, got %q", bb)
+	}
+
+	// Neither
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSL_CERT_FILE", filepath.Join(dir, "missing.pem"))
+	var e ErrCertsNotFound
+	if err := ensureCerts(job, path); !errors.As(err, &e) {
+		t.Fatalf("expected ErrCertsNotFound, got %v", err)
+	}
+	if e.Path != path || len(e.Searched) != 1 {
+		t.Fatalf("expected the path and the system bundle searched, got %+v", e)
+	}
+}
@@ -0,0 +1,116 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ChecksumsFile is the name of the checksums of a build's artifacts, written
+// to the build directory (see WithChecksums).
+const ChecksumsFile = "checksums.txt"
+
+// WithChecksums writes the SHA-256 checksums of the build's artifacts to
+// checksums.txt in the build directory (.func/builds/last), for example to be
+// signed or archived: those of the binaries of each platform (result/f.*, Go
+// functions only), and of the OCI layout's index (oci/index.json), whose
+// checksum is the digest of the image index as pushed.  The file is in the
+// format of sha256sum, such that it may be verified with sha256sum -c from
+// the build directory.
+func WithChecksums(checksums bool) BuilderOpt {
+	return func(b *Builder) {
+		b.checksums = checksums
+	}
+}
+
+// writeChecksums writes the checksums of the job's artifacts, if enabled.
+func writeChecksums(job buildJob) error {
+	if !job.checksums {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(job.buildDir(), "result", "f.*"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	paths = append(paths, filepath.Join(job.ociDir(), "index.json"))
+
+	var b bytes.Buffer
+	for _, path := range paths {
+		sum, err := sha256File(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(job.buildDir(), path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%x  %v\n", sum, filepath.ToSlash(name))
+	}
+	target := filepath.Join(job.buildDir(), ChecksumsFile)
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "Writing checksums of %v artifacts to %v\n", len(paths), target)
+	}
+	return os.WriteFile(target, b.Bytes(), 0644)
+}
+
+// sha256File returns the SHA-256 checksum of the file at path.
+func sha256File(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, file); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// extractExe writes the function binary (/func/f) of the exe layer tarball
+// at source to target, such as when the layer is reused from the cache and
+// the binary is therefore not built.
+func extractExe(source, target string) error {
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gr, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("no function binary in %v", source)
+		} else if err != nil {
+			return err
+		}
+		if header.Name != "/func/f" {
+			continue
+		}
+		if err = os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return err
+		}
+		exe, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(exe, tr); err != nil {
+			exe.Close()
+			return err
+		}
+		return exe.Close()
+	}
+}
@@ -0,0 +1,99 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// Test_writeChecksums ensures the checksums of the binaries and the index are
+// written in the format of sha256sum, relative to the build directory, and
+// only when enabled.
+func Test_writeChecksums(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+
+	files := map[string]string{
+		"result/f.linux.arm64": "arm64",
+		"result/f.linux.amd64": "amd64",
+		"oci/index.json":       "{}",
+	}
+	for path, content := range files {
+		path = filepath.Join(job.buildDir(), filepath.FromSlash(path))
+		if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := filepath.Join(job.buildDir(), ChecksumsFile)
+
+	// Disabled by default
+	if err = writeChecksums(job); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("expected no checksums by default, got %v", err)
+	}
+
+	job.checksums = true
+	if err = writeChecksums(job); err != nil {
+		t.Fatal(err)
+	}
+	checksums, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ""
+	for _, path := range []string{"result/f.linux.amd64", "result/f.linux.arm64", "oci/index.json"} {
+		expected += fmt.Sprintf("%x  %v\n", sha256.Sum256([]byte(files[path])), path)
+	}
+	if string(checksums) != expected {
+		t.Fatalf("unexpected checksums\nexpected:\n%v\ngot:\n%v", expected, string(checksums))
+	}
+}
+
+// Test_extractExe ensures the function binary is extracted from an exe layer,
+// as when reused from the cache.
+func Test_extractExe(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "f")
+	if err := os.WriteFile(exe, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	layer := filepath.Join(dir, "layer.tar.gz")
+	if err := goExeTarball(exe, layer, nil, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(dir, "result", "f.linux.amd64")
+	if err := extractExe(layer, target); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "binary" {
+		t.Fatalf("unexpected binary %q", content)
+	}
+}
@@ -0,0 +1,46 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Clean removes all build state of the function, such as to troubleshoot a
+// build from scratch: its builds (by-hash), their PID links (by-pid), the
+// images of single platforms (by-platform) and the link to its last build
+// (last), both within the work directory, if any, and the function's .func
+// directory.  Blob caches are not removed (see ClearCache).  Fails with
+// ErrBuildInProgress while a build of the function is in progress.
+func (b *Builder) Clean(f fn.Function) error {
+	job := buildJob{function: f, options: b.options}
+	if pid, ok := activeBuild(job); ok {
+		return ErrBuildInProgress{filepath.Join(job.pidsDir(), pid)}
+	}
+	dirs := []string{filepath.Join(job.dataDir(), "builds")}
+	if own := filepath.Join(f.Root, fn.RunDataDir, "builds"); own != dirs[0] {
+		dirs = append(dirs, own) // last, of a relocated work directory
+	}
+	for _, dir := range dirs {
+		if b.verbose {
+			fmt.Fprintf(os.Stderr, "rm -rf %v\n", dir)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeBuild returns the PID of a process building the function, if any.
+func activeBuild(job buildJob) (pid string, ok bool) {
+	dd, _ := os.ReadDir(job.pidsDir())
+	for _, d := range dd {
+		if processExists(d.Name()) {
+			return d.Name(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,66 @@
+package oci
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestBuilder_Clean ensures all builds of the function are removed, leaving
+// its blob cache, unless a build is in progress.
+func TestBuilder_Clean(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	builds := filepath.Join(root, fn.RunDataDir, "builds")
+	cache := filepath.Join(root, fn.RunDataDir, "blob-cache")
+	for _, dir := range []string{
+		filepath.Join(builds, "by-hash", "a"),
+		filepath.Join(builds, "by-pid"),
+		filepath.Join(builds, "by-platform", "linux.amd64"),
+		cache,
+	} {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.Symlink(filepath.Join(builds, "by-hash", "a"), filepath.Join(builds, "last")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A build in progress is not removed
+	active := filepath.Join(builds, "by-pid", strconv.Itoa(os.Getpid()))
+	if err = os.Symlink(filepath.Join("..", "by-hash", "a"), active); err != nil {
+		t.Fatal(err)
+	}
+	b := NewBuilder("", false)
+	var errInProgress ErrBuildInProgress
+	if err = b.Clean(f); !errors.As(err, &errInProgress) {
+		t.Fatalf("expected ErrBuildInProgress, got %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(builds, "by-hash", "a")); err != nil {
+		t.Fatalf("expected the build in progress to remain. %v", err)
+	}
+
+	if err = os.Remove(active); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Clean(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Lstat(builds); !os.IsNotExist(err) {
+		t.Fatalf("expected the builds to be removed, got %v", err)
+	}
+	if _, err = os.Stat(cache); err != nil {
+		t.Fatalf("expected the blob cache to remain. %v", err)
+	}
+}
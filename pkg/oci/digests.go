@@ -0,0 +1,227 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Digest algorithms of exported OCI layouts.
+const (
+	DigestSHA256 = "sha256" // default
+	DigestSHA512 = "sha512"
+)
+
+// WithDigestAlgorithm sets the digest algorithm of the exported OCI layouts
+// only (see WithOCIOutput and WithBundle): DigestSHA256 (the default) or
+// DigestSHA512, for registries and policies which require it.  With SHA-512
+// their blobs are named blobs/sha512/{hex} and the digest of each descriptor
+// (of the index, manifests, configs and layers) is of SHA-512, converted from
+// the build's layout on export (see convertLayout).  The build itself is not
+// affected: its layout, the blob cache (keyed by the digests of registries)
+// and the image pushed remain of SHA-256, which is all the registry client
+// supports.
+func WithDigestAlgorithm(algorithm string) BuilderOpt {
+	return func(b *Builder) {
+		b.digestAlgorithm = algorithm
+	}
+}
+
+// ValidateDigestAlgorithm returns an error if the digest algorithm is not
+// known.  Empty is the default (sha256).
+func ValidateDigestAlgorithm(algorithm string) error {
+	switch algorithm {
+	case "", DigestSHA256, DigestSHA512:
+		return nil
+	}
+	return fmt.Errorf("invalid digest algorithm %q: must be %q or %q", algorithm, DigestSHA256, DigestSHA512)
+}
+
+// digestAlgorithm of the job's exported layouts.
+func (j buildJob) digestAlgorithm() string {
+	if j.options.digestAlgorithm == "" {
+		return DigestSHA256
+	}
+	return j.options.digestAlgorithm
+}
+
+// newHasher returns a hash of the digest algorithm.
+func newHasher(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case DigestSHA256:
+		return sha256.New(), nil
+	case DigestSHA512:
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported digest algorithm %q", algorithm)
+}
+
+// convertLayout writes the OCI layout at dir (of SHA-256) to the directory
+// dest with digests of the given algorithm: each blob reachable from its
+// index is written once, named by its digest, with the descriptors of the
+// manifests and indices it contains rewritten accordingly.  Returns the size
+// of each blob written by digest.  The subject of an index, which is not of
+// the layout, is left as is, as is its artifactType.
+func convertLayout(dir, dest, algorithm string) (blobs map[v1.Hash]int64, err error) {
+	bb, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return
+	}
+	var index artifactIndex
+	if err = json.Unmarshal(bb, &index); err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Join(dest, "blobs", algorithm), os.ModePerm); err != nil {
+		return
+	}
+	c := layoutConverter{
+		dir:       dir,
+		dest:      dest,
+		algorithm: algorithm,
+		converted: map[v1.Hash]v1.Descriptor{},
+		blobs:     map[v1.Hash]int64{},
+	}
+	if err = c.index(&index.IndexManifest); err != nil {
+		return
+	}
+	if bb, err = json.Marshal(index); err != nil {
+		return
+	}
+	if err = os.WriteFile(filepath.Join(dest, "index.json"), bb, 0644); err != nil {
+		return
+	}
+	if err = copyFile(filepath.Join(dir, "oci-layout"), filepath.Join(dest, "oci-layout")); err != nil {
+		return
+	}
+	return c.blobs, nil
+}
+
+// layoutConverter converts the blobs of a layout, each once.
+type layoutConverter struct {
+	dir, dest, algorithm string
+	converted            map[v1.Hash]v1.Descriptor // by digest in dir
+	blobs                map[v1.Hash]int64         // written to dest
+}
+
+// index converts the descriptors of the index, and the blobs they describe.
+func (c layoutConverter) index(index *v1.IndexManifest) (err error) {
+	for i, d := range index.Manifests {
+		if index.Manifests[i], err = c.descriptor(d); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// descriptor converts the blob of the descriptor, returning the descriptor
+// of the blob converted.  A manifest or index is rewritten with its
+// descriptors converted, while any other blob is copied as it is.
+func (c layoutConverter) descriptor(d v1.Descriptor) (v1.Descriptor, error) {
+	if converted, ok := c.converted[d.Digest]; ok {
+		return converted, nil
+	}
+	var (
+		converted = d
+		path      = blobPath(c.dir, d.Digest)
+		err       error
+	)
+	switch {
+	case d.MediaType.IsIndex():
+		converted.Digest, converted.Size, err = c.writeJSON(path, func(bb []byte) (any, error) {
+			var index artifactIndex
+			if err := json.Unmarshal(bb, &index); err != nil {
+				return nil, err
+			}
+			return index, c.index(&index.IndexManifest)
+		})
+	case d.MediaType.IsImage():
+		converted.Digest, converted.Size, err = c.writeJSON(path, func(bb []byte) (any, error) {
+			manifest, err := v1.ParseManifest(bytes.NewReader(bb))
+			if err != nil {
+				return nil, err
+			}
+			if manifest.Config, err = c.descriptor(manifest.Config); err != nil {
+				return nil, err
+			}
+			for i, l := range manifest.Layers {
+				if manifest.Layers[i], err = c.descriptor(l); err != nil {
+					return nil, err
+				}
+			}
+			return manifest, nil
+		})
+	default:
+		converted.Digest, converted.Size, err = c.copyBlob(path)
+	}
+	if err != nil {
+		return d, fmt.Errorf("error converting blob %v. %w", d.Digest, err)
+	}
+	c.converted[d.Digest] = converted
+	return converted, nil
+}
+
+// writeJSON writes the manifest or index at path as converted by fn.
+func (c layoutConverter) writeJSON(path string, fn func([]byte) (any, error)) (digest v1.Hash, size int64, err error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	v, err := fn(bb)
+	if err != nil {
+		return
+	}
+	if bb, err = json.Marshal(v); err != nil {
+		return
+	}
+	return c.writeBlob(bytes.NewReader(bb))
+}
+
+// copyBlob writes the blob at path.
+func (c layoutConverter) copyBlob(path string) (digest v1.Hash, size int64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	return c.writeBlob(file)
+}
+
+// writeBlob writes the content of r as a blob named by its digest.
+func (c layoutConverter) writeBlob(r io.Reader) (digest v1.Hash, size int64, err error) {
+	h, err := newHasher(c.algorithm)
+	if err != nil {
+		return
+	}
+	dir := filepath.Join(c.dest, "blobs", c.algorithm)
+	tmp, err := os.CreateTemp(dir, ".blob.*")
+	if err != nil {
+		return
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }() // if not renamed
+	if err = tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return
+	}
+	if size, err = io.Copy(io.MultiWriter(tmp, h), r); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	digest = v1.Hash{Algorithm: c.algorithm, Hex: hex.EncodeToString(h.Sum(nil))}
+	if err = os.Rename(tmp.Name(), filepath.Join(dir, digest.Hex)); err != nil {
+		return
+	}
+	c.blobs[digest] = size
+	return
+}
@@ -0,0 +1,194 @@
+package oci
+
+import (
+	"archive/tar"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// TestExportLayout_SHA512 ensures a layout exported with SHA-512 digests has
+// its blobs named by, and each descriptor of, their SHA-512 digest, and that
+// the artifactType of its index is preserved.
+func TestExportLayout_SHA512(t *testing.T) {
+	dir := newTestLayout(t)
+	bb, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index artifactIndex
+	if err = json.Unmarshal(bb, &index); err != nil {
+		t.Fatal(err)
+	}
+	index.ArtifactType = "application/vnd.example.function.v1"
+	if bb, err = json.Marshal(index); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "index.json"), bb, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "oci")
+	if err = exportLayout(dir, dest, DigestSHA512); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "blobs", "sha256")); !os.IsNotExist(err) {
+		t.Fatalf("expected no blobs of sha256, got %v", err)
+	}
+
+	// 2 manifests, 2 configs, 1 shared and 2 own layers, each resolving by
+	// its SHA-512 digest
+	seen := map[string]bool{}
+	var walk func(path string)
+	walk = func(path string) {
+		bb, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m testManifest
+		if err = json.Unmarshal(bb, &m); err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range append(append(m.Manifests, m.Layers...), m.Config) {
+			if d.Digest == "" {
+				continue
+			}
+			algorithm, encoded, _ := strings.Cut(d.Digest, ":")
+			if algorithm != DigestSHA512 {
+				t.Fatalf("expected a sha512 digest, got %v", d.Digest)
+			}
+			blob := filepath.Join(dest, "blobs", algorithm, encoded)
+			content, err := os.ReadFile(blob)
+			if err != nil {
+				t.Fatalf("expected blob %v. %v", d.Digest, err)
+			}
+			if sum := sha512.Sum512(content); hex.EncodeToString(sum[:]) != encoded || int64(len(content)) != d.Size {
+				t.Fatalf("blob %v does not match its descriptor", d.Digest)
+			}
+			seen[d.Digest] = true
+			if types.MediaType(d.MediaType).IsImage() || types.MediaType(d.MediaType).IsIndex() {
+				walk(blob)
+			}
+		}
+	}
+	walk(filepath.Join(dest, "index.json"))
+	if len(seen) != 7 {
+		t.Fatalf("expected 7 blobs, got %v", len(seen))
+	}
+	if bb, err = os.ReadFile(filepath.Join(dest, "index.json")); err != nil {
+		t.Fatal(err)
+	}
+	var exported struct {
+		ArtifactType string `json:"artifactType"`
+	}
+	if err = json.Unmarshal(bb, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if exported.ArtifactType != index.ArtifactType {
+		t.Fatalf("expected artifactType %q, got %q", index.ArtifactType, exported.ArtifactType)
+	}
+}
+
+// TestExportBundle_SHA512 ensures a bundle exported with SHA-512 digests
+// stores its blobs by their SHA-512 digest, and is verified.
+func TestExportBundle_SHA512(t *testing.T) {
+	dir := newTestLayout(t)
+	dest := filepath.Join(t.TempDir(), "bundle.tar")
+	if err := exportBundle(dir, dest, DigestSHA512); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var (
+		tr = tar.NewReader(file)
+		n  int
+	)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(header.Name, "blobs/") && !strings.HasPrefix(header.Name, "blobs/sha512/") {
+			t.Fatalf("expected only blobs of sha512, got %v", header.Name)
+		}
+		if strings.HasPrefix(header.Name, "blobs/") {
+			n++
+		}
+	}
+	if n != 7 {
+		t.Fatalf("expected 7 blobs in the bundle, got %v", n)
+	}
+}
+
+// TestValidateDigestAlgorithm ensures only known algorithms are valid.
+func TestValidateDigestAlgorithm(t *testing.T) {
+	for _, a := range []string{"", DigestSHA256, DigestSHA512} {
+		if err := ValidateDigestAlgorithm(a); err != nil {
+			t.Errorf("expected %q to be valid, got %v", a, err)
+		}
+	}
+	if err := ValidateDigestAlgorithm("md5"); err == nil {
+		t.Error("expected md5 to be invalid")
+	}
+}
+
+// testManifest is a manifest or index, of any digest algorithm.
+type testManifest struct {
+	Manifests []testDescriptor `json:"manifests"`
+	Config    testDescriptor   `json:"config"`
+	Layers    []testDescriptor `json:"layers"`
+}
+
+type testDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// newTestLayout writes an OCI layout whose index, as that of a build, is of
+// two platform images sharing a base layer, each with its own layer.
+func newTestLayout(t *testing.T) string {
+	t.Helper()
+	base, err := random.Layer(1024, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := v1.ImageIndex(empty.Index)
+	for _, arch := range []string{"amd64", "arm64"} {
+		own, err := random.Layer(512, types.OCILayer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := mutate.AppendLayers(empty.Image, base, own)
+		if err != nil {
+			t.Fatal(err)
+		}
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: arch}},
+		})
+	}
+	dir := t.TempDir()
+	if _, err = layout.Write(dir, index); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
@@ -1,6 +1,12 @@
 package oci
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
 
 // BuildErr indicates a general build error occurred.
 type BuildErr struct {
@@ -18,3 +24,294 @@ type ErrBuildInProgress struct {
 func (e ErrBuildInProgress) Error() string {
 	return fmt.Sprintf("a build for this function is associated with an active PID appears to be already in progress %v", e.Dir)
 }
+
+// ErrNoFunction indicates the root of the function to build does not exist
+// or does not contain an initialized function.  Err is the reason.
+type ErrNoFunction struct {
+	Root string
+	Err  error
+}
+
+func (e ErrNoFunction) Error() string {
+	return fmt.Sprintf("no function to build at '%v'. %v", e.Root, e.Err)
+}
+
+func (e ErrNoFunction) Unwrap() error {
+	return e.Err
+}
+
+// ErrNoSpace indicates the device of the build's directory, or of its blob
+// cache, ran out of space.  Dir is that of the builds or of the cache.
+type ErrNoSpace struct {
+	Dir string
+	Err error
+}
+
+func (e ErrNoSpace) Error() string {
+	return fmt.Sprintf("no space left on the device of %v. %v", e.Dir, e.Err)
+}
+
+func (e ErrNoSpace) Unwrap() error {
+	return e.Err
+}
+
+// ErrUnsupportedRuntime indicates the function's runtime (language) has no
+// language builder in the host builder.
+type ErrUnsupportedRuntime struct {
+	Runtime string
+}
+
+func (e ErrUnsupportedRuntime) Error() string {
+	return fmt.Sprintf("%v functions are not yet supported by the host builder", e.Runtime)
+}
+
+// ErrUnsupportedPlatform indicates the language builder of the function's
+// runtime can not build for a requested platform.
+type ErrUnsupportedPlatform struct {
+	Runtime  string
+	Platform string // os/arch[/variant]
+}
+
+func (e ErrUnsupportedPlatform) Error() string {
+	return fmt.Sprintf("%v functions can not be built for the platform %v by the host builder", e.Runtime, e.Platform)
+}
+
+// ErrMissingTools indicates tools required by the language builder of the
+// function's runtime (see LanguageBuilder.RequiredTools) were not found on
+// the PATH of the build host.  Tools are the missing, each with an install
+// hint where known.
+type ErrMissingTools struct {
+	Runtime string
+	Tools   []string
+}
+
+func (e ErrMissingTools) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v functions require tools which were not found on the PATH: %v", e.Runtime, strings.Join(e.Tools, ", "))
+	for _, t := range e.Tools {
+		if hint, ok := toolHints[filepath.Base(t)]; ok {
+			fmt.Fprintf(&b, "\n  %v: %v", t, hint)
+		}
+	}
+	return b.String()
+}
+
+// ErrIncompleteIndex indicates the index of the built image does not hold
+// exactly one manifest for each requested platform.  Missing are the
+// requested platforms without a manifest, Duplicated those with more than
+// one, and Unexpected the platforms of manifests which were not requested.
+type ErrIncompleteIndex struct {
+	Missing    []string
+	Duplicated []string
+	Unexpected []string
+}
+
+func (e ErrIncompleteIndex) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, fmt.Sprintf("missing %v", strings.Join(e.Missing, ", ")))
+	}
+	if len(e.Duplicated) > 0 {
+		problems = append(problems, fmt.Sprintf("duplicated %v", strings.Join(e.Duplicated, ", ")))
+	}
+	if len(e.Unexpected) > 0 {
+		problems = append(problems, fmt.Sprintf("unexpected %v", strings.Join(e.Unexpected, ", ")))
+	}
+	return fmt.Sprintf("image index does not hold one manifest per platform: %v", strings.Join(problems, "; "))
+}
+
+// ErrScaffold indicates an error writing the scaffolding which wraps the
+// function as a service.
+type ErrScaffold struct {
+	Err error
+}
+
+func (e ErrScaffold) Error() string {
+	return fmt.Sprintf("error scaffolding function. %v", e.Err)
+}
+
+func (e ErrScaffold) Unwrap() error {
+	return e.Err
+}
+
+// ErrCertsNotFound indicates the certificates of the image were neither
+// provided by the scaffolding at Path nor found on the build host, where the
+// system bundles Searched were not found or, if Err, could not be used.
+type ErrCertsNotFound struct {
+	Path     string
+	Searched []string
+	Err      error
+}
+
+func (e ErrCertsNotFound) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("CA certificates not found at %v, nor could those of the system be used. %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("CA certificates not found at %v, nor those of the system at %v", e.Path, strings.Join(e.Searched, ", "))
+}
+
+func (e ErrCertsNotFound) Unwrap() error {
+	return e.Err
+}
+
+// ErrNotReproducible indicates the image, built twice from the same inputs
+// (see WithVerifyReproducible), was not the same: the indices of the First
+// and Second builds differ, as do the Blobs.
+type ErrNotReproducible struct {
+	First  v1.Hash
+	Second v1.Hash
+	Blobs  []BlobDiff
+}
+
+func (e ErrNotReproducible) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "image is not reproducible: built twice, its index %v != %v", e.First, e.Second)
+	for _, d := range e.Blobs {
+		fmt.Fprintf(&b, "\n  %v", d)
+	}
+	return b.String()
+}
+
+// ErrCompileFailed indicates the function failed to compile or its
+// dependencies failed to install.  Output contains the combined output
+// (stdout and stderr) of the failed command.
+type ErrCompileFailed struct {
+	Runtime string
+	Output  string
+	Err     error
+}
+
+func (e ErrCompileFailed) Error() string {
+	if e.Output != "" {
+		return fmt.Sprintf("%v build failed. %v\n%v", e.Runtime, e.Err, e.Output)
+	}
+	return fmt.Sprintf("%v build failed. %v", e.Runtime, e.Err)
+}
+
+func (e ErrCompileFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrHookFailed indicates a command of a build hook (HookPreBuild or
+// HookPostBuild) failed.  Output contains its combined output (stdout and
+// stderr).
+type ErrHookFailed struct {
+	Hook    string
+	Command string
+	Output  string
+	Err     error
+}
+
+func (e ErrHookFailed) Error() string {
+	if e.Output != "" {
+		return fmt.Sprintf("%v command %q failed. %v\n%v", e.Hook, e.Command, e.Err, e.Output)
+	}
+	return fmt.Sprintf("%v command %q failed. %v", e.Hook, e.Command, e.Err)
+}
+
+func (e ErrHookFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrBasePull indicates the base image could not be resolved or pulled.
+type ErrBasePull struct {
+	Ref string
+	Err error
+}
+
+func (e ErrBasePull) Error() string {
+	return fmt.Sprintf("error pulling base image %v. %v", e.Ref, e.Err)
+}
+
+func (e ErrBasePull) Unwrap() error {
+	return e.Err
+}
+
+// ErrBaseNotCached indicates the base image of the platform could not be
+// built upon without pulling it from its registry, as the pull policy is
+// PullNever (see WithBasePullPolicy), for the given Reason.
+type ErrBaseNotCached struct {
+	Ref      string
+	Platform string
+	Reason   string
+}
+
+func (e ErrBaseNotCached) Error() string {
+	return fmt.Sprintf("base image %v (%v) is not cached, and the pull policy is %v: %v", e.Ref, e.Platform, PullNever, e.Reason)
+}
+
+// ErrNotRebasable indicates the image of the last build, or of its Platform
+// if not empty, can not be rebased (see Builder.Rebase) for the given Reason,
+// and is to be built instead.
+type ErrNotRebasable struct {
+	Platform string
+	Reason   string
+}
+
+func (e ErrNotRebasable) Error() string {
+	if e.Platform != "" {
+		return fmt.Sprintf("the image of %v can not be rebased: %v", e.Platform, e.Reason)
+	}
+	return fmt.Sprintf("the image can not be rebased: %v", e.Reason)
+}
+
+// ErrTooManyLayers indicates the image of the Platform has more Layers,
+// those of its base included, than the Max configured (see WithMaxLayers).
+type ErrTooManyLayers struct {
+	Platform string
+	Layers   int
+	Max      int
+}
+
+func (e ErrTooManyLayers) Error() string {
+	return fmt.Sprintf("the image of %v has %v layers, more than the maximum of %v", e.Platform, e.Layers, e.Max)
+}
+
+// ErrBuilderRegistered indicates a language builder is already registered for
+// the runtime.
+type ErrBuilderRegistered struct {
+	Runtime string
+	Builtin bool
+}
+
+func (e ErrBuilderRegistered) Error() string {
+	if e.Builtin {
+		return fmt.Sprintf("the built-in %v builder may only be replaced explicitly (see ReplaceBuilder)", e.Runtime)
+	}
+	return fmt.Sprintf("a %v builder is already registered", e.Runtime)
+}
+
+// ErrInvalidLayer indicates a layer returned by a language builder does not
+// correspond to the blob it wrote.
+type ErrInvalidLayer struct {
+	Runtime string // runtime of the language builder
+	Method  string // method which returned the layer
+	Index   int    // index of the layer as returned
+	Digest  string // digest of the layer's descriptor
+	Reason  string
+}
+
+func (e ErrInvalidLayer) Error() string {
+	return fmt.Sprintf("the %v builder returned an invalid layer from %v (layer %v, %v): %v", e.Runtime, e.Method, e.Index, e.Digest, e.Reason)
+}
+
+// ErrScanFailed indicates the scan of the built image found vulnerabilities
+// of at least the severity, or the scanner failed or is not installed.
+// Output contains the combined output (stdout and stderr) of the scanner.
+type ErrScanFailed struct {
+	Scanner  string
+	Severity string
+	Output   string
+	Err      error
+}
+
+func (e ErrScanFailed) Error() string {
+	if e.Output != "" {
+		return fmt.Sprintf("image scan by %v failed (severity %v or higher). %v\n%v", e.Scanner, e.Severity, e.Err, e.Output)
+	}
+	return fmt.Sprintf("image scan by %v failed (severity %v or higher). %v", e.Scanner, e.Severity, e.Err)
+}
+
+func (e ErrScanFailed) Unwrap() error {
+	return e.Err
+}
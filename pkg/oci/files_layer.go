@@ -0,0 +1,151 @@
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	slashpath "path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// writeFilesLayer writes the layer of the function's files placed at paths of
+// its image outside of /func (build.files), if any.
+func writeFilesLayer(job buildJob) ([]ImageLayer, error) {
+	if len(job.function.Build.Files) == 0 {
+		return []ImageLayer{}, nil
+	}
+	target := filepath.Join(job.buildDir(), "fileslayer.tar.gz")
+	if err := newFilesTarball(job.function.Root, target, job.function.Build.Files, job.zeroTimes, job.verbose); err != nil {
+		return nil, err
+	}
+	layer, err := writeLayer(job, target)
+	if err != nil {
+		return nil, err
+	}
+	return []ImageLayer{layer}, nil
+}
+
+// newFilesTarball writes the files, relative to root, to a gzipped tarball at
+// target at their paths, with their modes and owners.  Links are followed, so
+// must be to within root, and written as the files to which they link.
+// Parent directories of the paths are not written, such that those of the
+// base image are unaffected.
+func newFilesTarball(root, target string, files []fn.FileSpec, zeroTimes, verbose bool) error {
+	if errs := fn.ValidateFiles(files); len(errs) > 0 {
+		return fmt.Errorf("invalid files: %v", strings.Join(errs, "; "))
+	}
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	targetFile, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+
+	gw := gzip.NewWriter(targetFile)
+	defer gw.Close()
+
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	for _, f := range files {
+		source, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(f.Source)))
+		if err != nil {
+			return fmt.Errorf("cannot place file %v at %v: %w", f.Source, f.Path, err)
+		}
+		if !isWithin(root, source) {
+			return fmt.Errorf("cannot place file %v at %v: links must stay within project root", f.Source, f.Path)
+		}
+		if err = writeFile(tw, root, source, f, zeroTimes, verbose); err != nil {
+			return fmt.Errorf("cannot place file %v at %v: %w", f.Source, f.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeFile writes the file or directory source, resolved within root, to tw
+// at the path of f.
+func writeFile(tw *tar.Writer, root, source string, f fn.FileSpec, zeroTimes, verbose bool) error {
+	uid, gid := DefaultUid, DefaultGid
+	if f.Owner != "" {
+		uid, gid, _ = fn.ParseOwner(f.Owner) // validated
+	}
+	var mode int64 = -1 // that of the source
+	if f.Mode != "" {
+		m, _ := strconv.ParseInt(f.Mode, 8, 64) // validated
+		mode = m
+	}
+
+	return filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+
+		// Links are followed, to files within the function
+		resolved := path
+		if d.Type()&fs.ModeSymlink != 0 {
+			if resolved, err = filepath.EvalSymlinks(path); err != nil {
+				return err
+			}
+			if !isWithin(root, resolved) {
+				return fmt.Errorf("link %v must stay within project root", rel)
+			}
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && resolved != path {
+			return fmt.Errorf("link %v to a directory is not supported", rel)
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return fmt.Errorf("%v is not a regular file or directory", rel)
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = slashpath.Join(f.Path, filepath.ToSlash(rel))
+		header.Uid, header.Gid = uid, gid
+		header.Uname, header.Gname = "", ""
+		zeroHeaderTimes(header, zeroTimes)
+		longNames(header)
+		if info.IsDir() {
+			header.Mode = 0755
+		} else if mode >= 0 {
+			header.Mode = mode
+		}
+		largeFiles(header)
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "→ %v \n", header.Name)
+		}
+		if info.IsDir() {
+			return nil
+		}
+		file, err := os.Open(resolved)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+}
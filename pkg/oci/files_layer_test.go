@@ -0,0 +1,144 @@
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// Test_newFilesTarball ensures the function's files are placed at their paths
+// with their modes and owners, links are followed, and the parents of the
+// paths are not written.
+func Test_newFilesTarball(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"config.yaml":       "key: value",
+		"static/index.html": "<html/>",
+	} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink("../config.yaml", filepath.Join(root, "static", "config.yaml")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := filepath.Join(t.TempDir(), "fileslayer.tar.gz")
+	files := []fn.FileSpec{
+		{Source: "config.yaml", Path: "/etc/myapp/config.yaml", Mode: "0640", Owner: "0:0"},
+		{Source: "static", Path: "/srv/static"},
+	}
+	if err := newFilesTarball(root, target, files, false, false); err != nil {
+		t.Fatal(err)
+	}
+	headers := readTarball(t, target)
+
+	config := headers["/etc/myapp/config.yaml"]
+	if config == nil || config.Mode != 0640 || config.Uid != 0 || config.Gid != 0 || config.Size != int64(len("key: value")) {
+		t.Fatalf("expected config.yaml at /etc/myapp with mode 0640 owned by 0:0, got %v", config)
+	}
+	dir := headers["/srv/static"]
+	if dir == nil || dir.Mode != 0755 || dir.Uid != DefaultUid || dir.Gid != DefaultGid {
+		t.Fatalf("expected /srv/static with mode 0755 owned by the function's user, got %v", dir)
+	}
+	index := headers["/srv/static/index.html"]
+	if index == nil || index.Mode != 0600 || index.Uid != DefaultUid {
+		t.Fatalf("expected index.html with the mode of its source, got %v", index)
+	}
+	if runtime.GOOS != "windows" {
+		if link := headers["/srv/static/config.yaml"]; link == nil || link.Typeflag != tar.TypeReg || link.Size != int64(len("key: value")) {
+			t.Fatalf("expected the link to be written as the file to which it links, got %v", link)
+		}
+	}
+	for _, parent := range []string{"/etc", "/etc/myapp", "/srv"} {
+		if headers[parent] != nil {
+			t.Fatalf("expected the parent %v not to be written", parent)
+		}
+	}
+}
+
+// Test_newFilesTarballEscape ensures files may not be placed from outside the
+// function, including through links, nor at protected paths.
+func Test_newFilesTarballEscape(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "config.yaml"), []byte(""), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err := os.Symlink(outside, filepath.Join(root, "secret")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, files := range [][]fn.FileSpec{
+		{{Source: "secret", Path: "/etc/myapp/secret"}},
+		{{Source: "../secret", Path: "/etc/myapp/secret"}},
+		{{Source: "config.yaml", Path: "/etc/passwd"}},
+		{{Source: "missing.yaml", Path: "/etc/myapp/config.yaml"}},
+	} {
+		target := filepath.Join(t.TempDir(), "fileslayer.tar.gz")
+		if err := newFilesTarball(root, target, files, false, false); err == nil {
+			t.Fatalf("expected placing %v to fail", files)
+		}
+	}
+}
+
+// TestBuilder_FilesLayer ensures a function with files placed in its image is
+// built with a layer of them.
+func TestBuilder_FilesLayer(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(root, "config.yaml"), []byte("key: value"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f.Build.Files = []fn.FileSpec{{Source: "config.yaml", Path: "/etc/myapp/config.yaml"}}
+
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.languageBuilder = NewTestLanguageBuilder()
+	job.keepTars = true
+	job.quiet = true
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+	if err = scaffold(job); err != nil {
+		t.Fatal(err)
+	}
+	if err = containerize(job); err != nil {
+		t.Fatal(err)
+	}
+
+	headers := readTarball(t, filepath.Join(job.buildDir(), "fileslayer.tar.gz"))
+	if headers["/etc/myapp/config.yaml"] == nil {
+		t.Fatalf("expected config.yaml in the files layer, got %v", headers)
+	}
+	if _, err = os.Lstat(filepath.Join(job.blobsByNameDir(), "files")); err != nil {
+		t.Fatalf("expected the files layer to be written as a blob. %v", err)
+	}
+}
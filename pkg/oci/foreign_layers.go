@@ -0,0 +1,82 @@
+package oci
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// WithForeignLayers marks the base image layers of the given digests as
+// foreign layers (types.DockerForeignLayer), to be fetched from the given URL
+// rather than from the registry to which the image is pushed.  This avoids
+// repeatedly pushing a large base in bandwidth-constrained environments, but
+// requires that the URL serve the layer to anything which pulls the image.
+// Foreign layers are not pushed.  Each digest must be that of a layer of the
+// base image of at least one of the platforms built.
+func WithForeignLayers(layers map[string]string) BuilderOpt {
+	return func(b *Builder) {
+		b.foreignLayers = layers
+	}
+}
+
+// ValidateForeignLayers returns an error if any of the keys is not a digest
+// or any of the values is not an http(s) URL.
+func ValidateForeignLayers(layers map[string]string) error {
+	for d, u := range layers {
+		if _, err := v1.NewHash(d); err != nil {
+			return fmt.Errorf("invalid foreign layer digest %q. %w", d, err)
+		}
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid foreign layer URL %q: must be an http or https URL", u)
+		}
+	}
+	return nil
+}
+
+// ParseForeignLayers parses foreign layers in the form digest=url, as
+// provided on the command line.
+func ParseForeignLayers(ll []string) (map[string]string, error) {
+	layers := map[string]string{}
+	for _, l := range ll {
+		d, u, ok := strings.Cut(l, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid foreign layer %q: must be in the form digest=url", l)
+		}
+		layers[d] = u
+	}
+	return layers, ValidateForeignLayers(layers)
+}
+
+// foreignLayers returns the layer descriptors with those configured as
+// foreign marked as such, with their URL, as a new slice.
+func foreignLayers(job buildJob, dd []v1.Descriptor) []v1.Descriptor {
+	layers := make([]v1.Descriptor, len(dd))
+	for i, d := range dd {
+		if u, ok := job.options.foreignLayers[d.Digest.String()]; ok {
+			d.MediaType = types.DockerForeignLayer
+			d.URLs = []string{u}
+		}
+		layers[i] = d
+	}
+	return layers
+}
+
+// checkForeignLayers returns an error if any layer configured as foreign is
+// not among the given base image layers (of all platforms), such that a
+// mistaken digest is not silently ignored.
+func checkForeignLayers(job buildJob, base map[v1.Hash]bool) error {
+	for d := range job.options.foreignLayers {
+		h, err := v1.NewHash(d)
+		if err != nil {
+			return err
+		}
+		if !base[h] {
+			return fmt.Errorf("foreign layer %v is not a layer of the base image", d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package oci
+
+import (
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestParseForeignLayers(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name    string
+		layers  []string
+		wantErr bool
+	}{
+		{"valid", []string{digest + "=https://cdn.example.com/base.tar.gz"}, false},
+		{"missing url", []string{digest}, true},
+		{"invalid digest", []string{"abc=https://cdn.example.com/base.tar.gz"}, true},
+		{"invalid url", []string{digest + "=cdn.example.com/base.tar.gz"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseForeignLayers(tt.layers); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestForeignLayers ensures only the configured base layers are marked as
+// foreign, with their URL, and that a configured layer not in the base is an
+// error.
+func TestForeignLayers(t *testing.T) {
+	foreign := v1.Hash{Algorithm: "sha256", Hex: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"}
+	other := v1.Hash{Algorithm: "sha256", Hex: "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"}
+	job := buildJob{}
+	job.foreignLayers = map[string]string{foreign.String(): "https://cdn.example.com/base.tar.gz"}
+
+	base := []v1.Descriptor{
+		{MediaType: types.DockerLayer, Digest: foreign},
+		{MediaType: types.DockerLayer, Digest: other},
+	}
+	layers := foreignLayers(job, base)
+	if layers[0].MediaType != types.DockerForeignLayer || len(layers[0].URLs) != 1 || layers[0].URLs[0] != "https://cdn.example.com/base.tar.gz" {
+		t.Fatalf("expected layer to be foreign, got %v", layers[0])
+	}
+	if layers[1].MediaType != types.DockerLayer || len(layers[1].URLs) != 0 {
+		t.Fatalf("expected layer to be unchanged, got %v", layers[1])
+	}
+	if base[0].MediaType != types.DockerLayer {
+		t.Fatal("expected the given layers to be unmodified")
+	}
+
+	if err := checkForeignLayers(job, map[v1.Hash]bool{foreign: true, other: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkForeignLayers(job, map[v1.Hash]bool{other: true}); err == nil {
+		t.Fatal("expected error for a foreign layer not in the base")
+	}
+}
@@ -3,17 +3,22 @@ package oci
 import (
 	"archive/tar"
 	"compress/gzip"
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	slashpath "path"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	fn "knative.dev/func/pkg/functions"
 )
 
 type goBuilder struct{}
@@ -23,59 +28,107 @@ func (b goBuilder) Base(customImage string) string {
 	return customImage
 }
 
-func (b goBuilder) Configure(_ buildJob, _ v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
+func (b goBuilder) Configure(_ BuildContext, _ v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
 	// 二进制文件放入 /func 目录中,直接执行
 	cf.Config.Cmd = []string{"/func/f"}
 	cf.Config.Env = append(cf.Config.Env, "LISTEN_ADDRESS=[::]:8080")
 	return cf, nil
 }
 
-func (b goBuilder) WriteShared(_ buildJob) ([]imageLayer, error) {
-	return []imageLayer{}, nil // 没有共享依赖生成在构建时
+// Compiled Go functions are a self-contained binary (see CompiledBuilder).
+func (b goBuilder) Compiled() bool {
+	return true
 }
 
-// WritePlatform 创建平台特定层
-// 使用交叉编译生成静态链接的二进制文件，并打包成tar文件
-func (b goBuilder) WritePlatform(cfg buildJob, p v1.Platform) (layers []imageLayer, err error) {
-	var desc v1.Descriptor
-	var layer v1.Layer
+// goArchitectures are the architectures for which Go cross-compiles linux
+// binaries, with the variants (GOARM, GOAMD64) of each which are supported.
+var goArchitectures = map[string][]string{
+	"386":      nil,
+	"amd64":    {"v1", "v2", "v3", "v4"},
+	"arm":      {"v5", "v6", "v7"},
+	"arm64":    {"v8"},
+	"loong64":  nil,
+	"mips":     nil,
+	"mipsle":   nil,
+	"mips64":   nil,
+	"mips64le": nil,
+	"ppc64":    nil,
+	"ppc64le":  nil,
+	"riscv64":  nil,
+	"s390x":    nil,
+}
 
-	// 1) 交叉编译
-	exe, err := goBuild(cfg, p)
-	if err != nil {
-		return
+// SupportsPlatform returns true for linux on architectures to which Go
+// cross-compiles (see PlatformBuilder).
+func (b goBuilder) SupportsPlatform(p v1.Platform) bool {
+	variants, ok := goArchitectures[p.Architecture]
+	if p.OS != "linux" || !ok {
+		return false
 	}
+	return p.Variant == "" || slices.Contains(variants, p.Variant)
+}
 
-	// 2) 打包可执行文件
-	target := filepath.Join(cfg.buildDir(), fmt.Sprintf("execlayer.%v.%v.tar.gz", p.OS, p.Architecture))
-	if err = goExeTarball(exe, target, cfg.verbose); err != nil {
-		return
-	}
+// RequiredTools is go, or that of FUNC_GO (see LanguageBuilder).
+func (b goBuilder) RequiredTools() []string {
+	return []string{goBin()}
+}
 
-	// 3) 转换为OCI层
-	if layer, err = tarball.LayerFromFile(target); err != nil {
-		return
-	}
+func (b goBuilder) WriteShared(_ BuildContext) ([]ImageLayer, error) {
+	return []ImageLayer{}, nil // 没有共享依赖生成在构建时
+}
 
-	// Descriptor
-	if desc, err = newDescriptor(layer); err != nil {
+// WritePlatform 创建平台特定层
+// 使用交叉编译生成静态链接的二进制文件，并打包成tar文件
+func (b goBuilder) WritePlatform(cfg BuildContext, p v1.Platform) (layers []ImageLayer, err error) {
+	target := filepath.Join(cfg.buildDir(), fmt.Sprintf("execlayer.%v.tar.gz", goExeName(p)))
+
+	// 1) Go源码及构建参数未变化时复用缓存的可执行文件层
+	key, err := goExeKey(cfg.buildJob, p)
+	if err != nil {
 		return
 	}
-	desc.Platform = &p
+	cached := cfg.exeCachePath(p, key)
+	if _, err = os.Stat(cached); err == nil {
+		if cfg.verbose {
+			fmt.Fprintf(os.Stderr, "Reusing unchanged %v from %v\n", goExeName(p), cached)
+		} else if !cfg.quiet {
+			fmt.Printf("   %v (unchanged)\n", goExeName(p))
+		}
+		if err = linkOrCopy(cached, target); err != nil {
+			return
+		}
+		// The binary is not built, but is required for its checksum
+		if cfg.checksums {
+			if err = extractExe(cached, filepath.Join(cfg.buildDir(), "result", goExeName(p))); err != nil {
+				return
+			}
+		}
+	} else {
+		// 2) 交叉编译
+		var exe string
+		if exe, err = goBuild(cfg.buildJob, p); err != nil {
+			return
+		}
 
-	// Blob
-	blob := filepath.Join(cfg.blobsDir(), desc.Digest.Hex)
-	if cfg.verbose {
-		fmt.Printf("mv %v %v\n", rel(cfg.buildDir(), target), rel(cfg.buildDir(), blob))
+		// 3) 打包可执行文件并缓存
+		if err = goExeTarball(exe, target, cfg.capabilities, cfg.zeroTimes, cfg.verbose); err != nil {
+			return
+		}
+		if err = cacheExeLayer(cfg.buildJob, p, key, target); err != nil {
+			return
+		}
 	}
-	err = os.Rename(target, blob)
+
+	// 4) 转换为OCI层,移动到blobs目录
+	layer, err := cfg.WriteLayer(target)
 	if err != nil {
-		return nil, fmt.Errorf("cannot rename blob: %w", err)
+		return
 	}
+	layer.Descriptor.Platform = &p
 
 	// NOTE: base is intentionally blank indiciating it is to be built without
 	// a base layer.
-	return []imageLayer{{Descriptor: desc, Layer: layer}}, nil
+	return []ImageLayer{layer}, nil
 }
 
 func goBuild(cfg buildJob, p v1.Platform) (binPath string, err error) {
@@ -83,61 +136,230 @@ func goBuild(cfg buildJob, p v1.Platform) (binPath string, err error) {
 	if err != nil {
 		return
 	}
-	envs := goBuildEnvs(p)
+	envs := goBuildEnvs(p, cfg)
 	if cfg.verbose {
 		fmt.Printf("%v %v\n", gobin, strings.Join(args, " "))
-	} else {
+	} else if !cfg.quiet {
 		fmt.Printf("   %v\n", filepath.Base(outpath))
 	}
 
+	dir := cfg.goBuildDir()
+
+	// 拉取私有模块的凭据(netrc), 仅在编译期间存在
+	netrc, done, err := goNetrc(cfg)
+	if err != nil {
+		return
+	}
+	defer done()
+	if netrc != "" {
+		envs = append(envs, "NETRC="+netrc)
+	}
+
 	// 执行go mod tidy
-	cmd := exec.CommandContext(cfg.ctx, gobin, "mod", "tidy")
-	cmd.Env = envs
-	cmd.Dir = cfg.buildDir()
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	if err = cmd.Run(); err != nil {
-		return "", fmt.Errorf("go mod tidy failed: %w", err)
+	var cmd *exec.Cmd
+	if !cfg.function.Build.NoScaffold {
+		cmd = exec.CommandContext(cfg.ctx, gobin, "mod", "tidy")
+		cmd.Env = envs
+		cmd.Dir = dir
+		if out, err := runCmd(cfg, cmd); err != nil {
+			return "", ErrCompileFailed{Runtime: "go", Output: out, Err: fmt.Errorf("go mod tidy failed: %w", err)}
+		}
 	}
 
 	// 执行go build
 	cmd = exec.CommandContext(cfg.ctx, gobin, args...)
 	cmd.Env = envs
-	cmd.Dir = cfg.buildDir()
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	err = cmd.Run()
+	cmd.Dir = dir
+	out, err := runCmd(cfg, cmd)
+
+	// 记录编译峰值内存(仅供参考),包括失败时(如内存不足被终止)
+	if rss := peakRSS(cmd.ProcessState); rss > 0 {
+		cfg.peak("compile "+platformName(p), rss)
+		if cfg.verbose {
+			fmt.Fprintf(os.Stderr, "go build %v peak memory: %.0f MiB\n", platformName(p), float64(rss)/(1<<20))
+		}
+	}
 	if err != nil {
-		return "", fmt.Errorf("go build failed: %w", err)
+		return "", ErrCompileFailed{Runtime: "go", Output: out, Err: fmt.Errorf("go build failed: %w", err)}
 	}
 
 	return outpath, nil
 }
 
-func goBuildCmd(p v1.Platform, cfg buildJob) (gobin string, args []string, outpath string, err error) {
-	// Use the binary specified FUNC_GO if defined
-	gobin = os.Getenv("FUNC_GO") // TODO: move to main and plumb through
-	if gobin == "" {
-		gobin = "go"
+// goBuildDir is the directory in which go build is run: the scaffolding, or
+// the function itself when not scaffolded.
+func (j buildJob) goBuildDir() string {
+	// 无脚手架时直接在函数目录中构建, 不修改其go.mod
+	if j.function.Build.NoScaffold {
+		return j.function.Root
+	}
+	return j.buildDir()
+}
+
+// goBin is the go command: that of FUNC_GO if defined, else go.
+func goBin() string {
+	if gobin := os.Getenv("FUNC_GO"); gobin != "" { // TODO: move to main and plumb through
+		return gobin
 	}
+	return "go"
+}
+
+func goBuildCmd(p v1.Platform, cfg buildJob) (gobin string, args []string, outpath string, err error) {
+	gobin = goBin()
 
 	// Build as ./func/builds/$PID/result/f.$OS.$Architecture
+	// The output path is absolute, as the build is not run in the build
+	// directory when not scaffolded.
+	outpath = filepath.Join(cfg.buildDir(), "result", goExeName(p))
+	if outpath, err = filepath.Abs(outpath); err != nil {
+		return
+	}
+	args = []string{"build", "-o", outpath}
+
+	// Build tags, passed as a single argument (not via a shell)
+	tags, err := cfg.goBuildTags()
+	if err != nil {
+		return
+	}
+	if len(tags) > 0 {
+		args = append(args, "-tags", strings.Join(tags, ","))
+	}
+
+	// Profile-guided optimization
+	profile, err := cfg.goProfile()
+	if err != nil {
+		return
+	}
+	if profile != "" {
+		args = append(args, "-pgo="+profile)
+	}
+
+	// VCS stamping
+	if vcs := cfg.goBuildVCS(); vcs != "" {
+		args = append(args, "-buildvcs="+vcs)
+	}
+	return gobin, args, outpath, nil
+}
+
+// WithBuildTags compiles Go functions with the given build tags, for example
+// "prod" to include files constrained by //go:build prod, in addition to
+// those of the function (build.buildTags).  Tags are validated such that each
+// is a single tag (see functions.ValidateBuildTags).
+//
+// The tags are in addition to those implied by the pinned build environment:
+// the platform's GOOS and GOARCH (eg. linux, arm64) are satisfied, and "cgo"
+// never is, as functions are built with CGO_ENABLED=0.  Tags given in
+// GOFLAGS (-tags) are replaced by these, as the flag takes precedence.
+func WithBuildTags(tags ...string) BuilderOpt {
+	return func(b *Builder) {
+		b.buildTags = tags
+	}
+}
+
+// goBuildTags returns the go build tags of the function followed by those of
+// the builder, without duplicates, or an error if any is invalid.
+func (j buildJob) goBuildTags() (tags []string, err error) {
+	seen := map[string]bool{}
+	for _, tag := range append(append([]string{}, j.function.Build.BuildTags...), j.options.buildTags...) {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if errs := fn.ValidateBuildTags(tags); len(errs) > 0 {
+		return nil, errors.New(errs[0])
+	}
+	return
+}
+
+// Profile-guided optimization (PGO) of Go functions.
+const (
+	PGODefault = "default.pgo" // profile used when in the function's root
+	PGOOff     = "off"         // disables the use of default.pgo
+)
+
+// WithPGO compiles Go functions using the CPU profile at the given path for
+// profile-guided optimization, or with PGOOff, without one.  By default, the
+// profile default.pgo in the function's root is used if present, as go build
+// would for a main package (the scaffolding's main package is not in the
+// function's root, so it would otherwise not be found).  The profile must be
+// available at build time: committed with the function or provided.
+func WithPGO(profile string) BuilderOpt {
+	return func(b *Builder) {
+		b.pgo = profile
+	}
+}
+
+// goProfile returns the absolute path of the profile with which to compile
+// the function, or empty if none.
+func (j buildJob) goProfile() (string, error) {
+	switch j.options.pgo {
+	case PGOOff:
+		return "", nil
+	case "":
+		path := filepath.Join(j.function.Root, PGODefault)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		return filepath.Abs(path)
+	}
+	if _, err := os.Stat(j.options.pgo); err != nil {
+		return "", fmt.Errorf("cannot use profile for PGO. %w", err)
+	}
+	return filepath.Abs(j.options.pgo)
+}
+
+// goExeName is the name of the binary built for the platform, for example
+// f.linux.amd64 or f.linux.arm.v7
+func goExeName(p v1.Platform) string {
 	name := fmt.Sprintf("f.%v.%v", p.OS, p.Architecture)
 	if p.Variant != "" {
 		name = name + "." + p.Variant
 	}
-	outpath = filepath.Join("result", name)
-	args = []string{"build", "-o", outpath}
-	// TODO 此处有问题(在buildDir下执行,使用result相对路径,但是结果路径需要增加buildDir前缀)
-	return gobin, args, filepath.Join(cfg.buildDir(), outpath), nil
+	return name
 }
 
-func goBuildEnvs(p v1.Platform) (envs []string) {
+// goEntrypoint ensures the go source at root is a main package with a main
+// function, as is required to build without scaffolding.
+func goEntrypoint(root string) error {
+	files, err := filepath.Glob(filepath.Join(root, "*.go"))
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	for _, path := range files {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		if file.Name.Name != "main" {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no entrypoint found: building without scaffolding requires a main package with a main function in %v", root)
+}
+
+func goBuildEnvs(p v1.Platform, cfg buildJob) (envs []string) {
 	pegged := []string{
 		"CGO_ENABLED=0",
 		"GOOS=" + p.OS,
 		"GOARCH=" + p.Architecture,
 	}
+	if toolchain := cfg.goBuildToolchain(); toolchain != "" {
+		pegged = append(pegged, "GOTOOLCHAIN="+toolchain)
+	}
+	pegged = append(pegged, cfg.goModuleEnvs()...)
+	pegged = append(pegged, cfg.proxyEnvs()...) // also of go version, which may fetch GOTOOLCHAIN
 	if p.Variant != "" && p.Architecture == "arm" {
 		pegged = append(pegged, "GOARM="+strings.TrimPrefix(p.Variant, "v"))
 	} else if p.Variant != "" && p.Architecture == "amd64" {
@@ -163,7 +385,20 @@ func goBuildEnvs(p v1.Platform) (envs []string) {
 	return envs
 }
 
-func goExeTarball(source, target string, verbose bool) error {
+// goExeTarball writes the binary at source to /func/f in a new tarball at
+// target, optionally granting it the given file capabilities.
+func goExeTarball(source, target string, capabilities []string, zeroTimes, verbose bool) error {
+	return exeTarball(source, target, slashpath.Join("/func", "f"), capabilities, zeroTimes, verbose)
+}
+
+// exeTarball writes the executable at source to the given path in a new
+// tarball at target, optionally granting it the given file capabilities.
+func exeTarball(source, target, name string, capabilities []string, zeroTimes, verbose bool) error {
+	caps, err := capabilityData(capabilities)
+	if err != nil {
+		return err
+	}
+
 	targetFile, err := os.Create(target)
 	if err != nil {
 		return err
@@ -187,9 +422,12 @@ func goExeTarball(source, target string, verbose bool) error {
 	}
 	header.Mode = (header.Mode & ^int64(fs.ModePerm)) | 0755
 
-	header.Name = slashpath.Join("/func", "f")
-	// TODO: should we set file timestamps to the build start time of cfg.t?
-	// header.ModTime = timestampArgument
+	header.Name = name
+	if caps != nil {
+		header.Format = tar.FormatPAX
+		header.PAXRecords = map[string]string{capabilityXattr: string(caps)}
+	}
+	zeroHeaderTimes(header, zeroTimes)
 
 	if err = tw.WriteHeader(header); err != nil {
 		return err
@@ -0,0 +1,388 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci/mock"
+	. "knative.dev/func/pkg/testing"
+)
+
+// Test_goExeTarballCapabilities ensures that file capabilities requested
+// are set on the function binary as the security.capability xattr, and that
+// none are set by default.
+func Test_goExeTarballCapabilities(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "f.linux.amd64")
+	if err := os.WriteFile(exe, []byte("binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Default: no capabilities
+	target := filepath.Join(dir, "default.tar.gz")
+	if err := goExeTarball(exe, target, nil, false, false); err != nil {
+		t.Fatal(err)
+	}
+	hdr := readTarball(t, target)["/func/f"]
+	if hdr == nil {
+		t.Fatal("binary not found in layer")
+	}
+	if _, ok := hdr.PAXRecords[capabilityXattr]; ok {
+		t.Fatal("expected no capabilities by default")
+	}
+
+	// With cap_net_bind_service (10): v2 magic with effective flag, and
+	// the permitted bit set.
+	target = filepath.Join(dir, "caps.tar.gz")
+	if err := goExeTarball(exe, target, []string{"cap_net_bind_service"}, false, false); err != nil {
+		t.Fatal(err)
+	}
+	hdr = readTarball(t, target)["/func/f"]
+	expected := string([]byte{
+		0x01, 0x00, 0x00, 0x02, // magic: revision 2 | effective
+		0x00, 0x04, 0x00, 0x00, // permitted (low): 1<<10
+		0x00, 0x00, 0x00, 0x00, // inheritable (low)
+		0x00, 0x00, 0x00, 0x00, // permitted (high)
+		0x00, 0x00, 0x00, 0x00, // inheritable (high)
+	})
+	if hdr.PAXRecords[capabilityXattr] != expected {
+		t.Fatalf("unexpected capability xattr %x", hdr.PAXRecords[capabilityXattr])
+	}
+
+	// Unrecognized capabilities are an error
+	if err := goExeTarball(exe, target, []string{"cap_invalid"}, false, false); err == nil {
+		t.Fatal("expected an error for an unrecognized capability")
+	}
+}
+
+// Test_runCmdOutput ensures that the output of a child process is captured
+// for reporting on failure, both when streaming (verbose) and when not.
+func Test_runCmdOutput(t *testing.T) {
+	for _, verbose := range []bool{false, true} {
+		job := buildJob{verbose: verbose}
+		out, err := runCmd(job, exec.Command("go", "notacommand"))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if !strings.Contains(out, "notacommand") {
+			t.Fatalf("expected captured output to contain the error (verbose=%v), got %q", verbose, out)
+		}
+	}
+}
+
+// Test_goBuildCompileFailed ensures that a function which fails to compile
+// results in an ErrCompileFailed containing the compiler's output.
+func Test_goBuildCompileFailed(t *testing.T) {
+	job := buildJob{ctx: context.Background(), function: fn.Function{Root: t.TempDir()}, hash: "test"}
+	if err := os.MkdirAll(job.buildDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(job.buildDir(), "go.mod"), []byte("module f\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(job.buildDir(), "main.go"), []byte("package main\n\nfunc main() { undefinedSymbol() }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := goBuild(job, v1.Platform{OS: "linux", Architecture: "amd64"})
+	var errCompile ErrCompileFailed
+	if !errors.As(err, &errCompile) {
+		t.Fatalf("expected ErrCompileFailed, got %v", err)
+	}
+	if !strings.Contains(errCompile.Output, "undefinedSymbol") {
+		t.Fatalf("expected compiler output in error, got %q", errCompile.Output)
+	}
+}
+
+// Test_goBuildNoScaffold ensures that a function built without scaffolding
+// requires an entrypoint, and is compiled from its own source.
+func Test_goBuildNoScaffold(t *testing.T) {
+	root := t.TempDir()
+	job := buildJob{
+		ctx:      context.Background(),
+		function: fn.Function{Root: root, Runtime: "go", Build: fn.BuildSpec{NoScaffold: true}},
+		hash:     "test",
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module f\n\ngo 1.21\n")
+
+	// A library, or a main only in tests, has no entrypoint
+	write("handle.go", "package function\n\nfunc Handle() {}\n")
+	write("main_test.go", "package main\n\nfunc main() {}\n")
+	var errScaffold ErrScaffold
+	if err := scaffold(job); !errors.As(err, &errScaffold) {
+		t.Fatalf("expected ErrScaffold without an entrypoint, got %v", err)
+	}
+
+	// Its own main
+	if err := os.Remove(filepath.Join(root, "handle.go")); err != nil {
+		t.Fatal(err)
+	}
+	write("main.go", "package main\n\nfunc main() {}\n")
+	if err := scaffold(job); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(job.buildDir(), "go.mod")); !os.IsNotExist(err) {
+		t.Fatalf("expected no scaffolding in the build directory. %v", err)
+	}
+	exe, err := goBuild(job, v1.Platform{OS: "linux", Architecture: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(exe); err != nil {
+		t.Fatalf("expected binary at %v. %v", exe, err)
+	}
+}
+
+// Test_goWritePlatformCached ensures the exe layer is reused when only files
+// other than the Go sources change, and rebuilt when the Go sources change or
+// when they embed the changed files.
+func Test_goWritePlatformCached(t *testing.T) {
+	root := t.TempDir()
+	job := buildJob{
+		ctx:      context.Background(),
+		function: fn.Function{Root: root, Runtime: "go", Build: fn.BuildSpec{NoScaffold: true}},
+		hash:     "test",
+	}
+	job.quiet = true
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	build := func() v1.Hash {
+		t.Helper()
+		if err := os.MkdirAll(job.blobsDir(), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		layers, err := goBuilder{}.WritePlatform(BuildContext{job}, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return layers[0].Descriptor.Digest
+	}
+	write("go.mod", "module f\n\ngo 1.21\n")
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("index.html", "<html></html>")
+	build()
+
+	// Replace the cached layer with a marker layer, which is returned if the
+	// cached layer is reused.
+	cached, err := filepath.Glob(job.exeCachePath(p, "*"))
+	if err != nil || len(cached) != 1 {
+		t.Fatalf("expected one cached layer, got %v. %v", cached, err)
+	}
+	marker := filepath.Join(t.TempDir(), "marker.tar.gz")
+	if err = goExeTarball(filepath.Join(root, "go.mod"), marker, nil, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Rename(marker, cached[0]); err != nil {
+		t.Fatal(err)
+	}
+	markerLayer, err := tarball.LayerFromFile(cached[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	markerDigest, err := markerLayer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An asset changed: reused
+	write("index.html", "<html>changed</html>")
+	if build() != markerDigest {
+		t.Fatal("expected the cached exe layer to be reused when only an asset changed")
+	}
+
+	// The Go source changed: rebuilt, replacing the cached layer
+	write("main.go", "package main\n\nfunc main() { println() }\n")
+	if build() == markerDigest {
+		t.Fatal("expected the exe layer to be rebuilt when the Go source changed")
+	}
+	if cached, _ = filepath.Glob(job.exeCachePath(p, "*")); len(cached) != 1 {
+		t.Fatalf("expected the cached layer to be replaced, got %v", cached)
+	}
+
+	// Embedded files are sources
+	write("main.go", "package main\n\nimport _ \"embed\"\n\n//go:embed index.html\nvar index string\n\nfunc main() { println(index) }\n")
+	key, err := goExeKey(job, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("index.html", "<html>changed again</html>")
+	if changed, _ := goExeKey(job, p); changed == key {
+		t.Fatal("expected the key to change when an embedded file changed")
+	}
+}
+
+// Test_goBuildCmdTags ensures the build tags of the function and the builder
+// are passed to go build as a single -tags argument, without duplicates, and
+// that invalid tags are rejected rather than passed to the toolchain.
+func Test_goBuildCmdTags(t *testing.T) {
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	job := buildJob{function: fn.Function{Root: t.TempDir()}}
+
+	// Default: no tags
+	_, args, _, err := goBuildCmd(p, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(args, "-tags") {
+		t.Fatalf("expected no -tags by default, got %v", args)
+	}
+
+	// Those of the function followed by those of the builder
+	job.function.Build.BuildTags = []string{"prod", "netgo"}
+	job.options.buildTags = []string{"netgo", "debug"}
+	_, args, _, err = goBuildCmd(p, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := slices.Index(args, "-tags")
+	if i < 0 || i+1 >= len(args) || args[i+1] != "prod,netgo,debug" {
+		t.Fatalf("expected -tags prod,netgo,debug, got %v", args)
+	}
+
+	// Invalid tags are an error
+	job.function.Build.BuildTags = []string{"prod -ldflags=-X"}
+	if _, _, _, err = goBuildCmd(p, job); err == nil {
+		t.Fatal("expected an error for an invalid build tag")
+	}
+}
+
+// Test_goBuildCmdPGO ensures the function's default.pgo is used for
+// profile-guided optimization when present, that an explicit profile is used
+// in its place, that PGO may be disabled, and that a missing explicit profile
+// is an error.
+func Test_goBuildCmdPGO(t *testing.T) {
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	root := t.TempDir()
+	job := buildJob{function: fn.Function{Root: root}}
+	pgo := func() string {
+		t.Helper()
+		_, args, _, err := goBuildCmd(p, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-pgo=") {
+				return strings.TrimPrefix(arg, "-pgo=")
+			}
+		}
+		return ""
+	}
+
+	// Default: none without default.pgo
+	if profile := pgo(); profile != "" {
+		t.Fatalf("expected no profile, got %v", profile)
+	}
+
+	// The function's default.pgo
+	if err := os.WriteFile(filepath.Join(root, PGODefault), []byte("profile"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if profile := pgo(); profile != filepath.Join(root, PGODefault) {
+		t.Fatalf("expected the function's default.pgo, got %v", profile)
+	}
+
+	// An explicit profile
+	explicit := filepath.Join(t.TempDir(), "cpu.pprof")
+	if err := os.WriteFile(explicit, []byte("profile"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	job.options.pgo = explicit
+	if profile := pgo(); profile != explicit {
+		t.Fatalf("expected %v, got %v", explicit, profile)
+	}
+
+	// Disabled
+	job.options.pgo = PGOOff
+	if profile := pgo(); profile != "" {
+		t.Fatalf("expected no profile when disabled, got %v", profile)
+	}
+
+	// A missing explicit profile
+	job.options.pgo = filepath.Join(root, "missing.pprof")
+	if _, _, _, err := goBuildCmd(p, job); err == nil {
+		t.Fatal("expected an error for a missing profile")
+	}
+}
+
+// Test_goBuildEnvsMicroarchitecture ensures the variant of amd64, its
+// microarchitecture level, is built as GOAMD64, and that of arm as GOARM.
+func Test_goBuildEnvsMicroarchitecture(t *testing.T) {
+	envs := goBuildEnvs(v1.Platform{OS: "linux", Architecture: "amd64", Variant: "v3"}, buildJob{})
+	if !slices.Contains(envs, "GOAMD64=v3") {
+		t.Fatalf("expected GOAMD64=v3, got %v", envs)
+	}
+	envs = goBuildEnvs(v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, buildJob{})
+	if !slices.Contains(envs, "GOARM=7") {
+		t.Fatalf("expected GOARM=7, got %v", envs)
+	}
+	if (goBuilder{}).SupportsPlatform(v1.Platform{OS: "linux", Architecture: "amd64", Variant: "v5"}) {
+		t.Fatal("expected amd64/v5 to be unsupported")
+	}
+}
+
+// TestPullBase_Microarchitecture ensures the base image of an amd64
+// microarchitecture level is that of amd64, which has no variant.
+func TestPullBase_Microarchitecture(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	registry := mock.NewRegistry()
+	defer registry.Close()
+	ref, index, err := registry.SeedBase("library/base", "latest", 1, 1, v1.Platform{OS: "linux", Architecture: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.BaseImage = ref
+
+	p := fn.Platform{OS: "linux", Architecture: "amd64", Variant: "v3"}
+	job, err := newBuildJob(context.Background(), f, []fn.Platform{p}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+
+	base, err := pullBase(job, job.platforms[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := base.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := baseImage(t, index).Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Fatalf("expected the amd64 base %v, got %v", want, got)
+	}
+}
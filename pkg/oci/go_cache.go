@@ -0,0 +1,188 @@
+package oci
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// The exe layer of a Go function is cached, per platform, keyed by a hash of
+// everything which determines the compiled binary: the Go sources, the
+// scaffolding, the toolchain, the build environment and flags.  When only
+// other files change (templates, static assets etc.), the cached layer is
+// reused rather than compiling again, and only the data layer is rebuilt.
+
+// exeCacheDir is the directory of the function's cached exe layers.
+func (j buildJob) exeCacheDir() string {
+	return filepath.Join(j.dataDir(), "exe-cache")
+}
+
+// exeCachePath is the path of the cached exe layer of the platform with the
+// given key.
+func (j buildJob) exeCachePath(p v1.Platform, key string) string {
+	return filepath.Join(j.exeCacheDir(), fmt.Sprintf("%v.%v.tar.gz", goExeName(p), key))
+}
+
+// cacheExeLayer caches the exe layer tarball at path, replacing any prior
+// layer of the platform such that the cache does not grow with each change.
+func cacheExeLayer(job buildJob, p v1.Platform, key, path string) (err error) {
+	if err = os.MkdirAll(job.exeCacheDir(), os.ModePerm); err != nil {
+		return
+	}
+	prior, err := filepath.Glob(job.exeCachePath(p, "*"))
+	if err != nil {
+		return
+	}
+	for _, f := range prior {
+		_ = os.Remove(f)
+	}
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "cp %v %v\n", rel(job.buildDir(), path), job.exeCachePath(p, key))
+	}
+	return linkOrCopy(path, job.exeCachePath(p, key))
+}
+
+// goExeKey returns the key of the exe layer of the platform: a hash of the
+// function's Go sources, the scaffolding, the toolchain's version, the build
+// environment, flags, capabilities and profile (PGO).
+func goExeKey(job buildJob, p v1.Platform) (string, error) {
+	h := sha256.New()
+
+	gobin, args, outpath, err := goBuildCmd(p, job)
+	if err != nil {
+		return "", err
+	}
+	// The version of the toolchain which builds, as pinned (GOTOOLCHAIN)
+	cmd := exec.CommandContext(job.ctx, gobin, "version")
+	cmd.Env = goBuildEnvs(p, job)
+	version, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine go version. %w", err)
+	}
+	fmt.Fprintf(h, "version:%s\n", bytes.TrimSpace(version))
+	for _, arg := range args {
+		if arg != outpath {
+			fmt.Fprintf(h, "arg:%v\n", arg)
+		}
+	}
+	fmt.Fprintf(h, "capabilities:%v\n", strings.Join(job.capabilities, ","))
+	fmt.Fprintf(h, "noScaffold:%v\n", job.function.Build.NoScaffold)
+	if job.zeroTimes {
+		fmt.Fprintf(h, "zeroTimestamps\n") // of the layer's binary
+	}
+
+	// The environment of the go toolchain
+	envs := []string{}
+	for _, env := range goBuildEnvs(p, job) {
+		if strings.HasPrefix(env, "GO") || strings.HasPrefix(env, "CGO_") {
+			envs = append(envs, env)
+		}
+	}
+	sort.Strings(envs)
+	for _, env := range envs {
+		fmt.Fprintf(h, "env:%v\n", env)
+	}
+
+	// The function's sources
+	if err = hashGoSources(h, job.function.Root); err != nil {
+		return "", err
+	}
+
+	// The profile (PGO), which may be outside the function
+	profile, err := job.goProfile()
+	if err != nil {
+		return "", err
+	}
+	if profile != "" {
+		if err = hashFile(h, filepath.Dir(profile), profile); err != nil {
+			return "", err
+		}
+	}
+
+	// The scaffolding (its module and main)
+	if !job.function.Build.NoScaffold {
+		for _, name := range []string{"go.mod", "go.sum", "main.go"} {
+			if err = hashFile(h, job.buildDir(), filepath.Join(job.buildDir(), name)); err != nil && !os.IsNotExist(err) {
+				return "", err
+			}
+		}
+	}
+
+	// The sources of directories which replace modules (see WithReplace)
+	replaces, err := job.goReplaces()
+	if err != nil {
+		return "", err
+	}
+	for _, r := range replaces {
+		if r.newVersion == "" {
+			if err = hashGoSources(h, r.newPath); err != nil {
+				return "", err
+			}
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// hashGoSources writes the paths and contents of the Go sources of the
+// function at root to h: its .go files, go.mod and go.sum.  If the function
+// embeds files (go:embed), which may be any file, all files are written.
+func hashGoSources(h io.Writer, root string) error {
+	var (
+		files  []string
+		embeds bool
+	)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != root && (d.Name() == fn.RunDataDir || d.Name() == ".git") {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if strings.HasSuffix(path, ".go") && !embeds {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			embeds = bytes.Contains(src, []byte("//go:embed"))
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		name := filepath.Base(path)
+		if embeds || strings.HasSuffix(name, ".go") || name == "go.mod" || name == "go.sum" {
+			if err = hashFile(h, root, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hashFile writes the path (relative to root) and content of the file to h.
+func hashFile(h io.Writer, root, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fmt.Fprintf(h, "file:%v\n", rel(root, path))
+	_, err = io.Copy(h, file)
+	return err
+}
@@ -0,0 +1,52 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// goMiddleware is the module of the middleware with which the scaffolding of
+// Go functions serves them.
+const goMiddleware = "knative.dev/func-go"
+
+// WithMiddlewareVersion pins the version of the middleware with which the
+// scaffolding serves Go functions (knative.dev/func-go), in place of that of
+// the function (build.middlewareVersion) if any, for example to pick up a
+// security fix before the scaffolding is updated.
+//
+// The version is pinned with a replace directive in the go.mod of the
+// scaffolding, not that of the function, and so applies to the whole build,
+// including any requirement of the function itself on the middleware.  The
+// go mod tidy run on the scaffolding at each build then resolves the version
+// (downloading it if not in the module cache) and updates its go.sum, so
+// building with GOPROXY=off requires that the version be in the cache.
+func WithMiddlewareVersion(version string) BuilderOpt {
+	return func(b *Builder) {
+		b.middleware = version
+	}
+}
+
+// goMiddlewareVersion returns the version of the middleware to which the
+// build is pinned, empty for that of the scaffolding, or an error if invalid
+// or the function is not scaffolded.
+func (j buildJob) goMiddlewareVersion() (string, error) {
+	version := j.function.Build.MiddlewareVersion
+	if j.options.middleware != "" {
+		version = j.options.middleware
+	}
+	if version == "" {
+		return "", nil
+	}
+	if errs := fn.ValidateMiddlewareVersion(version); len(errs) > 0 {
+		return "", errors.New(errs[0])
+	}
+	if j.function.Runtime != "go" {
+		return "", fmt.Errorf("pinning the middleware version is only supported for go functions, not %q", j.function.Runtime)
+	}
+	if j.function.Build.NoScaffold {
+		return "", errors.New("pinning the middleware version is not supported when building without scaffolding")
+	}
+	return version, nil
+}
@@ -0,0 +1,99 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// Test_pinGoMiddleware ensures the scaffolding's go.mod replaces the
+// middleware with the pinned version, that of the builder taking precedence
+// over that of the function, and is otherwise unchanged.
+func Test_pinGoMiddleware(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replaced returns the version with which the scaffolding replaces the
+	// middleware, empty if it does not.
+	replaced := func(fnVersion, version string) string {
+		f.Build.MiddlewareVersion = fnVersion
+		job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.middleware = version
+		if err = setup(job); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup(job)
+		defer os.Remove(job.pidLink())
+		if err = scaffold(job); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(job.buildDir(), "go.mod")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mod, err := modfile.Parse(path, data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range mod.Replace {
+			if r.Old.Path == goMiddleware {
+				if r.New.Path != goMiddleware {
+					t.Fatalf("expected the middleware to be replaced by a version, got %v", r.New.Path)
+				}
+				return r.New.Version
+			}
+		}
+		return ""
+	}
+
+	if v := replaced("", ""); v != "" {
+		t.Fatalf("expected the middleware of the scaffolding, got %v", v)
+	}
+	if v := replaced("v0.21.4", ""); v != "v0.21.4" {
+		t.Fatalf("expected the middleware version of the function, got %q", v)
+	}
+	if v := replaced("v0.21.4", "v0.22.0"); v != "v0.22.0" {
+		t.Fatalf("expected the middleware version of the builder, got %q", v)
+	}
+}
+
+// Test_goMiddlewareVersion ensures an invalid version, and a version for a
+// function which is not scaffolded go, are errors.
+func Test_goMiddlewareVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		function fn.Function
+		version  string
+		wantErr  bool
+	}{
+		{"none", fn.Function{Runtime: "go"}, "", false},
+		{"valid", fn.Function{Runtime: "go"}, "v0.21.4", false},
+		{"invalid", fn.Function{Runtime: "go"}, "latest", true},
+		{"python", fn.Function{Runtime: "python"}, "v0.21.4", true},
+		{"not scaffolded", fn.Function{Runtime: "go", Build: fn.BuildSpec{NoScaffold: true}}, "v0.21.4", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := buildJob{function: tt.function}
+			job.middleware = tt.version
+			if _, err := job.goMiddlewareVersion(); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
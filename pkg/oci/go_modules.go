@@ -0,0 +1,116 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GoModules configures how the go toolchain fetches the modules of Go
+// functions, such as from a private proxy or private repositories in CI.
+// Empty values leave those of the environment.
+type GoModules struct {
+	Proxy   string            // GOPROXY, such as "https://proxy.example.com,direct"
+	Private string            // GOPRIVATE, patterns of private modules
+	NoSumDB string            // GONOSUMDB, patterns of modules not in the checksum database
+	Flags   string            // GOFLAGS, such as "-mod=mod"
+	NetRC   string            // netrc file of credentials of module hosts
+	Tokens  map[string]string // tokens of module hosts, by host, as [login:]token
+}
+
+// WithGoModules configures the module proxy, private modules and credentials
+// with which Go functions are built (by go mod tidy and go build), in place
+// of those of the environment.  Credentials are provided to the go toolchain
+// as a netrc file (NETRC): that given, followed by an entry for each token.
+// The netrc file of the tokens is temporary, written for and removed after
+// each compilation, such that the tokens are not left in the build
+// directory, nor are they part of the key of the cached binary.
+func WithGoModules(m GoModules) BuilderOpt {
+	return func(b *Builder) {
+		b.goModules = m
+	}
+}
+
+// ParseGoTokens parses tokens of module hosts in the form host=[login:]token,
+// as provided on the command line.
+func ParseGoTokens(tt []string) (map[string]string, error) {
+	tokens := map[string]string{}
+	for _, t := range tt {
+		host, token, ok := strings.Cut(t, "=")
+		if !ok || host == "" || token == "" {
+			return nil, fmt.Errorf("invalid go module token for %q: must be in the form host=[login:]token", host)
+		}
+		if strings.ContainsAny(host+token, " \t\n") {
+			return nil, fmt.Errorf("invalid go module token for %q: must not contain whitespace", host)
+		}
+		tokens[host] = token
+	}
+	return tokens, nil
+}
+
+// goModuleEnvs returns the environment of the go toolchain which configures
+// the fetching of modules, for those set.
+func (j buildJob) goModuleEnvs() (envs []string) {
+	m := j.options.goModules
+	for _, env := range []struct{ name, value string }{
+		{"GOPROXY", m.Proxy},
+		{"GOPRIVATE", m.Private},
+		{"GONOSUMDB", m.NoSumDB},
+		{"GOFLAGS", m.Flags},
+	} {
+		if env.value != "" {
+			envs = append(envs, env.name+"="+env.value)
+		}
+	}
+	return
+}
+
+// goNetrc returns the netrc file (NETRC) with which the go toolchain fetches
+// modules, if any: that configured, or if there are tokens, a temporary file
+// of its entries and of those of the tokens, which is removed by done.
+func goNetrc(job buildJob) (path string, done func(), err error) {
+	m := job.options.goModules
+	done = func() {}
+	if len(m.Tokens) == 0 {
+		return m.NetRC, done, nil
+	}
+
+	var b strings.Builder
+	if m.NetRC != "" {
+		data, err := os.ReadFile(m.NetRC)
+		if err != nil {
+			return "", done, fmt.Errorf("cannot read netrc file. %w", err)
+		}
+		b.Write(data)
+		b.WriteString("\n")
+	}
+	hosts := make([]string, 0, len(m.Tokens))
+	for host := range m.Tokens {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		login, token, ok := strings.Cut(m.Tokens[host], ":")
+		if !ok {
+			login, token = "token", m.Tokens[host]
+		}
+		fmt.Fprintf(&b, "machine %v login %v password %v\n", host, login, token)
+	}
+
+	file, err := os.CreateTemp("", "func-netrc-*") // created 0600
+	if err != nil {
+		return
+	}
+	done = func() { _ = os.Remove(file.Name()) }
+	if _, err = file.WriteString(b.String()); err != nil {
+		file.Close()
+		done()
+		return "", func() {}, err
+	}
+	if err = file.Close(); err != nil {
+		done()
+		return "", func() {}, err
+	}
+	return file.Name(), done, nil
+}
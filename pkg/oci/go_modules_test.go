@@ -0,0 +1,117 @@
+package oci
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Test_goBuildEnvsModules ensures the configured module settings override
+// those of the environment, which are otherwise kept, and that a configured
+// proxy of off is offline.
+func Test_goBuildEnvsModules(t *testing.T) {
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	t.Setenv("GOPROXY", "https://proxy.golang.org,direct")
+	t.Setenv("GOPRIVATE", "example.com/env")
+	t.Setenv("GOTOOLCHAIN", "auto")
+
+	// Default: those of the environment
+	envs := goBuildEnvs(p, buildJob{})
+	if !slices.Contains(envs, "GOPROXY=https://proxy.golang.org,direct") || !slices.Contains(envs, "GOPRIVATE=example.com/env") {
+		t.Fatalf("expected the environment's module settings, got %v", envs)
+	}
+
+	// Configured
+	job := buildJob{}
+	job.goModules = GoModules{
+		Proxy:   "https://proxy.example.com",
+		Private: "example.com/private",
+		NoSumDB: "example.com/nosumdb",
+		Flags:   "-mod=mod",
+	}
+	envs = goBuildEnvs(p, job)
+	for _, env := range []string{"GOPROXY=https://proxy.example.com", "GOPRIVATE=example.com/private",
+		"GONOSUMDB=example.com/nosumdb", "GOFLAGS=-mod=mod"} {
+		if !slices.Contains(envs, env) {
+			t.Fatalf("expected %v, got %v", env, envs)
+		}
+	}
+	if slices.Contains(envs, "GOPRIVATE=example.com/env") {
+		t.Fatalf("expected the environment's GOPRIVATE to be overridden, got %v", envs)
+	}
+
+	// A configured proxy of off is offline
+	job.goModules.Proxy = "off"
+	if envs = goBuildEnvs(p, job); !slices.Contains(envs, "GOTOOLCHAIN=local") {
+		t.Fatalf("expected GOTOOLCHAIN=local when offline, got %v", envs)
+	}
+}
+
+func TestParseGoTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  []string
+		wantErr bool
+	}{
+		{"token", []string{"github.com=ghp_abc"}, false},
+		{"login and token", []string{"gitlab.example.com=alice:glpat-abc"}, false},
+		{"missing token", []string{"github.com"}, true},
+		{"empty token", []string{"github.com="}, true},
+		{"whitespace", []string{"github.com=abc def"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseGoTokens(tt.tokens); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// Test_goNetrc ensures the netrc file configured is used as is without
+// tokens, and that tokens are written with its entries to a temporary file
+// which is removed when done.
+func Test_goNetrc(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), ".netrc")
+	if err := os.WriteFile(netrc, []byte("machine example.com login alice password secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// None
+	path, done, err := goNetrc(buildJob{})
+	if err != nil || path != "" {
+		t.Fatalf("expected no netrc, got %q. %v", path, err)
+	}
+	done()
+
+	// The configured file
+	job := buildJob{}
+	job.goModules.NetRC = netrc
+	if path, done, err = goNetrc(job); err != nil || path != netrc {
+		t.Fatalf("expected the configured netrc, got %q. %v", path, err)
+	}
+	done()
+
+	// With tokens
+	job.goModules.Tokens = map[string]string{"github.com": "ghp_abc", "gitlab.example.com": "bob:glpat-abc"}
+	if path, done, err = goNetrc(job); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "machine example.com login alice password secret\n\n" +
+		"machine github.com login token password ghp_abc\n" +
+		"machine gitlab.example.com login bob password glpat-abc\n"
+	if string(data) != expected {
+		t.Fatalf("expected netrc:\n%v\ngot:\n%v", expected, string(data))
+	}
+	done()
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the temporary netrc to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// WithReplace adds replace directives to the go.mod of the scaffolding of Go
+// functions, replacing a module (path or path@version) by a directory or by a
+// module at a version, for example to develop against a local fork of the
+// middleware without publishing it.  These are in addition to those of the
+// function (build.replace), taking precedence for the same module.
+// Directories are relative to the working directory, whereas those of the
+// function are relative to the function, and must contain a go.mod.
+//
+// As with the middleware version (see WithMiddlewareVersion), the directives
+// are added to the scaffolding's go.mod, not the function's, before the go
+// mod tidy and go build of each build.  The Go sources of a replacing
+// directory are part of the key of the cached binary, such that a change to
+// them is built.
+func WithReplace(replace map[string]string) BuilderOpt {
+	return func(b *Builder) {
+		b.replace = replace
+	}
+}
+
+// ParseReplace parses replace directives in the form old=new, as provided on
+// the command line.
+func ParseReplace(rr []string) (map[string]string, error) {
+	replace := map[string]string{}
+	for _, r := range rr {
+		old, new, ok := strings.Cut(r, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid replace %q: must be in the form old=new", r)
+		}
+		replace[old] = new
+	}
+	if errs := fn.ValidateReplace(replace); len(errs) > 0 {
+		return nil, errors.New(errs[0])
+	}
+	return replace, nil
+}
+
+// goReplace is a replace directive of a go.mod.
+type goReplace struct {
+	oldPath, oldVersion string
+	newPath, newVersion string // newVersion is empty for a directory
+}
+
+// goReplaces returns the replace directives of the build, by the module
+// replaced: those of the function and of the builder, with their directories
+// made absolute, followed by the pin of the middleware version, if any.  An
+// error is returned if any is invalid, a directory does not contain a
+// module, or the function is not scaffolded go.
+func (j buildJob) goReplaces() (replaces []goReplace, err error) {
+	version, err := j.goMiddlewareVersion()
+	if err != nil {
+		return
+	}
+	if len(j.function.Build.Replace) == 0 && len(j.options.replace) == 0 {
+		if version != "" {
+			replaces = append(replaces, goReplace{goMiddleware, "", goMiddleware, version})
+		}
+		return
+	}
+	if j.function.Runtime != "go" {
+		return nil, fmt.Errorf("replacing go modules is only supported for go functions, not %q", j.function.Runtime)
+	}
+	if j.function.Build.NoScaffold {
+		return nil, errors.New("replacing go modules is not supported when building without scaffolding, where the function's go.mod may be edited instead")
+	}
+
+	// Directories of the function are relative to it, of the builder to the
+	// working directory
+	byOld := map[string]goReplace{}
+	for _, rr := range []struct {
+		replace map[string]string
+		dir     string
+	}{{j.function.Build.Replace, j.function.Root}, {j.options.replace, ""}} {
+		if errs := fn.ValidateReplace(rr.replace); len(errs) > 0 {
+			return nil, errors.New(errs[0])
+		}
+		for old, new := range rr.replace {
+			r := goReplace{}
+			r.oldPath, r.oldVersion, _ = strings.Cut(old, "@")
+			if modfile.IsDirectoryPath(new) {
+				if r.newPath, err = goReplaceDir(rr.dir, new); err != nil {
+					return
+				}
+			} else {
+				r.newPath, r.newVersion, _ = strings.Cut(new, "@")
+			}
+			byOld[old] = r
+		}
+	}
+	olds := make([]string, 0, len(byOld))
+	for old := range byOld {
+		if version != "" && byOld[old].oldPath == goMiddleware {
+			return nil, fmt.Errorf("the middleware version may not be pinned when %v is replaced", goMiddleware)
+		}
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+	for _, old := range olds {
+		replaces = append(replaces, byOld[old])
+	}
+	if version != "" {
+		replaces = append(replaces, goReplace{goMiddleware, "", goMiddleware, version})
+	}
+	return
+}
+
+// goReplaceDir returns the absolute path of the replacing directory, relative
+// to dir (the working directory if empty), or an error if it does not
+// contain a module.
+func goReplaceDir(dir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err = os.Stat(filepath.Join(path, "go.mod")); err != nil {
+		return "", fmt.Errorf("replacing directory %v must contain a go.mod. %w", path, err)
+	}
+	return path, nil
+}
+
+// writeGoReplaces adds the replace directives of the build, if any, to the
+// go.mod of the scaffolding written to the build directory.
+func writeGoReplaces(job buildJob) error {
+	replaces, err := job.goReplaces()
+	if err != nil || len(replaces) == 0 {
+		return err
+	}
+	path := filepath.Join(job.buildDir(), "go.mod")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	mod, err := modfile.Parse(path, data, nil)
+	if err != nil {
+		return err
+	}
+	for _, r := range replaces {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "Replacing %v with %v\n", joinVersion(r.oldPath, r.oldVersion), joinVersion(r.newPath, r.newVersion))
+		}
+		if err = mod.AddReplace(r.oldPath, r.oldVersion, r.newPath, r.newVersion); err != nil {
+			return err
+		}
+	}
+	if data, err = mod.Format(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// joinVersion returns path@version, or path if version is empty.
+func joinVersion(path, version string) string {
+	if version == "" {
+		return path
+	}
+	return path + "@" + version
+}
@@ -0,0 +1,159 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"golang.org/x/mod/modfile"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+func TestParseReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace []string
+		wantErr bool
+	}{
+		{"directory", []string{"knative.dev/func-go=../func-go"}, false},
+		{"module", []string{"knative.dev/func-go=github.com/alice/func-go@v0.21.4"}, false},
+		{"missing replacement", []string{"knative.dev/func-go"}, true},
+		{"module without version", []string{"knative.dev/func-go=github.com/alice/func-go"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseReplace(tt.replace); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// Test_writeGoReplaces ensures the replace directives of the function, with
+// directories relative to it, and of the builder, which take precedence, are
+// added to the scaffolding's go.mod, and that a directory without a module
+// or replacing the middleware while pinning its version are errors.
+func Test_writeGoReplaces(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fork := filepath.Join(t.TempDir(), "func-go")
+	if err = os.MkdirAll(fork, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(fork, "go.mod"), []byte("module knative.dev/func-go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	relFork, err := filepath.Rel(root, fork)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replaced returns the replace directives of the scaffolding's go.mod, by
+	// the module replaced, in the form path[@version].
+	replaced := func(f fn.Function, replace map[string]string) (map[string]string, error) {
+		job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.replace = replace
+		if err = setup(job); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup(job)
+		defer os.Remove(job.pidLink())
+		if err = scaffold(job); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(job.buildDir(), "go.mod")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mod, err := modfile.Parse(path, data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := map[string]string{}
+		for _, r := range mod.Replace {
+			rr[joinVersion(r.Old.Path, r.Old.Version)] = joinVersion(r.New.Path, r.New.Version)
+		}
+		return rr, nil
+	}
+
+	// The function's directory is relative to it (../)
+	f.Build.Replace = map[string]string{
+		"knative.dev/func-go":  filepath.ToSlash(relFork),
+		"example.com/x@v1.0.0": "example.com/y@v1.0.1",
+	}
+	rr, err := replaced(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr["knative.dev/func-go"] != fork {
+		t.Fatalf("expected the middleware to be replaced by %v, got %v", fork, rr)
+	}
+	if rr["example.com/x@v1.0.0"] != "example.com/y@v1.0.1" {
+		t.Fatalf("expected example.com/x@v1.0.0 to be replaced by a module, got %v", rr)
+	}
+
+	// The builder's take precedence
+	if rr, err = replaced(f, map[string]string{"knative.dev/func-go": "github.com/alice/func-go@v0.21.4"}); err != nil {
+		t.Fatal(err)
+	}
+	if rr["knative.dev/func-go"] != "github.com/alice/func-go@v0.21.4" {
+		t.Fatalf("expected the builder's replacement of the middleware, got %v", rr)
+	}
+
+	// A directory must contain a module
+	if _, err = replaced(f, map[string]string{"knative.dev/func-go": t.TempDir()}); err == nil {
+		t.Fatal("expected an error replacing with a directory without a go.mod")
+	}
+
+	// The middleware may not be both replaced and pinned
+	f.Build.MiddlewareVersion = "v0.21.4"
+	if _, err = replaced(f, nil); err == nil {
+		t.Fatal("expected an error pinning the version of a replaced middleware")
+	}
+}
+
+// Test_goExeKeyReplace ensures the key of the cached binary changes when the
+// Go sources of a replacing directory change.
+func Test_goExeKeyReplace(t *testing.T) {
+	root := t.TempDir()
+	fork := t.TempDir()
+	job := buildJob{
+		ctx:      context.Background(),
+		function: fn.Function{Root: root, Runtime: "go"},
+		hash:     "test",
+	}
+	job.replace = map[string]string{"knative.dev/func-go": fork}
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	write := func(dir, name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(root, "go.mod", "module f\n\ngo 1.21\n")
+	write(root, "f.go", "package f\n")
+	write(fork, "go.mod", "module knative.dev/func-go\n\ngo 1.21\n")
+	write(fork, "service.go", "package fn\n")
+
+	key, err := goExeKey(job, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(fork, "service.go", "package fn\n\nfunc Start() {}\n")
+	if changed, _ := goExeKey(job, p); changed == key {
+		t.Fatal("expected the key to change when the replacing directory changed")
+	}
+}
@@ -0,0 +1,59 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// GoToolchainLocal uses the installed go toolchain, even if the function's
+// go.mod requires a newer one (which then fails the build).
+const GoToolchainLocal = "local"
+
+// goToolchain is the format of a GOTOOLCHAIN which pins the toolchain: local
+// or a toolchain version such as go1.22.3 or go1.23rc1.
+var goToolchain = regexp.MustCompile(`^(local|go1\.\d+(\.\d+)?((rc|beta)\d+)?)$`)
+
+// WithGoToolchain pins the go toolchain with which Go functions are built
+// (GOTOOLCHAIN): GoToolchainLocal for that installed, or a version such as
+// go1.22.3, which is downloaded if not installed.  By default, the go
+// toolchain may switch to a newer toolchain when required by the function's
+// go.mod (its go or toolchain directive), silently downloading it.  When
+// offline (GOPROXY=off), where a download would fail, local is the default.
+func WithGoToolchain(toolchain string) BuilderOpt {
+	return func(b *Builder) {
+		b.goToolchain = toolchain
+	}
+}
+
+// ValidateGoToolchain returns an error if the toolchain is neither local nor
+// a go version.  Empty is the default.
+func ValidateGoToolchain(toolchain string) error {
+	if toolchain != "" && !goToolchain.MatchString(toolchain) {
+		return fmt.Errorf("invalid go toolchain %q: must be %q or a version such as \"go1.22.3\"", toolchain, GoToolchainLocal)
+	}
+	return nil
+}
+
+// goBuildToolchain returns the GOTOOLCHAIN with which to build, or empty to
+// leave that of the environment (by default auto).
+func (j buildJob) goBuildToolchain() string {
+	if j.options.goToolchain != "" {
+		return j.options.goToolchain
+	}
+	if j.offline() {
+		return GoToolchainLocal
+	}
+	return ""
+}
+
+// offline returns true if the go toolchain is configured not to download
+// modules (or toolchains), with GOPROXY=off.
+func (j buildJob) offline() bool {
+	proxy := os.Getenv("GOPROXY")
+	if j.options.goModules.Proxy != "" {
+		proxy = j.options.goModules.Proxy
+	}
+	return strings.TrimSpace(proxy) == "off"
+}
@@ -0,0 +1,53 @@
+package oci
+
+import (
+	"slices"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Test_goBuildEnvsToolchain ensures the go toolchain is that of the
+// environment by default, local when offline, and that pinned otherwise,
+// overriding the environment.
+func Test_goBuildEnvsToolchain(t *testing.T) {
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	t.Setenv("GOTOOLCHAIN", "auto")
+	t.Setenv("GOPROXY", "https://proxy.golang.org,direct")
+
+	// Default: that of the environment
+	envs := goBuildEnvs(p, buildJob{})
+	if !slices.Contains(envs, "GOTOOLCHAIN=auto") {
+		t.Fatalf("expected the environment's GOTOOLCHAIN, got %v", envs)
+	}
+
+	// Offline: local
+	t.Setenv("GOPROXY", "off")
+	envs = goBuildEnvs(p, buildJob{})
+	if !slices.Contains(envs, "GOTOOLCHAIN=local") || slices.Contains(envs, "GOTOOLCHAIN=auto") {
+		t.Fatalf("expected GOTOOLCHAIN=local when offline, got %v", envs)
+	}
+
+	// Pinned
+	job := buildJob{}
+	job.goToolchain = "go1.22.3"
+	envs = goBuildEnvs(p, job)
+	if !slices.Contains(envs, "GOTOOLCHAIN=go1.22.3") || slices.Contains(envs, "GOTOOLCHAIN=local") {
+		t.Fatalf("expected GOTOOLCHAIN=go1.22.3, got %v", envs)
+	}
+}
+
+// Test_ValidateGoToolchain ensures only local and go versions are accepted,
+// such that the toolchain is pinned.
+func Test_ValidateGoToolchain(t *testing.T) {
+	for _, toolchain := range []string{"", "local", "go1.22", "go1.22.3", "go1.23rc1"} {
+		if err := ValidateGoToolchain(toolchain); err != nil {
+			t.Errorf("expected %q to be valid, got %v", toolchain, err)
+		}
+	}
+	for _, toolchain := range []string{"auto", "path", "1.22.3", "go1.22.3+auto", "go1.22.3; rm"} {
+		if err := ValidateGoToolchain(toolchain); err == nil {
+			t.Errorf("expected %q to be invalid", toolchain)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// VCS stamping modes of Go functions' binaries (go build -buildvcs).
+const (
+	BuildVCSAuto = "auto"  // stamped unless it would fail (default)
+	BuildVCSOn   = "true"  // always stamped; the build fails if it can not be
+	BuildVCSOff  = "false" // never stamped
+)
+
+// WithBuildVCS sets whether version control information (the revision, time
+// and whether modified) is stamped into Go functions' binaries.  By default
+// (BuildVCSAuto) it is stamped as go build would, except when the build is
+// within a git repository which can not be read: when git is not installed,
+// the repository is a shallow clone (common in CI), or git fails.  Stamping
+// would then fail the build with "error obtaining VCS status".
+func WithBuildVCS(mode string) BuilderOpt {
+	return func(b *Builder) {
+		b.buildVCS = mode
+	}
+}
+
+// ValidateBuildVCS returns an error if the VCS stamping mode is not known.
+// Empty is the default (auto).
+func ValidateBuildVCS(mode string) error {
+	switch mode {
+	case "", BuildVCSAuto, BuildVCSOn, BuildVCSOff:
+		return nil
+	}
+	return fmt.Errorf("invalid VCS stamping mode %q: must be %q, %q or %q", mode, BuildVCSAuto, BuildVCSOn, BuildVCSOff)
+}
+
+// goBuildVCS returns the value of go build's -buildvcs flag, or empty to
+// leave the toolchain's default.
+func (j buildJob) goBuildVCS() string {
+	switch j.options.buildVCS {
+	case BuildVCSOn, BuildVCSOff:
+		return j.options.buildVCS
+	}
+	if !canStampVCS(j.goBuildDir()) {
+		return BuildVCSOff
+	}
+	return ""
+}
+
+// canStampVCS returns true unless dir is within a git repository which can
+// not be read to stamp VCS information: git is not installed, the repository
+// is a shallow clone, or git fails.
+func canStampVCS(dir string) bool {
+	repo := gitRepository(dir)
+	if repo == "" {
+		return true // nothing to stamp
+	}
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return false
+	}
+	out, err := exec.Command(git, "-C", repo, "rev-parse", "--is-shallow-repository").Output()
+	return err == nil && strings.TrimSpace(string(out)) != "true"
+}
+
+// gitRepository returns the root of the git repository containing dir (the
+// nearest directory with a .git entry), or empty if it is not in one.
+func gitRepository(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
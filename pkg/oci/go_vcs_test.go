@@ -0,0 +1,77 @@
+package oci
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Test_canStampVCS ensures VCS stamping is detected as possible outside of
+// a repository and within a complete one, but not within a shallow clone or
+// when git is not installed.
+func Test_canStampVCS(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required")
+	}
+	root := t.TempDir()
+	dir := filepath.Join(root, ".func", "builds", "by-hash", "abc")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not within a repository
+	if !canStampVCS(dir) {
+		t.Fatal("expected stamping outside of a repository")
+	}
+
+	// Within a repository
+	if out, err := exec.Command("git", "init", root).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v %s", err, out)
+	}
+	if !canStampVCS(dir) {
+		t.Fatal("expected stamping within a repository")
+	}
+
+	// Within a shallow clone
+	shallow := filepath.Join(root, ".git", "shallow")
+	if err := os.WriteFile(shallow, []byte("0123456789abcdef0123456789abcdef01234567\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if canStampVCS(dir) {
+		t.Fatal("expected no stamping within a shallow clone")
+	}
+	if err := os.Remove(shallow); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without git
+	t.Setenv("PATH", t.TempDir())
+	if canStampVCS(dir) {
+		t.Fatal("expected no stamping without git")
+	}
+}
+
+// Test_goBuildCmdVCS ensures an explicit VCS stamping mode is passed to go
+// build as is, and that unknown modes are invalid.
+func Test_goBuildCmdVCS(t *testing.T) {
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	job := buildJob{function: fn.Function{Root: t.TempDir()}}
+	for _, mode := range []string{BuildVCSOn, BuildVCSOff} {
+		job.options.buildVCS = mode
+		_, args, _, err := goBuildCmd(p, job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Contains(args, "-buildvcs="+mode) {
+			t.Fatalf("expected -buildvcs=%v, got %v", mode, args)
+		}
+	}
+	if err := ValidateBuildVCS("sometimes"); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+}
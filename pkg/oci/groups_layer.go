@@ -0,0 +1,286 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	slashpath "path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// The OCI image config has no supplementary groups: its user is a single
+// user[:group].  Container runtimes (runc, crun and so Docker, Podman and
+// Kubernetes) instead set the supplementary groups of the container's
+// process to those of the image's /etc/group of which its user, by name in
+// /etc/passwd, is a member.  The supplementary groups of the function's user
+// (build.groups) are therefore written as a layer of each platform's image
+// with the /etc/passwd and /etc/group of its base, the function's user and
+// its membership of the groups added.
+
+const (
+	passwdPath = "/etc/passwd"
+	groupPath  = "/etc/group"
+)
+
+// writeGroupsLayer writes the layer of the /etc/passwd and /etc/group of the
+// platform's image atop the base, in which the function's user is a member of
+// its supplementary groups (build.groups), if any.
+func writeGroupsLayer(job buildJob, p v1.Platform, base v1.Image) ([]ImageLayer, error) {
+	if len(job.function.Build.Groups) == 0 {
+		return []ImageLayer{}, nil
+	}
+	user := fmt.Sprintf("%v:%v", DefaultUid, DefaultGid)
+	if base != nil {
+		cfg, err := base.ConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Config.User != "" {
+			user = cfg.Config.User // see newConfigFile
+		}
+	}
+	files, err := readBaseFiles(job, base, passwdPath, groupPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the users and groups of the base image: %w", err)
+	}
+	passwd, group, err := addGroups(lines(files[passwdPath]), lines(files[groupPath]), user, job.function.Build.Groups)
+	if err != nil {
+		return nil, err
+	}
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "Adding user %v to groups %v\n", user, job.function.Build.Groups)
+	}
+
+	target := filepath.Join(job.buildDir(),
+		fmt.Sprintf("groupslayer.%v.tar.gz", strings.ReplaceAll(platformName(p), "/", ".")))
+	if err = newGroupsTarball(target, passwd, group); err != nil {
+		return nil, err
+	}
+	layer, err := writeLayer(job, target)
+	if err != nil {
+		return nil, err
+	}
+	return []ImageLayer{layer}, nil
+}
+
+// addGroups adds the user, in the form user[:group] of an image's config, to
+// the groups of the given IDs, returning the lines of /etc/passwd and
+// /etc/group.  A numeric user without an entry is added to /etc/passwd, as
+// membership is by name.  Groups without an entry are added.
+func addGroups(passwd, group []string, user string, gids []int) ([]string, []string, error) {
+	passwd, group = slices.Clone(passwd), slices.Clone(group)
+	u, g, _ := strings.Cut(user, ":")
+
+	// The user's name, by name or uid
+	name := ""
+	for _, line := range passwd {
+		if f := strings.Split(line, ":"); len(f) >= 4 && (f[0] == u || f[2] == u) {
+			name = f[0]
+			break
+		}
+	}
+	if name == "" {
+		uid, err := strconv.Atoi(u)
+		if err != nil {
+			return nil, nil, fmt.Errorf("user %q of the image is not in its %v", u, passwdPath)
+		}
+		gid := uid
+		if g != "" {
+			if gid, err = groupID(group, g); err != nil {
+				return nil, nil, err
+			}
+		}
+		name = "func"
+		if entry(passwd, name) != -1 {
+			name = fmt.Sprintf("func%v", uid)
+		}
+		passwd = append(passwd, fmt.Sprintf("%v:x:%v:%v:func:/func:/sbin/nologin", name, uid, gid))
+	}
+
+	// Membership of each group, by name
+	for _, gid := range gids {
+		i := slices.IndexFunc(group, func(line string) bool {
+			f := strings.Split(line, ":")
+			return len(f) >= 3 && f[2] == strconv.Itoa(gid)
+		})
+		if i == -1 {
+			groupName := fmt.Sprintf("func%v", gid)
+			group = append(group, fmt.Sprintf("%v:x:%v:%v", groupName, gid, name))
+			continue
+		}
+		f := strings.Split(group[i], ":")
+		for len(f) < 4 {
+			f = append(f, "")
+		}
+		members := strings.FieldsFunc(f[3], func(r rune) bool { return r == ',' })
+		if !slices.Contains(members, name) {
+			f[3] = strings.Join(append(members, name), ",")
+		}
+		group[i] = strings.Join(f, ":")
+	}
+	return passwd, group, nil
+}
+
+// groupID returns the ID of the group of the given name or ID.
+func groupID(group []string, g string) (int, error) {
+	if gid, err := strconv.Atoi(g); err == nil {
+		return gid, nil
+	}
+	if i := entry(group, g); i != -1 {
+		if gid, err := strconv.Atoi(strings.Split(group[i], ":")[2]); err == nil {
+			return gid, nil
+		}
+	}
+	return 0, fmt.Errorf("group %q of the image is not in its %v", g, groupPath)
+}
+
+// entry returns the index of the line of /etc/passwd or /etc/group of the
+// given name, or -1 if none.
+func entry(lines []string, name string) int {
+	return slices.IndexFunc(lines, func(line string) bool {
+		f := strings.Split(line, ":")
+		return len(f) >= 3 && f[0] == name
+	})
+}
+
+// lines returns the non-empty lines of a file.
+func lines(data []byte) (ll []string) {
+	for _, l := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(l) != "" {
+			ll = append(ll, l)
+		}
+	}
+	return
+}
+
+// newGroupsTarball writes /etc/passwd and /etc/group to a gzipped tarball at
+// target, owned by root as in any image.  The files are of a fixed time, such
+// that the layer is the same for each build of the same groups and base.
+func newGroupsTarball(target string, passwd, group []string) error {
+	targetFile, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+
+	gw := gzip.NewWriter(targetFile)
+	defer gw.Close()
+
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	for _, f := range []struct {
+		path  string
+		lines []string
+	}{{passwdPath, passwd}, {groupPath, group}} {
+		data := []byte(strings.Join(f.lines, "\n") + "\n")
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.path,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			ModTime:  epoch,
+		}
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err = tw.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readBaseFiles returns the contents of the given files of the base image
+// (absolute paths), as visible in it, omitting those which it does not have
+// or which are not regular files.
+func readBaseFiles(job buildJob, base v1.Image, paths ...string) (map[string][]byte, error) {
+	if base == nil {
+		return map[string][]byte{}, nil
+	}
+	layers, err := pulledBaseLayers(job, base)
+	if err != nil {
+		return nil, err
+	}
+	return readFiles(layers, paths...)
+}
+
+// readFiles returns the contents of the given files of the layers (lowest
+// first), as visible in the image of them.  Layers are read from the highest
+// until all are found.
+func readFiles(layers []v1.Layer, paths ...string) (map[string][]byte, error) {
+	var (
+		files   = map[string][]byte{}
+		found   = map[string]bool{} // paths found
+		deleted = map[string]bool{}
+		opaque  = map[string]bool{}
+	)
+	for i := len(layers) - 1; i >= 0 && len(found) < len(paths); i-- {
+		// Whiteouts of this layer apply only to lower layers
+		layerDeleted, layerOpaque := map[string]bool{}, map[string]bool{}
+		if err := readLayerFiles(layers[i], paths, files, found, deleted, opaque, layerDeleted, layerOpaque); err != nil {
+			return nil, err
+		}
+		for p := range layerDeleted {
+			deleted[p] = true
+		}
+		for p := range layerOpaque {
+			opaque[p] = true
+		}
+	}
+	return files, nil
+}
+
+// readLayerFiles reads those of the given files of a single layer which are
+// visible and not yet found.
+func readLayerFiles(layer v1.Layer, paths []string, files map[string][]byte, found, deleted, opaque, layerDeleted, layerOpaque map[string]bool) error {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		p := slashpath.Clean("/" + header.Name)
+		dir, base := slashpath.Split(p)
+		dir = slashpath.Clean(dir)
+		if hidden(p, deleted, opaque) {
+			continue
+		}
+		if base == whiteoutOpaque {
+			layerOpaque[dir] = true
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			layerDeleted[slashpath.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))] = true
+			continue
+		}
+		if !slices.Contains(paths, p) || found[p] {
+			continue
+		}
+		found[p] = true
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		var buf bytes.Buffer
+		if _, err = io.Copy(&buf, tr); err != nil {
+			return err
+		}
+		files[p] = buf.Bytes()
+	}
+}
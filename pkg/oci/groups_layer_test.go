@@ -0,0 +1,166 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// Test_addGroups ensures the user is added to the groups, by name, including
+// a numeric user without an entry, and groups without an entry are added.
+func Test_addGroups(t *testing.T) {
+	passwd := []string{"root:x:0:0:root:/root:/bin/bash", "nonroot:x:65532:65532::/home/nonroot:/sbin/nologin"}
+	group := []string{"root:x:0:", "video:x:44:alice", "nonroot:x:65532:"}
+
+	tests := []struct {
+		name       string
+		user       string
+		gids       []int
+		wantPasswd []string
+		wantGroup  []string
+		wantErr    bool
+	}{
+		{
+			name:       "numeric user without an entry",
+			user:       "1000:1000",
+			gids:       []int{44, 2000},
+			wantPasswd: append(passwd[:2:2], "func:x:1000:1000:func:/func:/sbin/nologin"),
+			wantGroup:  []string{"root:x:0:", "video:x:44:alice,func", "nonroot:x:65532:", "func2000:x:2000:func"},
+		},
+		{
+			name:       "user by name",
+			user:       "nonroot",
+			gids:       []int{0},
+			wantPasswd: passwd,
+			wantGroup:  []string{"root:x:0:nonroot", "video:x:44:alice", "nonroot:x:65532:"},
+		},
+		{
+			name:       "user by uid",
+			user:       "65532:65532",
+			gids:       []int{44},
+			wantPasswd: passwd,
+			wantGroup:  []string{"root:x:0:", "video:x:44:alice,nonroot", "nonroot:x:65532:"},
+		},
+		{
+			name:       "numeric user with a group by name",
+			user:       "1000:video",
+			gids:       []int{0},
+			wantPasswd: append(passwd[:2:2], "func:x:1000:44:func:/func:/sbin/nologin"),
+			wantGroup:  []string{"root:x:0:func", "video:x:44:alice", "nonroot:x:65532:"},
+		},
+		{
+			name:    "unknown user name",
+			user:    "alice",
+			gids:    []int{44},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPasswd, gotGroup, err := addGroups(passwd, group, tt.user, tt.gids)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantPasswd, gotPasswd); diff != "" {
+				t.Errorf("unexpected /etc/passwd (-want, +got): %v", diff)
+			}
+			if diff := cmp.Diff(tt.wantGroup, gotGroup); diff != "" {
+				t.Errorf("unexpected /etc/group (-want, +got): %v", diff)
+			}
+		})
+	}
+}
+
+// Test_readFiles ensures the files read from layers are those visible in the
+// image of them.
+func Test_readFiles(t *testing.T) {
+	base := testLayer(t, map[string]string{
+		"etc/passwd": "root:x:0:0:root:/root:/bin/bash",
+		"etc/group":  "root:x:0:",
+		"etc/hosts":  "localhost",
+	})
+	upper := testLayer(t, map[string]string{
+		"etc/.wh.group": "",
+		"etc/passwd":    "root:x:0:0:root:/root:/sbin/nologin",
+	})
+	got, err := readFiles([]v1.Layer{base, upper}, passwdPath, groupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{passwdPath: []byte("root:x:0:0:root:/root:/sbin/nologin")}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected files (-want, +got): %v", diff)
+	}
+}
+
+// TestBuilder_Groups ensures a function with supplementary groups is built
+// with a layer of the /etc/passwd and /etc/group in which its user is a
+// member of them.
+func TestBuilder_Groups(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.Groups = []int{2000}
+
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.languageBuilder = NewTestLanguageBuilder() // from scratch
+	job.keepTars = true
+	job.quiet = true
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+	if err = scaffold(job); err != nil {
+		t.Fatal(err)
+	}
+	if err = containerize(job); err != nil {
+		t.Fatal(err)
+	}
+
+	name := "groupslayer." + strings.ReplaceAll(platformName(job.platforms[0]), "/", ".") + ".tar.gz"
+	path := filepath.Join(job.buildDir(), name)
+	headers := readTarball(t, path)
+	for _, p := range []string{passwdPath, groupPath} {
+		if h := headers[p]; h == nil || h.Uid != 0 || h.Mode != 0644 {
+			t.Fatalf("expected %v owned by root, got %v", p, h)
+		}
+	}
+	layer, err := tarball.LayerFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := readFiles([]v1.Layer{layer}, passwdPath, groupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{
+		passwdPath: []byte("func:x:1000:1000:func:/func:/sbin/nologin\n"),
+		groupPath:  []byte("func2000:x:2000:func\n"),
+	}
+	if diff := cmp.Diff(want, files); diff != "" {
+		t.Fatalf("unexpected files (-want, +got): %v", diff)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package oci
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileKey uniquely identifies a file on the host (device and inode), and is
+// used to detect multiple hard links to the same file.
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// hardlinkKey returns the key identifying the file described by info, and
+// true if it is a regular file with more than one link to it.
+func hardlinkKey(info os.FileInfo) (fileKey, bool) {
+	if !info.Mode().IsRegular() {
+		return fileKey{}, false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink < 2 {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, true
+}
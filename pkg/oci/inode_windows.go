@@ -0,0 +1,12 @@
+package oci
+
+import "os"
+
+// fileKey uniquely identifies a file on the host.
+type fileKey struct{}
+
+// hardlinkKey always returns false on Windows, where inode information is not
+// available from the file info, resulting in hard links being copied.
+func hardlinkKey(_ os.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
@@ -0,0 +1,79 @@
+package oci
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/term"
+)
+
+// WithInspectFailure reports, when a build fails, the state of the build up
+// to the failure before its build directory is cleaned up: the phases
+// started, the last of each platform being that which failed, and the blobs
+// written to the partial OCI layout, by their readable names (see linkBlob).
+// This shows where the build broke, such as a base image pulled but a failed
+// compile.  When attached to a terminal the build then waits for Enter before
+// cleaning up, such that the build directory may be explored.
+func WithInspectFailure(inspect bool) BuilderOpt {
+	return func(b *Builder) {
+		b.inspect = inspect
+	}
+}
+
+// inspectFailure writes the report of the failed build job to w, then waits
+// for a line of in if it is a terminal.
+func inspectFailure(job buildJob, w io.Writer, in *os.File) {
+	fmt.Fprintf(w, "Build failed.  Build directory: %v\n", job.buildDir())
+	r := job.result()
+	if len(r.Timings) > 0 {
+		fmt.Fprintf(w, "Phases started: %v\n", r.Timings)
+	}
+	writeLayoutState(job, w)
+	if term.IsTerminal(int(in.Fd())) {
+		fmt.Fprintf(w, "Press Enter to clean up the build directory...")
+		_, _ = bufio.NewReader(in).ReadString('\n')
+	}
+}
+
+// writeLayoutState writes the blobs of the job's (partial) OCI layout to w,
+// with their sizes and readable names where linked, and whether its index was
+// written.
+func writeLayoutState(job buildJob, w io.Writer) {
+	names := map[string]string{} // readable names, by blob
+	links, _ := os.ReadDir(job.blobsByNameDir())
+	for _, l := range links {
+		if target, err := os.Readlink(filepath.Join(job.blobsByNameDir(), l.Name())); err == nil {
+			names[filepath.Base(target)] = l.Name()
+		}
+	}
+
+	blobs, _ := os.ReadDir(job.blobsDir())
+	if len(blobs) == 0 {
+		fmt.Fprintf(w, "Blobs written: none\n")
+	} else {
+		fmt.Fprintf(w, "Blobs written (%v):\n", job.blobsDir())
+	}
+	sort.Slice(blobs, func(i, j int) bool {
+		return names[blobs[i].Name()] < names[blobs[j].Name()]
+	})
+	for _, b := range blobs {
+		var size int64
+		if info, err := b.Info(); err == nil {
+			size = info.Size()
+		}
+		name := names[b.Name()]
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(w, "  sha256:%.12v %10v  %v\n", b.Name(), size, name)
+	}
+	if _, err := os.Stat(filepath.Join(job.ociDir(), "index.json")); err != nil {
+		fmt.Fprintf(w, "Index: not written\n")
+	} else {
+		fmt.Fprintf(w, "Index: %v\n", filepath.Join(job.ociDir(), "index.json"))
+	}
+}
@@ -0,0 +1,67 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestBuilder_Inspect ensures the report of a failed build includes the phases
+// started, the blobs written by readable name, and that its index was not
+// written.
+func TestBuilder_Inspect(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := NewTestLanguageBuilder()
+	impl.WritePlatformFn = func(BuildContext, v1.Platform) ([]ImageLayer, error) {
+		return nil, errors.New("compile failed")
+	}
+
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.languageBuilder = impl
+	job.inspect = true
+	job.quiet = true
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+	if err = scaffold(job); err != nil {
+		t.Fatal(err)
+	}
+	if err = containerize(job); err == nil {
+		t.Fatal("expected the build to fail")
+	}
+
+	// Not a terminal, so the report does not wait
+	in, err := os.Open(filepath.Join(root, "func.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	var out bytes.Buffer
+	inspectFailure(job, &out, in)
+	for _, expected := range []string{job.buildDir(), "compile linux/" + runtime.GOARCH, "datalayer", "certs", "Index: not written"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("expected %q in the report, got:\n%v", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package oci
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// DockerConfigDir is the directory of the docker configuration (config.json)
+// from which registry credentials are read by default: $DOCKER_CONFIG if set,
+// otherwise .docker in the user's home directory.  Empty if neither is
+// defined.
+func DockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// WithDockerConfig sets the directory of the docker configuration
+// (config.json) from which the credentials used to pull base images are
+// read, such as in CI where the home directory is not that of the user.
+// Defaults to DockerConfigDir.
+func WithDockerConfig(dir string) BuilderOpt {
+	return func(b *Builder) {
+		b.dockerConfig = dir
+	}
+}
+
+// dockerConfigKeychain resolves the credentials of registries from the
+// config.json of a docker configuration directory, including by way of its
+// credential helpers, and is anonymous for registries without.
+type dockerConfigKeychain struct {
+	dir string
+}
+
+func (k dockerConfigKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if k.dir == "" {
+		return authn.Anonymous, nil
+	}
+	cf, err := config.Load(k.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{target.String(), target.RegistryStr()} {
+		if key == name.DefaultRegistry {
+			key = authn.DefaultAuthKey
+		}
+		cfg, err := cf.GetAuthConfig(key)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Username == "" && cfg.Password == "" && cfg.Auth == "" &&
+			cfg.IdentityToken == "" && cfg.RegistryToken == "" {
+			continue
+		}
+		return authn.FromConfig(authn.AuthConfig{
+			Username:      cfg.Username,
+			Password:      cfg.Password,
+			Auth:          cfg.Auth,
+			IdentityToken: cfg.IdentityToken,
+			RegistryToken: cfg.RegistryToken,
+		}), nil
+	}
+	return authn.Anonymous, nil
+}
+
+// keychain from which the credentials used to pull base images are read.
+func (j buildJob) keychain() authn.Keychain {
+	if j.dockerConfig != "" {
+		return dockerConfigKeychain{dir: j.dockerConfig}
+	}
+	return dockerConfigKeychain{dir: DockerConfigDir()}
+}
@@ -0,0 +1,65 @@
+package oci
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Test_dockerConfigKeychain ensures credentials for pulling base images are
+// read from the docker configuration directory provided, or $DOCKER_CONFIG by
+// default, and are anonymous for registries without.
+func Test_dockerConfigKeychain(t *testing.T) {
+	dir := t.TempDir()
+	auth := base64.StdEncoding.EncodeToString([]byte("alice:secret"))
+	config := `{"auths": {"registry.example.com": {"auth": "` + auth + `"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	authenticated, err := name.NewRepository("registry.example.com/base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymous, err := name.NewRepository("other.example.com/base")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// resolve the credentials of the repository with the job's keychain.
+	resolve := func(job buildJob, repo name.Repository) authn.AuthConfig {
+		t.Helper()
+		a, err := job.keychain().Resolve(repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := a.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *cfg
+	}
+
+	t.Setenv("DOCKER_CONFIG", t.TempDir()) // empty
+	job := buildJob{options: options{dockerConfig: dir}}
+	if cfg := resolve(job, authenticated); cfg.Username != "alice" || cfg.Password != "secret" {
+		t.Fatalf("expected the credentials of the docker config, got %+v", cfg)
+	}
+	if cfg := resolve(job, anonymous); cfg != (authn.AuthConfig{}) {
+		t.Fatalf("expected anonymous for a registry without credentials, got %+v", cfg)
+	}
+	if cfg := resolve(buildJob{}, authenticated); cfg != (authn.AuthConfig{}) {
+		t.Fatalf("expected anonymous with an empty $DOCKER_CONFIG, got %+v", cfg)
+	}
+
+	t.Setenv("DOCKER_CONFIG", dir)
+	if DockerConfigDir() != dir {
+		t.Fatalf("expected the docker config directory %v, got %v", dir, DockerConfigDir())
+	}
+	if cfg := resolve(buildJob{}, authenticated); cfg.Username != "alice" {
+		t.Fatalf("expected the credentials of $DOCKER_CONFIG, got %+v", cfg)
+	}
+}
@@ -0,0 +1,33 @@
+package oci
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+)
+
+const (
+	// ustarMaxSize is the size of the largest file of a USTAR header, whose
+	// size field is of 11 octal digits (8 GiB).
+	ustarMaxSize = 1<<33 - 1
+
+	// largeFileSize is the size of a single file beyond which its layer is
+	// warned to bloat the image, such as that of a bundled model.
+	largeFileSize = 1 << 30
+)
+
+// largeFiles writes the header in the PAX format if its file is too large
+// for a USTAR header, such that large assets bundled with a function are
+// packaged in full, and warns of files large enough to bloat the image.
+func largeFiles(header *tar.Header) {
+	if header.Typeflag != tar.TypeReg {
+		return
+	}
+	if header.Size > ustarMaxSize {
+		header.Format = tar.FormatPAX
+	}
+	if header.Size > largeFileSize {
+		fmt.Fprintf(os.Stderr, "Warning: %v is %.1f GiB, which bloats the image and slows its pull.  Consider fetching it at runtime rather than bundling it with the function\n",
+			header.Name, float64(header.Size)/(1<<30))
+	}
+}
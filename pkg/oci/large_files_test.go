@@ -0,0 +1,26 @@
+package oci
+
+import (
+	"archive/tar"
+	"io"
+	"testing"
+)
+
+// Test_largeFiles ensures only headers of files larger than USTAR supports
+// are written in the PAX format, which then represents their size.
+func Test_largeFiles(t *testing.T) {
+	small := &tar.Header{Name: "/func/main.go", Typeflag: tar.TypeReg, Size: 1 << 20}
+	largeFiles(small)
+	if small.Format != tar.FormatUnknown {
+		t.Fatalf("expected the format of a small file to be unchanged, got %v", small.Format)
+	}
+
+	large := &tar.Header{Name: "/func/model.bin", Typeflag: tar.TypeReg, Size: ustarMaxSize + 1, Mode: 0644}
+	largeFiles(large)
+	if large.Format != tar.FormatPAX {
+		t.Fatalf("expected PAX of a file of %v bytes, got %v", large.Size, large.Format)
+	}
+	if err := tar.NewWriter(io.Discard).WriteHeader(large); err != nil {
+		t.Fatalf("expected the header of a file of %v bytes to be written. %v", large.Size, err)
+	}
+}
@@ -0,0 +1,104 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// WithOCIOutput writes the built OCI layout to the directory at the given
+// path after each successful build (see ExportLayout), such that it may be
+// collected from a predictable path without knowing the build's fingerprint.
+func WithOCIOutput(dir string) BuilderOpt {
+	return func(b *Builder) {
+		b.ociOutput = dir
+	}
+}
+
+// ExportLayout writes the OCI layout at dir to the directory dest: the
+// oci-layout file, the index and each blob reachable from the index.  Blobs
+// are hard-linked where dest is on the same filesystem, and otherwise copied.
+// The layout is written beside dest and then moved into place, replacing any
+// layout previously exported there, such that dest is never partial.  An
+// existing dest which is neither empty nor an OCI layout is not replaced.
+func ExportLayout(dir, dest string) error {
+	return exportLayout(dir, dest, DigestSHA256)
+}
+
+// exportLayout exports the layout at dir to dest (see ExportLayout) with
+// digests of the given algorithm, converting its blobs unless of SHA-256.
+func exportLayout(dir, dest, algorithm string) (err error) {
+	blobs, err := layoutBlobs(dir)
+	if err != nil {
+		return fmt.Errorf("error reading OCI layout %v. %w", dir, err)
+	}
+	if err = checkLayoutDest(dest); err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(tmp)
+		}
+	}()
+	if err = os.Chmod(tmp, 0755); err != nil {
+		return
+	}
+	if algorithm != DigestSHA256 {
+		if _, err = convertLayout(dir, tmp, algorithm); err != nil {
+			return fmt.Errorf("error writing OCI layout %v. %w", dest, err)
+		}
+		if err = os.RemoveAll(dest); err != nil {
+			return
+		}
+		return os.Rename(tmp, dest)
+	}
+	if err = os.MkdirAll(filepath.Join(tmp, "blobs", "sha256"), os.ModePerm); err != nil {
+		return
+	}
+	for h := range blobs {
+		if err = linkOrCopy(blobPath(dir, h), blobPath(tmp, h)); err != nil {
+			return fmt.Errorf("error writing blob %v to %v. %w", h, dest, err)
+		}
+	}
+	for _, name := range []string{"oci-layout", "index.json"} {
+		if err = copyFile(filepath.Join(dir, name), filepath.Join(tmp, name)); err != nil {
+			return fmt.Errorf("error writing %v to %v. %w", name, dest, err)
+		}
+	}
+	if err = os.RemoveAll(dest); err != nil {
+		return
+	}
+	return os.Rename(tmp, dest)
+}
+
+// checkLayoutDest returns an error if dest exists and is neither an empty
+// directory nor an OCI layout, such that it is not replaced.
+func checkLayoutDest(dest string) error {
+	entries, err := os.ReadDir(dest)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(entries) == 0) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("invalid OCI output %v. %w", dest, err)
+	}
+	if _, err = os.Stat(filepath.Join(dest, "oci-layout")); err != nil {
+		return fmt.Errorf("invalid OCI output %v: exists and is not an OCI layout", dest)
+	}
+	return nil
+}
+
+// copyFile copies the regular file at source to dest.
+func copyFile(source, dest string) error {
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0644)
+}
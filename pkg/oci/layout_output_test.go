@@ -0,0 +1,94 @@
+package oci
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// TestExportLayout ensures the exported layout is a valid OCI layout of the
+// blobs reachable from the index, hard-linked on the same filesystem, which
+// replaces a previously exported layout but not any other directory.
+func TestExportLayout(t *testing.T) {
+	layer, err := random.Layer(1024, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+	})
+	dir := t.TempDir()
+	if _, err = layout.Write(dir, index); err != nil {
+		t.Fatal(err)
+	}
+	// A stray blob, which is not referenced, is omitted
+	if err = os.WriteFile(filepath.Join(dir, "blobs", "sha256", "stray"), []byte("stray"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "out", "oci")
+	for i := 0; i < 2; i++ { // replacing the first
+		if err = ExportLayout(dir, dest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exported, err := layout.ImageIndexFromPath(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = exported.IndexManifest(); err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := os.ReadDir(filepath.Join(dest, "blobs", "sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 manifest, 1 config and 1 layer
+	if len(blobs) != 3 {
+		t.Fatalf("expected 3 blobs, got %v", len(blobs))
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(dest)); len(entries) != 1 {
+		t.Fatalf("expected only the layout beside the output, got %v", entries)
+	}
+
+	// Blobs are hard-linked
+	digest, err := layer.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := os.Stat(filepath.Join(dir, "blobs", "sha256", digest.Hex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exportedBlob, err := os.Stat(filepath.Join(dest, "blobs", "sha256", digest.Hex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(source, exportedBlob) {
+		t.Fatal("expected the blob to be hard-linked")
+	}
+
+	// A directory which is not a layout is not replaced
+	other := t.TempDir()
+	if err = os.WriteFile(filepath.Join(other, "keep.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ExportLayout(dir, other); err == nil {
+		t.Fatal("expected error exporting over a directory which is not a layout")
+	}
+	if _, err = os.Stat(filepath.Join(other, "keep.txt")); err != nil {
+		t.Fatalf("expected the directory to be kept. %v", err)
+	}
+}
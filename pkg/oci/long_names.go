@@ -0,0 +1,18 @@
+package oci
+
+import "archive/tar"
+
+// ustarNameSize is the longest name, or link target, of a USTAR header
+// without the prefix field.
+const ustarNameSize = 100
+
+// longNames writes the header in the PAX format if its name or link target
+// is longer than fits a USTAR header, such that deeply nested paths, such
+// as those of a function's dependencies beneath /func, are preserved.
+// Headers of shorter names are unchanged, and so too the digests of layers
+// which have none.
+func longNames(header *tar.Header) {
+	if len(header.Name) > ustarNameSize || len(header.Linkname) > ustarNameSize {
+		header.Format = tar.FormatPAX
+	}
+}
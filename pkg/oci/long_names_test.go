@@ -0,0 +1,79 @@
+package oci
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Test_newDataTarballLongNames ensures paths, and link targets, longer than
+// the limits of USTAR are preserved in the data layer.
+func Test_newDataTarballLongNames(t *testing.T) {
+	root := t.TempDir()
+	dir := root
+	for i := 0; i < 6; i++ { // /func/ + 6*51 characters: beyond USTAR's 255
+		dir = filepath.Join(dir, strings.Repeat(string(rune('a'+i)), 50))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, []byte("nested"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" {
+		if err = os.Symlink(rel, filepath.Join(root, "link")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := filepath.Join(t.TempDir(), "datalayer.tar.gz")
+	if err = newDataTarball(root, target, defaultIgnored, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	headers := readTarball(t, target)
+	name := "/func/" + filepath.ToSlash(rel)
+	if len(name) <= 255 {
+		t.Fatalf("expected a path beyond the limits of USTAR, got %v characters", len(name))
+	}
+	hdr, ok := headers[name]
+	if !ok {
+		t.Fatalf("expected %v in the layer", name)
+	}
+	if hdr.Typeflag != tar.TypeReg || hdr.Size != int64(len("nested")) {
+		t.Fatalf("expected a regular file with content, got %v", hdr)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if link := headers["/func/link"]; link == nil || link.Linkname != filepath.ToSlash(rel) {
+		t.Fatalf("expected link to %v, got %v", rel, link)
+	}
+}
+
+// Test_longNames ensures only headers whose names exceed USTAR's are written
+// in the PAX format.
+func Test_longNames(t *testing.T) {
+	short := &tar.Header{Name: "/func/main.go", Linkname: "/func/other.go"}
+	longNames(short)
+	if short.Format != tar.FormatUnknown {
+		t.Fatalf("expected the format of a short name to be unchanged, got %v", short.Format)
+	}
+	for _, h := range []*tar.Header{
+		{Name: "/func/" + strings.Repeat("a", ustarNameSize)},
+		{Name: "/func/link", Linkname: "/func/" + strings.Repeat("a", ustarNameSize)},
+	} {
+		longNames(h)
+		if h.Format != tar.FormatPAX {
+			t.Fatalf("expected PAX of %v -> %v, got %v", h.Name, h.Linkname, h.Format)
+		}
+	}
+}
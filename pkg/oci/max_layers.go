@@ -0,0 +1,44 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// WithMaxLayers limits the number of layers of each platform's image, those
+// of its base included, as registries and container runtimes limit the
+// layers of an image (often to 127).  An image of more layers than max is
+// warned of, or fails the build with ErrTooManyLayers if fail is set.  The
+// layers of the image may be reduced by squashing them (see WithSquash).
+// Zero, the default, is no limit.
+func WithMaxLayers(max int, fail bool) BuilderOpt {
+	return func(b *Builder) {
+		b.maxLayers = max
+		b.maxLayersFail = fail
+	}
+}
+
+// ValidateMaxLayers returns an error if the maximum number of layers is
+// negative.  Zero (no limit) is valid.
+func ValidateMaxLayers(max int) error {
+	if max < 0 {
+		return fmt.Errorf("invalid maximum number of layers %v: must be at least 1", max)
+	}
+	return nil
+}
+
+// checkLayers warns of, or fails with ErrTooManyLayers, an image of the
+// platform of more than the maximum number of layers (see WithMaxLayers).
+func checkLayers(job buildJob, p v1.Platform, layers int) error {
+	if job.maxLayers == 0 || layers <= job.maxLayers {
+		return nil
+	}
+	err := ErrTooManyLayers{Platform: platformName(p), Layers: layers, Max: job.maxLayers}
+	if job.maxLayersFail {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %v.  Consider squashing its layers (see --squash)\n", err)
+	return nil
+}
@@ -0,0 +1,68 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestWriteManifest_MaxLayers ensures an image of more layers, those of its
+// base included, than the maximum fails the build only if so configured.
+func TestWriteManifest_MaxLayers(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+
+	base, err := random.Image(64, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := []ImageLayer{{Descriptor: v1.Descriptor{}}, {Descriptor: v1.Descriptor{}}}
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	write := func(max int, fail bool) error {
+		job.maxLayers, job.maxLayersFail = max, fail
+		_, err := writeManifest(job, p, base, v1.Descriptor{}, layers, nil)
+		return err
+	}
+
+	// Within the maximum, or without one
+	for _, max := range []int{0, 5} {
+		if err = write(max, true); err != nil {
+			t.Fatalf("unexpected error for a maximum of %v. %v", max, err)
+		}
+	}
+	// Beyond it: a warning, unless failing
+	if err = write(4, false); err != nil {
+		t.Fatalf("expected a warning, got %v", err)
+	}
+	var e ErrTooManyLayers
+	if err = write(4, true); !errors.As(err, &e) {
+		t.Fatalf("expected ErrTooManyLayers, got %v", err)
+	}
+	if e.Layers != 5 || e.Max != 4 || e.Platform != "linux/amd64" {
+		t.Fatalf("unexpected error %+v", e)
+	}
+	if err = ValidateMaxLayers(-1); err == nil {
+		t.Fatal("expected a negative maximum to be invalid")
+	}
+}
@@ -0,0 +1,119 @@
+package oci
+
+import (
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Media type sets of the built image.
+const (
+	MediaTypesOCI    = "oci"    // OCI image index, manifests, config and layers (default)
+	MediaTypesDocker = "docker" // Docker schema2 manifest list, manifests, config and layers
+)
+
+// dockerLayers are the Docker equivalents of the OCI layer media types.
+var dockerLayers = map[types.MediaType]types.MediaType{
+	types.OCILayer:             types.DockerLayer,
+	types.OCIUncompressedLayer: types.DockerUncompressedLayer,
+	types.OCIRestrictedLayer:   types.DockerForeignLayer,
+}
+
+// WithMediaTypes sets the media types of the built image: MediaTypesOCI
+// (the default) or MediaTypesDocker, for registries and tools which only
+// accept Docker schema2.  With Docker, the index is written as a manifest
+// list, and the media types of the manifests, configs and all layers
+// (including those of the base image) are their Docker equivalents.
+func WithMediaTypes(set string) BuilderOpt {
+	return func(b *Builder) {
+		b.mediaType = set
+	}
+}
+
+// ValidateMediaTypes returns an error if the media type set is not known.
+// Empty is the default (OCI).
+func ValidateMediaTypes(set string) error {
+	switch set {
+	case "", MediaTypesOCI, MediaTypesDocker:
+		return nil
+	}
+	return fmt.Errorf("invalid media type %q: must be %q or %q", set, MediaTypesOCI, MediaTypesDocker)
+}
+
+// mediaTypes of the image being built.
+type mediaTypes struct {
+	docker bool
+}
+
+func (j buildJob) mediaTypes() mediaTypes {
+	return mediaTypes{docker: j.options.mediaType == MediaTypesDocker}
+}
+
+func (m mediaTypes) index() types.MediaType {
+	if m.docker {
+		return types.DockerManifestList
+	}
+	return types.OCIImageIndex
+}
+
+func (m mediaTypes) manifest() types.MediaType {
+	if m.docker {
+		return types.DockerManifestSchema2
+	}
+	return types.OCIManifestSchema1
+}
+
+func (m mediaTypes) config() types.MediaType {
+	if m.docker {
+		return types.DockerConfigJSON
+	}
+	return types.OCIConfigJSON
+}
+
+// layers returns the layer descriptors with their media types converted to
+// the set, as a new slice.  Layers of the base image are left as they are
+// when building OCI, as they are accepted as such.
+func (m mediaTypes) layers(dd []v1.Descriptor) []v1.Descriptor {
+	layers := make([]v1.Descriptor, len(dd))
+	for i, d := range dd {
+		if mt, ok := dockerLayers[d.MediaType]; ok && m.docker {
+			d.MediaType = mt
+		}
+		layers[i] = d
+	}
+	return layers
+}
+
+// typedIndex is an index whose media type is that of its manifest, as an
+// OCI layout's index is otherwise always presumed to be an OCI image index.
+type typedIndex struct {
+	imageIndex
+	mediaType types.MediaType
+}
+
+// imageIndex is embedded by typedIndex, as the field may not be named for the
+// interface, which has a method of the same name.
+type imageIndex = v1.ImageIndex
+
+func (i typedIndex) MediaType() (types.MediaType, error) {
+	return i.mediaType, nil
+}
+
+// withIndexMediaType returns the index (as read from an OCI layout) with the
+// media type declared by its manifest, such that a Docker manifest list is
+// pushed as such.
+func withIndexMediaType(ii v1.ImageIndex) (v1.ImageIndex, error) {
+	manifest, err := ii.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	mt, err := ii.MediaType()
+	if err != nil {
+		return nil, err
+	}
+	if manifest.MediaType == "" || manifest.MediaType == mt {
+		return ii, nil
+	}
+	return typedIndex{imageIndex: ii, mediaType: manifest.MediaType}, nil
+}
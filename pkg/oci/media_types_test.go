@@ -0,0 +1,66 @@
+package oci
+
+import (
+	"os"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// TestMediaTypes ensures the Docker media type set converts the layers
+// consistently, leaving them unchanged for OCI.
+func TestMediaTypes(t *testing.T) {
+	layers := []v1.Descriptor{
+		{MediaType: types.DockerLayer}, // eg. of the base
+		{MediaType: types.OCILayer},
+		{MediaType: types.OCIUncompressedLayer},
+	}
+
+	oci := mediaTypes{}.layers(layers)
+	for i := range layers {
+		if oci[i].MediaType != layers[i].MediaType {
+			t.Fatalf("expected layer %v to be unchanged, got %v", layers[i].MediaType, oci[i].MediaType)
+		}
+	}
+
+	docker := mediaTypes{docker: true}.layers(layers)
+	for i, want := range []types.MediaType{types.DockerLayer, types.DockerLayer, types.DockerUncompressedLayer} {
+		if docker[i].MediaType != want {
+			t.Fatalf("expected layer %v to be %v, got %v", i, want, docker[i].MediaType)
+		}
+	}
+	if layers[1].MediaType != types.OCILayer {
+		t.Fatal("expected the given layers to be unmodified")
+	}
+
+	if err := ValidateMediaTypes("schema1"); err == nil {
+		t.Fatal("expected an unknown media type set to be invalid")
+	}
+}
+
+// TestWriteIndex_Docker ensures an index written with Docker media types is
+// a manifest list, and read from the layout as such to be pushed.
+func TestWriteIndex_Docker(t *testing.T) {
+	job := buildJob{function: fn.Function{Root: t.TempDir()}, hash: "abc"}
+	job.mediaType = MediaTypesDocker
+	if err := os.MkdirAll(job.ociDir(), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeIndex(job, []v1.Descriptor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ii, err := layout.ImageIndexFromPath(job.ociDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ii, err = withIndexMediaType(ii); err != nil {
+		t.Fatal(err)
+	}
+	if mt, _ := ii.MediaType(); mt != types.DockerManifestList {
+		t.Fatalf("expected a Docker manifest list, got %v", mt)
+	}
+}
@@ -0,0 +1,88 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Mirror pushes the function's image, once pushed (f.Build.Image), to the
+// given image, such as one of a registry for disaster recovery, returning
+// its digest.  Where the image is of the same registry as the function's,
+// its blobs are mounted from the function's image rather than uploaded
+// again.  Otherwise they are uploaded from the last build.  Signatures of the
+// last build, if any (see WithSigning), are pushed to the mirror as well.
+func (p *Pusher) Mirror(ctx context.Context, f fn.Function, image string) (digest string, err error) {
+	var opts []name.Option
+	if p.Insecure {
+		opts = append(opts, name.Insecure)
+	}
+	source, err := name.ParseReference(f.Build.Image, opts...)
+	if err != nil {
+		return
+	}
+	ref, err := name.ParseReference(image, opts...)
+	if err != nil {
+		return
+	}
+
+	updates, done := make(chan v1.Update, 10), make(chan bool, 1)
+	go p.handleUpdates(ctx, updates, done)
+	defer func() { done <- true }()
+
+	ii, err := p.mirrorSource(ctx, f, source, ref)
+	if err != nil {
+		return
+	}
+	credentials, _ := p.credentialsProvider(ctx, image)
+	if err = p.writeIndex(ctx, ref, ii, credentials, updates); err != nil {
+		return
+	}
+	// Signatures of the index and manifests, if signed, as of the function's
+	// image, whose digests the mirror's are.
+	buildDir, err := getLastBuildDir(f)
+	if err != nil {
+		return
+	}
+	if err = p.writeSignatures(ctx, ref, buildDir, credentials); err != nil {
+		return
+	}
+	h, err := ii.Digest()
+	if err != nil {
+		return
+	}
+	digest = h.String()
+	if p.Verbose {
+		fmt.Printf("\ndigest: %s\n", h)
+	}
+	return
+}
+
+// mirrorSource is the index pushed to the mirror ref: that pushed to the
+// registry of the source if the same, such that its blobs are mounted, and
+// otherwise that of the last build.
+func (p *Pusher) mirrorSource(ctx context.Context, f fn.Function, source, ref name.Reference) (ii v1.ImageIndex, err error) {
+	if source.Context().RegistryStr() == ref.Context().RegistryStr() {
+		credentials, _ := p.credentialsProvider(ctx, source.String())
+		oo, err := p.remoteOptions(ctx, credentials)
+		if err != nil {
+			return nil, err
+		}
+		return remote.Index(source, oo...)
+	}
+	buildDir, err := getLastBuildDir(f)
+	if err != nil {
+		return
+	}
+	if ii, err = layout.ImageIndexFromPath(filepath.Join(buildDir, "oci")); err != nil {
+		return
+	}
+	return withIndexMediaType(ii)
+}
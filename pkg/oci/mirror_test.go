@@ -0,0 +1,111 @@
+package oci
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// TestPusher_Mirror ensures the pushed image is mirrored to another image
+// with the same digest, mounting its blobs when of the same registry.
+func TestPusher_Mirror(t *testing.T) {
+	newRegistry := func() string {
+		s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+		t.Cleanup(s.Close)
+		return strings.TrimPrefix(s.URL, "http://")
+	}
+	var (
+		primary = newRegistry()
+		other   = newRegistry()
+		root    = t.TempDir()
+	)
+
+	// The last build of the function, as pushed
+	ii, err := layout.ImageIndexFromPath(newTestLayout(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = layout.Write(filepath.Join(root, fn.RunDataDir, "builds", "last", "oci"), ii); err != nil {
+		t.Fatal(err)
+	}
+	f := fn.Function{Root: root, Name: "f"}
+	f.Build.Image = primary + "/funcs/f:latest"
+
+	p := NewPusher(true, true, false)
+	digest, err := p.Push(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.Image = f.ImageNameWithDigest(digest)
+
+	// Of the same registry: mounted; of another: uploaded
+	for image, mounted := range map[string]bool{primary + "/dr/f:latest": true, other + "/funcs/f:latest": false} {
+		source, err := name.ParseReference(f.Build.Image, name.Insecure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := name.ParseReference(image, name.Insecure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ii, err := p.mirrorSource(context.Background(), f, source, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		im, err := ii.IndexManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := ii.Image(im.Manifests[0].Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := layers[0].(*remote.MountableLayer); ok != mounted {
+			t.Fatalf("expected the layers of %v to be mountable: %v", image, mounted)
+		}
+	}
+	for _, image := range []string{primary + "/dr/f:latest", other + "/funcs/f:latest"} {
+		mirrored, err := p.Mirror(context.Background(), f, image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mirrored != digest {
+			t.Fatalf("expected %v to be of digest %v, got %v", image, digest, mirrored)
+		}
+		ref, err := name.ParseReference(image, name.Insecure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := remote.Head(ref)
+		if err != nil {
+			t.Fatalf("expected %v to be pushed. %v", image, err)
+		}
+		if d.Digest.String() != digest {
+			t.Fatalf("expected %v of digest %v, got %v", image, digest, d.Digest)
+		}
+	}
+
+	// Without a last build, an image of another registry can not be pushed
+	if err = os.RemoveAll(filepath.Join(root, fn.RunDataDir)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Mirror(context.Background(), f, other+"/other/f:latest"); err == nil {
+		t.Fatal("expected error mirroring without a last build")
+	}
+}
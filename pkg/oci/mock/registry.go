@@ -1,11 +1,19 @@
 package mock
 
 import (
+	"fmt"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	impl "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
 type Registry struct {
@@ -42,3 +50,43 @@ func (r *Registry) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		r.RegistryImpl.ServeHTTP(res, req)
 	}
 }
+
+// SeedBase writes a deterministic, multi-arch base image to the registry at
+// the given repository and tag.  The index contains one pseudo-random image
+// (of the given number of layers) per platform, and the seed makes the
+// content reproducible across test runs.  Returned is the full reference
+// (including this registry's address) suitable for use as a base image.
+func (r *Registry) SeedBase(repo, tag string, seed int64, layers int64, platforms ...v1.Platform) (string, v1.ImageIndex, error) {
+	image := fmt.Sprintf("%v/%v:%v", r.Addr(), repo, tag)
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return image, nil, err
+	}
+
+	source := rand.NewSource(seed)
+	var index v1.ImageIndex = empty.Index
+	for _, p := range platforms {
+		img, err := random.Image(1024, layers, random.WithSource(source))
+		if err != nil {
+			return image, nil, err
+		}
+		cfg, err := img.ConfigFile()
+		if err != nil {
+			return image, nil, err
+		}
+		cfg = cfg.DeepCopy()
+		cfg.OS = p.OS
+		cfg.Architecture = p.Architecture
+		cfg.Variant = p.Variant
+		if img, err = mutate.ConfigFile(img, cfg); err != nil {
+			return image, nil, err
+		}
+		platform := p
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{
+			Add:        img,
+			Descriptor: v1.Descriptor{Platform: &platform},
+		})
+	}
+
+	return image, index, remote.WriteIndex(ref, index)
+}
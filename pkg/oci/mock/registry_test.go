@@ -0,0 +1,59 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// TestRegistry_SeedBase ensures a seeded base image resolves to the image of
+// each of its platforms, and that seeding is deterministic.
+func TestRegistry_SeedBase(t *testing.T) {
+	registry := NewRegistry()
+	defer registry.Close()
+
+	platforms := []v1.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm64"},
+	}
+	base, index, err := registry.SeedBase("base", "latest", 1, 2, platforms...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range platforms {
+		image, err := remote.Image(ref, remote.WithPlatform(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := image.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Architecture != p.Architecture {
+			t.Fatalf("expected base for %v, got %v", p.Architecture, cfg.Architecture)
+		}
+	}
+
+	// The same seed is the same index
+	_, again, err := registry.SeedBase("again", "latest", 1, 2, platforms...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d1, err := index.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := again.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d1 != d2 {
+		t.Fatalf("expected the same seed to be the same index, got %v and %v", d1, d2)
+	}
+}
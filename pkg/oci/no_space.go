@@ -0,0 +1,19 @@
+package oci
+
+import (
+	"errors"
+	"syscall"
+)
+
+// noSpace returns err as ErrNoSpace if it is of the device of dir having no
+// space left (ENOSPC), such as of a layer or base image layer written to
+// it.  Partially written files are removed where they are written: those of
+// the blob cache by ensureCached and linkOrCopy, and those of the build
+// directory with it, such that a build retried once space is freed succeeds.
+func noSpace(dir string, err error) error {
+	var e ErrNoSpace
+	if err == nil || errors.As(err, &e) || !errors.Is(err, syscall.ENOSPC) {
+		return err
+	}
+	return ErrNoSpace{Dir: dir, Err: err}
+}
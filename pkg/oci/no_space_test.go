@@ -0,0 +1,99 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestBuilder_NoSpace ensures a build which runs out of disk space fails
+// with ErrNoSpace, leaving no partial build behind.
+func TestBuilder_NoSpace(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := NewTestLanguageBuilder()
+	impl.WriteSharedFn = func(ctx BuildContext) ([]ImageLayer, error) {
+		path := filepath.Join(ctx.buildDir(), "layer.tar.gz")
+		return nil, &os.PathError{Op: "write", Path: path, Err: syscall.ENOSPC}
+	}
+	b := NewBuilder("", false, WithQuiet(true))
+	b.impl = impl
+
+	var e ErrNoSpace
+	_, err = b.BuildPlatform(context.Background(), f, fn.Platform{OS: "linux", Architecture: "amd64"})
+	if !errors.As(err, &e) || !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("expected ErrNoSpace, got %v", err)
+	}
+	if e.Dir != filepath.Join(root, fn.RunDataDir) {
+		t.Fatalf("expected the directory of the builds, got %v", e.Dir)
+	}
+	dd, _ := os.ReadDir(filepath.Join(root, fn.RunDataDir, "builds", "by-hash"))
+	if len(dd) != 0 {
+		t.Fatalf("expected the partial build to be removed, got %v", dd)
+	}
+}
+
+// Test_ensureCachedNoSpace ensures a base layer partially written to the
+// blob cache, such as when the disk is full, is removed.
+func Test_ensureCachedNoSpace(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.sharedCache = t.TempDir()
+	layer, err := random.Layer(64, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e ErrNoSpace
+	if err = noSpace(job.cacheDir(), ensureCached(job, noSpaceLayer{layer})); !errors.As(err, &e) {
+		t.Fatalf("expected ErrNoSpace, got %v", err)
+	}
+	dd, _ := os.ReadDir(job.cacheDir())
+	if len(dd) != 0 {
+		t.Fatalf("expected no partial entry in the cache, got %v", dd)
+	}
+
+	// Once space is freed, the layer is cached
+	if err = ensureCached(job, layer); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// noSpaceLayer is a layer whose compressed contents fail to be read as if
+// written to a full disk.
+type noSpaceLayer struct {
+	v1.Layer
+}
+
+func (l noSpaceLayer) Compressed() (io.ReadCloser, error) {
+	return io.NopCloser(noSpaceReader{}), nil
+}
+
+type noSpaceReader struct{}
+
+func (noSpaceReader) Read([]byte) (int, error) { return 0, syscall.ENOSPC }
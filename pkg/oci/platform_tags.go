@@ -0,0 +1,64 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// WithPlatformTagSuffix additionally tags the image of a single-platform
+// build with its architecture as a suffix on push, such as :latest-amd64,
+// such that it is addressable alongside the index (:latest).  Pushing a
+// build of more than one platform is then an error.
+func WithPlatformTagSuffix(suffix bool) Opt {
+	return func(pusher *Pusher) {
+		pusher.platformTagSuffix = suffix
+	}
+}
+
+// platformTag returns the tag of the image of the single platform of the
+// index: that of ref suffixed with the architecture (and variant), such as
+// latest-amd64 or latest-armv7.
+func platformTag(ref name.Reference, ii v1.ImageIndex) (tag name.Tag, img v1.Image, err error) {
+	t, ok := ref.(name.Tag)
+	if !ok {
+		err = fmt.Errorf("the platform tag suffix requires an image with a tag, got %v", ref)
+		return
+	}
+	im, err := ii.IndexManifest()
+	if err != nil {
+		return
+	}
+	var dd []v1.Descriptor
+	for _, d := range im.Manifests {
+		if d.Platform != nil {
+			dd = append(dd, d)
+		}
+	}
+	if len(dd) != 1 {
+		err = fmt.Errorf("the platform tag suffix requires a single-platform build, got %v platforms", len(dd))
+		return
+	}
+	tag = t.Context().Tag(t.TagStr() + "-" + dd[0].Platform.Architecture + dd[0].Platform.Variant)
+	img, err = ii.Image(dd[0].Digest)
+	return
+}
+
+// writePlatformTag tags the image with its platform tag (see platformTag).
+// Its blobs were uploaded with the index, so only its manifest is written.
+func (p *Pusher) writePlatformTag(ctx context.Context, tag name.Tag, img v1.Image, creds Credentials) error {
+	oo, err := p.remoteOptions(ctx, creds)
+	if err != nil {
+		return err
+	}
+	if err = remote.Write(tag, img, oo...); err != nil {
+		return err
+	}
+	if p.Verbose {
+		fmt.Printf("\ntagged: %s\n", tag)
+	}
+	return nil
+}
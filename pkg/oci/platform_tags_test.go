@@ -0,0 +1,127 @@
+package oci
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// TestPusher_PlatformTagSuffix ensures the image of a single-platform build
+// is also tagged with its architecture, and that a build of more than one
+// platform is not pushed.
+func TestPusher_PlatformTagSuffix(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(s.Close)
+	reg := strings.TrimPrefix(s.URL, "http://")
+
+	// The last build of the function, of linux/arm/v7 only
+	img, err := random.Image(512, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+	})
+	root := t.TempDir()
+	if _, err = layout.Write(filepath.Join(root, fn.RunDataDir, "builds", "last", "oci"), ii); err != nil {
+		t.Fatal(err)
+	}
+	f := fn.Function{Root: root, Name: "f"}
+	f.Build.Image = reg + "/funcs/f:1.0"
+
+	p := NewPusher(true, true, false, WithPlatformTagSuffix(true))
+	if _, err = p.Push(context.Background(), f); err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(reg+"/funcs/f:1.0-armv7", name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := remote.Head(ref)
+	if err != nil {
+		t.Fatalf("expected the platform tag to be pushed. %v", err)
+	}
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Digest != want {
+		t.Fatalf("expected the platform tag of the image %v, got %v", want, d.Digest)
+	}
+
+	// Of two platforms: neither the index nor a platform tag is pushed
+	root = t.TempDir()
+	if _, err = layout.Write(filepath.Join(root, fn.RunDataDir, "builds", "last", "oci"), mustIndex(t, newTestLayout(t))); err != nil {
+		t.Fatal(err)
+	}
+	f = fn.Function{Root: root, Name: "g"}
+	f.Build.Image = reg + "/funcs/g:latest"
+	if _, err = p.Push(context.Background(), f); err == nil {
+		t.Fatal("expected error pushing a build of two platforms with a platform tag suffix")
+	}
+	ref, err = name.ParseReference(f.Build.Image, name.Insecure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = remote.Head(ref); err == nil {
+		t.Fatal("expected the index of two platforms not to be pushed")
+	}
+}
+
+// Test_platformTag ensures the tag is suffixed with the architecture and
+// variant, and requires a tagged reference.
+func Test_platformTag(t *testing.T) {
+	img, err := random.Image(64, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+	})
+
+	ref, err := name.ParseReference("example.com/alice/f") // latest by default
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, _, err := platformTag(ref, ii)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.String() != "example.com/alice/f:latest-amd64" {
+		t.Fatalf("unexpected platform tag %v", tag)
+	}
+
+	ref, err = name.ParseReference("example.com/alice/f@sha256:" + strings.Repeat("0", 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = platformTag(ref, ii); err == nil {
+		t.Fatal("expected error for an image without a tag")
+	}
+}
+
+func mustIndex(t *testing.T, path string) v1.ImageIndex {
+	t.Helper()
+	ii, err := layout.ImageIndexFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ii
+}
@@ -0,0 +1,104 @@
+package oci
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Proxy through which a build reaches the network, such as a corporate HTTP
+// proxy: to pull base images, and for the commands of the build such as go
+// (modules) and pip (packages).  Each field overrides the corresponding
+// variable of the environment, which is otherwise honored as-is.
+type Proxy struct {
+	HTTP  string // HTTP_PROXY: proxy of http requests
+	HTTPS string // HTTPS_PROXY: proxy of https requests
+
+	// NoProxy (NO_PROXY) are the hosts which bypass the proxy, such as
+	// internal registries and module proxies: a comma-separated list of
+	// host names, domains (.example.com, including its subdomains), IPs and
+	// CIDRs, each optionally with a port.  Loopback addresses always bypass
+	// the proxy.
+	NoProxy string
+}
+
+// WithProxy builds through the given proxy (see Proxy), rather than only
+// that of the environment.
+func WithProxy(p Proxy) BuilderOpt {
+	return func(b *Builder) {
+		b.proxy = p
+	}
+}
+
+// ValidateProxy returns an error if a proxy of the given is not a URL of an
+// http, https or socks5 proxy.  Empty is that of the environment.
+func ValidateProxy(p Proxy) error {
+	for _, v := range []string{p.HTTP, p.HTTPS} {
+		if v == "" {
+			continue
+		}
+		u, err := url.Parse(v)
+		if err != nil {
+			return fmt.Errorf("invalid proxy %q. %w", v, err)
+		}
+		if u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			return fmt.Errorf("invalid proxy %q: expected a URL such as http://proxy.example.com:3128", v)
+		}
+	}
+	return nil
+}
+
+// proxyConfig is that of the environment with the fields of the job's proxy
+// which are set.
+func (j buildJob) proxyConfig() *httpproxy.Config {
+	c := httpproxy.FromEnvironment()
+	if j.proxy.HTTP != "" {
+		c.HTTPProxy = j.proxy.HTTP
+	}
+	if j.proxy.HTTPS != "" {
+		c.HTTPSProxy = j.proxy.HTTPS
+	}
+	if j.proxy.NoProxy != "" {
+		c.NoProxy = j.proxy.NoProxy
+	}
+	return c
+}
+
+// transport of the requests to registries, such as base image pulls, which
+// is that of the job's proxy, if any.  remote.DefaultTransport is otherwise
+// used, which honors the proxy of the environment.
+func (j buildJob) transport() http.RoundTripper {
+	if j.proxy == (Proxy{}) {
+		return remote.DefaultTransport
+	}
+	t, ok := remote.DefaultTransport.(*http.Transport)
+	if !ok {
+		return remote.DefaultTransport
+	}
+	t = t.Clone()
+	proxy := j.proxyConfig().ProxyFunc()
+	t.Proxy = func(r *http.Request) (*url.URL, error) {
+		return proxy(r.URL)
+	}
+	return t
+}
+
+// proxyEnvs of the commands of the build, such as go and pip, which are
+// those of the fields of the job's proxy which are set.  Both cases are set,
+// as tools differ in that which they honor (pip, for example, lower).
+func (j buildJob) proxyEnvs() (envs []string) {
+	for _, e := range []struct{ name, value string }{
+		{"HTTP_PROXY", j.proxy.HTTP},
+		{"HTTPS_PROXY", j.proxy.HTTPS},
+		{"NO_PROXY", j.proxy.NoProxy},
+	} {
+		if e.value != "" {
+			envs = append(envs, e.name+"="+e.value, strings.ToLower(e.name)+"="+e.value)
+		}
+	}
+	return
+}
@@ -0,0 +1,89 @@
+package oci
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Test_ValidateProxy ensures proxies are URLs of http, https or socks5
+// proxies.
+func Test_ValidateProxy(t *testing.T) {
+	tests := []struct {
+		proxy Proxy
+		valid bool
+	}{
+		{Proxy{}, true},
+		{Proxy{HTTP: "http://proxy.example.com:3128", HTTPS: "http://proxy.example.com:3128"}, true},
+		{Proxy{HTTPS: "socks5://127.0.0.1:1080", NoProxy: ".example.com,10.0.0.0/8"}, true},
+		{Proxy{HTTP: "proxy.example.com:3128"}, false}, // no scheme
+		{Proxy{HTTPS: "ftp://proxy.example.com"}, false},
+		{Proxy{HTTPS: "http://"}, false},
+	}
+	for _, test := range tests {
+		if err := ValidateProxy(test.proxy); (err == nil) != test.valid {
+			t.Errorf("expected %+v valid: %v, got %v", test.proxy, test.valid, err)
+		}
+	}
+}
+
+// TestBuilder_Proxy ensures registries are reached through the proxy of the
+// build, but for those of NO_PROXY, and that the build's commands are given
+// the proxy in their environment.
+func TestBuilder_Proxy(t *testing.T) {
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"} {
+		t.Setenv(name, "")
+	}
+
+	// Without a proxy: that of the environment (remote.DefaultTransport)
+	job := buildJob{ctx: context.Background()}
+	if job.transport() != remote.DefaultTransport {
+		t.Fatal("expected the default transport without a proxy")
+	}
+	if envs := job.proxyEnvs(); len(envs) != 0 {
+		t.Fatalf("expected no proxy environment, got %v", envs)
+	}
+
+	job.proxy = Proxy{HTTPS: "http://proxy.example.com:3128", NoProxy: "internal.example.com"}
+	tr, ok := job.transport().(*http.Transport)
+	if !ok {
+		t.Fatalf("unexpected transport %T", job.transport())
+	}
+	for url, want := range map[string]string{
+		"https://registry.example.com/v2/": "http://proxy.example.com:3128",
+		"https://internal.example.com/v2/": "", // NO_PROXY
+	} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxy, err := tr.Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if got != want {
+			t.Fatalf("expected %v to be proxied by %q, got %v", url, want, proxy)
+		}
+	}
+
+	// The environment of the build's commands, such as pip
+	out, err := runCmd(job, exec.Command("env"))
+	if err != nil {
+		t.Skipf("env not available: %v", err)
+	}
+	envs := strings.Split(strings.TrimSpace(out), "\n")
+	for _, env := range []string{"HTTPS_PROXY=http://proxy.example.com:3128", "https_proxy=http://proxy.example.com:3128", "NO_PROXY=internal.example.com", "no_proxy=internal.example.com"} {
+		if !slices.Contains(envs, env) {
+			t.Fatalf("expected %v in the environment of the build's commands, got %v", env, envs)
+		}
+	}
+}
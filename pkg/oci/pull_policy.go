@@ -0,0 +1,234 @@
+package oci
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// Pull policies of base images (see WithBasePullPolicy).
+const (
+	// PullAlways resolves the base image from its registry on every build,
+	// ignoring the local daemon, and fetches its layers again.
+	PullAlways = "always"
+	// PullIfNotPresent uses the base image of the local daemon if it exists,
+	// and otherwise resolves it from its registry, fetching only the layers
+	// which are not cached.  The default.
+	PullIfNotPresent = "if-not-present"
+	// PullNever uses the base image of the local daemon, or that last pulled
+	// by the function and held by the blob cache, without contacting the
+	// registry, failing with ErrBaseNotCached if there is neither.
+	PullNever = "never"
+)
+
+// WithBasePullPolicy sets when the base image is fetched from its registry:
+// PullAlways, for freshness, PullIfNotPresent (the default) or PullNever, for
+// builds which must not reach the network such as air-gapped CI.
+func WithBasePullPolicy(policy string) BuilderOpt {
+	return func(b *Builder) {
+		b.pullPolicy = policy
+	}
+}
+
+// ValidatePullPolicy returns an error if the base image pull policy is not
+// known.  Empty is the default (if-not-present).
+func ValidatePullPolicy(policy string) error {
+	switch policy {
+	case "", PullAlways, PullIfNotPresent, PullNever:
+		return nil
+	}
+	return fmt.Errorf("invalid base image pull policy %q: must be %q, %q or %q", policy, PullAlways, PullIfNotPresent, PullNever)
+}
+
+// pullPolicy of the job's base image.
+func (j buildJob) pullPolicy() string {
+	if j.options.pullPolicy == "" {
+		return PullIfNotPresent
+	}
+	return j.options.pullPolicy
+}
+
+// refetch returns true if the cached base layer is to be fetched again,
+// which with PullAlways is once per build.
+func refetch(job buildJob, digest string) bool {
+	if job.pullPolicy() != PullAlways {
+		return false
+	}
+	b := job.bases
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fetched[digest] {
+		return false
+	}
+	b.fetched[digest] = true
+	return true
+}
+
+// cacheBase writes the manifest and config of the base image pulled from
+// its registry to the blob cache, beside its layers, such that it can be
+// built upon without the registry (see PullNever).
+func cacheBase(job buildJob, image v1.Image) (err error) {
+	digest, err := image.Digest()
+	if err != nil {
+		return
+	}
+	manifest, err := image.RawManifest()
+	if err != nil {
+		return
+	}
+	if err = writeCachedBlob(job, digest, manifest); err != nil {
+		return
+	}
+	config, err := image.ConfigName()
+	if err != nil {
+		return
+	}
+	bb, err := image.RawConfigFile()
+	if err != nil {
+		return
+	}
+	return writeCachedBlob(job, config, bb)
+}
+
+// writeCachedBlob writes the blob to the blob cache, unless cached.
+func writeCachedBlob(job buildJob, digest v1.Hash, bb []byte) error {
+	path := filepath.Join(job.cacheDir(), digest.Hex)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(job.cacheDir(), os.ModePerm); err != nil {
+		return err
+	}
+
+	// The cache may be shared by concurrent builds, writing the same
+	// temporary file (see ensureCached): lock the entry and check again.
+	unlock, err := lockCached(job, digest.Hex)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err = os.Stat(path); err == nil {
+		return nil
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, bb, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// cachedBase returns the base image of the platform last pulled by the
+// function for the reference (see recordBase), built from its manifest,
+// config and layers in the blob cache, or ErrBaseNotCached.
+func cachedBase(job buildJob, ref name.Reference, p v1.Platform) (v1.Image, error) {
+	notCached := func(reason string) error {
+		return ErrBaseNotCached{Ref: ref.String(), Platform: platformName(p), Reason: reason}
+	}
+	digests, err := readBaseDigests(job)
+	if err != nil {
+		return nil, err
+	}
+	recorded := digests[ref.String()][platformName(p)]
+	if recorded == "" {
+		return nil, notCached("it has not been pulled")
+	}
+	digest, err := v1.NewHash(recorded)
+	if err != nil {
+		return nil, notCached(err.Error())
+	}
+	image, err := readCachedImage(job, digest)
+	if err != nil {
+		return nil, notCached(err.Error())
+	}
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "Using cached base image %v (%v)\n", ref, digest)
+	}
+	return image, nil
+}
+
+// readCachedImage returns the image of the digest built from its manifest,
+// config and layers in the blob cache, or an error naming that which is not
+// cached.
+func readCachedImage(job buildJob, digest v1.Hash) (v1.Image, error) {
+	var err error
+	img := cachedImage{dir: job.cacheDir()}
+	if img.manifest, err = os.ReadFile(filepath.Join(img.dir, digest.Hex)); err != nil {
+		return nil, fmt.Errorf("its manifest %v is not in the blob cache", digest)
+	}
+	m, err := v1.ParseManifest(bytes.NewReader(img.manifest))
+	if err != nil {
+		return nil, err
+	}
+	img.mediaType = m.MediaType
+	if img.mediaType == "" {
+		img.mediaType = types.OCIManifestSchema1
+	}
+	if img.config, err = os.ReadFile(filepath.Join(img.dir, m.Config.Digest.Hex)); err != nil {
+		return nil, fmt.Errorf("its config %v is not in the blob cache", m.Config.Digest)
+	}
+	img.layers = map[v1.Hash]v1.Descriptor{}
+	for _, l := range m.Layers {
+		if _, err = os.Stat(filepath.Join(img.dir, l.Digest.Hex)); err != nil {
+			return nil, fmt.Errorf("its layer %v is not in the blob cache", l.Digest)
+		}
+		img.layers[l.Digest] = l
+	}
+	return partial.CompressedToImage(img)
+}
+
+// readBaseDigests returns the digests of the base images last resolved by
+// the function (see recordBase).
+func readBaseDigests(job buildJob) (digests baseDigests, err error) {
+	digests = baseDigests{}
+	bb, err := os.ReadFile(filepath.Join(job.dataDir(), "builds", "bases.json"))
+	if os.IsNotExist(err) {
+		return digests, nil
+	} else if err != nil {
+		return
+	}
+	_ = json.Unmarshal(bb, &digests) // an unreadable record records nothing
+	return
+}
+
+// cachedImage is an image of which each blob is in the blob cache.
+type cachedImage struct {
+	dir       string
+	mediaType types.MediaType
+	manifest  []byte
+	config    []byte
+	layers    map[v1.Hash]v1.Descriptor
+}
+
+func (i cachedImage) MediaType() (types.MediaType, error) { return i.mediaType, nil }
+func (i cachedImage) RawManifest() ([]byte, error)        { return i.manifest, nil }
+func (i cachedImage) RawConfigFile() ([]byte, error)      { return i.config, nil }
+
+func (i cachedImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	desc, ok := i.layers[h]
+	if !ok {
+		return nil, fmt.Errorf("layer %v is not of the cached image", h)
+	}
+	return cachedLayer{path: filepath.Join(i.dir, h.Hex), desc: desc}, nil
+}
+
+// cachedLayer is a compressed layer in the blob cache.
+type cachedLayer struct {
+	path string
+	desc v1.Descriptor
+}
+
+func (l cachedLayer) Digest() (v1.Hash, error)            { return l.desc.Digest, nil }
+func (l cachedLayer) Size() (int64, error)                { return l.desc.Size, nil }
+func (l cachedLayer) MediaType() (types.MediaType, error) { return l.desc.MediaType, nil }
+func (l cachedLayer) Compressed() (io.ReadCloser, error)  { return os.Open(l.path) }
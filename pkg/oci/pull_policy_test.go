@@ -0,0 +1,97 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci/mock"
+	. "knative.dev/func/pkg/testing"
+)
+
+func TestValidatePullPolicy(t *testing.T) {
+	for _, policy := range []string{"", PullAlways, PullIfNotPresent, PullNever} {
+		if err := ValidatePullPolicy(policy); err != nil {
+			t.Fatalf("unexpected error for %q. %v", policy, err)
+		}
+	}
+	if err := ValidatePullPolicy("sometimes"); err == nil {
+		t.Fatal("expected an unknown pull policy to error")
+	}
+}
+
+// TestBuilder_PullPolicyNever ensures a base image which has not been pulled
+// fails with ErrBaseNotCached when never pulled, and that once pulled it is
+// built upon from the blob cache without the registry.
+func TestBuilder_PullPolicyNever(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	registry := mock.NewRegistry()
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	if _, _, err := registry.SeedBase("library/base", "latest", 1, 2, p); err != nil {
+		t.Fatal(err)
+	}
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.BaseImage = registry.Addr().String() + "/library/base:latest"
+
+	pull := func(policy string) (v1.Image, error) {
+		job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.options.pullPolicy = policy
+		if err = setup(job); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup(job)
+		defer os.Remove(job.pidLink())
+		return pullBase(job, p)
+	}
+
+	// Not yet pulled
+	_, err = pull(PullNever)
+	var e ErrBaseNotCached
+	if !errors.As(err, &e) {
+		t.Fatalf("expected ErrBaseNotCached, got %v", err)
+	}
+
+	// Pulled from the registry
+	pulled, err := pull(PullIfNotPresent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := pulled.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then from the cache, without the registry
+	registry.Close()
+	cached, err := pull(PullNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := cached.Digest(); err != nil || got != want {
+		t.Fatalf("expected the cached base image %v, got %v (%v)", want, got, err)
+	}
+	layers, err := cached.Layers()
+	if err != nil || len(layers) != 2 {
+		t.Fatalf("expected the 2 layers of the cached base image, got %v (%v)", len(layers), err)
+	}
+	if _, err = cached.ConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Always pulled: the registry is required
+	if _, err = pull(PullAlways); err == nil || errors.As(err, &e) {
+		t.Fatalf("expected always pulling without the registry to fail, got %v", err)
+	}
+}
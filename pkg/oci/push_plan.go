@@ -0,0 +1,148 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// PushPlan is what pushing the function's last build to its image would do,
+// as determined by querying the registry without writing to it.
+type PushPlan struct {
+	Image  string  // the function's image (f.Build.Image)
+	Digest v1.Hash // of the index of the last build
+	Remote v1.Hash // of the image in the registry, if it exists
+	// Blobs which are not in the image's repository, and so would be
+	// uploaded: the configs and layers of each platform, each once.  Empty
+	// when UpToDate.
+	Blobs []PushBlob
+}
+
+// PushBlob is a blob which would be uploaded when pushing.
+type PushBlob struct {
+	Platform string // os/arch[/variant] of the first image of the blob
+	Digest   v1.Hash
+	Size     int64
+	Config   bool // the image's config rather than a layer
+}
+
+// UpToDate returns true if the image in the registry is that of the last
+// build, such that pushing would change nothing.
+func (p PushPlan) UpToDate() bool {
+	return p.Remote == p.Digest
+}
+
+// Plan returns what pushing the function's last build to its image would
+// upload (see PushPlan), without pushing.  The digest of the image in the
+// registry is compared with that of the last build and, where they differ,
+// the registry is asked for each blob of the build.  The manifests and
+// index themselves, being small, are not reported.
+func (p *Pusher) Plan(ctx context.Context, f fn.Function) (plan PushPlan, err error) {
+	plan.Image = f.Build.Image
+	credentials, _ := p.credentialsProvider(ctx, f.Build.Image)
+	buildDir, err := getLastBuildDir(f)
+	if err != nil {
+		return
+	}
+	var opts []name.Option
+	if p.Insecure {
+		opts = append(opts, name.Insecure)
+	}
+	ref, err := name.ParseReference(f.Build.Image, opts...)
+	if err != nil {
+		return
+	}
+	ii, err := layout.ImageIndexFromPath(filepath.Join(buildDir, "oci"))
+	if err != nil {
+		return
+	}
+	if ii, err = withIndexMediaType(ii); err != nil {
+		return
+	}
+	if plan.Digest, err = ii.Digest(); err != nil {
+		return
+	}
+	oo, err := p.remoteOptions(ctx, credentials)
+	if err != nil {
+		return
+	}
+
+	// The image, if it exists, is up-to-date if of the same digest
+	if d, err := remote.Head(ref, oo...); err == nil {
+		plan.Remote = d.Digest
+	} else if !isNotFound(err) {
+		return plan, err
+	}
+	if plan.UpToDate() {
+		return
+	}
+
+	// Otherwise each blob not in the repository would be uploaded
+	im, err := ii.IndexManifest()
+	if err != nil {
+		return
+	}
+	seen := map[v1.Hash]bool{}
+	for _, desc := range im.Manifests {
+		img, err := ii.Image(desc.Digest)
+		if err != nil {
+			return plan, err
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			return plan, err
+		}
+		var platform string
+		if desc.Platform != nil {
+			platform = platformName(*desc.Platform)
+		}
+		blobs := []PushBlob{{Platform: platform, Digest: m.Config.Digest, Size: m.Config.Size, Config: true}}
+		for _, l := range m.Layers {
+			blobs = append(blobs, PushBlob{Platform: platform, Digest: l.Digest, Size: l.Size})
+		}
+		for _, b := range blobs {
+			if seen[b.Digest] {
+				continue
+			}
+			seen[b.Digest] = true
+			exists, err := blobExists(ref.Context().Digest(b.Digest.String()), oo)
+			if err != nil {
+				return plan, err
+			}
+			if !exists {
+				plan.Blobs = append(plan.Blobs, b)
+			}
+		}
+	}
+	return
+}
+
+// blobExists returns true if the registry has the blob in the repository.
+func blobExists(ref name.Digest, oo []remote.Option) (bool, error) {
+	l, err := remote.Layer(ref, oo...)
+	if err != nil {
+		return false, err
+	}
+	if _, err = l.Size(); isNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isNotFound returns true if the error is that of a registry responding
+// that the manifest or blob requested does not exist.
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
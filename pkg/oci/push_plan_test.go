@@ -0,0 +1,80 @@
+package oci
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// TestPusher_Plan ensures the plan of a push reports each blob to upload
+// while the image is not in the registry, and that it is up-to-date once
+// pushed, without pushing.
+func TestPusher_Plan(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(s.Close)
+	var (
+		reg  = strings.TrimPrefix(s.URL, "http://")
+		root = t.TempDir()
+	)
+	ii, err := layout.ImageIndexFromPath(newTestLayout(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = layout.Write(filepath.Join(root, fn.RunDataDir, "builds", "last", "oci"), ii); err != nil {
+		t.Fatal(err)
+	}
+	f := fn.Function{Root: root, Name: "f"}
+	f.Build.Image = reg + "/funcs/f:latest"
+	p := NewPusher(true, true, false)
+
+	// Not pushed: 2 configs, the shared base layer and 2 own layers
+	plan, err := p.Plan(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.UpToDate() || len(plan.Blobs) != 5 {
+		t.Fatalf("expected 5 blobs to upload, got %+v", plan)
+	}
+	for _, b := range plan.Blobs {
+		if b.Platform == "" || b.Size == 0 {
+			t.Fatalf("expected the platform and size of each blob, got %+v", b)
+		}
+	}
+	plan, err = p.Plan(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Blobs) != 5 {
+		t.Fatalf("expected planning not to push, got %+v", plan)
+	}
+
+	// Pushed: up-to-date
+	digest, err := p.Push(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan, err = p.Plan(context.Background(), f); err != nil {
+		t.Fatal(err)
+	}
+	if !plan.UpToDate() || plan.Remote.String() != digest || len(plan.Blobs) != 0 {
+		t.Fatalf("expected the image to be up-to-date, got %+v", plan)
+	}
+
+	// Another tag of the repository: not up-to-date, but no blob to upload
+	f.Build.Image = reg + "/funcs/f:other"
+	if plan, err = p.Plan(context.Background(), f); err != nil {
+		t.Fatal(err)
+	}
+	if plan.UpToDate() || len(plan.Blobs) != 0 {
+		t.Fatalf("expected only the manifests to push, got %+v", plan)
+	}
+}
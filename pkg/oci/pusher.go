@@ -38,10 +38,8 @@ type Pusher struct {
 	Username string
 	Verbose  bool
 
-	updates chan v1.Update
-	done    chan bool
-
-	transport http.RoundTripper
+	transport         http.RoundTripper
+	platformTagSuffix bool // also tag single-platform images by arch
 }
 
 func EmptyCredentialsProvider(ctx context.Context, registry string) (Credentials, error) {
@@ -72,8 +70,6 @@ func NewPusher(insecure, anon, verbose bool, opts ...Opt) *Pusher {
 		Insecure:            insecure,
 		Anonymous:           anon,
 		Verbose:             verbose,
-		updates:             make(chan v1.Update, 10),
-		done:                make(chan bool, 1),
 		transport:           remote.DefaultTransport,
 	}
 	for _, opt := range opts {
@@ -85,8 +81,9 @@ func NewPusher(insecure, anon, verbose bool, opts ...Opt) *Pusher {
 func (p *Pusher) Push(ctx context.Context, f fn.Function) (digest string, err error) {
 	credentials, _ := p.credentialsProvider(ctx, f.Build.Image)
 
-	go p.handleUpdates(ctx)
-	defer func() { p.done <- true }()
+	updates, done := make(chan v1.Update, 10), make(chan bool, 1)
+	go p.handleUpdates(ctx, updates, done)
+	defer func() { done <- true }()
 	buildDir, err := getLastBuildDir(f)
 	if err != nil {
 		return
@@ -107,7 +104,30 @@ func (p *Pusher) Push(ctx context.Context, f fn.Function) (digest string, err er
 	if err != nil {
 		return
 	}
-	if err = p.writeIndex(ctx, ref, ii, credentials); err != nil {
+	if ii, err = withIndexMediaType(ii); err != nil {
+		return
+	}
+	// The platform tag is determined before pushing, such that a build of
+	// more than one platform is not pushed at all.
+	var (
+		tag name.Tag
+		img v1.Image
+	)
+	if p.platformTagSuffix {
+		if tag, img, err = platformTag(ref, ii); err != nil {
+			return
+		}
+	}
+	if err = p.writeIndex(ctx, ref, ii, credentials, updates); err != nil {
+		return
+	}
+	if p.platformTagSuffix {
+		if err = p.writePlatformTag(ctx, tag, img, credentials); err != nil {
+			return
+		}
+	}
+	// Signatures of the index and manifests, if signed (see WithSigning)
+	if err = p.writeSignatures(ctx, ref, buildDir, credentials); err != nil {
 		return
 	}
 	h, err := ii.Digest()
@@ -121,11 +141,18 @@ func (p *Pusher) Push(ctx context.Context, f fn.Function) (digest string, err er
 	return
 }
 
-func (p *Pusher) handleUpdates(ctx context.Context) {
+// handleUpdates reports the progress of a push, whose updates are closed
+// once written, until done.  Each push has its own updates, as they are
+// closed by the push.
+func (p *Pusher) handleUpdates(ctx context.Context, updates <-chan v1.Update, done <-chan bool) {
 	var bar *progress.ProgressBar
 	for {
 		select {
-		case update := <-p.updates:
+		case update, ok := <-updates:
+			if !ok {
+				updates = nil // written: wait for done
+				continue
+			}
 			if bar == nil {
 				bar = progress.NewOptions64(update.Total,
 					progress.OptionSetVisibility(term.IsTerminal(int(os.Stdin.Fd()))),
@@ -136,7 +163,7 @@ func (p *Pusher) handleUpdates(ctx context.Context) {
 			}
 			_ = bar.Set64(update.Complete)
 			continue
-		case <-p.done:
+		case <-done:
 			if bar != nil {
 				_ = bar.Finish()
 			}
@@ -159,23 +186,31 @@ func getLastBuildDir(f fn.Function) (string, error) {
 	return dir, nil
 }
 
-// writeIndex to its defined registry.
-func (p *Pusher) writeIndex(ctx context.Context, ref name.Reference, ii v1.ImageIndex, creds Credentials) error {
+// writeIndex to its defined registry, reporting progress to updates.
+func (p *Pusher) writeIndex(ctx context.Context, ref name.Reference, ii v1.ImageIndex, creds Credentials, updates chan<- v1.Update) error {
+	oo, err := p.remoteOptions(ctx, creds)
+	if err != nil {
+		return err
+	}
+	oo = append(oo, remote.WithProgress(updates))
+	return remote.WriteIndex(ref, ii, oo...)
+}
+
+// remoteOptions of requests to a registry with the given credentials.
+func (p *Pusher) remoteOptions(ctx context.Context, creds Credentials) ([]remote.Option, error) {
 	oo := []remote.Option{
 		remote.WithContext(ctx),
-		remote.WithProgress(p.updates),
 		remote.WithTransport(p.transport),
 	}
 
 	if !p.Anonymous {
 		a, err := p.authOption(ctx, creds)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		oo = append(oo, a)
 	}
-
-	return remote.WriteIndex(ref, ii, oo...)
+	return oo, nil
 }
 
 // authOption selects an appropriate authentication option.
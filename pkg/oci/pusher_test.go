@@ -61,7 +61,7 @@ func TestPusher_Push(t *testing.T) {
 
 	// Create and push a function
 	client := fn.New(
-		fn.WithBuilder(NewBuilder("", false)),
+		fn.WithBuilder(NewBuilder("", false, WithSharedCache(t.TempDir()))),
 		fn.WithPusher(NewPusher(insecure, anon, verbose)))
 
 	f := fn.Function{Root: root, Runtime: "go", Name: "f", Registry: l.Addr().String() + "/funcs"}
@@ -126,7 +126,7 @@ func TestPusher_BasicAuth(t *testing.T) {
 	// Client
 	// initialized with an OCI builder and pusher.
 	client := fn.New(
-		fn.WithBuilder(NewBuilder("", verbose)),
+		fn.WithBuilder(NewBuilder("", verbose, WithSharedCache(t.TempDir()))),
 		fn.WithPusher(NewPusher(false, false, verbose)))
 
 	// Function
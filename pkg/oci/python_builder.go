@@ -3,6 +3,7 @@ package oci
 import (
 	"archive/tar"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -11,39 +12,82 @@ import (
 	slashpath "path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
 
 	v1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/tarball"
 )
 
 var defaultPythonBase = "python:3.13-slim" // Moving from docker.io.  See issue #2720
 
+// The dependencies of a function, including the function itself and the
+// scaffolding's service, are installed (pip install --target) into a plain
+// site-packages directory of the build directory rather than a virtualenv,
+// which is written to a fixed location of the image on the function's
+// PYTHONPATH.  Its platform-specific dependencies are installed into a
+// directory within it (see writePythonPlatform) which, not being a valid
+// module name, can not be mistaken for a package.
+const (
+	pythonDeps             = ".deps"
+	pythonDepsPath         = "/func/.deps"
+	pythonPlatformDepsPath = pythonDepsPath + "/.platform"
+)
+
 type pythonBuilder struct{}
 
 func (b pythonBuilder) Base(customBase string) string {
 	if customBase != "" {
 		return customBase
 	}
+	version := pythonVersion()
+	if version == "" {
+		return defaultPythonBase
+	}
+	return fmt.Sprintf("python:%s-slim", version)
+}
+
+// pythonVersion returns the version (major.minor) of the python interpreter
+// of the host, such as 3.13, or empty if it can not be determined.
+func pythonVersion() string {
 	cmd := exec.Command(pythonCmd(), "-V")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return defaultPythonBase
+		return ""
 	}
 	re := regexp.MustCompile(`Python (\d+\.\d+)\.\d+`)
 	subMatches := re.FindSubmatch(out)
 	if len(subMatches) != 2 {
-		return defaultPythonBase
+		return ""
 	}
-	return fmt.Sprintf("python:%s-slim", subMatches[1])
+	return string(subMatches[1])
+}
+
+// pythonPlatforms are those of the official python base images, for which
+// an interpreter is available.
+var pythonPlatforms = []string{
+	"linux/386",
+	"linux/amd64",
+	"linux/arm",
+	"linux/arm/v5",
+	"linux/arm/v7",
+	"linux/arm64",
+	"linux/arm64/v8",
+	"linux/ppc64le",
+	"linux/s390x",
+}
+
+// SupportsPlatform returns true for platforms of the official python base
+// images (see PlatformBuilder).
+func (b pythonBuilder) SupportsPlatform(p v1.Platform) bool {
+	return slices.Contains(pythonPlatforms, platformName(p))
 }
 
 // Configure gives the python builder a chance to mutate the final
 // ConfigFile that will be used when building the template.
-func (b pythonBuilder) Configure(job buildJob, _ v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
+func (b pythonBuilder) Configure(job BuildContext, p v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
 	var (
-		svcRelPath, _ = filepath.Rel(job.function.Root, job.buildDir()) // eg .func/builds/by-hash/$HASH
-		svcPath       = filepath.Join("/func", svcRelPath)              // eg /func/.func/builds/by-hash/$HASH
-		pythonPathEnv = fmt.Sprintf("PYTHONPATH=%v/lib", svcPath)
+		svcPath       = job.imageBuildDir() // eg /func/.func/builds/by-hash/$HASH
+		pythonPathEnv = fmt.Sprintf("PYTHONPATH=%v:%v", pythonDepsPath, pythonPlatformDepsPath)
 		mainPath      = fmt.Sprintf("%v/service/main.py", svcPath)
 		listenAddrEnv = "LISTEN_ADDRESS=[::]:8080"
 	)
@@ -53,20 +97,15 @@ func (b pythonBuilder) Configure(job buildJob, _ v1.Platform, cf v1.ConfigFile)
 	return cf, nil
 }
 
-func (b pythonBuilder) WriteShared(job buildJob) (layers []imageLayer, err error) {
-	var desc v1.Descriptor
-	var layer v1.Layer
-
+func (b pythonBuilder) WriteShared(job BuildContext) (layers []ImageLayer, err error) {
 	// 1) 创建venv虚拟环境
 	if job.verbose {
 		fmt.Printf("python -m venv .venv\n")
 	}
 	cmd := exec.CommandContext(job.ctx, pythonCmd(), "-m", "venv", ".venv")
 	cmd.Dir = job.buildDir()
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	if err = cmd.Run(); err != nil {
-		return
+	if out, err := runCmd(job.buildJob, cmd); err != nil {
+		return nil, ErrCompileFailed{Runtime: "python", Output: out, Err: fmt.Errorf("python -m venv failed: %w", err)}
 	}
 
 	pipPath := filepath.Join(".venv", "bin", "pip")
@@ -77,57 +116,47 @@ func (b pythonBuilder) WriteShared(job buildJob) (layers []imageLayer, err error
 	}
 	cmd = exec.CommandContext(job.ctx, pipPath, "install", "--upgrade", "pip")
 	cmd.Dir = job.buildDir()
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	if err = cmd.Run(); err != nil {
-		return
+	if out, err := runCmd(job.buildJob, cmd); err != nil {
+		return nil, ErrCompileFailed{Runtime: "python", Output: out, Err: fmt.Errorf("pip upgrade failed: %w", err)}
 	}
 
-	// 3) 安装依赖
+	// 3) 安装依赖(不生成字节码,保证层可复现)
 	if job.verbose {
-		fmt.Printf(".venv/bin/pip install . --target lib\n")
+		fmt.Printf(".venv/bin/pip install . --target %v --no-compile\n", pythonDeps)
 	}
-	cmd = exec.CommandContext(job.ctx, pipPath, "install", ".", "--target", "lib")
+	cmd = exec.CommandContext(job.ctx, pipPath, "install", ".", "--target", pythonDeps, "--no-compile")
 	cmd.Dir = job.buildDir()
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	if err = cmd.Run(); err != nil {
-		return
-	}
-
-	// 4) 打包依赖
-	source := job.buildDir()
-	target := filepath.Join(job.buildDir(), "lib.tar.gz")
-	if err = newPythonLibTarball(job, source, target); err != nil {
-		return
+	if out, err := runCmd(job.buildJob, cmd); err != nil {
+		return nil, ErrCompileFailed{Runtime: "python", Output: out, Err: fmt.Errorf("pip install failed: %w", err)}
 	}
 
-	// 5) 转换为OCI层
-	if layer, err = tarball.LayerFromFile(target); err != nil {
-		return
+	// 4) 平台特定的依赖(native wheel)从共享层移除,按平台安装(见WritePlatform)
+	if err = splitPythonNative(job.buildJob); err != nil {
+		return nil, ErrCompileFailed{Runtime: "python", Err: fmt.Errorf("cannot separate platform-specific packages: %w", err)}
 	}
 
-	// 6) 生成描述符
-	if desc, err = newDescriptor(layer); err != nil {
+	// 5) 打包依赖
+	source := job.buildDir()
+	target := filepath.Join(job.buildDir(), "lib.tar.gz")
+	if err = newPythonLibTarball(job.buildJob, source, target); err != nil {
 		return
 	}
 
-	// 7) 移动到blobs目录
-	blob := filepath.Join(job.blobsDir(), desc.Digest.Hex)
-	if job.verbose {
-		fmt.Printf("mv %v %v\n", rel(job.buildDir(), target), rel(job.buildDir(), blob))
-	}
-	if err = os.Rename(target, blob); err != nil {
+	// 6) 转换为OCI层,移动到blobs目录
+	layer, err := job.WriteLayer(target)
+	if err != nil {
 		return
 	}
 
-	return []imageLayer{{Descriptor: desc, Layer: layer}}, nil
+	return []ImageLayer{layer}, nil
 }
 
 func newPythonLibTarball(job buildJob, root, target string) error {
 	// Create a tarball of the "build directory"
 	// when extracted, it's root will be /func
 	// all files within should have path prefix .func/builds/by-hash/$hash
+	// except the dependencies, which are written to /func/.deps
+	deps := filepath.Join(root, pythonDeps)
 
 	targetFile, err := os.Create(target) // final .tar.gz
 	if err != nil {
@@ -159,13 +188,32 @@ func newPythonLibTarball(job buildJob, root, target string) error {
 		if path == filepath.Join(root, ".venv") {
 			return filepath.SkipDir
 		}
-		if path == target {
+		// Bytecode differs between builds
+		if info.IsDir() && info.Name() == "__pycache__" {
+			return filepath.SkipDir
+		}
+		// Platform-specific packages are in layers of their platforms
+		if path == filepath.Join(root, pythonPlatformsDir) || path == job.blobsByNameDir() {
+			return filepath.SkipDir
+		}
+		// Layer tarballs, including those kept (see WithKeepTars)
+		if filepath.Dir(path) == root && strings.HasSuffix(path, ".tar.gz") {
+			return nil
+		}
+		if path == target || path == filepath.Join(root, pythonNativeRequirements) {
 			return nil
 		}
 
+		inDeps := isWithin(deps, path)
+
 		lnk := "" // if link, this will be used as the target
 		if info.Mode()&fs.ModeSymlink != 0 {
-			if lnk, err = validatedLinkTarget(job.function.Root, path); err != nil {
+			if inDeps {
+				lnk, err = validatedLinkTarget(deps, path)
+			} else {
+				lnk, err = pythonLinkTarget(job, path)
+			}
+			if err != nil {
 				return err
 			}
 		}
@@ -175,14 +223,25 @@ func newPythonLibTarball(job buildJob, root, target string) error {
 			return err
 		}
 
-		// The relative path from the function's root to the file
-		relPath, err := filepath.Rel(job.function.Root, path)
-		if err != nil {
-			return err
+		// The relative path from the build directory, or its dependencies,
+		// to the file
+		if inDeps {
+			relPath, err := filepath.Rel(deps, path)
+			if err != nil {
+				return err
+			}
+			header.Name = slashpath.Join(pythonDepsPath, filepath.ToSlash(relPath))
+		} else {
+			relPath, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			header.Name = slashpath.Join(job.imageBuildDir(), filepath.ToSlash(relPath))
 		}
-		header.Name = slashpath.Join("/func/", filepath.ToSlash(relPath))
 		header.Uid = DefaultUid
 		header.Gid = DefaultGid
+		zeroHeaderTimes(header, job.zeroTimes)
+		longNames(header)
 		if err := tw.WriteHeader(header); err != nil {
 			return err
 		}
@@ -201,8 +260,17 @@ func newPythonLibTarball(job buildJob, root, target string) error {
 	})
 }
 
-func (b pythonBuilder) WritePlatform(ctx buildJob, p v1.Platform) (layers []imageLayer, err error) {
-	return []imageLayer{}, nil
+// WritePlatform installs the platform-specific packages (native wheels) of
+// the function's dependencies for the platform (see splitPythonNative),
+// failing if a wheel is not available for it.
+func (b pythonBuilder) WritePlatform(ctx BuildContext, p v1.Platform) (layers []ImageLayer, err error) {
+	return writePythonPlatform(ctx, p)
+}
+
+// RequiredTools is python, else python3 (see pythonCmd).  Pip is that of
+// the virtual environment created by python (see LanguageBuilder).
+func (b pythonBuilder) RequiredTools() []string {
+	return []string{pythonCmd()}
 }
 
 func pythonCmd() string {
@@ -212,3 +280,55 @@ func pythonCmd() string {
 	}
 	return "python"
 }
+
+// pythonLinkTarget returns the target of the link at path within the build
+// directory as it should be written to the image.  When the build directory
+// is within the function (the default) the validated target is used as-is.
+// When relocated (see WithWorkDir) the link, such as the scaffolding's link to
+// the function source, is resolved and rewritten relative to the location of
+// the function and build directory within the image.
+func pythonLinkTarget(job buildJob, path string) (string, error) {
+	if job.workDir == "" {
+		return validatedLinkTarget(job.function.Root, path)
+	}
+	tgt, err := os.Readlink(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read link: %w", err)
+	}
+	if isAbsLink(tgt) {
+		return "", errors.New("project may not contain absolute links")
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(job.function.Root)
+	if err != nil {
+		return "", err
+	}
+	buildDir, err := filepath.EvalSymlinks(job.buildDir())
+	if err != nil {
+		return "", err
+	}
+
+	// Location of the link's target within the image
+	var target string
+	switch {
+	case isWithin(buildDir, resolved):
+		rel, _ := filepath.Rel(buildDir, resolved)
+		target = slashpath.Join(job.imageBuildDir(), filepath.ToSlash(rel))
+	case isWithin(root, resolved):
+		rel, _ := filepath.Rel(root, resolved)
+		target = slashpath.Join("/func", filepath.ToSlash(rel))
+	default:
+		return "", errors.New("links must stay within project root")
+	}
+
+	// Relative to the link's location within the image
+	rel, _ := filepath.Rel(job.buildDir(), filepath.Dir(path))
+	dir := slashpath.Join(job.imageBuildDir(), filepath.ToSlash(rel))
+	if tgt, err = filepath.Rel(filepath.FromSlash(dir), filepath.FromSlash(target)); err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(tgt), nil
+}
@@ -0,0 +1,62 @@
+package oci
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Test_newPythonLibTarball ensures the dependencies of the build directory are
+// written to the site-packages directory of the image, the scaffolding to the
+// build directory of the image, and that bytecode is excluded.
+func Test_newPythonLibTarball(t *testing.T) {
+	job := buildJob{function: fn.Function{Root: t.TempDir()}, hash: "test"}
+	for _, path := range []string{
+		"service/main.py",
+		"service/__pycache__/main.cpython-313.pyc",
+		".deps/function/func.py",
+		".deps/function/__pycache__/func.cpython-313.pyc",
+		".venv/bin/pip",
+	} {
+		path = filepath.Join(job.buildDir(), filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	target := filepath.Join(job.buildDir(), "lib.tar.gz")
+	if err := newPythonLibTarball(job, job.buildDir(), target); err != nil {
+		t.Fatal(err)
+	}
+	headers := readTarball(t, target)
+	for _, name := range []string{job.imageBuildDir() + "/service/main.py", "/func/.deps/function/func.py"} {
+		if headers[name] == nil {
+			t.Fatalf("expected %v in the layer, got %v", name, headers)
+		}
+	}
+	for name := range headers {
+		if filepath.Base(filepath.Dir(name)) == "__pycache__" || filepath.Base(name) == "pip" {
+			t.Fatalf("unexpected %v in the layer", name)
+		}
+	}
+}
+
+// TestPythonBuilder_Configure ensures the dependencies, shared and of the
+// platform, are on the PYTHONPATH of the function.
+func TestPythonBuilder_Configure(t *testing.T) {
+	job := BuildContext{buildJob{function: fn.Function{Root: t.TempDir()}, hash: "test"}}
+	cf, err := pythonBuilder{}.Configure(job, v1.Platform{OS: "linux", Architecture: "amd64"}, v1.ConfigFile{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(cf.Config.Env, "PYTHONPATH=/func/.deps:/func/.deps/.platform") {
+		t.Fatalf("expected the dependencies on the PYTHONPATH, got %v", cf.Config.Env)
+	}
+}
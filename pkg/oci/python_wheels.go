@@ -0,0 +1,326 @@
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	slashpath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// Dependencies of Python functions are installed once, for the host, into the
+// shared layer (.deps).  Those which are platform-specific (native wheels) are
+// then removed from it, and installed for each platform from wheels of that
+// platform into a layer of its own (platforms/$PLATFORM/lib, written to
+// /func/.deps/.platform of the image), such that each platform's image
+// contains native code of its architecture.
+
+// pythonNativeRequirements is the file of the build directory listing the
+// platform-specific distributions removed from the shared layer, as
+// requirements (name==version), to be installed for each platform.
+const pythonNativeRequirements = "native-requirements.txt"
+
+// pythonPlatformsDir is the directory of the build directory in which the
+// platform-specific distributions of each platform are installed.
+const pythonPlatformsDir = "platforms"
+
+// pythonGlibc is the minor version of the glibc of the default (debian
+// bookworm) python base image, up to which manylinux wheels are compatible.
+const pythonGlibc = 36
+
+// pythonArchitectures are the architectures of the manylinux wheel tags of
+// each platform.  Platforms without have no native wheels.
+var pythonArchitectures = map[string]string{
+	"linux/386":      "i686",
+	"linux/amd64":    "x86_64",
+	"linux/arm":      "armv7l",
+	"linux/arm/v7":   "armv7l",
+	"linux/arm64":    "aarch64",
+	"linux/arm64/v8": "aarch64",
+	"linux/ppc64le":  "ppc64le",
+	"linux/s390x":    "s390x",
+}
+
+// pythonDist is a distribution (package) installed in a directory.
+type pythonDist struct {
+	name, version string
+	distInfo      string // path of its .dist-info directory
+}
+
+// requirement of the distribution at its installed version.
+func (d pythonDist) requirement() string {
+	return d.name + "==" + d.version
+}
+
+// pythonNativeDists returns the distributions installed in lib which are
+// platform-specific: those of a wheel which is not pure (Root-Is-Purelib) or
+// is tagged for a platform other than any.
+func pythonNativeDists(lib string) (dists []pythonDist, err error) {
+	infos, err := filepath.Glob(filepath.Join(lib, "*.dist-info"))
+	if err != nil {
+		return
+	}
+	sort.Strings(infos)
+	for _, info := range infos {
+		wheel, err := readPythonHeaders(filepath.Join(info, "WHEEL"))
+		if os.IsNotExist(err) {
+			continue // not installed from a wheel
+		} else if err != nil {
+			return nil, err
+		}
+		native := len(wheel["Root-Is-Purelib"]) > 0 && wheel["Root-Is-Purelib"][0] != "true"
+		for _, tag := range wheel["Tag"] {
+			if !strings.HasSuffix(tag, "-any") {
+				native = true
+			}
+		}
+		if !native {
+			continue
+		}
+		metadata, err := readPythonHeaders(filepath.Join(info, "METADATA"))
+		if err != nil {
+			return nil, err
+		}
+		if len(metadata["Name"]) == 0 || len(metadata["Version"]) == 0 {
+			return nil, fmt.Errorf("no name or version in the metadata of %v", filepath.Base(info))
+		}
+		dists = append(dists, pythonDist{metadata["Name"][0], metadata["Version"][0], info})
+	}
+	return
+}
+
+// readPythonHeaders returns the values of the headers of the metadata file
+// at path (such as WHEEL or METADATA) by name, up to the first empty line.
+func readPythonHeaders(path string) (map[string][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	headers := map[string][]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			headers[name] = append(headers[name], strings.TrimSpace(value))
+		}
+	}
+	return headers, scanner.Err()
+}
+
+// removePythonDist removes the files of the distribution installed in lib,
+// as listed in its RECORD, along with directories left empty.
+func removePythonDist(lib string, d pythonDist) error {
+	file, err := os.Open(filepath.Join(d.distInfo, "RECORD"))
+	if err != nil {
+		return err
+	}
+	records, err := csv.NewReader(file).ReadAll()
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("cannot read the record of %v. %w", d.name, err)
+	}
+	dirs := map[string]bool{}
+	for _, r := range records {
+		if len(r) == 0 || r[0] == "" {
+			continue
+		}
+		path := filepath.Join(lib, filepath.FromSlash(r[0]))
+		if !isWithin(lib, path) {
+			continue // such as scripts installed outside of lib
+		}
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for dir := filepath.Dir(path); dir != lib && isWithin(lib, dir); dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	if err = os.RemoveAll(d.distInfo); err != nil {
+		return err
+	}
+	// Deepest first, such that parents are empty once their children are
+	// removed.  Directories which are not empty remain.
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, dir := range sorted {
+		_ = os.Remove(dir)
+	}
+	return nil
+}
+
+// splitPythonNative removes the platform-specific distributions installed in
+// the build directory's dependencies, listing them as requirements in the build
+// directory, to be installed for each platform.
+func splitPythonNative(job buildJob) error {
+	lib := filepath.Join(job.buildDir(), pythonDeps)
+	dists, err := pythonNativeDists(lib)
+	if err != nil {
+		return err
+	}
+	requirements := []string{}
+	for _, d := range dists {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "Installing %v per platform\n", d.requirement())
+		}
+		if err = removePythonDist(lib, d); err != nil {
+			return err
+		}
+		requirements = append(requirements, d.requirement())
+	}
+	data := strings.Join(requirements, "\n")
+	return os.WriteFile(filepath.Join(job.buildDir(), pythonNativeRequirements), []byte(data), 0644)
+}
+
+// pythonPlatformDir is the directory in which the platform-specific
+// distributions of the platform are installed, relative to the build
+// directory.
+func pythonPlatformDir(p v1.Platform) string {
+	return slashpath.Join(pythonPlatformsDir, strings.ReplaceAll(platformName(p), "/", "."), "lib")
+}
+
+// pythonPlatformTags returns the tags of the wheels compatible with the
+// platform, as accepted by pip install --platform, or none if the platform
+// has no native wheels.
+func pythonPlatformTags(p v1.Platform) (tags []string) {
+	arch, ok := pythonArchitectures[platformName(p)]
+	if !ok {
+		return
+	}
+	for glibc := pythonGlibc; glibc >= 17; glibc-- {
+		tags = append(tags, fmt.Sprintf("manylinux_2_%v_%v", glibc, arch))
+	}
+	tags = append(tags, "manylinux2014_"+arch)
+	if arch == "x86_64" || arch == "i686" {
+		tags = append(tags, "manylinux2010_"+arch, "manylinux1_"+arch)
+	}
+	return
+}
+
+// writePythonPlatform installs the platform-specific distributions, if any,
+// for the platform from binary wheels only, returning the layer of them.
+func writePythonPlatform(ctx BuildContext, p v1.Platform) (layers []ImageLayer, err error) {
+	requirements := filepath.Join(ctx.buildDir(), pythonNativeRequirements)
+	data, err := os.ReadFile(requirements)
+	if os.IsNotExist(err) || (err == nil && strings.TrimSpace(string(data)) == "") {
+		return []ImageLayer{}, nil // no platform-specific distributions
+	} else if err != nil {
+		return
+	}
+	tags := pythonPlatformTags(p)
+	if len(tags) == 0 {
+		return nil, ErrCompileFailed{Runtime: "python", Err: fmt.Errorf("no wheels are available for %v, required by %v",
+			platformName(p), strings.Join(strings.Fields(string(data)), ", "))}
+	}
+
+	// 为平台安装二进制wheel(不解析依赖, 依赖已在共享层中解析)
+	dir := filepath.FromSlash(pythonPlatformDir(p))
+	if err = os.RemoveAll(filepath.Join(ctx.buildDir(), dir)); err != nil {
+		return
+	}
+	args := []string{"install", "--target", dir, "--only-binary=:all:", "--no-deps", "--no-compile"}
+	for _, tag := range tags {
+		args = append(args, "--platform", tag)
+	}
+	if version := pythonVersion(); version != "" {
+		args = append(args, "--python-version", version)
+	}
+	args = append(args, "-r", pythonNativeRequirements)
+	if ctx.verbose {
+		fmt.Printf(".venv/bin/pip install --target %v --only-binary=:all: --platform ... -r %v\n", dir, pythonNativeRequirements)
+	}
+	cmd := exec.CommandContext(ctx.ctx, filepath.Join(".venv", "bin", "pip"), args...)
+	cmd.Dir = ctx.buildDir()
+	if out, err := runCmd(ctx.buildJob, cmd); err != nil {
+		return nil, ErrCompileFailed{Runtime: "python", Output: out,
+			Err: fmt.Errorf("pip install for %v failed, a wheel may not be available for the platform: %w", platformName(p), err)}
+	}
+
+	target := filepath.Join(ctx.buildDir(), fmt.Sprintf("lib.%v.tar.gz", strings.ReplaceAll(platformName(p), "/", ".")))
+	if err = newPythonPlatformTarball(filepath.Join(ctx.buildDir(), dir), target, ctx.zeroTimes); err != nil {
+		return
+	}
+	layer, err := ctx.WriteLayer(target)
+	if err != nil {
+		return
+	}
+	return []ImageLayer{layer}, nil
+}
+
+// newPythonPlatformTarball writes the directory of the platform-specific
+// distributions to a gzipped tarball at target, at their location within the
+// dependencies of the image.
+func newPythonPlatformTarball(dir, target string, zeroTimes bool) error {
+	targetFile, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer targetFile.Close()
+
+	gw := gzip.NewWriter(targetFile)
+	defer gw.Close()
+
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == "__pycache__" {
+			return filepath.SkipDir
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		lnk := ""
+		if d.Type()&fs.ModeSymlink != 0 {
+			if lnk, err = validatedLinkTarget(dir, path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, lnk)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		header.Name = slashpath.Join(pythonPlatformDepsPath, filepath.ToSlash(rel))
+		header.Uid = DefaultUid
+		header.Gid = DefaultGid
+		zeroHeaderTimes(header, zeroTimes)
+		longNames(header)
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+}
@@ -0,0 +1,127 @@
+package oci
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// writePythonDist writes a distribution installed from a wheel of the given
+// tag to lib, with a module of the given files.
+func writePythonDist(t *testing.T, lib, name, version, tag string, purelib bool, files ...string) {
+	t.Helper()
+	info := filepath.Join(lib, name+"-"+version+".dist-info")
+	if err := os.MkdirAll(info, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	record := ""
+	for _, f := range files {
+		path := filepath.Join(lib, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+		record += f + ",sha256=abc,1\n"
+	}
+	record += name + "-" + version + ".dist-info/METADATA,,\n" + name + "-" + version + ".dist-info/RECORD,,\n"
+	purelibValue := "false"
+	if purelib {
+		purelibValue = "true"
+	}
+	for file, content := range map[string]string{
+		"WHEEL":    "Wheel-Version: 1.0\nRoot-Is-Purelib: " + purelibValue + "\nTag: " + tag + "\n",
+		"METADATA": "Metadata-Version: 2.1\nName: " + name + "\nVersion: " + version + "\n\nName: not a header\n",
+		"RECORD":   record,
+	} {
+		if err := os.WriteFile(filepath.Join(info, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Test_splitPythonNative ensures platform-specific distributions are removed
+// from the shared lib and listed as requirements, and pure distributions are
+// kept.
+func Test_splitPythonNative(t *testing.T) {
+	job := buildJob{function: fn.Function{Root: t.TempDir()}, hash: "test"}
+	lib := filepath.Join(job.buildDir(), pythonDeps)
+	writePythonDist(t, lib, "purepkg", "1.0.0", "py3-none-any", true, "purepkg/__init__.py")
+	writePythonDist(t, lib, "nativepkg", "2.1.0", "cp311-cp311-manylinux_2_17_x86_64", false,
+		"nativepkg/__init__.py", "nativepkg/_speedups.cpython-311-x86_64-linux-gnu.so")
+	writePythonDist(t, lib, "abi3pkg", "3.0.0", "cp37-abi3-manylinux2014_x86_64", true, "abi3pkg/_lib.abi3.so")
+
+	if err := splitPythonNative(job); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"purepkg/__init__.py", "purepkg-1.0.0.dist-info/WHEEL"} {
+		if _, err := os.Stat(filepath.Join(lib, path)); err != nil {
+			t.Fatalf("expected %v of the pure distribution to be kept. %v", path, err)
+		}
+	}
+	for _, path := range []string{"nativepkg", "nativepkg-2.1.0.dist-info", "abi3pkg", "abi3pkg-3.0.0.dist-info"} {
+		if _, err := os.Stat(filepath.Join(lib, path)); !os.IsNotExist(err) {
+			t.Fatalf("expected %v of a native distribution to be removed, got %v", path, err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(job.buildDir(), pythonNativeRequirements))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "abi3pkg==3.0.0\nnativepkg==2.1.0"; string(data) != expected {
+		t.Fatalf("expected requirements %q, got %q", expected, string(data))
+	}
+}
+
+// Test_pythonPlatformTags ensures the manylinux tags of a platform are those
+// of its architecture, including the legacy aliases, and that a platform
+// without native wheels has none.
+func Test_pythonPlatformTags(t *testing.T) {
+	tags := pythonPlatformTags(v1.Platform{OS: "linux", Architecture: "amd64"})
+	for _, tag := range []string{"manylinux_2_36_x86_64", "manylinux_2_17_x86_64", "manylinux2014_x86_64", "manylinux1_x86_64"} {
+		if !slices.Contains(tags, tag) {
+			t.Fatalf("expected tag %v, got %v", tag, tags)
+		}
+	}
+	tags = pythonPlatformTags(v1.Platform{OS: "linux", Architecture: "arm64"})
+	if !slices.Contains(tags, "manylinux2014_aarch64") || slices.Contains(tags, "manylinux1_aarch64") {
+		t.Fatalf("expected the aarch64 tags, got %v", tags)
+	}
+	if tags = pythonPlatformTags(v1.Platform{OS: "linux", Architecture: "arm", Variant: "v5"}); len(tags) != 0 {
+		t.Fatalf("expected no tags of linux/arm/v5, got %v", tags)
+	}
+}
+
+// Test_writePythonPlatform ensures a function without platform-specific
+// distributions has no platform layer, and that a platform without wheels
+// for those required is an error.
+func Test_writePythonPlatform(t *testing.T) {
+	job := buildJob{function: fn.Function{Root: t.TempDir()}, hash: "test"}
+	if err := os.MkdirAll(job.buildDir(), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	requirements := filepath.Join(job.buildDir(), pythonNativeRequirements)
+	if err := os.WriteFile(requirements, []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+	armv5 := v1.Platform{OS: "linux", Architecture: "arm", Variant: "v5"}
+	layers, err := writePythonPlatform(BuildContext{job}, armv5)
+	if err != nil || len(layers) != 0 {
+		t.Fatalf("expected no platform layers, got %v. %v", layers, err)
+	}
+
+	if err = os.WriteFile(requirements, []byte("nativepkg==2.1.0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = writePythonPlatform(BuildContext{job}, armv5)
+	var compileErr ErrCompileFailed
+	if !errors.As(err, &compileErr) {
+		t.Fatalf("expected a compile error for a platform without wheels, got %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Rebase recomposes the image of the function's last build upon its base
+// image (f.Build.BaseImage, or that of its runtime), such as to pick up a
+// security update of the base, without building its source.  The layers of
+// the function (its data, certificates and executable) and its config are
+// those of the last build, and the layers of the base it was built upon are
+// replaced by those of the new base: its environment, history and user are
+// replaced in the config likewise.  The base of each platform's image is that
+// recorded by its manifest (see AnnotationBaseName), which is resolved from
+// the blob cache or its registry, and whose layers must be the first of the
+// image.  Images built from scratch, of which the base layers are squashed
+// (see WithSquash) or with supplementary groups (build.groups), whose
+// /etc/group is of the base, can not be rebased (see ErrNotRebasable).
+//
+// The rebased image is written as a build of its own, which becomes the last
+// build (.func/builds/last) such that it is pushed as the function's image.
+// Post-build commands (build.postBuild) are not run, and the layout is not
+// exported (see WithOCIOutput and WithBundle).
+func (b *Builder) Rebase(ctx context.Context, f fn.Function) (err error) {
+	var (
+		job buildJob
+		pp  []fn.Platform // of the last build
+	)
+	defer func(start time.Time) { b.record(f, pp, start, job.cacheStats, err) }(time.Now())
+
+	if err = b.options.validate(); err != nil {
+		return
+	}
+	if len(f.Build.Groups) > 0 {
+		return ErrNotRebasable{Reason: "its /etc/group, of supplementary groups (build.groups), is of the base image"}
+	}
+
+	// The last build, from which the image is rebased
+	last, err := filepath.EvalSymlinks(filepath.Join(f.Root, fn.RunDataDir, "builds", "last"))
+	if err != nil {
+		return fmt.Errorf("last build not found. Has it been built? %w", err)
+	}
+	ii, err := layout.ImageIndexFromPath(filepath.Join(last, "oci"))
+	if err != nil {
+		return
+	}
+	index, err := ii.IndexManifest()
+	if err != nil {
+		return
+	}
+	for _, desc := range index.Manifests {
+		if desc.Platform == nil {
+			return ErrNotRebasable{Reason: fmt.Sprintf("its image %v is not of a platform", desc.Digest)}
+		}
+		pp = append(pp, fn.Platform{OS: desc.Platform.OS, Architecture: desc.Platform.Architecture, Variant: desc.Platform.Variant})
+	}
+
+	if job, err = newBuildJob(ctx, f, pp, b.verbose); err != nil {
+		return
+	}
+	job.options = b.options
+	job.quiet = b.quiet && !b.verbose
+	job.sharedCache = availableCache(job.sharedCache, job.verbose)
+	job.hash += ".rebase"
+	// The index's annotations and media types are those of the last build,
+	// overridden by those of the builder
+	job.options.annotations = map[string]string{}
+	for k, v := range index.Annotations {
+		job.options.annotations[k] = v
+	}
+	for k, v := range b.annotations {
+		job.options.annotations[k] = v
+	}
+	if index.MediaType == types.DockerManifestList {
+		job.options.mediaType = MediaTypesDocker
+	}
+	var digest v1.Hash
+	defer func() {
+		b.resultMu.Lock()
+		b.result = job.result()
+		b.result.Index = digest
+		b.resultMu.Unlock()
+	}()
+
+	// The last build may be a rebase of the same source, which is replaced:
+	// it is moved aside (outside the builds, which are cleaned up), and
+	// restored if rebasing fails
+	source := filepath.Join(last, "oci")
+	if dir, _ := filepath.EvalSymlinks(job.buildDir()); dir == last {
+		previous := filepath.Join(job.dataDir(), "builds", "rebase.previous")
+		_ = os.RemoveAll(previous)
+		if err = os.Rename(last, previous); err != nil {
+			return
+		}
+		defer func() {
+			if err != nil {
+				_ = os.RemoveAll(last)
+				_ = os.Rename(previous, last)
+				_ = updateLastLink(job)
+			}
+			_ = os.RemoveAll(previous)
+		}()
+		source = filepath.Join(previous, "oci")
+		if ii, err = layout.ImageIndexFromPath(source); err != nil {
+			return
+		}
+	}
+
+	if err = setup(job); err != nil {
+		return
+	}
+	defer cleanup(job)
+	defer func() {
+		if job.verbose {
+			fmt.Fprintf(os.Stderr, "rm %v\n", job.pidLink())
+		}
+		_ = os.Remove(job.pidLink())
+	}()
+	if err = os.WriteFile(filepath.Join(job.ociDir(), "oci-layout"),
+		[]byte(`{ "imageLayoutVersion": "1.0.0" }`), os.ModePerm); err != nil {
+		return
+	}
+
+	manifests := []v1.Descriptor{}
+	for _, desc := range index.Manifests {
+		end := job.phase("rebase " + platformName(*desc.Platform))
+		manifest, err := rebasePlatform(job, ii, source, desc)
+		end()
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, manifest)
+	}
+	if err = writeIndex(job, manifests); err != nil {
+		return
+	}
+	if digest, _, err = readImages(job.ociDir()); err != nil {
+		return
+	}
+	if err = updateLastLink(job); err != nil {
+		return
+	}
+	if !job.quiet {
+		fmt.Printf("Rebased onto %v (%v)\n", job.languageBuilder.Base(f.Build.BaseImage), digest)
+	}
+	return
+}
+
+// rebasePlatform writes the image of the platform (desc) of the index ii,
+// of the layout at source, recomposed upon the job's base image, returning
+// the descriptor of its manifest.
+func rebasePlatform(job buildJob, ii v1.ImageIndex, source string, desc v1.Descriptor) (v1.Descriptor, error) {
+	p := *desc.Platform
+	notRebasable := func(reason string) error {
+		return ErrNotRebasable{Platform: platformName(p), Reason: reason}
+	}
+	img, err := ii.Image(desc.Digest)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	m, err := img.Manifest()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+
+	// The base upon which the image was built
+	switch m.Annotations[AnnotationBaseName] {
+	case "":
+		return v1.Descriptor{}, notRebasable("its base image is not recorded (built by an earlier version)")
+	case BaseScratch:
+		return v1.Descriptor{}, notRebasable("it was built from scratch")
+	}
+	previous, err := previousBase(job, p, m.Annotations[AnnotationBaseName], m.Annotations[AnnotationBaseDigest])
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	pm, err := previous.Manifest()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	pcfg, err := previous.ConfigFile()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	n := len(pm.Layers)
+	if len(m.Layers) < n || len(cfg.RootFS.DiffIDs) < n || len(cfg.History) < len(pcfg.History) {
+		return v1.Descriptor{}, notRebasable("its base layers are squashed")
+	}
+	for i, l := range pm.Layers {
+		if m.Layers[i].Digest != l.Digest {
+			return v1.Descriptor{}, notRebasable("its base layers are squashed")
+		}
+	}
+
+	// The new base, as when building
+	base, err := pullBase(job, p)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	if base == nil {
+		return v1.Descriptor{}, notRebasable("there is no base image upon which to rebase it")
+	}
+	bcfg, err := base.ConfigFile()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+
+	// The function's own layers, as they are
+	layers := []ImageLayer{}
+	for _, l := range m.Layers[n:] {
+		target := filepath.Join(job.blobsDir(), l.Digest.Hex)
+		if _, err = os.Stat(target); os.IsNotExist(err) {
+			if err = linkOrCopy(filepath.Join(source, "blobs", l.Digest.Algorithm, l.Digest.Hex), target); err != nil {
+				return v1.Descriptor{}, err
+			}
+		}
+		layers = append(layers, ImageLayer{Descriptor: l})
+	}
+
+	// The config, of which that of the previous base is replaced
+	cfg.RootFS.DiffIDs = append(append([]v1.Hash{}, bcfg.RootFS.DiffIDs...), cfg.RootFS.DiffIDs[n:]...)
+	cfg.History = append(append([]v1.History{}, bcfg.History...), cfg.History[len(pcfg.History):]...)
+	cfg.History = zeroHistoryTimes(cfg.History, job.zeroTimes)
+	cfg.Config.Env = rebaseEnv(cfg.Config.Env, pcfg.Config.Env, bcfg.Config.Env)
+	cfg.Config.User = rebaseUser(cfg.Config.User, pcfg.Config.User, bcfg.Config.User)
+	config, err := writeConfig(job, p, *cfg)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+
+	// The manifest, recording the new base
+	digest, err := base.Digest()
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	annotations := map[string]string{}
+	for k, v := range m.Annotations {
+		annotations[k] = v
+	}
+	annotations[AnnotationBaseName] = job.languageBuilder.Base(job.function.Build.BaseImage)
+	annotations[AnnotationBaseDigest] = digest.String()
+	return writeManifest(job, p, base, config, layers, annotations)
+}
+
+// previousBase returns the base image of the given reference and digest
+// upon which an image was built: from the blob cache, else its registry
+// unless never pulled (see PullNever).
+func previousBase(job buildJob, p v1.Platform, ref, digest string) (v1.Image, error) {
+	h, err := v1.NewHash(digest)
+	if err != nil {
+		return nil, ErrNotRebasable{Platform: platformName(p), Reason: fmt.Sprintf("the digest of its base image %v is not valid. %v", ref, err)}
+	}
+	if image, err := readCachedImage(job, h); err == nil {
+		return image, nil
+	} else if job.pullPolicy() == PullNever {
+		return nil, ErrBaseNotCached{Ref: ref + "@" + digest, Platform: platformName(p), Reason: err.Error()}
+	}
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, ErrBasePull{ref, err}
+	}
+	pull, err := mirrorReference(job, r.Context().Digest(digest))
+	if err != nil {
+		return nil, ErrBasePull{ref, err}
+	}
+	image, err := remote.Image(pull, remote.WithContext(job.ctx), remote.WithAuthFromKeychain(job.keychain()),
+		remote.WithTransport(job.transport()))
+	if err != nil {
+		return nil, ErrBasePull{ref + "@" + digest, err}
+	}
+	return image, nil
+}
+
+// rebaseEnv returns the environment of an image (env) with that of its
+// previous base, by which it is prefixed, replaced by that of the next.
+func rebaseEnv(env, previous, next []string) []string {
+	own := env
+	if len(env) >= len(previous) {
+		own = env[len(previous):]
+		for i, e := range previous {
+			if env[i] != e {
+				own = env // not prefixed: all its own
+				break
+			}
+		}
+	}
+	return append(append([]string{}, next...), own...)
+}
+
+// rebaseUser returns the user of an image which, if that of its previous
+// base, is that of the next, or the default if the next defines none.
+func rebaseUser(user, previous, next string) string {
+	defaultUser := fmt.Sprintf("%v:%v", DefaultUid, DefaultGid)
+	if user != defaultUser && (previous == "" || user != previous) {
+		return user // of the function's own config
+	}
+	if next != "" {
+		return next
+	}
+	return defaultUser
+}
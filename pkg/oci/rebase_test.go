@@ -0,0 +1,167 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci/mock"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestBuilder_Rebase ensures the last build is recomposed upon the new image
+// of its base, retaining its own layers, and that a rebase may be rebased.
+func TestBuilder_Rebase(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	registry := mock.NewRegistry()
+	defer registry.Close()
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	ref, index, err := registry.SeedBase("library/base", "latest", 1, 2, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.BaseImage = ref
+
+	// The last build: the base with a layer of the function's own
+	own, err := random.Layer(512, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestBuild(t, f, baseImage(t, index), own, ref)
+
+	// The base is updated
+	if _, index, err = registry.SeedBase("library/base", "latest", 2, 3, p); err != nil {
+		t.Fatal(err)
+	}
+	next := baseImage(t, index)
+	nextDigest, err := next.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBuilder("", false, WithQuiet(true))
+	for i := 0; i < 2; i++ { // a rebase, then a rebase of the rebase
+		if err = b.Rebase(context.Background(), f); err != nil {
+			t.Fatal(err)
+		}
+		img := lastImage(t, f)
+		if err = validate.Image(img); err != nil {
+			t.Fatalf("invalid rebased image. %v", err)
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Layers) != 4 {
+			t.Fatalf("expected the 3 layers of the new base and the function's own, got %v", len(m.Layers))
+		}
+		ownDigest, _ := own.Digest()
+		if m.Layers[3].Digest != ownDigest {
+			t.Fatalf("expected the function's own layer %v, got %v", ownDigest, m.Layers[3].Digest)
+		}
+		if m.Annotations[AnnotationBaseDigest] != nextDigest.String() || m.Annotations[AnnotationBaseName] != ref {
+			t.Fatalf("expected the new base %v@%v, got %v", ref, nextDigest, m.Annotations)
+		}
+	}
+}
+
+// TestBuilder_RebaseNotRebasable ensures images built from scratch, or with
+// supplementary groups, are not rebased.
+func TestBuilder_RebaseNotRebasable(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	own, err := random.Layer(512, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestBuild(t, f, empty.Image, own, BaseScratch)
+	f.Build.BaseImage = "example.com/base:latest"
+
+	var e ErrNotRebasable
+	b := NewBuilder("", false, WithQuiet(true))
+	if err = b.Rebase(context.Background(), f); !errors.As(err, &e) {
+		t.Fatalf("expected ErrNotRebasable of an image built from scratch, got %v", err)
+	}
+	f.Build.Groups = []int{2000}
+	if err = b.Rebase(context.Background(), f); !errors.As(err, &e) {
+		t.Fatalf("expected ErrNotRebasable of an image with groups, got %v", err)
+	}
+}
+
+// baseImage returns the single image of the index.
+func baseImage(t *testing.T, index v1.ImageIndex) v1.Image {
+	t.Helper()
+	im, err := index.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := index.Image(im.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// writeTestBuild writes a build of the function, its last, of the base
+// (named baseName) with the function's own layer.
+func writeTestBuild(t *testing.T, f fn.Function, base v1.Image, own v1.Layer, baseName string) {
+	t.Helper()
+	img, err := mutate.Append(base, mutate.Addendum{Layer: own})
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotations := map[string]string{AnnotationBaseName: baseName}
+	if baseName != BaseScratch {
+		digest, err := base.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		annotations[AnnotationBaseDigest] = digest.String()
+	}
+	img = mutate.Annotations(img, annotations).(v1.Image)
+	ii := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+	})
+	dir := filepath.Join(f.Root, fn.RunDataDir, "builds", "by-hash", "test")
+	if _, err = layout.Write(filepath.Join(dir, "oci"), ii); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(dir, filepath.Join(f.Root, fn.RunDataDir, "builds", "last")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lastImage returns the single image of the function's last build.
+func lastImage(t *testing.T, f fn.Function) v1.Image {
+	t.Helper()
+	ii, err := layout.ImageIndexFromPath(filepath.Join(f.Root, fn.RunDataDir, "builds", "last", "oci"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return baseImage(t, ii)
+}
@@ -0,0 +1,79 @@
+package oci
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// WithRegistryMirrors sets mirrors through which base images are pulled, by
+// the registry they mirror.  A mirror is a registry host optionally followed
+// by a path under which the mirrored repositories are found, such that with
+// docker.io mirrored by mirror.corp/dockerhub the base image
+// docker.io/library/alpine:3 is pulled as mirror.corp/dockerhub/library/alpine:3.
+// The tag or digest of the base image is preserved, so a base pinned by
+// digest is verified against the mirror as it would be against the registry.
+func WithRegistryMirrors(mirrors map[string]string) BuilderOpt {
+	return func(b *Builder) {
+		b.registryMirrors = mirrors
+	}
+}
+
+// ValidateRegistryMirrors returns an error if any of the keys is not a
+// registry host or any of the values is not a registry host, optionally
+// followed by a path.
+func ValidateRegistryMirrors(mirrors map[string]string) error {
+	for r, m := range mirrors {
+		if strings.Contains(r, "/") {
+			return fmt.Errorf("invalid mirrored registry %q: must be a registry host", r)
+		}
+		if _, err := name.NewRegistry(r); err != nil {
+			return fmt.Errorf("invalid mirrored registry %q. %w", r, err)
+		}
+		host, path, _ := strings.Cut(strings.TrimSuffix(m, "/"), "/")
+		if !strings.ContainsAny(host, ".:") && host != "localhost" {
+			return fmt.Errorf("invalid registry mirror %q: must begin with a registry host", m)
+		}
+		if _, err := name.NewRepository(host + "/" + strings.Trim(path+"/base", "/")); err != nil {
+			return fmt.Errorf("invalid registry mirror %q. %w", m, err)
+		}
+	}
+	return nil
+}
+
+// ParseRegistryMirrors parses registry mirrors in the form registry=mirror,
+// as provided on the command line.
+func ParseRegistryMirrors(mm []string) (map[string]string, error) {
+	mirrors := map[string]string{}
+	for _, m := range mm {
+		r, mirror, ok := strings.Cut(m, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid registry mirror %q: must be in the form registry=mirror", m)
+		}
+		mirrors[r] = mirror
+	}
+	return mirrors, ValidateRegistryMirrors(mirrors)
+}
+
+// mirrorReference returns the reference through which the given reference is
+// pulled: that of its registry's mirror with the same repository and tag or
+// digest, or the reference itself if its registry is not mirrored.
+func mirrorReference(job buildJob, ref name.Reference) (name.Reference, error) {
+	for r, m := range job.options.registryMirrors {
+		registry, err := name.NewRegistry(r)
+		if err != nil {
+			return nil, err
+		}
+		if registry.RegistryStr() != ref.Context().RegistryStr() {
+			continue
+		}
+		separator := ":"
+		if _, ok := ref.(name.Digest); ok {
+			separator = "@"
+		}
+		return name.ParseReference(strings.TrimSuffix(m, "/") + "/" +
+			ref.Context().RepositoryStr() + separator + ref.Identifier())
+	}
+	return ref, nil
+}
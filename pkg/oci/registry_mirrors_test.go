@@ -0,0 +1,129 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	fn "knative.dev/func/pkg/functions"
+	"knative.dev/func/pkg/oci/mock"
+	. "knative.dev/func/pkg/testing"
+)
+
+func TestParseRegistryMirrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		mirrors []string
+		wantErr bool
+	}{
+		{"valid", []string{"docker.io=mirror.corp/dockerhub"}, false},
+		{"host only", []string{"ghcr.io=mirror.corp:5000"}, false},
+		{"localhost", []string{"docker.io=localhost/dockerhub"}, false},
+		{"missing mirror", []string{"docker.io"}, true},
+		{"registry with path", []string{"docker.io/library=mirror.corp"}, true},
+		{"mirror without host", []string{"docker.io=dockerhub/library"}, true},
+		{"invalid mirror path", []string{"docker.io=mirror.corp/Docker Hub"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRegistryMirrors(tt.mirrors); (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// Test_mirrorReference ensures references of a mirrored registry are
+// rewritten to the mirror, preserving their tag or digest, and that others
+// are not.
+func Test_mirrorReference(t *testing.T) {
+	digest := "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	job := buildJob{}
+	job.registryMirrors = map[string]string{
+		"docker.io": "mirror.corp/dockerhub/",
+		"quay.io":   "quay-mirror.corp:5000",
+	}
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"alpine", "mirror.corp/dockerhub/library/alpine:latest"},
+		{"docker.io/library/alpine:3", "mirror.corp/dockerhub/library/alpine:3"},
+		{"index.docker.io/library/alpine@" + digest, "mirror.corp/dockerhub/library/alpine@" + digest},
+		{"quay.io/org/base:1.0@" + digest, "quay-mirror.corp:5000/org/base@" + digest},
+		{"ghcr.io/org/base:1.0", "ghcr.io/org/base:1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ref, err := name.ParseReference(tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := mirrorReference(job, ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestBuilder_PullBaseMirror ensures a base image of a mirrored registry is
+// pulled through the mirror, by the digest to which it is pinned.
+func TestBuilder_PullBaseMirror(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	registry := mock.NewRegistry()
+	defer registry.Close()
+	p := v1.Platform{OS: "linux", Architecture: "amd64"}
+	_, index, err := registry.SeedBase("dockerhub/library/base", "latest", 1, 1, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := index.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not resolvable but through the mirror
+	f.Build.BaseImage = "registry.example.invalid/library/base@" + digest.String()
+
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.registryMirrors = map[string]string{
+		"registry.example.invalid": registry.Addr().String() + "/dockerhub",
+	}
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+
+	image, err := pullBase(job, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := image.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := manifest.Manifests[0].Digest; got != want {
+		t.Fatalf("expected the base image %v from the mirror, got %v", want, got)
+	}
+}
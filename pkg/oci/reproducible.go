@@ -0,0 +1,178 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+)
+
+// WithVerifyReproducible builds the image a second time from the same
+// inputs, with the same creation time and base images, and fails the build
+// with ErrNotReproducible if the digests of the two indices differ, naming
+// the blobs which differ.  The layout of the second build is that of the
+// build.  Intended for CI, where the reproducibility of the image is to be
+// guaranteed; without WithZeroTimestamps the modification times of files
+// written by the build, such as the compiled binary, usually differ.
+func WithVerifyReproducible(verify bool) BuilderOpt {
+	return func(b *Builder) {
+		b.verifyReproducible = verify
+	}
+}
+
+// BlobDiff is a blob of a platform's image which differs between two builds.
+type BlobDiff struct {
+	Platform string  // os/arch[/variant]
+	Blob     string  // "manifest", "config" or "layer {n}" (from 1)
+	First    v1.Hash // of the first build, zero if absent
+	Second   v1.Hash // of the second build, zero if absent
+}
+
+func (d BlobDiff) String() string {
+	digest := func(h v1.Hash) string {
+		if h.Hex == "" {
+			return "(none)"
+		}
+		return h.String()
+	}
+	return fmt.Sprintf("%v %v: %v != %v", d.Platform, d.Blob, digest(d.First), digest(d.Second))
+}
+
+// verifyReproducible builds the job's image again, where enabled, and
+// returns ErrNotReproducible if its index is not that of the first build
+// (index).  The layout of the first build is moved aside while the second is
+// built to the job's OCI directory, and then removed.
+func verifyReproducible(job buildJob, index v1.Hash) (err error) {
+	if !job.verifyReproducible {
+		return
+	}
+	end := job.phase("verify-reproducible")
+	defer end()
+	if !job.quiet {
+		fmt.Println("Building again to verify reproducibility")
+	}
+	first := filepath.Join(job.buildDir(), "oci.first")
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "mv %v %v\n", job.ociDir(), first)
+	}
+	if err = os.Rename(job.ociDir(), first); err != nil {
+		return
+	}
+	defer os.RemoveAll(first)
+	if err = os.MkdirAll(job.blobsDir(), os.ModePerm); err != nil {
+		return
+	}
+
+	// The same job, and so the same start and base images, untimed
+	second := job
+	second.timer = nil
+	if err = containerize(second); err != nil {
+		return fmt.Errorf("error building again to verify reproducibility. %w", err)
+	}
+	secondIndex, _, err := readImages(job.ociDir())
+	if err != nil || secondIndex == index {
+		return
+	}
+	blobs, err := diffLayouts(first, job.ociDir())
+	if err != nil {
+		return
+	}
+	return ErrNotReproducible{First: index, Second: secondIndex, Blobs: blobs}
+}
+
+// diffLayouts returns the blobs of the images of the OCI layouts at first
+// and second which differ, by platform in the order of the first: the
+// config and each layer by position, or the manifest itself where they are
+// the same (such as of differing annotations).
+func diffLayouts(first, second string) (diffs []BlobDiff, err error) {
+	a, err := layoutManifests(first)
+	if err != nil {
+		return
+	}
+	b, err := layoutManifests(second)
+	if err != nil {
+		return
+	}
+	platforms := []string{}
+	seen := map[string]bool{}
+	for _, m := range append(a, b...) {
+		if !seen[m.platform] {
+			seen[m.platform] = true
+			platforms = append(platforms, m.platform)
+		}
+	}
+	find := func(mm []platformManifest, platform string) (m platformManifest) {
+		for _, m = range mm {
+			if m.platform == platform {
+				return
+			}
+		}
+		return platformManifest{platform: platform}
+	}
+	for _, p := range platforms {
+		ma, mb := find(a, p), find(b, p)
+		if ma.digest == mb.digest {
+			continue
+		}
+		var found []BlobDiff
+		if ma.manifest != nil && mb.manifest != nil {
+			if ma.manifest.Config.Digest != mb.manifest.Config.Digest {
+				found = append(found, BlobDiff{Platform: p, Blob: "config", First: ma.manifest.Config.Digest, Second: mb.manifest.Config.Digest})
+			}
+			for i := 0; i < max(len(ma.manifest.Layers), len(mb.manifest.Layers)); i++ {
+				var da, db v1.Hash
+				if i < len(ma.manifest.Layers) {
+					da = ma.manifest.Layers[i].Digest
+				}
+				if i < len(mb.manifest.Layers) {
+					db = mb.manifest.Layers[i].Digest
+				}
+				if da != db {
+					found = append(found, BlobDiff{Platform: p, Blob: fmt.Sprintf("layer %d", i+1), First: da, Second: db})
+				}
+			}
+		}
+		if len(found) == 0 {
+			found = append(found, BlobDiff{Platform: p, Blob: "manifest", First: ma.digest, Second: mb.digest})
+		}
+		diffs = append(diffs, found...)
+	}
+	return
+}
+
+// platformManifest is the manifest of a platform's image in a layout.
+type platformManifest struct {
+	platform string
+	digest   v1.Hash
+	manifest *v1.Manifest
+}
+
+// layoutManifests returns the manifest of each image of the layout at dir,
+// in the order of its index.
+func layoutManifests(dir string) (mm []platformManifest, err error) {
+	ii, err := layout.ImageIndexFromPath(dir)
+	if err != nil {
+		return
+	}
+	im, err := ii.IndexManifest()
+	if err != nil {
+		return
+	}
+	for _, desc := range im.Manifests {
+		m := platformManifest{digest: desc.Digest}
+		if desc.Platform != nil {
+			m.platform = platformName(*desc.Platform)
+		}
+		img, err := ii.Image(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if m.manifest, err = img.Manifest(); err != nil {
+			return nil, err
+		}
+		mm = append(mm, m)
+	}
+	return
+}
@@ -0,0 +1,102 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// Test_verifyReproducible ensures a build verified to be reproducible
+// succeeds where its image is the same when built again, and otherwise fails
+// with ErrNotReproducible naming the layer which differs.
+func Test_verifyReproducible(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		builds int32 // of each platform's binary
+		same   = true
+	)
+	impl := NewTestLanguageBuilder()
+	impl.WritePlatformFn = func(ctx BuildContext, p v1.Platform) ([]ImageLayer, error) {
+		content := "exe"
+		if !same {
+			content = fmt.Sprintf("exe %d", atomic.AddInt32(&builds, 1))
+		}
+		source := filepath.Join(ctx.BuildDir(), "exe."+p.Architecture)
+		target := filepath.Join(ctx.BuildDir(), "exe."+p.Architecture+".tar.gz")
+		if err := os.WriteFile(source, []byte(content), 0755); err != nil {
+			return nil, err
+		}
+		if err := goExeTarball(source, target, nil, true, false); err != nil {
+			return nil, err
+		}
+		layer, err := ctx.WriteLayer(target)
+		return []ImageLayer{layer}, err
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.languageBuilder = impl
+	job.quiet = true
+	job.zeroTimes = true
+	job.verifyReproducible = true
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+	if err = scaffold(job); err != nil {
+		t.Fatal(err)
+	}
+	build := func() error {
+		if err := containerize(job); err != nil {
+			return err
+		}
+		index, _, err := readImages(job.ociDir())
+		if err != nil {
+			return err
+		}
+		return verifyReproducible(job, index)
+	}
+
+	// Reproducible
+	if err = build(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(job.ociDir(), "index.json")); err != nil {
+		t.Fatalf("expected the layout of the second build. %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(job.buildDir(), "oci.first")); !os.IsNotExist(err) {
+		t.Fatalf("expected the layout of the first build to be removed, got %v", err)
+	}
+
+	// Not reproducible: the binary differs
+	same = false
+	var e ErrNotReproducible
+	if err = build(); !errors.As(err, &e) {
+		t.Fatalf("expected ErrNotReproducible, got %v", err)
+	}
+	if e.First == e.Second || len(e.Blobs) != len(TestPlatforms) {
+		t.Fatalf("expected a blob of each platform to differ, got %v", e)
+	}
+	for _, d := range e.Blobs {
+		if d.Blob != "layer 3" || d.First == d.Second { // atop data and certs
+			t.Fatalf("expected the layer of the binary to differ, got %v", d)
+		}
+	}
+}
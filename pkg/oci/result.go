@@ -0,0 +1,54 @@
+package oci
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+)
+
+// ImageResult is the image built for a platform.
+type ImageResult struct {
+	Platform string        // os/arch[/variant]
+	Digest   v1.Hash       // of the image's manifest
+	Layers   []LayerResult // in order, base layers first
+}
+
+// LayerResult is a layer of a built image.
+type LayerResult struct {
+	Digest v1.Hash
+	Size   int64 // compressed size in bytes
+}
+
+// readImages of the OCI layout at dir, returning the digest of its index and
+// the image of each platform, in the order of the index.
+func readImages(dir string) (index v1.Hash, images []ImageResult, err error) {
+	ii, err := layout.ImageIndexFromPath(dir)
+	if err != nil {
+		return
+	}
+	if index, err = ii.Digest(); err != nil {
+		return
+	}
+	im, err := ii.IndexManifest()
+	if err != nil {
+		return
+	}
+	for _, desc := range im.Manifests {
+		img, err := ii.Image(desc.Digest)
+		if err != nil {
+			return index, images, err
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			return index, images, err
+		}
+		image := ImageResult{Digest: desc.Digest}
+		if desc.Platform != nil {
+			image.Platform = platformName(*desc.Platform)
+		}
+		for _, l := range m.Layers {
+			image.Layers = append(image.Layers, LayerResult{Digest: l.Digest, Size: l.Size})
+		}
+		images = append(images, image)
+	}
+	return
+}
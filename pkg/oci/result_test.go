@@ -0,0 +1,73 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// Test_readImages ensures the digest of the built index, and the digest and
+// layers of the image of each platform, are read from the built layout.
+func Test_readImages(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := NewTestLanguageBuilder()
+	impl.ConfigureFn = func(_ BuildContext, _ v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
+		return cf, nil
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.languageBuilder = impl
+	job.quiet = true
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+	if err = scaffold(job); err != nil {
+		t.Fatal(err)
+	}
+	if err = containerize(job); err != nil {
+		t.Fatal(err)
+	}
+
+	index, images, err := readImages(job.ociDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.Hex == "" {
+		t.Fatal("expected the digest of the index")
+	}
+	if len(images) != len(TestPlatforms) {
+		t.Fatalf("expected an image per platform %v, got %v", TestPlatforms, images)
+	}
+	for i, image := range images {
+		expected := TestPlatforms[i].OS + "/" + TestPlatforms[i].Architecture
+		if TestPlatforms[i].Variant != "" {
+			expected += "/" + TestPlatforms[i].Variant
+		}
+		if image.Platform != expected {
+			t.Fatalf("expected platform %v, got %v", expected, image.Platform)
+		}
+		if image.Digest.Hex == "" || len(image.Layers) == 0 {
+			t.Fatalf("expected the digest and layers of the image, got %+v", image)
+		}
+		for _, l := range image.Layers {
+			if l.Digest.Hex == "" || l.Size <= 0 {
+				t.Fatalf("expected the digest and size of each layer, got %+v", l)
+			}
+		}
+	}
+}
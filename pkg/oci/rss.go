@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package oci
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// peakRSS returns the peak resident set size in bytes of the exited process,
+// including that of the largest of its waited-for children (such as the
+// compiler and linker invoked by go build), or zero if not available.
+func peakRSS(state *os.ProcessState) int64 {
+	if state == nil {
+		return 0
+	}
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(usage.Maxrss) // bytes
+	}
+	return int64(usage.Maxrss) * 1024 // KiB
+}
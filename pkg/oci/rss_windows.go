@@ -0,0 +1,9 @@
+package oci
+
+import "os"
+
+// peakRSS always returns zero on Windows, where the memory usage of an exited
+// process is not available from its state.
+func peakRSS(_ *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,124 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Known scanners of the built image, whose severity thresholds are passed as
+// each expects.  Any other scanner is a command (see WithScan).
+const (
+	ScannerTrivy = "trivy"
+	ScannerGrype = "grype"
+)
+
+// WithScan scans the built image (its OCI layout) for vulnerabilities once
+// built, failing the build with ErrScanFailed if any of at least the given
+// severity (see functions.ScanSeverities) are found.  The scanner is trivy,
+// grype, or a command line in which {layout} and {severity} are replaced
+// with the path of the OCI layout and the severity (the layout is appended
+// if absent), and which exits non-zero to fail the build.  Arguments of a
+// command are separated by whitespace and may not be quoted.
+//
+// A scanner given here is required: the build fails if it is not installed.
+// A scan configured by the function (build.scan) is instead skipped with a
+// warning.  Here either overrides that of the function.  An empty severity
+// is that of the function, or high.
+func WithScan(scanner, severity string) BuilderOpt {
+	return func(b *Builder) {
+		b.scanner = scanner
+		b.scanSeverity = severity
+	}
+}
+
+// ValidateScanSeverity returns an error if the severity is not known.
+// Empty is the default (high).
+func ValidateScanSeverity(severity string) error {
+	if severity != "" && !slices.Contains(fn.ScanSeverities, severity) {
+		return fmt.Errorf("invalid scan severity %q: must be one of %v", severity, strings.Join(fn.ScanSeverities, ", "))
+	}
+	return nil
+}
+
+// scanner of the job's image, if any, the severity at or above which
+// vulnerabilities fail the build, and whether the scanner is required.
+func (j buildJob) scan() (scanner, severity string, required bool) {
+	scanner, required = strings.TrimSpace(j.function.Build.Scan.Scanner), false
+	if strings.TrimSpace(j.options.scanner) != "" {
+		scanner, required = strings.TrimSpace(j.options.scanner), true
+	}
+	severity = j.options.scanSeverity
+	if severity == "" {
+		severity = j.function.Build.Scan.Severity
+	}
+	if severity == "" {
+		severity = fn.ScanSeverityHigh
+	}
+	return
+}
+
+// scanImage scans the job's OCI layout with its scanner, if any.
+func scanImage(job buildJob) (err error) {
+	scanner, severity, required := job.scan()
+	if scanner == "" {
+		return
+	}
+	if err = ValidateScanSeverity(severity); err != nil {
+		return
+	}
+	name, args := scanCommand(scanner, severity, job.ociDir())
+	path, err := exec.LookPath(name)
+	if err != nil {
+		if required {
+			return ErrScanFailed{Scanner: name, Severity: severity, Err: fmt.Errorf("scanner not installed. %w", err)}
+		}
+		if !job.quiet {
+			fmt.Fprintf(os.Stderr, "Warning: not scanning the image as %v is not installed\n", name)
+		}
+		return nil
+	}
+
+	defer job.phase("scan")()
+	if job.verbose {
+		fmt.Printf("%v %v\n", path, strings.Join(args, " "))
+	} else if !job.quiet {
+		fmt.Printf("   scanning with %v (%v or higher)\n", name, severity)
+	}
+	if out, err := runCmd(job, exec.CommandContext(job.ctx, path, args...)); err != nil {
+		return ErrScanFailed{Scanner: name, Severity: severity, Output: out, Err: err}
+	}
+	return
+}
+
+// scanCommand returns the name and arguments of the command which scans the
+// OCI layout, failing if vulnerabilities of at least the severity are found.
+func scanCommand(scanner, severity, layout string) (name string, args []string) {
+	switch scanner {
+	case ScannerTrivy:
+		// Trivy fails on only those severities listed
+		i := slices.Index(fn.ScanSeverities, severity)
+		severities := []string{}
+		for _, s := range fn.ScanSeverities[i:] {
+			severities = append(severities, strings.ToUpper(s))
+		}
+		return ScannerTrivy, []string{"image", "--input", layout,
+			"--severity", strings.Join(severities, ","), "--exit-code", "1"}
+	case ScannerGrype:
+		return ScannerGrype, []string{"oci-dir:" + layout, "--fail-on", severity}
+	}
+	fields := strings.Fields(scanner)
+	placed := false
+	for _, f := range fields[1:] {
+		placed = placed || strings.Contains(f, "{layout}")
+		args = append(args, strings.NewReplacer("{layout}", layout, "{severity}", severity).Replace(f))
+	}
+	if !placed {
+		args = append(args, layout)
+	}
+	return fields[0], args
+}
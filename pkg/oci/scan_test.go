@@ -0,0 +1,87 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Test_scanCommand ensures the known scanners are passed the layout and
+// severity as each expects, and that a command has its placeholders replaced,
+// or the layout appended.
+func Test_scanCommand(t *testing.T) {
+	tests := []struct {
+		scanner  string
+		severity string
+		name     string
+		args     []string
+	}{
+		{"trivy", "high", "trivy", []string{"image", "--input", "/oci", "--severity", "HIGH,CRITICAL", "--exit-code", "1"}},
+		{"trivy", "low", "trivy", []string{"image", "--input", "/oci", "--severity", "LOW,MEDIUM,HIGH,CRITICAL", "--exit-code", "1"}},
+		{"grype", "critical", "grype", []string{"oci-dir:/oci", "--fail-on", "critical"}},
+		{"scan --min={severity} --dir {layout}", "medium", "scan", []string{"--min=medium", "--dir", "/oci"}},
+		{"scan --quiet", "high", "scan", []string{"--quiet", "/oci"}},
+	}
+	for _, tt := range tests {
+		name, args := scanCommand(tt.scanner, tt.severity, "/oci")
+		if name != tt.name || !slices.Equal(args, tt.args) {
+			t.Errorf("%q: expected %v %v, got %v %v", tt.scanner, tt.name, tt.args, name, args)
+		}
+	}
+}
+
+// Test_scanImage ensures the scan of the function is skipped when its
+// scanner is not installed, that one given to the builder is required, and
+// that a scanner which exits non-zero fails with ErrScanFailed.
+func Test_scanImage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("scanner scripts are not supported on windows")
+	}
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	job := buildJob{ctx: context.Background(), function: fn.Function{Root: t.TempDir()}}
+	job.quiet = true
+
+	// None configured
+	if err := scanImage(job); err != nil {
+		t.Fatal(err)
+	}
+
+	// That of the function, not installed: skipped
+	job.function.Build.Scan = fn.Scan{Scanner: "scanner"}
+	if err := scanImage(job); err != nil {
+		t.Fatalf("expected the scan to be skipped, got %v", err)
+	}
+
+	// That of the builder, not installed: required
+	job.options.scanner = "scanner"
+	var errScan ErrScanFailed
+	if err := scanImage(job); !errors.As(err, &errScan) {
+		t.Fatalf("expected ErrScanFailed for a required scanner, got %v", err)
+	}
+
+	// Installed, finding no vulnerabilities of the severity
+	script := "#!/bin/sh\n[ \"$1\" = critical ] && exit 0\necho \"found $1\"\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(bin, "scanner"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	job.options.scanner = "scanner {severity} {layout}"
+	job.options.scanSeverity = fn.ScanSeverityCritical
+	if err := scanImage(job); err != nil {
+		t.Fatal(err)
+	}
+
+	// Installed, finding vulnerabilities of the severity (that of the
+	// function, as the builder's is not set)
+	job.options.scanSeverity = ""
+	job.function.Build.Scan.Severity = fn.ScanSeverityMedium
+	if err := scanImage(job); !errors.As(err, &errScan) || errScan.Severity != "medium" || errScan.Output != "found medium\n" {
+		t.Fatalf("expected ErrScanFailed with the scanner's output, got %v", err)
+	}
+}
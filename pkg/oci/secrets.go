@@ -0,0 +1,207 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Secret is made available to the commands run by a build, such as a
+// credential with which go or pip fetch private dependencies, without
+// being written to the image.
+type Secret struct {
+	ID     string // identifies the secret, such as "pip"
+	Src    string // file of the secret, or
+	Env    string // environment variable of the value of the secret
+	Target string // environment variable of the secret's file, FUNC_SECRET_{ID} if empty
+}
+
+// secretID is the form of the ID of a secret.
+var secretID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// WithSecrets makes the secrets available to the commands run by each build
+// (go, pip, build hooks), and to none other, as a file named by an
+// environment variable: Target, or FUNC_SECRET_{ID} (in upper case, with "."
+// and "-" as "_").  For example, a secret of target PIP_CONFIG_FILE
+// configures pip.  A secret of a file (Src) is read in place, and must be
+// outside of the function's directory, whose files are written to the image.
+// That of an environment variable (Env) is written to a temporary file,
+// outside of the function and its build directory, which is removed when the
+// build ends.
+//
+// Secrets are never written to a layer, the image's config or the build
+// directory, nor are they part of the fingerprint or of the key of a cached
+// layer: a change of a secret alone does not rebuild the function.
+func WithSecrets(ss []Secret) BuilderOpt {
+	return func(b *Builder) {
+		b.secrets = ss
+	}
+}
+
+// ParseSecrets parses secrets, each as by ParseSecret, failing if two are
+// of the same ID or target.
+func ParseSecrets(ss []string) (secrets []Secret, err error) {
+	for _, v := range ss {
+		secret, err := ParseSecret(v)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, ValidateSecrets(secrets)
+}
+
+// ParseSecret parses a secret in the form id=ID,src=PATH[,target=VAR] or
+// id=ID,env=VAR[,target=VAR], as provided on the command line.  A src
+// beginning ~/ is of the home directory.
+func ParseSecret(s string) (secret Secret, err error) {
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return secret, fmt.Errorf("invalid secret %q: fields must be in the form key=value", s)
+		}
+		switch key {
+		case "id":
+			secret.ID = value
+		case "src", "source":
+			if secret.Src, err = expandHome(value); err != nil {
+				return
+			}
+		case "env":
+			secret.Env = value
+		case "target":
+			secret.Target = value
+		default:
+			return secret, fmt.Errorf("invalid secret %q: unknown field %q (id, src, env or target)", s, key)
+		}
+	}
+	return secret, ValidateSecrets([]Secret{secret})
+}
+
+// ValidateSecrets returns an error if a secret has no valid ID, is not of
+// exactly one of a file or an environment variable, or if two are of the
+// same ID or target.
+func ValidateSecrets(ss []Secret) error {
+	ids, targets := map[string]bool{}, map[string]bool{}
+	for _, s := range ss {
+		if !secretID.MatchString(s.ID) {
+			return fmt.Errorf("invalid secret id %q: must be letters, digits, '_', '.' or '-'", s.ID)
+		}
+		if (s.Src == "") == (s.Env == "") {
+			return fmt.Errorf("invalid secret %q: must be of exactly one of a file (src) or an environment variable (env)", s.ID)
+		}
+		if ids[s.ID] {
+			return fmt.Errorf("duplicate secret %q", s.ID)
+		}
+		ids[s.ID] = true
+		if targets[s.target()] {
+			return fmt.Errorf("duplicate secret target %q", s.target())
+		}
+		targets[s.target()] = true
+	}
+	return nil
+}
+
+// target is the environment variable of the secret's file.
+func (s Secret) target() string {
+	if s.Target != "" {
+		return s.Target
+	}
+	return "FUNC_SECRET_" + strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(s.ID))
+}
+
+// prepareSecrets sets the environment of the job's secrets, with which its
+// commands are run (see runCmd), writing those of environment variables to
+// temporary files which are removed by done.
+func prepareSecrets(job *buildJob) (done func(), err error) {
+	var files []string
+	done = func() {
+		for _, f := range files {
+			_ = os.Remove(f)
+		}
+	}
+	for _, s := range job.secrets {
+		path := s.Src
+		if s.Env != "" {
+			value, ok := os.LookupEnv(s.Env)
+			if !ok {
+				done()
+				return func() {}, fmt.Errorf("secret %q: environment variable %v is not set", s.ID, s.Env)
+			}
+			if path, err = writeSecret(value); err != nil {
+				done()
+				return func() {}, fmt.Errorf("secret %q: %w", s.ID, err)
+			}
+			files = append(files, path)
+		} else if err = checkSecretSource(path, job.function.Root); err != nil {
+			done()
+			return func() {}, fmt.Errorf("secret %q: %w", s.ID, err)
+		}
+		if path, err = filepath.Abs(path); err != nil {
+			done()
+			return func() {}, err
+		}
+		job.secretEnvs = append(job.secretEnvs, s.target()+"="+path)
+	}
+	return done, nil
+}
+
+// checkSecretSource returns an error if the file of a secret does not exist
+// or is within the function's root, whose files are written to the image's
+// data layer and its fingerprint.  Both the path and that to which it
+// resolves are checked, such that links to or from the function are as well.
+func checkSecretSource(src, root string) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return err
+	}
+	within := isWithin(root, src)
+	if resolvedSrc, err := filepath.EvalSymlinks(src); err == nil {
+		if resolvedRoot, err := filepath.EvalSymlinks(root); err == nil {
+			within = within || isWithin(resolvedRoot, resolvedSrc)
+		}
+	}
+	if within {
+		return fmt.Errorf("%v is within the function's directory, and so would be written to the image: move it out of the function's directory, or provide the secret by an environment variable (env)", src)
+	}
+	return nil
+}
+
+// writeSecret writes the value of a secret to a temporary file, readable
+// only by its owner.
+func writeSecret(value string) (path string, err error) {
+	file, err := os.CreateTemp("", "func-secret-*") // created 0600
+	if err != nil {
+		return
+	}
+	if _, err = file.WriteString(value); err != nil {
+		file.Close()
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	if err = file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// expandHome returns the path with a leading ~/ as the home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
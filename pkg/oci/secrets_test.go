@@ -0,0 +1,180 @@
+package oci
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// Test_ParseSecret ensures secrets are parsed from the command line form,
+// and that invalid secrets are rejected.
+func Test_ParseSecret(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value   string
+		want    Secret
+		wantErr bool
+	}{
+		{"id=npmrc,src=~/.npmrc", Secret{ID: "npmrc", Src: filepath.Join(home, ".npmrc")}, false},
+		{"id=npmrc,src=.npmrc,target=NPM_CONFIG_USERCONFIG", Secret{ID: "npmrc", Src: ".npmrc", Target: "NPM_CONFIG_USERCONFIG"}, false},
+		{"id=token,env=GITHUB_TOKEN", Secret{ID: "token", Env: "GITHUB_TOKEN"}, false},
+		{"src=.npmrc", Secret{}, true},                    // no id
+		{"id=npmrc", Secret{}, true},                      // neither src nor env
+		{"id=npmrc,src=.npmrc,env=NPMRC", Secret{}, true}, // both src and env
+		{"id=npm rc,src=.npmrc", Secret{}, true},          // invalid id
+		{"id=npmrc,src=.npmrc,mode=0400", Secret{}, true}, // unknown field
+		{"id=npmrc,src", Secret{}, true},                  // not key=value
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseSecret(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	if err = ValidateSecrets([]Secret{{ID: "a", Env: "A"}, {ID: "a", Env: "B"}}); err == nil {
+		t.Fatal("expected an error of secrets of the same id")
+	}
+	if err = ValidateSecrets([]Secret{{ID: "a.b", Env: "A"}, {ID: "a-b", Env: "B"}}); err == nil {
+		t.Fatal("expected an error of secrets of the same target (FUNC_SECRET_A_B)")
+	}
+}
+
+// TestBuilder_Secrets ensures secrets, of a file and of an environment
+// variable, are available to the commands of the build, and that neither is
+// written to any blob of the image nor left in the build directory.
+func TestBuilder_Secrets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("commands of the test require a POSIX shell")
+	}
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	const fileSecret, envSecret = "file-s3cr3t", "env-s3cr3t"
+	src := filepath.Join(t.TempDir(), ".npmrc")
+	if err := os.WriteFile(src, []byte(fileSecret), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_TOKEN", envSecret)
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := `test "$(cat "$NPM_CONFIG_USERCONFIG")" = ` + fileSecret + ` && test "$(cat "$FUNC_SECRET_TOKEN")" = ` + envSecret
+	f.Build.PreBuild = []string{check}
+
+	var tokenFile string
+	impl := NewTestLanguageBuilder()
+	impl.WriteSharedFn = func(ctx BuildContext) ([]ImageLayer, error) {
+		for _, env := range ctx.secretEnvs {
+			if v, ok := strings.CutPrefix(env, "FUNC_SECRET_TOKEN="); ok {
+				tokenFile = v
+			}
+		}
+		if out, err := runCmd(ctx.buildJob, exec.Command("sh", "-c", check)); err != nil {
+			t.Fatalf("expected the secrets in the environment of the command. %v %v", err, out)
+		}
+		return []ImageLayer{}, nil
+	}
+	b := NewBuilder("", false, WithQuiet(true), WithSecrets([]Secret{
+		{ID: "npmrc", Src: src, Target: "NPM_CONFIG_USERCONFIG"},
+		{ID: "token", Env: "TEST_TOKEN"},
+	}))
+	b.impl = impl
+	if _, err = b.BuildPlatform(context.Background(), f, fn.Platform{OS: "linux", Architecture: "amd64"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The secret of the environment variable is removed
+	if _, err = os.Stat(tokenFile); tokenFile == "" || !os.IsNotExist(err) {
+		t.Fatalf("expected the secret's file %q to be removed, got %v", tokenFile, err)
+	}
+
+	// Neither secret is in any blob, decompressed, nor any file of the build
+	assertSecretsNotWritten(t, root, fileSecret, envSecret)
+}
+
+// TestBuilder_SecretsWithinFunction ensures a secret of a file within the
+// function's directory, or of a link to one, is rejected rather than
+// written to the image's data layer.
+func TestBuilder_SecretsWithinFunction(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	const secret = "file-s3cr3t"
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(root, ".npmrc"), []byte(secret), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), ".npmrc")
+	if err = os.Symlink(filepath.Join(root, ".npmrc"), link); err != nil {
+		t.Skip("symlinks are not supported")
+	}
+
+	for _, src := range []string{".npmrc", filepath.Join(root, ".npmrc"), link} {
+		b := NewBuilder("", false, WithQuiet(true), WithSecrets([]Secret{{ID: "npmrc", Src: src}}))
+		b.impl = NewTestLanguageBuilder()
+		_, err := b.BuildPlatform(context.Background(), f, fn.Platform{OS: "linux", Architecture: "amd64"})
+		if err == nil || !strings.Contains(err.Error(), "within the function's directory") {
+			t.Fatalf("expected the secret %v within the function to be rejected, got %v", src, err)
+		}
+	}
+	assertSecretsNotWritten(t, root, secret)
+}
+
+// assertSecretsNotWritten fails the test if a secret is in any blob,
+// decompressed, or any other file of the function's builds.
+func assertSecretsNotWritten(t *testing.T, root string, secrets ...string) {
+	t.Helper()
+	err := filepath.WalkDir(filepath.Join(root, fn.RunDataDir), func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil // not built
+		}
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if gr, err := gzip.NewReader(bytes.NewReader(data)); err == nil {
+			if data, err = io.ReadAll(gr); err != nil {
+				return err
+			}
+		}
+		for _, secret := range secrets {
+			if bytes.Contains(data, []byte(secret)) {
+				t.Errorf("secret %q found in %v", secret, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
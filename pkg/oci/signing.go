@@ -0,0 +1,261 @@
+package oci
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// SignaturesDir is the directory of the signatures of a build, within the
+// build directory (.func/builds/last/signatures), one file per digest signed
+// (see WithSigning).
+const SignaturesDir = "signatures"
+
+const (
+	// simpleSigningMediaType is the media type of the payload signed, that
+	// of cosign's simple signing format, such that cosign verify can verify
+	// the signatures pushed.
+	simpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// signatureAnnotation of the payload layer holds its signature (base64).
+	signatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// WithSigning signs the image index with the private key at path, and with
+// manifests also each platform manifest (see writeManifest), such that a
+// verifier which resolves a platform's manifest rather than the index can
+// verify it.  The key is an unencrypted PEM private key of ECDSA, Ed25519 or
+// RSA.  Signatures are of cosign's simple signing format, written to the
+// build directory (see SignaturesDir) and pushed alongside the image as
+// {algorithm}-{hex}.sig tags, from which cosign verify --key verifies them.
+func WithSigning(key string, manifests bool) BuilderOpt {
+	return func(b *Builder) {
+		b.signingKey = key
+		b.signManifests = manifests
+	}
+}
+
+// ValidateSigning returns an error if the key at path is not a supported
+// private key, or if manifests are to be signed without a key.
+func ValidateSigning(key string, manifests bool) error {
+	if key == "" {
+		if manifests {
+			return errors.New("signing platform manifests requires a signing key")
+		}
+		return nil
+	}
+	_, err := loadSigningKey(key)
+	return err
+}
+
+// loadSigningKey reads the PEM private key at path.
+func loadSigningKey(path string) (crypto.Signer, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key %v. %w", path, err)
+	}
+	// The first block of a private key, skipping others such as the
+	// EC PARAMETERS written by openssl ecparam.
+	var block *pem.Block
+	for {
+		if block, bb = pem.Decode(bb); block == nil {
+			return nil, fmt.Errorf("invalid signing key %v: no PEM private key found", path)
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			break
+		}
+	}
+	if strings.Contains(block.Type, "ENCRYPTED") {
+		return nil, fmt.Errorf("invalid signing key %v: encrypted keys are not supported", path)
+	}
+	var key any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key %v. %w", path, err)
+	}
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	case *rsa.PrivateKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("invalid signing key %v: unsupported key type %T", path, key)
+}
+
+// Signature of a digest of the image, such as that of its index or of a
+// platform manifest, over the simple signing Payload.
+type Signature struct {
+	Digest    string `json:"digest"`
+	Payload   []byte `json:"payload"`
+	Signature string `json:"signature"` // base64
+}
+
+// simpleSigningPayload of the image of the given digest, whose reference is
+// its repository, if known.
+func simpleSigningPayload(reference string, digest v1.Hash) ([]byte, error) {
+	var p struct {
+		Critical struct {
+			Identity struct {
+				DockerReference string `json:"docker-reference"`
+			} `json:"identity"`
+			Image struct {
+				DockerManifestDigest string `json:"docker-manifest-digest"`
+			} `json:"image"`
+			Type string `json:"type"`
+		} `json:"critical"`
+		Optional map[string]any `json:"optional"`
+	}
+	p.Critical.Identity.DockerReference = reference
+	p.Critical.Image.DockerManifestDigest = digest.String()
+	p.Critical.Type = "cosign container image signature"
+	return json.Marshal(p)
+}
+
+// sign the payload with the key: of its SHA-256 digest for ECDSA and RSA
+// (PKCS #1 v1.5), and of the payload itself for Ed25519.
+func sign(key crypto.Signer, payload []byte) ([]byte, error) {
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		return key.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+	sum := sha256.Sum256(payload)
+	return key.Sign(rand.Reader, sum[:], crypto.SHA256)
+}
+
+// signDigest signs the digest with the job's signing key, writing its
+// signature to the build's signatures directory.
+func signDigest(job buildJob, digest v1.Hash) error {
+	key, err := loadSigningKey(job.signingKey)
+	if err != nil {
+		return err
+	}
+	var reference string
+	if ref, err := name.ParseReference(job.function.Build.Image); err == nil {
+		reference = ref.Context().Name()
+	}
+	payload, err := simpleSigningPayload(reference, digest)
+	if err != nil {
+		return err
+	}
+	sig, err := sign(key, payload)
+	if err != nil {
+		return fmt.Errorf("error signing %v. %w", digest, err)
+	}
+	bb, err := json.Marshal(Signature{
+		Digest:    digest.String(),
+		Payload:   payload,
+		Signature: base64.StdEncoding.EncodeToString(sig),
+	})
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(job.buildDir(), SignaturesDir)
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, signatureTag(digest)+".json")
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "Signing %v to %v\n", digest, rel(job.buildDir(), path))
+	}
+	return os.WriteFile(path, bb, 0644)
+}
+
+// signatureTag is the tag of the signature of the digest, as of cosign:
+// {algorithm}-{hex}.sig
+func signatureTag(digest v1.Hash) string {
+	return digest.Algorithm + "-" + digest.Hex + ".sig"
+}
+
+// readSignatures of the build at dir, if any.
+func readSignatures(dir string) (ss []Signature, err error) {
+	paths, err := filepath.Glob(filepath.Join(dir, SignaturesDir, "*.json"))
+	if err != nil {
+		return
+	}
+	for _, path := range paths {
+		bb, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var s Signature
+		if err = json.Unmarshal(bb, &s); err != nil {
+			return nil, fmt.Errorf("invalid signature %v. %w", path, err)
+		}
+		ss = append(ss, s)
+	}
+	return
+}
+
+// signatureImage is the image of the signature as of cosign: its payload as
+// the one layer, annotated with the signature.
+func signatureImage(s Signature) (v1.Image, error) {
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       static.NewLayer(s.Payload, simpleSigningMediaType),
+		Annotations: map[string]string{signatureAnnotation: s.Signature},
+	})
+	if err != nil {
+		return nil, err
+	}
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	return mutate.ConfigMediaType(img, types.OCIConfigJSON), nil
+}
+
+// writeSignatures pushes the signatures of the build at dir, if any, to the
+// repository of ref as {algorithm}-{hex}.sig tags.
+func (p *Pusher) writeSignatures(ctx context.Context, ref name.Reference, dir string, creds Credentials) error {
+	ss, err := readSignatures(dir)
+	if err != nil || len(ss) == 0 {
+		return err
+	}
+	oo, err := p.remoteOptions(ctx, creds)
+	if err != nil {
+		return err
+	}
+	for _, s := range ss {
+		digest, err := v1.NewHash(s.Digest)
+		if err != nil {
+			return fmt.Errorf("invalid signature of %v. %w", s.Digest, err)
+		}
+		img, err := signatureImage(s)
+		if err != nil {
+			return err
+		}
+		tag := ref.Context().Tag(signatureTag(digest))
+		if err = remote.Write(tag, img, oo...); err != nil {
+			return fmt.Errorf("error pushing the signature of %v. %w", digest, err)
+		}
+		if p.Verbose {
+			fmt.Printf("\nsigned: %s\n", tag)
+		}
+	}
+	return nil
+}
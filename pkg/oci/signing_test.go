@@ -0,0 +1,298 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// writeSigningKey writes the key, PEM encoded as of the given type, to a
+// file of the test's temporary directory.
+func writeSigningKey(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newSigningKey returns the path of a new ECDSA P-256 key (PKCS #8).
+func newSigningKey(t *testing.T) (string, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeSigningKey(t, "PRIVATE KEY", der), key
+}
+
+// Test_ValidateSigning ensures ECDSA, Ed25519 and RSA keys are accepted, and
+// encrypted or invalid keys, or signing manifests without a key, are not.
+func Test_ValidateSigning(t *testing.T) {
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(ec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{
+		writeSigningKey(t, "EC PRIVATE KEY", sec1),
+		writeSigningKey(t, "PRIVATE KEY", pkcs8),
+		writeSigningKey(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rs)),
+	} {
+		if err := ValidateSigning(key, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{
+		writeSigningKey(t, "ENCRYPTED PRIVATE KEY", pkcs8),
+		writeSigningKey(t, "PRIVATE KEY", []byte("invalid")),
+		filepath.Join(t.TempDir(), "missing.pem"),
+	} {
+		if err := ValidateSigning(key, false); err == nil {
+			t.Fatalf("expected error for the key %v", key)
+		}
+	}
+	if err := ValidateSigning("", true); err == nil {
+		t.Fatal("expected error signing manifests without a key")
+	}
+}
+
+// TestBuilder_SignManifests ensures the index and, when enabled, each
+// platform manifest are signed, each signature verifying with the public key
+// over a payload of its digest.
+func TestBuilder_SignManifests(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Build.Image = "example.com/alice/f:latest"
+	platforms := []fn.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64"}}
+	job, err := newBuildJob(context.Background(), f, platforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+
+	path, key := newSigningKey(t)
+	for _, manifests := range []bool{false, true} {
+		job.signingKey, job.signManifests = path, manifests
+		if err = os.RemoveAll(filepath.Join(job.buildDir(), SignaturesDir)); err != nil {
+			t.Fatal(err)
+		}
+
+		var dd []v1.Descriptor
+		for _, p := range job.platforms {
+			// A config of each platform, such that their manifests differ
+			config, _, err := v1.SHA256(strings.NewReader(p.Architecture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := writeManifest(job, p, nil, v1.Descriptor{Digest: config}, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dd = append(dd, d)
+		}
+		if err = writeIndex(job, dd); err != nil {
+			t.Fatal(err)
+		}
+		bb, err := os.ReadFile(filepath.Join(job.ociDir(), "index.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		index, _, err := v1.SHA256(bytes.NewReader(bb))
+		if err != nil {
+			t.Fatal(err)
+		}
+		signed := []string{index.String()}
+		if manifests {
+			for _, d := range dd {
+				signed = append(signed, d.Digest.String())
+			}
+		}
+
+		ss, err := readSignatures(job.buildDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ss) != len(signed) {
+			t.Fatalf("expected %v signatures, got %v", len(signed), len(ss))
+		}
+		for _, digest := range signed {
+			s := signatureOf(t, ss, digest)
+			var payload struct {
+				Critical struct {
+					Identity struct {
+						DockerReference string `json:"docker-reference"`
+					} `json:"identity"`
+					Image struct {
+						DockerManifestDigest string `json:"docker-manifest-digest"`
+					} `json:"image"`
+				} `json:"critical"`
+			}
+			if err = json.Unmarshal(s.Payload, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Critical.Image.DockerManifestDigest != digest {
+				t.Fatalf("expected a payload of %v, got %v", digest, payload.Critical.Image.DockerManifestDigest)
+			}
+			if payload.Critical.Identity.DockerReference != "example.com/alice/f" {
+				t.Fatalf("unexpected docker-reference %q", payload.Critical.Identity.DockerReference)
+			}
+			sig, err := base64.StdEncoding.DecodeString(s.Signature)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := sha256.Sum256(s.Payload)
+			if !ecdsa.VerifyASN1(&key.PublicKey, h[:], sig) {
+				t.Fatalf("signature of %v does not verify", digest)
+			}
+		}
+	}
+}
+
+// TestPusher_Signatures ensures the signatures of the last build are pushed,
+// and mirrored, as {algorithm}-{hex}.sig tags, their payload annotated with
+// the signature.
+func TestPusher_Signatures(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(s.Close)
+	reg := strings.TrimPrefix(s.URL, "http://")
+
+	root := t.TempDir()
+	last := filepath.Join(root, fn.RunDataDir, "builds", "last")
+	ii, err := layout.ImageIndexFromPath(newTestLayout(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = layout.Write(filepath.Join(last, "oci"), ii); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := ii.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, key := newSigningKey(t)
+	signer, err := loadSigningKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := simpleSigningPayload("", digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := sign(signer, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb, err := json.Marshal(Signature{Digest: digest.String(), Payload: payload, Signature: base64.StdEncoding.EncodeToString(sig)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(last, SignaturesDir), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(last, SignaturesDir, signatureTag(digest)+".json"), bb, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pushed, and mirrored, alongside the image
+	f := fn.Function{Root: root, Name: "f"}
+	f.Build.Image = reg + "/funcs/f:latest"
+	p := NewPusher(true, true, false)
+	if _, err = p.Push(context.Background(), f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Mirror(context.Background(), f, reg+"/dr/f:latest"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, repo := range []string{"funcs", "dr"} {
+		ref, err := name.ParseReference(reg+"/"+repo+"/f:"+signatureTag(digest), name.Insecure)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := remote.Image(ref)
+		if err != nil {
+			t.Fatalf("expected the signature to be pushed to %v. %v", repo, err)
+		}
+		m, err := img.Manifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Layers) != 1 || string(m.Layers[0].MediaType) != simpleSigningMediaType {
+			t.Fatalf("expected one simple signing layer, got %v", m.Layers)
+		}
+		pushed, err := base64.StdEncoding.DecodeString(m.Layers[0].Annotations[signatureAnnotation])
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := sha256.Sum256(payload)
+		if !ecdsa.VerifyASN1(&key.PublicKey, h[:], pushed) {
+			t.Fatalf("signature pushed to %v does not verify", repo)
+		}
+	}
+}
+
+func signatureOf(t *testing.T, ss []Signature, digest string) Signature {
+	t.Helper()
+	for _, s := range ss {
+		if s.Digest == digest {
+			return s
+		}
+	}
+	t.Fatalf("expected a signature of %v", digest)
+	return Signature{}
+}
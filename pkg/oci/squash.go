@@ -0,0 +1,231 @@
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	slashpath "path"
+	"path/filepath"
+	"strings"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// Squash modes, which combine the layers of each platform's image into one.
+const (
+	SquashNone     = ""         // layers are not squashed (default)
+	SquashFunction = "function" // the function's layers, atop the base's
+	SquashAll      = "all"      // the function's and the base's layers
+)
+
+// Whiteout files, as defined by the OCI image spec, which delete a path of a
+// lower layer (.wh.<name>) or all of a directory's contents of lower layers
+// (.wh..wh..opq).
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// WithSquash combines the layers of each platform's image into a single
+// layer: those of the function (data, certificates, language and platform
+// layers) with SquashFunction, or additionally those of the base image with
+// SquashAll, for a single-layer image.  Files deleted by an upper layer
+// (whiteouts) are omitted, and when including the base, the whiteouts
+// themselves are removed as there is no lower layer to which they apply.
+func WithSquash(mode string) BuilderOpt {
+	return func(b *Builder) {
+		b.squash = mode
+	}
+}
+
+// ValidateSquash returns an error if the squash mode is not known.
+// SquashNone is valid.
+func ValidateSquash(mode string) error {
+	switch mode {
+	case SquashNone, SquashFunction, SquashAll:
+		return nil
+	}
+	return fmt.Errorf("invalid squash mode %q: must be %q or %q", mode, SquashFunction, SquashAll)
+}
+
+// squash returns the function's layers for the platform squashed into a
+// single layer, including those of the base with SquashAll.
+func squash(job buildJob, p v1.Platform, base v1.Image, layers []ImageLayer) (squashed []ImageLayer, err error) {
+	ll := []v1.Layer{}
+	if job.squash == SquashAll && base != nil {
+		if ll, err = pulledBaseLayers(job, base); err != nil {
+			return
+		}
+	}
+	for _, l := range layers {
+		ll = append(ll, l.Layer)
+	}
+	layer, err := squashLayers(job, p, ll, job.squash != SquashAll)
+	return []ImageLayer{layer}, err
+}
+
+// squashedConfig returns the config of an image squashed with its base
+// (SquashAll): that of the base and function with only the squashed layer,
+// and its history.
+func squashedConfig(job buildJob, cfg v1.ConfigFile) v1.ConfigFile {
+	cfg.RootFS.DiffIDs = cfg.RootFS.DiffIDs[len(cfg.RootFS.DiffIDs)-1:]
+	cfg.History = []v1.History{{
+		Author:  "func",
+		Created: v1.Time{Time: job.created()},
+		Comment: "func host builder (squashed)",
+	}}
+	return cfg
+}
+
+// squashLayers writes the given layers (lowest first) as a single layer to
+// the blobs directory.  Whiteouts are retained if keepWhiteouts, as they
+// apply to lower layers which are not squashed (the base).
+func squashLayers(job buildJob, p v1.Platform, layers []v1.Layer, keepWhiteouts bool) (ImageLayer, error) {
+	target := filepath.Join(job.buildDir(),
+		fmt.Sprintf("squashed.%v.tar.gz", strings.ReplaceAll(platformName(p), "/", ".")))
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "Squashing %v layers into %v\n", len(layers), rel(job.buildDir(), target))
+	}
+	if err := newSquashedTarball(target, layers, keepWhiteouts); err != nil {
+		return ImageLayer{}, err
+	}
+	return writeLayer(job, target)
+}
+
+// newSquashedTarball writes the layers to a single gzipped tarball at target.
+// Layers are read from the highest to the lowest such that the first entry of
+// a path is that which is visible in the image, and entries of a lower layer
+// which are deleted by a whiteout, or hidden by an opaque directory, of a
+// higher layer are omitted.
+func newSquashedTarball(target string, layers []v1.Layer, keepWhiteouts bool) (err error) {
+	file, err := os.Create(target)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	gw := gzip.NewWriter(file)
+	defer gw.Close()
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	var (
+		seen    = map[string]bool{} // paths written (or whited out)
+		deleted = map[string]bool{} // paths deleted, and their contents
+		opaque  = map[string]bool{} // directories whose lower contents are hidden
+	)
+	for i := len(layers) - 1; i >= 0; i-- {
+		// Whiteouts of this layer apply only to lower layers
+		layerDeleted, layerOpaque := map[string]bool{}, map[string]bool{}
+		if err = squashLayer(tw, layers[i], keepWhiteouts, seen, deleted, opaque, layerDeleted, layerOpaque); err != nil {
+			return
+		}
+		for p := range layerDeleted {
+			deleted[p] = true
+		}
+		for p := range layerOpaque {
+			opaque[p] = true
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return
+	}
+	if err = gw.Close(); err != nil {
+		return
+	}
+	return file.Close()
+}
+
+// squashLayer writes the visible entries of a single layer.
+func squashLayer(tw *tar.Writer, layer v1.Layer, keepWhiteouts bool, seen, deleted, opaque, layerDeleted, layerOpaque map[string]bool) error {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		p := slashpath.Clean("/" + header.Name)
+		dir, base := slashpath.Split(p)
+		dir = slashpath.Clean(dir)
+
+		if hidden(p, deleted, opaque) {
+			continue
+		}
+
+		// Whiteouts
+		if base == whiteoutOpaque {
+			layerOpaque[dir] = true
+		} else if strings.HasPrefix(base, whiteoutPrefix) {
+			target := slashpath.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))
+			if seen[target] {
+				continue // recreated by a higher layer
+			}
+			layerDeleted[target] = true
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			if keepWhiteouts && !seen[p] {
+				seen[p] = true
+				if err = tw.WriteHeader(header); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if seen[p] {
+			continue // overwritten by a higher layer
+		}
+		seen[p] = true
+		if err = tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err = io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// hidden returns true if the path, or any of its parent directories, was
+// deleted by a higher layer, or is within a directory made opaque by a higher
+// layer.
+func hidden(p string, deleted, opaque map[string]bool) bool {
+	for q := p; ; q = slashpath.Dir(q) {
+		if deleted[q] {
+			return true
+		}
+		if q != p && opaque[q] {
+			return true
+		}
+		if q == "/" {
+			return false
+		}
+	}
+}
+
+// pulledBaseLayers returns the layers of the base image, as written to the
+// blobs directory when pulled, such that they are not fetched again.
+func pulledBaseLayers(job buildJob, base v1.Image) (layers []v1.Layer, err error) {
+	manifest, err := base.Manifest()
+	if err != nil {
+		return
+	}
+	for _, d := range manifest.Layers {
+		layer, err := tarball.LayerFromFile(filepath.Join(job.blobsDir(), d.Digest.Hex))
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+	return
+}
@@ -0,0 +1,117 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// TestSquash ensures squashed layers contain only the files visible in the
+// image: the highest version of each, without those deleted by whiteouts or
+// hidden by opaque directories, and with the whiteouts themselves only when
+// they apply to lower layers which are not squashed.
+func TestSquash(t *testing.T) {
+	base := testLayer(t, map[string]string{
+		"etc/a":   "a",
+		"etc/b":   "b",
+		"opt/x/1": "1",
+		"opt/x/2": "2",
+	})
+	upper := testLayer(t, map[string]string{
+		"etc/.wh.a":          "",
+		"opt/x/.wh..wh..opq": "",
+		"opt/x/3":            "3",
+		"etc/b":              "b2",
+	})
+	top := testLayer(t, map[string]string{
+		"etc/a": "a2", // recreated
+	})
+
+	// With the base, whiteouts are applied and removed
+	got := squashedFiles(t, []v1.Layer{base, upper}, false)
+	want := map[string]string{"/etc/b": "b2", "/opt/x/3": "3"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected squashed files (-want, +got): %v", diff)
+	}
+
+	// Without the base, whiteouts which apply to it are retained, but not
+	// those of a path recreated by a higher layer.
+	got = squashedFiles(t, []v1.Layer{upper, top}, true)
+	want = map[string]string{"/etc/a": "a2", "/etc/b": "b2", "/opt/x/3": "3", "/opt/x/.wh..wh..opq": ""}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected squashed files (-want, +got): %v", diff)
+	}
+
+	if err := ValidateSquash("base"); err == nil {
+		t.Fatal("expected an unknown squash mode to be invalid")
+	}
+}
+
+// testLayer returns an uncompressed layer of the given files and contents.
+func testLayer(t *testing.T, files map[string]string) v1.Layer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layer
+}
+
+// squashedFiles squashes the layers and returns the files of the result.
+func squashedFiles(t *testing.T, layers []v1.Layer, keepWhiteouts bool) map[string]string {
+	t.Helper()
+	target := filepath.Join(t.TempDir(), "squashed.tar.gz")
+	if err := newSquashedTarball(target, layers, keepWhiteouts); err != nil {
+		t.Fatal(err)
+	}
+	layer, err := tarball.LayerFromFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	files := map[string]string{}
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := files["/"+header.Name]; ok {
+			t.Fatalf("duplicate entry %v", header.Name)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files["/"+header.Name] = string(content)
+	}
+}
@@ -0,0 +1,92 @@
+package oci
+
+import (
+	"sync/atomic"
+	"time"
+
+	fn "knative.dev/func/pkg/functions"
+)
+
+// Sink receives an event at the end of each build, such as to feed metrics
+// of builds to a dashboard.  Record is called synchronously by the build,
+// and so should not block.
+type Sink interface {
+	Record(BuildEvent)
+}
+
+// SinkFunc is a function which is a Sink.
+type SinkFunc func(BuildEvent)
+
+// Record the event by calling the function.
+func (f SinkFunc) Record(e BuildEvent) { f(e) }
+
+// BuildEvent is the anonymized record of a build sent to the telemetry
+// sink.  It includes nothing which identifies the function or its author,
+// such as its name, path, image or source.
+type BuildEvent struct {
+	Runtime     string        // runtime of the function, such as "go"
+	Platforms   int           // number of platforms built
+	Duration    time.Duration // total duration of the build
+	Success     bool          // whether the build succeeded
+	CacheHits   int           // base layers found in the blob cache
+	CacheMisses int           // base layers downloaded into the blob cache
+}
+
+// CacheHitRate is the fraction of the build's base layers found in the blob
+// cache, from 0 to 1, or 0 if it had none.
+func (e BuildEvent) CacheHitRate() float64 {
+	if e.CacheHits+e.CacheMisses == 0 {
+		return 0
+	}
+	return float64(e.CacheHits) / float64(e.CacheHits+e.CacheMisses)
+}
+
+// WithTelemetry sends an event of each build (see BuildEvent), successful or
+// failed, to the sink.  Telemetry is opt-in: without a sink, the default, no
+// events are recorded.
+func WithTelemetry(s Sink) BuilderOpt {
+	return func(b *Builder) {
+		b.telemetry = s
+	}
+}
+
+// cacheStats counts the base layers found in, and downloaded into, the blob
+// cache by a build.
+type cacheStats struct {
+	hits, misses atomic.Int64
+}
+
+// cacheHit counts a base layer found in the blob cache.
+func (j buildJob) cacheHit() {
+	if j.cacheStats != nil {
+		j.cacheStats.hits.Add(1)
+	}
+}
+
+// cacheMiss counts a base layer downloaded into the blob cache.
+func (j buildJob) cacheMiss() {
+	if j.cacheStats != nil {
+		j.cacheStats.misses.Add(1)
+	}
+}
+
+// record sends the event of a build of the function's platforms begun at
+// start, which failed if err is not nil, to the telemetry sink, if any.  It
+// is deferred first by each build, such that builds which fail before their
+// job is created are recorded as well, of the cache stats of the job, if any.
+func (b *Builder) record(f fn.Function, pp []fn.Platform, start time.Time, stats *cacheStats, err error) {
+	if b.telemetry == nil {
+		return
+	}
+	e := BuildEvent{
+		Runtime:   f.Runtime,
+		Platforms: len(pp),
+		Duration:  time.Since(start),
+		Success:   err == nil,
+	}
+	if stats != nil {
+		e.CacheHits = int(stats.hits.Load())
+		e.CacheMisses = int(stats.misses.Load())
+	}
+	b.telemetry.Record(e)
+}
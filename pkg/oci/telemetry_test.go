@@ -0,0 +1,138 @@
+package oci
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestBuilder_Telemetry ensures an event is recorded of a failed build, and
+// that none is recorded without a sink.
+func TestBuilder_Telemetry(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsupported := []fn.Platform{{OS: "windows", Architecture: "amd64"}}
+
+	events := []BuildEvent{}
+	sink := SinkFunc(func(e BuildEvent) { events = append(events, e) })
+	b := NewBuilder("", false, WithQuiet(true), WithTelemetry(sink))
+	if err = b.Build(context.Background(), f, unsupported); err == nil {
+		t.Fatal("expected the build of an unsupported platform to fail")
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %v", len(events))
+	}
+	if e := events[0]; e.Success || e.Runtime != "go" || e.Platforms != 1 || e.Duration <= 0 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	// Opt-in: without a sink no event is recorded
+	if err = NewBuilder("", false, WithQuiet(true)).Build(context.Background(), f, unsupported); err == nil {
+		t.Fatal("expected the build of an unsupported platform to fail")
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected no event without a sink, got %v", len(events)-1)
+	}
+}
+
+// TestBuilder_TelemetryEarlyFailure ensures an event is recorded of each
+// entry point of the builder which fails before its build job is created,
+// such as of invalid options or a missing function.
+func TestBuilder_TelemetryEarlyFailure(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	platform := fn.Platform{OS: "linux", Architecture: "amd64"}
+
+	events := []BuildEvent{}
+	sink := SinkFunc(func(e BuildEvent) { events = append(events, e) })
+	invalid := NewBuilder("", false, WithQuiet(true), WithTelemetry(sink), WithMediaTypes("schema1"))
+	tests := []struct {
+		name      string
+		build     func() error
+		platforms int
+	}{
+		{"Build of a missing function", func() error {
+			return NewBuilder("", false, WithQuiet(true), WithTelemetry(sink)).Build(context.Background(), fn.Function{Root: t.TempDir(), Runtime: "go"}, []fn.Platform{platform})
+		}, 1},
+		{"Build", func() error {
+			return invalid.Build(context.Background(), f, []fn.Platform{platform})
+		}, 1},
+		{"BuildPlatform", func() error {
+			_, err := invalid.BuildPlatform(context.Background(), f, platform)
+			return err
+		}, 1},
+		{"BuildArtifact", func() error {
+			return invalid.BuildArtifact(context.Background(), f, "module.wasm", "")
+		}, 0},
+		{"Rebase", func() error {
+			return invalid.Rebase(context.Background(), f)
+		}, 0},
+	}
+	for _, test := range tests {
+		events = events[:0]
+		if err = test.build(); err == nil {
+			t.Fatalf("%v: expected error", test.name)
+		}
+		if len(events) != 1 {
+			t.Fatalf("%v: expected one event, got %v", test.name, len(events))
+		}
+		if e := events[0]; e.Success || e.Runtime != "go" || e.Platforms != test.platforms {
+			t.Fatalf("%v: unexpected event %+v", test.name, e)
+		}
+	}
+}
+
+// Test_recordCacheHits ensures the base layers found in and downloaded into
+// the blob cache are counted.
+func Test_recordCacheHits(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.sharedCache = availableCache(t.TempDir(), false)
+	layer, err := random.Layer(64, types.OCILayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ { // downloaded once, then found
+		if err = ensureCached(job, layer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var e BuildEvent
+	b := NewBuilder("", false, WithTelemetry(SinkFunc(func(event BuildEvent) { e = event })))
+	b.record(f, TestPlatforms, job.start, job.cacheStats, nil)
+	if !e.Success || e.CacheHits != 2 || e.CacheMisses != 1 {
+		t.Fatalf("expected a successful build of 2 cache hits and 1 miss, got %+v", e)
+	}
+	if rate := e.CacheHitRate(); rate < 0.66 || rate > 0.67 {
+		t.Fatalf("expected a cache hit rate of 2/3, got %v", rate)
+	}
+	if rate := (BuildEvent{}).CacheHitRate(); rate != 0 {
+		t.Fatalf("expected a cache hit rate of 0 without base layers, got %v", rate)
+	}
+}
@@ -0,0 +1,125 @@
+package oci
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// BuildResult is the result of a build, available from the builder after
+// building (see Builder.Result).
+type BuildResult struct {
+	Duration    time.Duration // total duration of the build
+	Timings     Timings       // duration of each phase, in the order started
+	Fingerprint string        // of the function's source, identifying the build
+	Dir         string        // build directory (.func/builds/by-hash/{fingerprint})
+	Index       v1.Hash       // digest of the image index, if built
+	Images      []ImageResult // image of each platform, if built
+}
+
+// PhaseTiming is the duration of a phase of a build, such as "scaffold" or
+// "compile linux/amd64".
+type PhaseTiming struct {
+	Phase    string
+	Duration time.Duration
+	PeakRSS  int64 // peak memory (resident set size) of the phase's processes in bytes, if known
+}
+
+func (t PhaseTiming) String() string {
+	if t.PeakRSS > 0 {
+		return fmt.Sprintf("%v %.1fs (peak %.0f MiB)", t.Phase, t.Duration.Seconds(), float64(t.PeakRSS)/(1<<20))
+	}
+	return fmt.Sprintf("%v %.1fs", t.Phase, t.Duration.Seconds())
+}
+
+// Timings of the phases of a build.
+type Timings []PhaseTiming
+
+// String summarizes the timings, eg. "scaffold 0.2s, compile 4.1s (peak
+// 512 MiB)".
+func (tt Timings) String() string {
+	ss := make([]string, len(tt))
+	for i, t := range tt {
+		ss[i] = t.String()
+	}
+	return strings.Join(ss, ", ")
+}
+
+// phaseTimer records the timings of the phases of a build job.
+type phaseTimer struct {
+	mu      sync.Mutex
+	timings Timings
+}
+
+// phase starts timing the named phase of the job, returning the function
+// which ends it.  A job without a timer (nil) records nothing.
+//
+//	defer job.phase("scaffold")()
+func (j buildJob) phase(name string) (end func()) {
+	t, start := j.timer, time.Now()
+	if t == nil {
+		return func() {}
+	}
+	t.mu.Lock()
+	i := len(t.timings)
+	t.timings = append(t.timings, PhaseTiming{Phase: name})
+	t.mu.Unlock()
+	return func() {
+		t.mu.Lock()
+		t.timings[i].Duration = time.Since(start)
+		t.mu.Unlock()
+	}
+}
+
+// peak records the peak memory (resident set size) in bytes of a process of
+// the most recently started phase of the given name, such as the compiler,
+// retaining the greatest of those recorded.  Informational only: zero (not
+// available) is ignored.
+func (j buildJob) peak(name string, rss int64) {
+	t := j.timer
+	if t == nil || rss <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := len(t.timings) - 1; i >= 0; i-- {
+		if t.timings[i].Phase == name {
+			t.timings[i].PeakRSS = max(t.timings[i].PeakRSS, rss)
+			return
+		}
+	}
+}
+
+// result of the job as of now.
+func (j buildJob) result() BuildResult {
+	r := BuildResult{Duration: time.Since(j.start), Fingerprint: j.hash}
+	if j.hash != "" {
+		r.Dir = j.buildDir()
+	}
+	if j.timer != nil {
+		j.timer.mu.Lock()
+		r.Timings = append(Timings{}, j.timer.timings...)
+		j.timer.mu.Unlock()
+	}
+	return r
+}
+
+// Result of the last build, including its phase timings.  A build which
+// failed has timings up to and including the phase which failed.
+func (b *Builder) Result() BuildResult {
+	b.resultMu.Lock()
+	defer b.resultMu.Unlock()
+	return b.result
+}
+
+// platformName returns the platform in the form os/arch[/variant].
+func platformName(p v1.Platform) string {
+	name := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		name += "/" + p.Variant
+	}
+	return name
+}
@@ -0,0 +1,88 @@
+package oci
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestBuildJob_Phase ensures the phases of a job are timed in the order
+// started, and summarized.
+func TestBuildJob_Phase(t *testing.T) {
+	job := buildJob{start: time.Now(), timer: &phaseTimer{}}
+
+	endScaffold := job.phase("scaffold")
+	endCompile := job.phase("compile linux/amd64")
+	time.Sleep(10 * time.Millisecond)
+	endCompile()
+	endScaffold()
+
+	r := job.result()
+	if len(r.Timings) != 2 || r.Timings[0].Phase != "scaffold" || r.Timings[1].Phase != "compile linux/amd64" {
+		t.Fatalf("unexpected phases: %v", r.Timings)
+	}
+	for _, p := range r.Timings {
+		if p.Duration < 10*time.Millisecond {
+			t.Fatalf("expected phase %q to take at least 10ms, got %v", p.Phase, p.Duration)
+		}
+	}
+	if r.Duration < r.Timings[0].Duration {
+		t.Fatalf("expected total %v to include phases", r.Duration)
+	}
+
+	tt := Timings{{Phase: "scaffold", Duration: 200 * time.Millisecond}, {Phase: "compile", Duration: 4100 * time.Millisecond}}
+	if s := tt.String(); s != "scaffold 0.2s, compile 4.1s" {
+		t.Fatalf("unexpected summary %q", s)
+	}
+	tt[1].PeakRSS = 512 << 20
+	if s := tt.String(); s != "scaffold 0.2s, compile 4.1s (peak 512 MiB)" {
+		t.Fatalf("unexpected summary with peak memory %q", s)
+	}
+
+	// A job without a timer records nothing
+	buildJob{}.phase("scaffold")()
+}
+
+// TestBuildJob_Peak ensures the peak memory of a phase's processes is
+// recorded against the latest phase of the name, keeping the greatest, and
+// that unavailable (zero) measurements are ignored.
+func TestBuildJob_Peak(t *testing.T) {
+	job := buildJob{start: time.Now(), timer: &phaseTimer{}}
+	job.phase("compile linux/amd64")()
+	job.phase("compile linux/arm64")()
+
+	job.peak("compile linux/amd64", 200<<20)
+	job.peak("compile linux/amd64", 100<<20)
+	job.peak("compile linux/arm64", 0)
+	job.peak("unknown", 100<<20)
+
+	r := job.result()
+	if r.Timings[0].PeakRSS != 200<<20 {
+		t.Fatalf("expected the greatest peak to be retained, got %v", r.Timings[0].PeakRSS)
+	}
+	if r.Timings[1].PeakRSS != 0 {
+		t.Fatalf("expected no peak when unavailable, got %v", r.Timings[1].PeakRSS)
+	}
+
+	// A job without a timer records nothing
+	buildJob{}.peak("compile linux/amd64", 100<<20)
+}
+
+// Test_peakRSS ensures the peak memory of an exited process is available
+// where supported.
+func Test_peakRSS(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("peak memory is only verified on linux and darwin")
+	}
+	cmd := exec.Command("go", "version")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if rss := peakRSS(cmd.ProcessState); rss < 1<<20 {
+		t.Fatalf("expected a peak of at least 1 MiB, got %v bytes", rss)
+	}
+	if rss := peakRSS(nil); rss != 0 {
+		t.Fatalf("expected no peak for a process not run, got %v", rss)
+	}
+}
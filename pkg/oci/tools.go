@@ -0,0 +1,29 @@
+package oci
+
+import (
+	"os/exec"
+)
+
+// toolHints are how to install the tools required by the language builders,
+// by command name.
+var toolHints = map[string]string{
+	"go":      "install Go from https://go.dev/dl",
+	"python":  "install Python 3 from https://www.python.org/downloads",
+	"python3": "install Python 3 from https://www.python.org/downloads (with venv, such as the python3-venv package)",
+	"cargo":   "install Rust from https://rustup.rs",
+}
+
+// checkTools returns an ErrMissingTools listing each tool required by the
+// job's language builder which is not found on the PATH.
+func checkTools(job buildJob) error {
+	var missing []string
+	for _, t := range job.languageBuilder.RequiredTools() {
+		if _, err := exec.LookPath(t); err != nil {
+			missing = append(missing, t)
+		}
+	}
+	if len(missing) > 0 {
+		return ErrMissingTools{Runtime: job.function.Runtime, Tools: missing}
+	}
+	return nil
+}
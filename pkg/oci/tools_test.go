@@ -0,0 +1,60 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestBuilder_RequiredTools ensures a build whose language builder requires
+// tools not found on the PATH fails before building, listing each missing
+// tool with its install hint.
+func TestBuilder_RequiredTools(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	// A PATH of only go
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "go"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := NewTestLanguageBuilder()
+	impl.RequiredToolsFn = func() []string { return []string{"go", "cargo", "func-test-tool"} }
+	b := NewBuilder("", false, WithQuiet(true))
+	b.impl = impl
+
+	var e ErrMissingTools
+	_, err = b.BuildPlatform(context.Background(), f, fn.Platform{OS: "linux", Architecture: "amd64"})
+	if !errors.As(err, &e) {
+		t.Fatalf("expected ErrMissingTools, got %v", err)
+	}
+	if !reflect.DeepEqual(e.Tools, []string{"cargo", "func-test-tool"}) {
+		t.Fatalf("unexpected missing tools %v", e.Tools)
+	}
+	if !strings.Contains(err.Error(), "https://rustup.rs") {
+		t.Fatalf("expected an install hint for cargo, got %v", err)
+	}
+	if impl.WriteSharedInvoked {
+		t.Fatal("expected the build to fail before building")
+	}
+
+	// All found
+	impl.RequiredToolsFn = func() []string { return []string{"go"} }
+	if _, err = b.BuildPlatform(context.Background(), f, fn.Platform{OS: "linux", Architecture: "amd64"}); err != nil {
+		t.Fatal(err)
+	}
+}
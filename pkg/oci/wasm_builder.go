@@ -0,0 +1,130 @@
+package oci
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// wasmRuntime is the runtime of functions built as a WebAssembly module.
+const wasmRuntime = "wasm"
+
+// wasmPlatform is the platform of a WebAssembly (WASI preview 1) image, as
+// understood by container runtimes which run wasm, such as the containerd
+// wasm shims.  Go names its OS wasip1 (see toPlatforms).
+var wasmPlatform = v1.Platform{OS: "wasi", Architecture: "wasm"}
+
+// wasmModule is the path of the module in the image.
+const wasmModule = "/func/f.wasm"
+
+// wasmBuilder builds functions of the wasm runtime as a WebAssembly module:
+// a prebuilt module (the one *.wasm in the function's root), or else the
+// function's own main package compiled by Go for wasip1.  There is no
+// scaffolding, as wasip1 has no sockets with which to serve the function.
+type wasmBuilder struct{}
+
+func (b wasmBuilder) Base(customImage string) string {
+	return customImage // from scratch unless defined
+}
+
+func (b wasmBuilder) Configure(_ BuildContext, _ v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
+	cf.Config.Cmd = []string{wasmModule}
+	return cf, nil
+}
+
+// Compiled wasm functions are a self-contained module (see CompiledBuilder).
+func (b wasmBuilder) Compiled() bool {
+	return true
+}
+
+// SupportsPlatform returns true only for wasi/wasm (see PlatformBuilder).
+func (b wasmBuilder) SupportsPlatform(p v1.Platform) bool {
+	return p.OS == wasmPlatform.OS && p.Architecture == wasmPlatform.Architecture && p.Variant == ""
+}
+
+// DefaultPlatforms is only wasi/wasm (see DefaultPlatformsBuilder).
+func (b wasmBuilder) DefaultPlatforms() []fn.Platform {
+	return []fn.Platform{{OS: wasmPlatform.OS, Architecture: wasmPlatform.Architecture}}
+}
+
+// RequiredTools is none, as a prebuilt module requires no tools.  Go is
+// only required when compiling, which fails if it is not found (see
+// LanguageBuilder).
+func (b wasmBuilder) RequiredTools() []string {
+	return nil
+}
+
+func (b wasmBuilder) WriteShared(_ BuildContext) ([]ImageLayer, error) {
+	return []ImageLayer{}, nil
+}
+
+// WritePlatform writes the module, prebuilt or compiled, to /func/f.wasm.
+func (b wasmBuilder) WritePlatform(cfg BuildContext, p v1.Platform) (layers []ImageLayer, err error) {
+	module, err := wasmPrebuilt(cfg.function.Root)
+	if err != nil {
+		return
+	}
+	if module == "" {
+		// The function's main package, as is built without scaffolding
+		job := cfg.buildJob
+		job.function.Build.NoScaffold = true
+		if module, err = goBuild(job, v1.Platform{OS: "wasip1", Architecture: "wasm"}); err != nil {
+			return
+		}
+	} else if cfg.verbose {
+		fmt.Fprintf(os.Stderr, "Using prebuilt module %v\n", module)
+	} else if !cfg.quiet {
+		fmt.Printf("   %v (prebuilt)\n", filepath.Base(module))
+	}
+
+	target := filepath.Join(cfg.buildDir(), "execlayer.f.wasm.tar.gz")
+	if err = exeTarball(module, target, wasmModule, nil, cfg.zeroTimes, cfg.verbose); err != nil {
+		return
+	}
+	layer, err := cfg.WriteLayer(target)
+	if err != nil {
+		return
+	}
+	layer.Descriptor.Platform = &p
+	return []ImageLayer{layer}, nil
+}
+
+// wasmScaffold ensures the source of a wasm function can be built, in place
+// of scaffolding: a prebuilt module or a Go main package.
+func wasmScaffold(job buildJob) error {
+	module, err := wasmPrebuilt(job.function.Root)
+	if err != nil {
+		return ErrScaffold{err}
+	}
+	if module == "" {
+		if _, err = os.Stat(filepath.Join(job.function.Root, "go.mod")); err != nil {
+			return ErrScaffold{fmt.Errorf("wasm functions require a prebuilt module (*.wasm) or Go source (go.mod) in %v", job.function.Root)}
+		}
+		if err = goEntrypoint(job.function.Root); err != nil {
+			return ErrScaffold{err}
+		}
+	}
+	if job.verbose {
+		fmt.Fprintf(os.Stderr, "Skipping scaffolding of wasm function %v\n", job.function.Root)
+	}
+	return os.MkdirAll(job.buildDir(), os.ModePerm)
+}
+
+// wasmPrebuilt returns the path of the prebuilt module in the function's
+// root, or empty if there is none.  More than one is ambiguous.
+func wasmPrebuilt(root string) (string, error) {
+	modules, err := filepath.Glob(filepath.Join(root, "*.wasm"))
+	if err != nil {
+		return "", err
+	}
+	switch len(modules) {
+	case 0:
+		return "", nil
+	case 1:
+		return modules[0], nil
+	}
+	return "", fmt.Errorf("more than one prebuilt wasm module in %v", root)
+}
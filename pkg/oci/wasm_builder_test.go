@@ -0,0 +1,149 @@
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	fn "knative.dev/func/pkg/functions"
+)
+
+// TestWasmBuilder ensures a wasm function is built for wasi/wasm by default
+// with its module, prebuilt or compiled from its main package, at
+// /func/f.wasm, and that source which is neither fails before building.
+func TestWasmBuilder(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+	module := []byte("\x00asm\x01\x00\x00\x00")
+
+	build := func(t *testing.T, root string) (content []byte, err error) {
+		f := fn.Function{Root: root, Name: "f", Runtime: wasmRuntime}
+		job, err := newBuildJob(context.Background(), f, defaultPlatforms(f.Runtime), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job.quiet = true
+		if err = setup(job); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup(job)
+		defer os.Remove(job.pidLink())
+		if err = scaffold(job); err != nil {
+			return
+		}
+		if err = containerize(job); err != nil {
+			return
+		}
+		ii, err := layout.ImageIndexFromPath(job.ociDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		im, err := ii.IndexManifest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(im.Manifests) != 1 || platformName(*im.Manifests[0].Platform) != "wasi/wasm" {
+			t.Fatalf("expected an image of wasi/wasm, got %v", im.Manifests)
+		}
+		img, err := ii.Image(im.Manifests[0].Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cf, err := img.ConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cf.OS != "wasi" || cf.Architecture != "wasm" || len(cf.Config.Cmd) != 1 || cf.Config.Cmd[0] != wasmModule {
+			t.Fatalf("expected the config of a wasm module, got %v/%v %v", cf.OS, cf.Architecture, cf.Config.Cmd)
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := layers[len(layers)-1].Compressed()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		gr, err := gzip.NewReader(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := tar.NewReader(gr)
+		header, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name != wasmModule {
+			t.Fatalf("expected %v, got %v", wasmModule, header.Name)
+		}
+		return io.ReadAll(tr)
+	}
+
+	t.Run("prebuilt", func(t *testing.T) {
+		root := t.TempDir()
+		if err := os.WriteFile(filepath.Join(root, "f.wasm"), module, 0644); err != nil {
+			t.Fatal(err)
+		}
+		content, err := build(t, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != string(module) {
+			t.Fatal("expected the prebuilt module")
+		}
+	})
+
+	t.Run("go", func(t *testing.T) {
+		root := t.TempDir()
+		if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module f\n\ngo 1.21\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc main() { println(\"hello\") }\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		content, err := build(t, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(content) < 4 || string(content[:4]) != "\x00asm" {
+			t.Fatal("expected a compiled wasm module")
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		root := t.TempDir()
+		if err := os.WriteFile(filepath.Join(root, "main.py"), []byte("print('hello')\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var errScaffold ErrScaffold
+		if _, err := build(t, root); !errors.As(err, &errScaffold) {
+			t.Fatalf("expected ErrScaffold, got %v", err)
+		}
+		for _, name := range []string{"a.wasm", "b.wasm"} {
+			if err := os.WriteFile(filepath.Join(root, name), module, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := build(t, root); !errors.As(err, &errScaffold) {
+			t.Fatalf("expected ErrScaffold for more than one module, got %v", err)
+		}
+	})
+}
+
+// Test_toPlatforms_wasm ensures the wasm target of Go (wasip1/wasm) is the
+// platform of wasm images (wasi/wasm).
+func Test_toPlatforms_wasm(t *testing.T) {
+	pp := toPlatforms([]fn.Platform{{OS: "wasip1", Architecture: "wasm"}, {OS: "linux", Architecture: "amd64"}})
+	if platformName(pp[0]) != "wasi/wasm" || platformName(pp[1]) != "linux/amd64" {
+		t.Fatalf("unexpected platforms %v", pp)
+	}
+	if !(wasmBuilder{}).SupportsPlatform(pp[0]) || (wasmBuilder{}).SupportsPlatform(pp[1]) {
+		t.Fatal("expected only wasi/wasm to be supported")
+	}
+}
@@ -0,0 +1,59 @@
+package oci
+
+import (
+	"archive/tar"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+// epoch is the time of the image and of its files when zeroing timestamps.
+var epoch = time.Unix(0, 0).UTC()
+
+// WithZeroTimestamps sets the times of the image to the Unix epoch: its
+// creation time, that of each entry of its history, including those of the
+// base, and the modification times of the files of the layers written by the
+// build, such that the image is independent of when and from which checkout
+// it was built.  Intended for conformance testing and maximally reproducible
+// images, this may confuse tools which expect realistic dates, such as those
+// which list images by age or expire them.  Layers of the base image are
+// unchanged.
+func WithZeroTimestamps(zero bool) BuilderOpt {
+	return func(b *Builder) {
+		b.zeroTimes = zero
+	}
+}
+
+// created returns the creation time of the job's image: the start of the
+// build, or the epoch when zeroing timestamps.
+func (j buildJob) created() time.Time {
+	if j.zeroTimes {
+		return epoch
+	}
+	return j.start
+}
+
+// zeroHeaderTimes sets the modification time of the header to the epoch,
+// clearing its access and change times, if zero.
+func zeroHeaderTimes(header *tar.Header, zero bool) {
+	if !zero {
+		return
+	}
+	header.ModTime = epoch
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+}
+
+// zeroHistoryTimes returns the history with the creation time of each entry
+// set to the epoch, if zero.
+func zeroHistoryTimes(history []v1.History, zero bool) []v1.History {
+	if !zero {
+		return history
+	}
+	zeroed := make([]v1.History, len(history))
+	for i, h := range history {
+		h.Created = v1.Time{Time: epoch}
+		zeroed[i] = h
+	}
+	return zeroed
+}
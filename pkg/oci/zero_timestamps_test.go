@@ -0,0 +1,81 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	fn "knative.dev/func/pkg/functions"
+	. "knative.dev/func/pkg/testing"
+)
+
+// TestBuilder_ZeroTimestamps ensures that when zeroing timestamps the image's
+// creation time, history and the files of its layers are of the epoch.
+func TestBuilder_ZeroTimestamps(t *testing.T) {
+	root, done := Mktemp(t)
+	defer done()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // shared blob cache
+
+	f, err := fn.New().Init(fn.Function{Root: root, Runtime: "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := newBuildJob(context.Background(), f, TestPlatforms, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impl := NewTestLanguageBuilder()
+	impl.ConfigureFn = func(_ BuildContext, _ v1.Platform, cf v1.ConfigFile) (v1.ConfigFile, error) {
+		return cf, nil
+	}
+	job.languageBuilder = impl
+	job.zeroTimes = true
+	job.keepTars = true
+	job.quiet = true
+	if err = setup(job); err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup(job)
+	defer os.Remove(job.pidLink())
+	if err = scaffold(job); err != nil {
+		t.Fatal(err)
+	}
+	if err = containerize(job); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := layout.ImageIndexFromPath(job.ociDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := index.Image(manifest.Manifests[0].Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Created.Equal(epoch) {
+		t.Fatalf("expected the image to be created at the epoch, got %v", cfg.Created)
+	}
+	for _, h := range cfg.History {
+		if !h.Created.Equal(epoch) {
+			t.Fatalf("expected the history to be of the epoch, got %v", h.Created)
+		}
+	}
+	for _, tar := range []string{"datalayer.tar.gz", "certslayer.tar.gz"} {
+		for name, h := range readTarball(t, filepath.Join(job.buildDir(), tar)) {
+			if !h.ModTime.Equal(epoch) {
+				t.Fatalf("expected %v of %v to be modified at the epoch, got %v", name, tar, h.ModTime)
+			}
+		}
+	}
+}